@@ -4,11 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/spf13/cobra"
 	"github.com/uselagoon/build-deploy-tool/internal/collector"
 	"github.com/uselagoon/build-deploy-tool/internal/helpers"
 	"github.com/uselagoon/build-deploy-tool/internal/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 func init() {
@@ -57,3 +62,47 @@ func CollectEnvironment(c *collector.Collector, namespace string) (*collector.La
 	}
 	return state, nil
 }
+
+// newCollector returns a collector for the cluster, or if a state file from `collect environment` is provided
+// a collector backed by a fake client seeded with the objects from the state file
+func newCollector(stateFile, namespace string) (*collector.Collector, error) {
+	if stateFile == "" {
+		client, err := k8s.NewClient()
+		if err != nil {
+			return nil, err
+		}
+		return collector.NewCollector(client), nil
+	}
+	return newStateCollector(map[string]string{namespace: stateFile})
+}
+
+// newStateCollector returns a collector backed by a fake client seeded with the objects from the state file of each namespace
+func newStateCollector(stateFiles map[string]string) (*collector.Collector, error) {
+	var fakeClient client.Client
+	for _, namespace := range slices.Sorted(maps.Keys(stateFiles)) {
+		stateFile := stateFiles[namespace]
+		state, err := collector.LoadState(stateFile)
+		if err != nil {
+			return nil, err
+		}
+		objects, err := state.Objects()
+		if err != nil {
+			return nil, err
+		}
+		if fakeClient == nil {
+			fakeClient, err = k8s.NewFakeClient(namespace)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+			if err := fakeClient.Create(context.Background(), ns); err != nil {
+				return nil, fmt.Errorf("error creating namespace %v: %v", namespace, err)
+			}
+		}
+		if err := k8s.SeedFakeObjects(fakeClient, namespace, objects); err != nil {
+			return nil, fmt.Errorf("error seeding state file %v: %v", stateFile, err)
+		}
+	}
+	return collector.NewCollector(fakeClient), nil
+}
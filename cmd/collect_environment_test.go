@@ -8,7 +8,12 @@ import (
 
 	"github.com/andreyvit/diff"
 	"github.com/uselagoon/build-deploy-tool/internal/collector"
+	"github.com/uselagoon/build-deploy-tool/internal/dbaasclient"
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/identify"
 	"github.com/uselagoon/build-deploy-tool/internal/k8s"
+	"github.com/uselagoon/build-deploy-tool/internal/testdata"
 
 	// changes the testing to source from root so paths to test resources must be defined from repo root
 	_ "github.com/uselagoon/build-deploy-tool/internal/testing"
@@ -76,3 +81,90 @@ func TestCollectEnvironment(t *testing.T) {
 		})
 	}
 }
+
+func TestNewCollectorStateFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      testdata.TestData
+		namespace string
+		seedDir   string
+		stateFile string
+		wantErr   bool
+	}{
+		{
+			name: "basic-deployment",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/basic/lagoon.yml",
+					ImageReferences: map[string]string{
+						"node": "harbor.example/example-project/main/node@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+					},
+				}, true),
+			namespace: "example-project-main",
+			seedDir:   "internal/testdata/basic/cleanup-seed/basic-deployment",
+			stateFile: "internal/testdata/basic/state/basic-deployment.json",
+		},
+		{
+			name:      "missing-state-file",
+			namespace: "example-project-main",
+			stateFile: "internal/testdata/basic/state/missing.json",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			helpers.UnsetEnvVars(nil) //unset variables before running tests
+			col, err := newCollector(tt.stateFile, tt.namespace)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newCollector() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			// set the environment variables from args
+			savedTemplates, err := os.MkdirTemp("", "testoutput")
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			defer os.RemoveAll(savedTemplates)
+			generator, err := testdata.SetupEnvironment(generator.GeneratorInput{}, savedTemplates, tt.args)
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			ts := dbaasclient.TestDBaaSHTTPServer()
+			defer ts.Close()
+			err = os.Setenv("DBAAS_OPERATOR_HTTP", ts.URL)
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			// the decisions made from the state file must match the decisions made from the environment it was collected from
+			client, err := k8s.NewFakeClient(tt.namespace)
+			if err != nil {
+				t.Errorf("error creating fake client")
+			}
+			err = k8s.SeedFakeData(client, tt.namespace, tt.seedDir)
+			if err != nil {
+				t.Errorf("error seeding fake data: %v", err)
+			}
+			wantServices, wantMariaDB, _, _, wantDep, wantVol, wantServ, _, _, _, err := identify.GetCurrentState(collector.NewCollector(client), generator)
+			if err != nil {
+				t.Errorf("GetCurrentState() error = %v", err)
+			}
+			gotServices, gotMariaDB, _, _, gotDep, gotVol, gotServ, _, _, _, err := identify.GetCurrentState(col, generator)
+			if err != nil {
+				t.Errorf("GetCurrentState() error = %v", err)
+			}
+			if !reflect.DeepEqual(gotServices, wantServices) {
+				t.Errorf("GetCurrentState() services = %v, want %v", gotServices, wantServices)
+			}
+			if len(gotMariaDB) != len(wantMariaDB) || len(gotDep) != len(wantDep) || len(gotVol) != len(wantVol) || len(gotServ) != len(wantServ) {
+				t.Errorf("GetCurrentState() deletions = %d/%d/%d/%d, want %d/%d/%d/%d",
+					len(gotMariaDB), len(gotDep), len(gotVol), len(gotServ), len(wantMariaDB), len(wantDep), len(wantVol), len(wantServ))
+			}
+		})
+	}
+}
@@ -0,0 +1,350 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/uselagoon/build-deploy-tool/internal/collector"
+	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	machinerynamespace "github.com/uselagoon/machinery/utils/namespace"
+	networkv1 "k8s.io/api/networking/v1"
+)
+
+type activeStandbyMigrationJSON struct {
+	ActiveEnvironment  string                          `json:"activeEnvironment"`
+	ActiveNamespace    string                          `json:"activeNamespace"`
+	StandbyEnvironment string                          `json:"standbyEnvironment"`
+	StandbyNamespace   string                          `json:"standbyNamespace"`
+	Migrations         []activeStandbyIngressMigration `json:"migrations"`
+	Warnings           []activeStandbyRouteWarning     `json:"warnings"`
+}
+
+type activeStandbyIngressMigration struct {
+	Ingress     string            `json:"ingress"`
+	Hosts       []string          `json:"hosts"`
+	From        string            `json:"from"`
+	To          string            `json:"to"`
+	Secrets     []string          `json:"secrets"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type activeStandbyRouteWarning struct {
+	Route     string `json:"route"`
+	Namespace string `json:"namespace,omitempty"`
+	Warning   string `json:"warning"`
+}
+
+var activeStandbyMigrationIdentify = &cobra.Command{
+	Use:     "activestandby-migration",
+	Aliases: []string{"asm"},
+	Short:   "Identify the ingress that would move between the active and standby environments in a switch",
+	Long: `Identify the ingress that would move between the active and standby environments in a switch
+
+The production_routes of both environments are compared with the ingress in the active and standby namespaces.
+Any ingress labelled with 'activestandby.lagoon.sh/migrate=true' moves to the other namespace with its tls secrets and annotations.
+Routes that are missing 'migrate: true', or are defined in both the active and standby routes, are reported as warnings.
+The active and standby namespaces can be read from state files created by 'collect environment' instead of the cluster,
+a state file is required for both namespaces.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		activeLagoonYAML, err := cmd.Flags().GetString("active-lagoon-yml")
+		if err != nil {
+			return fmt.Errorf("error reading active-lagoon-yml flag: %v", err)
+		}
+		standbyLagoonYAML, err := cmd.Flags().GetString("standby-lagoon-yml")
+		if err != nil {
+			return fmt.Errorf("error reading standby-lagoon-yml flag: %v", err)
+		}
+		activeNamespace, err := cmd.Flags().GetString("active-namespace")
+		if err != nil {
+			return fmt.Errorf("error reading active-namespace flag: %v", err)
+		}
+		standbyNamespace, err := cmd.Flags().GetString("standby-namespace")
+		if err != nil {
+			return fmt.Errorf("error reading standby-namespace flag: %v", err)
+		}
+		activeStateFile, err := cmd.Flags().GetString("active-state-file")
+		if err != nil {
+			return fmt.Errorf("error reading active-state-file flag: %v", err)
+		}
+		standbyStateFile, err := cmd.Flags().GetString("standby-state-file")
+		if err != nil {
+			return fmt.Errorf("error reading standby-state-file flag: %v", err)
+		}
+		if (activeStateFile == "") != (standbyStateFile == "") {
+			return fmt.Errorf("both active-state-file and standby-state-file must be provided to use state files")
+		}
+		gen, err := GenerateInput(*rootCmd, false)
+		if err != nil {
+			return err
+		}
+		col, err := activeStandbyCollector(gen, activeStateFile, standbyStateFile, &activeNamespace, &standbyNamespace)
+		if err != nil {
+			return err
+		}
+		migration, err := ActiveStandbyMigrationIdentification(gen, col, activeLagoonYAML, standbyLagoonYAML, activeNamespace, standbyNamespace)
+		if err != nil {
+			return err
+		}
+		migrationJSON, _ := json.Marshal(migration)
+		fmt.Println(string(migrationJSON))
+		return nil
+	},
+}
+
+// ActiveStandbyMigrationIdentification identifies the ingress, tls secrets, and annotations that would move between the
+// active and standby namespaces if the environments were switched. The .lagoon.yml files default to the one used by this build
+func ActiveStandbyMigrationIdentification(g generator.GeneratorInput, c *collector.Collector, activeLagoonYAML, standbyLagoonYAML, activeNamespace, standbyNamespace string) (*activeStandbyMigrationJSON, error) {
+	lagoonBuild, err := generator.NewGenerator(
+		g,
+	)
+	if err != nil {
+		return nil, err
+	}
+	buildValues := lagoonBuild.BuildValues
+	if buildValues.ActiveEnvironment == "" || buildValues.StandbyEnvironment == "" {
+		return nil, fmt.Errorf("active and standby environments are not defined, this project doesn't use active/standby")
+	}
+	if activeNamespace == "" {
+		activeNamespace = activeStandbyNamespace(buildValues.Project, buildValues.ActiveEnvironment)
+	}
+	if standbyNamespace == "" {
+		standbyNamespace = activeStandbyNamespace(buildValues.Project, buildValues.StandbyEnvironment)
+	}
+
+	activeYAML, err := activeStandbyLagoonYAML(activeLagoonYAML, buildValues)
+	if err != nil {
+		return nil, err
+	}
+	standbyYAML, err := activeStandbyLagoonYAML(standbyLagoonYAML, buildValues)
+	if err != nil {
+		return nil, err
+	}
+	activeRoutes := &lagoon.RoutesV2{}
+	if activeYAML.ProductionRoutes != nil && activeYAML.ProductionRoutes.Active != nil {
+		activeRoutes, err = activeStandbyRoutes(activeYAML.ProductionRoutes.Active.Routes, buildValues)
+		if err != nil {
+			return nil, err
+		}
+	}
+	standbyRoutes := &lagoon.RoutesV2{}
+	if standbyYAML.ProductionRoutes != nil && standbyYAML.ProductionRoutes.Standby != nil {
+		standbyRoutes, err = activeStandbyRoutes(standbyYAML.ProductionRoutes.Standby.Routes, buildValues)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx := context.Background()
+	activeIngress, err := c.CollectIngress(ctx, activeNamespace)
+	if err != nil {
+		return nil, err
+	}
+	standbyIngress, err := c.CollectIngress(ctx, standbyNamespace)
+	if err != nil {
+		return nil, err
+	}
+	activeSecrets, err := c.CollectTLSSecrets(ctx, activeNamespace)
+	if err != nil {
+		return nil, err
+	}
+	standbySecrets, err := c.CollectTLSSecrets(ctx, standbyNamespace)
+	if err != nil {
+		return nil, err
+	}
+	var activeSecretNames, standbySecretNames []string
+	for _, secret := range activeSecrets.Items {
+		activeSecretNames = append(activeSecretNames, secret.Name)
+	}
+	for _, secret := range standbySecrets.Items {
+		standbySecretNames = append(standbySecretNames, secret.Name)
+	}
+
+	migration := &activeStandbyMigrationJSON{
+		ActiveEnvironment:  buildValues.ActiveEnvironment,
+		ActiveNamespace:    activeNamespace,
+		StandbyEnvironment: buildValues.StandbyEnvironment,
+		StandbyNamespace:   standbyNamespace,
+		Migrations:         []activeStandbyIngressMigration{},
+		Warnings:           []activeStandbyRouteWarning{},
+	}
+	// a route can only exist in one of the environments, if it is in both then the switch would fail to move it
+	for _, activeRoute := range activeRoutes.Routes {
+		for _, standbyRoute := range standbyRoutes.Routes {
+			if activeRoute.IngressName == standbyRoute.IngressName {
+				migration.Warnings = append(migration.Warnings, activeStandbyRouteWarning{
+					Route:   activeRoute.IngressName,
+					Warning: "route is defined in both the active and standby production routes",
+				})
+			}
+		}
+	}
+	// the active ingress move to the standby namespace, and the standby ingress move to the active namespace
+	activeStandbyIngressMigrations(migration, activeRoutes.Routes, activeIngress.Items, standbyIngress.Items, activeSecretNames, activeNamespace, standbyNamespace)
+	activeStandbyIngressMigrations(migration, standbyRoutes.Routes, standbyIngress.Items, activeIngress.Items, standbySecretNames, standbyNamespace, activeNamespace)
+	return migration, nil
+}
+
+func activeStandbyIngressMigrations(
+	migration *activeStandbyMigrationJSON,
+	routes []lagoon.RouteV2,
+	ingress, destinationIngress []networkv1.Ingress,
+	secretNames []string,
+	from, to string,
+) {
+	var routeNames []string
+	for _, route := range routes {
+		routeNames = append(routeNames, route.IngressName)
+		if route.Migrate == nil || !*route.Migrate {
+			migration.Warnings = append(migration.Warnings, activeStandbyRouteWarning{
+				Route:     route.IngressName,
+				Namespace: from,
+				Warning:   "route is missing 'migrate: true' and won't be moved in a switch",
+			})
+		}
+		idx := slices.IndexFunc(ingress, func(i networkv1.Ingress) bool { return i.Name == route.IngressName })
+		if idx == -1 {
+			migration.Warnings = append(migration.Warnings, activeStandbyRouteWarning{
+				Route:     route.IngressName,
+				Namespace: from,
+				Warning:   "ingress doesn't exist, a deployment is required before switching",
+			})
+			continue
+		}
+		if route.Migrate != nil && *route.Migrate && ingress[idx].Labels["activestandby.lagoon.sh/migrate"] != "true" {
+			migration.Warnings = append(migration.Warnings, activeStandbyRouteWarning{
+				Route:     route.IngressName,
+				Namespace: from,
+				Warning:   "ingress is not labelled for migration, a deployment is required before switching",
+			})
+		}
+	}
+	// the switch moves every ingress with the migrate label, not just the ones in the production routes
+	for _, i := range ingress {
+		if i.Labels["activestandby.lagoon.sh/migrate"] != "true" {
+			continue
+		}
+		if !slices.Contains(routeNames, i.Name) {
+			migration.Warnings = append(migration.Warnings, activeStandbyRouteWarning{
+				Route:     i.Name,
+				Namespace: from,
+				Warning:   "ingress is labelled for migration but isn't in the production routes",
+			})
+		}
+		if slices.ContainsFunc(destinationIngress, func(d networkv1.Ingress) bool { return d.Name == i.Name }) {
+			migration.Warnings = append(migration.Warnings, activeStandbyRouteWarning{
+				Route:     i.Name,
+				Namespace: to,
+				Warning:   "ingress already exists in the destination namespace",
+			})
+		}
+		move := activeStandbyIngressMigration{
+			Ingress:     i.Name,
+			Hosts:       []string{},
+			From:        from,
+			To:          to,
+			Secrets:     []string{},
+			Annotations: map[string]string{},
+		}
+		for _, rule := range i.Spec.Rules {
+			move.Hosts = append(move.Hosts, rule.Host)
+		}
+		for _, tls := range i.Spec.TLS {
+			if tls.SecretName == "" {
+				continue
+			}
+			move.Secrets = append(move.Secrets, tls.SecretName)
+			if !slices.Contains(secretNames, tls.SecretName) {
+				migration.Warnings = append(migration.Warnings, activeStandbyRouteWarning{
+					Route:     i.Name,
+					Namespace: from,
+					Warning:   fmt.Sprintf("tls secret %s doesn't exist and won't be moved", tls.SecretName),
+				})
+			}
+		}
+		for key, value := range i.Annotations {
+			// the last applied configuration is specific to the namespace the ingress was applied in
+			if key == "kubectl.kubernetes.io/last-applied-configuration" {
+				continue
+			}
+			move.Annotations[key] = value
+		}
+		migration.Migrations = append(migration.Migrations, move)
+	}
+}
+
+// activeStandbyRoutes generates the production routes, the generator always migrates production routes so the migrate
+// option of each route is taken from the .lagoon.yml instead, routes that don't define it are reported on
+func activeStandbyRoutes(routeMaps []map[string][]lagoon.Route, buildValues *generator.BuildValues) (*lagoon.RoutesV2, error) {
+	routes := &lagoon.RoutesV2{}
+	for _, routeMap := range routeMaps {
+		if err := lagoon.GenerateRoutesV2(routes, routeMap, buildValues.EnvironmentVariables, buildValues.IngressClass, true); err != nil {
+			return nil, err
+		}
+	}
+	for idx, route := range routes.Routes {
+		routes.Routes[idx].Migrate = nil
+		for _, lagoonRoutes := range routeMaps {
+			for _, lagoonRoute := range lagoonRoutes[route.LagoonService] {
+				if ingress, ok := lagoonRoute.Ingresses[route.Domain]; ok && ingress.Migrate != nil {
+					routes.Routes[idx].Migrate = ingress.Migrate
+				}
+			}
+		}
+	}
+	return routes, nil
+}
+
+// activeStandbyLagoonYAML loads the .lagoon.yml of one of the environments, or uses the .lagoon.yml of this build if none is provided
+func activeStandbyLagoonYAML(file string, buildValues *generator.BuildValues) (*lagoon.YAML, error) {
+	if file == "" {
+		return &buildValues.LagoonYAML, nil
+	}
+	lYAML := &lagoon.YAML{}
+	if err := lagoon.UnmarshalLagoonYAML(file, lYAML, buildValues.Project); err != nil {
+		return nil, fmt.Errorf("couldn't unmarshal file %v: %v", file, err)
+	}
+	return lYAML, nil
+}
+
+// activeStandbyCollector returns a collector for the cluster, or if state files are provided a collector seeded with the state
+// of the active and standby namespaces. The state files are loaded into the namespaces that the migration will use
+func activeStandbyCollector(g generator.GeneratorInput, activeStateFile, standbyStateFile string, activeNamespace, standbyNamespace *string) (*collector.Collector, error) {
+	if activeStateFile == "" {
+		return newCollector("", "")
+	}
+	lagoonBuild, err := generator.NewGenerator(
+		g,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if *activeNamespace == "" {
+		*activeNamespace = activeStandbyNamespace(lagoonBuild.BuildValues.Project, lagoonBuild.BuildValues.ActiveEnvironment)
+	}
+	if *standbyNamespace == "" {
+		*standbyNamespace = activeStandbyNamespace(lagoonBuild.BuildValues.Project, lagoonBuild.BuildValues.StandbyEnvironment)
+	}
+	return newStateCollector(map[string]string{
+		*activeNamespace:  activeStateFile,
+		*standbyNamespace: standbyStateFile,
+	})
+}
+
+// activeStandbyNamespace is the namespace of an environment in the project
+func activeStandbyNamespace(project, environment string) string {
+	return machinerynamespace.GenerateNamespaceName("", environment, project, "", "", false)
+}
+
+func init() {
+	identifyCmd.AddCommand(activeStandbyMigrationIdentify)
+	activeStandbyMigrationIdentify.Flags().String("active-lagoon-yml", "", "the .lagoon.yml of the active environment, defaults to the lagoon-yml flag")
+	activeStandbyMigrationIdentify.Flags().String("standby-lagoon-yml", "", "the .lagoon.yml of the standby environment, defaults to the lagoon-yml flag")
+	activeStandbyMigrationIdentify.Flags().String("active-namespace", "", "the namespace of the active environment, defaults to <project>-<active environment>")
+	activeStandbyMigrationIdentify.Flags().String("standby-namespace", "", "the namespace of the standby environment, defaults to <project>-<standby environment>")
+	activeStandbyMigrationIdentify.Flags().String("active-state-file", "", "the path to a file from 'collect environment' of the active namespace to use instead of the cluster")
+	activeStandbyMigrationIdentify.Flags().String("standby-state-file", "", "the path to a file from 'collect environment' of the standby namespace to use instead of the cluster")
+}
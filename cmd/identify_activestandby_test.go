@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/uselagoon/build-deploy-tool/internal/collector"
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/k8s"
+	"github.com/uselagoon/build-deploy-tool/internal/testdata"
+
+	// changes the testing to source from root so paths to test resources must be defined from repo root
+	_ "github.com/uselagoon/build-deploy-tool/internal/testing"
+)
+
+func TestActiveStandbyMigrationIdentification(t *testing.T) {
+	tests := []struct {
+		name              string
+		args              testdata.TestData
+		standbyLagoonYAML string
+		wantJSON          string
+		wantErr           string
+	}{
+		{
+			name: "active and standby routes",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:        "example-project",
+					EnvironmentName:    "main",
+					Branch:             "main",
+					ActiveEnvironment:  "main",
+					StandbyEnvironment: "main-sb",
+					LagoonYAML:         "internal/testdata/node/lagoon.activestandby-migrate.yml",
+				}, true),
+			wantJSON: `{"activeEnvironment":"main","activeNamespace":"example-project-main","standbyEnvironment":"main-sb","standbyNamespace":"example-project-main-sb","migrations":[{"ingress":"active.example.com","hosts":["active.example.com"],"from":"example-project-main","to":"example-project-main-sb","secrets":["active.example.com-tls"],"annotations":{"fastly.amazee.io/watch":"false","kubernetes.io/tls-acme":"true","nginx.ingress.kubernetes.io/server-snippet":"add_header X-Active true;"}},{"ingress":"standby.example.com","hosts":["standby.example.com"],"from":"example-project-main-sb","to":"example-project-main","secrets":["standby.example.com-tls"],"annotations":{"fastly.amazee.io/watch":"false","kubernetes.io/tls-acme":"true"}}],"warnings":[]}`,
+		},
+		{
+			name: "standby routes missing migrate and defined in both",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:        "example-project",
+					EnvironmentName:    "main",
+					Branch:             "main",
+					ActiveEnvironment:  "main",
+					StandbyEnvironment: "main-sb",
+					LagoonYAML:         "internal/testdata/node/lagoon.activestandby.yml",
+				}, true),
+			standbyLagoonYAML: "internal/testdata/node/lagoon.activestandby-migration.yml",
+			wantJSON:          `{"activeEnvironment":"main","activeNamespace":"example-project-main","standbyEnvironment":"main-sb","standbyNamespace":"example-project-main-sb","migrations":[{"ingress":"active.example.com","hosts":["active.example.com"],"from":"example-project-main","to":"example-project-main-sb","secrets":["active.example.com-tls"],"annotations":{"fastly.amazee.io/watch":"false","kubernetes.io/tls-acme":"true","nginx.ingress.kubernetes.io/server-snippet":"add_header X-Active true;"}},{"ingress":"standby.example.com","hosts":["standby.example.com"],"from":"example-project-main-sb","to":"example-project-main","secrets":["standby.example.com-tls"],"annotations":{"fastly.amazee.io/watch":"false","kubernetes.io/tls-acme":"true"}}],"warnings":[{"route":"active.example.com","warning":"route is defined in both the active and standby production routes"},{"route":"active.example.com","namespace":"example-project-main","warning":"route is missing 'migrate: true' and won't be moved in a switch"},{"route":"standby.example.com","namespace":"example-project-main-sb","warning":"route is missing 'migrate: true' and won't be moved in a switch"},{"route":"active.example.com","namespace":"example-project-main-sb","warning":"route is missing 'migrate: true' and won't be moved in a switch"},{"route":"active.example.com","namespace":"example-project-main-sb","warning":"ingress doesn't exist, a deployment is required before switching"}]}`,
+		},
+		{
+			name: "not an active standby project",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/node/lagoon.yml",
+				}, true),
+			wantErr: "active and standby environments are not defined, this project doesn't use active/standby",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			helpers.UnsetEnvVars(nil) //unset variables before running tests
+			// set the environment variables from args
+			savedTemplates := "testoutput"
+			generator, err := testdata.SetupEnvironment(generator.GeneratorInput{}, savedTemplates, tt.args)
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			client, err := k8s.NewFakeClient("example-project-main")
+			if err != nil {
+				t.Errorf("error creating fake client")
+			}
+			if err := k8s.SeedFakeData(client, "example-project-main", "internal/testdata/node/activestandby-seed/active"); err != nil {
+				t.Errorf("error seeding fake data: %v", err)
+			}
+			if err := k8s.SeedFakeData(client, "example-project-main-sb", "internal/testdata/node/activestandby-seed/standby"); err != nil {
+				t.Errorf("error seeding fake data: %v", err)
+			}
+			got, err := ActiveStandbyMigrationIdentification(generator, collector.NewCollector(client), "", tt.standbyLagoonYAML, "", "")
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("ActiveStandbyMigrationIdentification() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("ActiveStandbyMigrationIdentification() error = %v", err)
+				return
+			}
+			gotJSON, _ := json.Marshal(got)
+			if string(gotJSON) != tt.wantJSON {
+				t.Errorf("ActiveStandbyMigrationIdentification() = %v, want %v", string(gotJSON), tt.wantJSON)
+			}
+		})
+	}
+}
+
+func Test_activeStandbyNamespace(t *testing.T) {
+	tests := []struct {
+		name        string
+		project     string
+		environment string
+		want        string
+	}{
+		{
+			name:        "short names",
+			project:     "example-project",
+			environment: "main-sb",
+			want:        "example-project-main-sb",
+		},
+		{
+			name:        "environment with unsafe characters",
+			project:     "example-project",
+			environment: "feature/Main_SB",
+			want:        "example-project-feature-main-sb",
+		},
+		{
+			name:        "long environment name",
+			project:     "example-project-with-a-long-name",
+			environment: "standby-environment-with-a-very-long-name-that-is-shortened",
+			want:        "example-project-with-a-long-name-standby-environment-w-98cd",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := activeStandbyNamespace(tt.project, tt.environment); got != tt.want {
+				t.Errorf("activeStandbyNamespace() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestActiveStandbyMigrationIdentificationStateFile(t *testing.T) {
+	helpers.UnsetEnvVars(nil) //unset variables before running tests
+	args := testdata.GetSeedData(
+		testdata.TestData{
+			ProjectName:        "example-project",
+			EnvironmentName:    "main",
+			Branch:             "main",
+			ActiveEnvironment:  "main",
+			StandbyEnvironment: "main-sb",
+			LagoonYAML:         "internal/testdata/node/lagoon.activestandby-migrate.yml",
+		}, true)
+	generator, err := testdata.SetupEnvironment(generator.GeneratorInput{}, "testoutput", args)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	// collect the state of each namespace into a state file, the same way `collect environment` does
+	stateFiles := map[string]string{}
+	for namespace, seedDir := range map[string]string{
+		"example-project-main":    "internal/testdata/node/activestandby-seed/active",
+		"example-project-main-sb": "internal/testdata/node/activestandby-seed/standby",
+	} {
+		client, err := k8s.NewFakeClient(namespace)
+		if err != nil {
+			t.Errorf("error creating fake client")
+		}
+		if err := k8s.SeedFakeData(client, namespace, seedDir); err != nil {
+			t.Errorf("error seeding fake data: %v", err)
+		}
+		state, err := collector.NewCollector(client).Collect(context.Background(), namespace)
+		if err != nil {
+			t.Errorf("error collecting state: %v", err)
+		}
+		stateBytes, _ := json.Marshal(state)
+		stateFiles[namespace] = filepath.Join(t.TempDir(), fmt.Sprintf("%s.json", namespace))
+		if err := os.WriteFile(stateFiles[namespace], stateBytes, 0644); err != nil {
+			t.Errorf("couldn't write state file: %v", err)
+		}
+	}
+	activeNamespace, standbyNamespace := "", ""
+	col, err := activeStandbyCollector(generator, stateFiles["example-project-main"], stateFiles["example-project-main-sb"], &activeNamespace, &standbyNamespace)
+	if err != nil {
+		t.Errorf("activeStandbyCollector() error = %v", err)
+		return
+	}
+	got, err := ActiveStandbyMigrationIdentification(generator, col, "", "", activeNamespace, standbyNamespace)
+	if err != nil {
+		t.Errorf("ActiveStandbyMigrationIdentification() error = %v", err)
+		return
+	}
+	wantJSON := `{"activeEnvironment":"main","activeNamespace":"example-project-main","standbyEnvironment":"main-sb","standbyNamespace":"example-project-main-sb","migrations":[{"ingress":"active.example.com","hosts":["active.example.com"],"from":"example-project-main","to":"example-project-main-sb","secrets":["active.example.com-tls"],"annotations":{"fastly.amazee.io/watch":"false","kubernetes.io/tls-acme":"true","nginx.ingress.kubernetes.io/server-snippet":"add_header X-Active true;"}},{"ingress":"standby.example.com","hosts":["standby.example.com"],"from":"example-project-main-sb","to":"example-project-main","secrets":["standby.example.com-tls"],"annotations":{"fastly.amazee.io/watch":"false","kubernetes.io/tls-acme":"true"}}],"warnings":[]}`
+	gotJSON, _ := json.Marshal(got)
+	if string(gotJSON) != wantJSON {
+		t.Errorf("ActiveStandbyMigrationIdentification() = %v, want %v", string(gotJSON), wantJSON)
+	}
+}
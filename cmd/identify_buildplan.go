@@ -0,0 +1,427 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	composetypes "github.com/compose-spec/compose-go/types"
+	"github.com/spf13/cobra"
+	"github.com/uselagoon/build-deploy-tool/internal/collector"
+	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/identify"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// BuildPlanAPIVersion is the version of the build plan schema, this must be changed if fields are removed or changed
+	BuildPlanAPIVersion = "build-deploy-tool.lagoon.sh/v1"
+	// BuildPlanKind is the kind of the build plan document
+	BuildPlanKind = "BuildPlan"
+	// buildPlanRedacted replaces any secret values in the build plan
+	buildPlanRedacted = "[redacted]"
+)
+
+// BuildPlan contains everything the tool has determined about a build in a single document
+type BuildPlan struct {
+	APIVersion     string                  `json:"apiVersion"`
+	Kind           string                  `json:"kind"`
+	BuildValues    buildPlanValues         `json:"buildValues"`
+	DBaaS          []string                `json:"dbaas"`
+	ImageBuild     imageBuild              `json:"imageBuild"`
+	Ingress        buildPlanIngress        `json:"ingress"`
+	NativeCronjobs []string                `json:"nativeCronjobs"`
+	Compose        buildPlanCompose        `json:"compose"`
+	Services       identify.LagoonServices `json:"services"`
+	Create         []BuildPlanObject       `json:"create"`
+	Delete         []BuildPlanObject       `json:"delete"`
+}
+
+// buildPlanValues are the build values that are safe to print in the build log, the build values also contain the ssh key,
+// registry credentials, and the values of variables and secrets, which must never be added to the build plan
+type buildPlanValues struct {
+	Project              string              `json:"project"`
+	Environment          string              `json:"environment"`
+	EnvironmentType      string              `json:"environmentType"`
+	Namespace            string              `json:"namespace"`
+	BuildName            string              `json:"buildName"`
+	BuildType            string              `json:"buildType"`
+	GitSHA               string              `json:"gitSha"`
+	Branch               string              `json:"branch,omitempty"`
+	PRNumber             string              `json:"prNumber,omitempty"`
+	PRHeadBranch         string              `json:"prHeadBranch,omitempty"`
+	PRBaseBranch         string              `json:"prBaseBranch,omitempty"`
+	PromotionSource      string              `json:"promotionSourceEnvironment,omitempty"`
+	Kubernetes           string              `json:"kubernetes"`
+	LagoonVersion        string              `json:"lagoonVersion"`
+	ActiveEnvironment    string              `json:"activeEnvironment,omitempty"`
+	StandbyEnvironment   string              `json:"standbyEnvironment,omitempty"`
+	ImageRegistry        string              `json:"imageRegistry"`
+	ImageReferences      map[string]string   `json:"imageReferences"`
+	IngressClass         string              `json:"ingressClass"`
+	Gateway              *generator.Gateway  `json:"gateway,omitempty"`
+	RouteQuota           *int                `json:"routeQuota,omitempty"`
+	BackupsEnabled       bool                `json:"backupsEnabled"`
+	CronjobsDisabled     bool                `json:"cronjobsDisabled"`
+	IsCI                 bool                `json:"isCI"`
+	Services             []buildPlanService  `json:"services"`
+	Variables            []buildPlanVariable `json:"variables"`
+	LagoonEnvVariables   []string            `json:"lagoonEnvVariables"`
+	PlatformEnvVariables []string            `json:"lagoonPlatformEnvVariables"`
+}
+
+// buildPlanVariable is a variable of the environment, only the name and scope are in the build plan
+type buildPlanVariable struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+}
+
+type buildPlanIngress struct {
+	Primary       string   `json:"primary"`
+	Secondary     []string `json:"secondary"`
+	Autogenerated []string `json:"autogenerated"`
+	// the names of the ingress objects that will be created
+	Created ingressIdentifyJSON `json:"created"`
+}
+
+// buildPlanService are the service values that are safe to print in the build log, cronjob commands
+// and the other scheduling and resource values of a service are not added to the build plan
+type buildPlanService struct {
+	Name                       string                `json:"name"`
+	OverrideName               string                `json:"overrideName"`
+	Type                       string                `json:"type"`
+	AutogeneratedRoutesEnabled bool                  `json:"autogeneratedRoutesEnabled"`
+	AutogeneratedRouteDomain   string                `json:"autogeneratedRouteDomain,omitempty"`
+	DBaaSEnvironment           string                `json:"dbaasEnvironment,omitempty"`
+	DeploymentServiceType      string                `json:"deploymentServiceType"`
+	ServicePort                int32                 `json:"servicePort,omitempty"`
+	PersistentVolumePath       string                `json:"persistentVolumePath,omitempty"`
+	PersistentVolumeName       string                `json:"persistentVolumeName,omitempty"`
+	PersistentVolumeSize       string                `json:"persistentVolumeSize,omitempty"`
+	Replicas                   int32                 `json:"replicas"`
+	BackupsEnabled             bool                  `json:"backupsEnabled"`
+	IsDBaaS                    bool                  `json:"isDBaaS"`
+	IsSingle                   bool                  `json:"isSingle"`
+	CreateDefaultVolume        bool                  `json:"createDefaultVolume"`
+	ExternalServiceName        string                `json:"externalServiceName,omitempty"`
+	NativeCronjobs             []string              `json:"nativeCronjobs"`
+	InPodCronjobs              []string              `json:"inPodCronjobs"`
+	ImageBuild                 *generator.ImageBuild `json:"docker,omitempty"`
+}
+
+type buildPlanCompose struct {
+	Order []lagoon.OriginalServiceOrder `json:"order"`
+	Spec  buildPlanComposeSpec          `json:"spec"`
+}
+
+// buildPlanComposeSpec is the part of the docker-compose file that is safe to print in the build log, the interpolated
+// environment and build arguments of the services can contain the values of variables and are not added to the build plan
+type buildPlanComposeSpec struct {
+	Services []buildPlanComposeService `json:"services"`
+	Volumes  []string                  `json:"volumes"`
+	Networks []string                  `json:"networks"`
+}
+
+type buildPlanComposeService struct {
+	Name       string            `json:"name"`
+	Image      string            `json:"image,omitempty"`
+	Context    string            `json:"context,omitempty"`
+	Dockerfile string            `json:"dockerfile,omitempty"`
+	Target     string            `json:"target,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Volumes    []string          `json:"volumes,omitempty"`
+}
+
+// BuildPlanObject is an object that will be created or deleted by the build
+type BuildPlanObject struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+var buildPlanIdentify = &cobra.Command{
+	Use:     "build-plan",
+	Aliases: []string{"bp"},
+	Short:   "Identify everything about a Lagoon build in a single document",
+	Long: `Identify everything about a Lagoon build in a single document
+This contains the build values, the dbaas, image build, ingress, native cronjob and docker-compose information
+and the objects that will be created or deleted in the environment`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputYAML, err := cmd.Flags().GetBool("yaml")
+		if err != nil {
+			return fmt.Errorf("error reading yaml flag: %v", err)
+		}
+		gen, err := GenerateInput(*rootCmd, false)
+		if err != nil {
+			return err
+		}
+		images, err := rootCmd.PersistentFlags().GetString("images")
+		if err != nil {
+			return fmt.Errorf("error reading images flag: %v", err)
+		}
+		imageRefs, err := loadImagesFromFile(images)
+		if err != nil {
+			return err
+		}
+		namespace := helpers.GetEnv("NAMESPACE", "", false)
+		namespace, err = helpers.GetNamespace(namespace, "/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+		if err != nil {
+			return err
+		}
+		if namespace == "" {
+			return fmt.Errorf("unable to detect namespace")
+		}
+		stateFile, err := cmd.Flags().GetString("state-file")
+		if err != nil {
+			return fmt.Errorf("error reading state-file flag: %v", err)
+		}
+		// create a collector
+		col, err := newCollector(stateFile, namespace)
+		if err != nil {
+			return err
+		}
+		gen.Namespace = namespace
+		gen.ImageReferences = imageRefs.Images
+		plan, err := BuildPlanIdentification(col, gen)
+		if err != nil {
+			return err
+		}
+		var planBytes []byte
+		if outputYAML {
+			planBytes, err = yaml.Marshal(plan)
+		} else {
+			planBytes, err = json.Marshal(plan)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(planBytes))
+		return nil
+	},
+}
+
+// BuildPlanIdentification generates the build plan for a build
+func BuildPlanIdentification(c *collector.Collector, g generator.GeneratorInput) (*BuildPlan, error) {
+	lagoonBuild, err := generator.NewGenerator(
+		g,
+	)
+	if err != nil {
+		return nil, err
+	}
+	plan := &BuildPlan{
+		APIVersion:     BuildPlanAPIVersion,
+		Kind:           BuildPlanKind,
+		BuildValues:    newBuildPlanValues(*lagoonBuild.BuildValues),
+		DBaaS:          identify.DBaaSConsumers(*lagoonBuild.BuildValues),
+		ImageBuild:     buildPlanImageBuild(imageBuildConfiguration(lagoonBuild)),
+		NativeCronjobs: nativeCronjobNames(*lagoonBuild.BuildValues),
+		Create:         []BuildPlanObject{},
+		Delete:         []BuildPlanObject{},
+	}
+	plan.Ingress.Primary = lagoonBuild.BuildValues.Route
+	plan.Ingress.Secondary = lagoonBuild.BuildValues.Routes
+	plan.Ingress.Autogenerated = lagoonBuild.BuildValues.AutogeneratedRoutes
+	plan.Ingress.Created.Autogenerated, plan.Ingress.Created.Secondary = createdIngress(lagoonBuild)
+
+	composeSpec, composeOrder, err := ValidateDockerCompose(lagoonBuild.BuildValues.LagoonYAMLFile, g.IgnoreNonStringKeyErrors, g.IgnoreMissingEnvFiles)
+	if err != nil {
+		return nil, err
+	}
+	plan.Compose.Order = composeOrder
+	plan.Compose.Spec = newBuildPlanComposeSpec(composeSpec)
+
+	objects, err := deployObjects(lagoonBuild)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objects {
+		gvk, err := apiutil.GVKForObject(obj, c.Client.Scheme())
+		if err != nil {
+			return nil, fmt.Errorf("unable to determine kind of %s: %v", obj.GetName(), err)
+		}
+		plan.Create = append(plan.Create, BuildPlanObject{
+			Kind: gvk.Kind,
+			Name: obj.GetName(),
+		})
+	}
+
+	services, mariadbDelete, mongodbDelete, postgresDelete, depDelete, volDelete, servDelete, hpaDelete, pdbDelete, _, err := identify.GetCurrentState(c, g)
+	if err != nil {
+		return nil, err
+	}
+	plan.Services = services
+	for _, d := range mariadbDelete {
+		plan.Delete = append(plan.Delete, BuildPlanObject{Kind: "MariaDBConsumer", Name: d.Name})
+	}
+	for _, d := range mongodbDelete {
+		plan.Delete = append(plan.Delete, BuildPlanObject{Kind: "MongoDBConsumer", Name: d.Name})
+	}
+	for _, d := range postgresDelete {
+		plan.Delete = append(plan.Delete, BuildPlanObject{Kind: "PostgreSQLConsumer", Name: d.Name})
+	}
+	for _, d := range depDelete {
+		plan.Delete = append(plan.Delete, BuildPlanObject{Kind: "Deployment", Name: d.Name})
+	}
+	for _, d := range volDelete {
+		plan.Delete = append(plan.Delete, BuildPlanObject{Kind: "PersistentVolumeClaim", Name: d.Name})
+	}
+	for _, d := range servDelete {
+		plan.Delete = append(plan.Delete, BuildPlanObject{Kind: "Service", Name: d.Name})
+	}
+	for _, d := range hpaDelete {
+		plan.Delete = append(plan.Delete, BuildPlanObject{Kind: "HorizontalPodAutoscaler", Name: d.Name})
+	}
+	for _, d := range pdbDelete {
+		plan.Delete = append(plan.Delete, BuildPlanObject{Kind: "PodDisruptionBudget", Name: d.Name})
+	}
+	return plan, nil
+}
+
+// newBuildPlanValues copies the build values that are safe to print into the build plan
+func newBuildPlanValues(buildValues generator.BuildValues) buildPlanValues {
+	values := buildPlanValues{
+		Project:              buildValues.Project,
+		Environment:          buildValues.Environment,
+		EnvironmentType:      buildValues.EnvironmentType,
+		Namespace:            buildValues.Namespace,
+		BuildName:            buildValues.BuildName,
+		BuildType:            buildValues.BuildType,
+		GitSHA:               buildValues.GitSHA,
+		Branch:               buildValues.Branch,
+		PRNumber:             buildValues.PRNumber,
+		PRHeadBranch:         buildValues.PRHeadBranch,
+		PRBaseBranch:         buildValues.PRBaseBranch,
+		PromotionSource:      buildValues.PromotionSourceEnvironment,
+		Kubernetes:           buildValues.Kubernetes,
+		LagoonVersion:        buildValues.LagoonVersion,
+		ActiveEnvironment:    buildValues.ActiveEnvironment,
+		StandbyEnvironment:   buildValues.StandbyEnvironment,
+		ImageRegistry:        buildValues.ImageRegistry,
+		ImageReferences:      buildValues.ImageReferences,
+		IngressClass:         buildValues.IngressClass,
+		Gateway:              buildValues.Gateway,
+		RouteQuota:           buildValues.RouteQuota,
+		BackupsEnabled:       buildValues.BackupsEnabled,
+		CronjobsDisabled:     buildValues.CronjobsDisabled,
+		IsCI:                 buildValues.IsCI,
+		Services:             []buildPlanService{},
+		Variables:            []buildPlanVariable{},
+		LagoonEnvVariables:   sortedKeys(buildValues.LagoonEnvVariables),
+		PlatformEnvVariables: sortedKeys(buildValues.LagoonPlatformEnvVariables),
+	}
+	for _, v := range buildValues.EnvironmentVariables {
+		values.Variables = append(values.Variables, buildPlanVariable{Name: v.Name, Scope: v.Scope})
+	}
+	for _, service := range buildValues.Services {
+		planService := buildPlanService{
+			Name:                       service.Name,
+			OverrideName:               service.OverrideName,
+			Type:                       service.Type,
+			AutogeneratedRoutesEnabled: service.AutogeneratedRoutesEnabled,
+			AutogeneratedRouteDomain:   service.AutogeneratedRouteDomain,
+			DBaaSEnvironment:           service.DBaaSEnvironment,
+			DeploymentServiceType:      service.DeploymentServiceType,
+			ServicePort:                service.ServicePort,
+			PersistentVolumePath:       service.PersistentVolumePath,
+			PersistentVolumeName:       service.PersistentVolumeName,
+			PersistentVolumeSize:       service.PersistentVolumeSize,
+			Replicas:                   service.Replicas,
+			BackupsEnabled:             service.BackupsEnabled,
+			IsDBaaS:                    service.IsDBaaS,
+			IsSingle:                   service.IsSingle,
+			CreateDefaultVolume:        service.CreateDefaultVolume,
+			ExternalServiceName:        service.ExternalServiceName,
+			NativeCronjobs:             []string{},
+			InPodCronjobs:              []string{},
+			ImageBuild:                 service.ImageBuild,
+		}
+		// only the names of the cronjobs, the commands can contain secret values
+		for _, cronjob := range service.NativeCronjobs {
+			planService.NativeCronjobs = append(planService.NativeCronjobs, cronjob.Name)
+		}
+		for _, cronjob := range service.InPodCronjobs {
+			planService.InPodCronjobs = append(planService.InPodCronjobs, cronjob.Name)
+		}
+		values.Services = append(values.Services, planService)
+	}
+	return values
+}
+
+// newBuildPlanComposeSpec copies the parts of the docker-compose file that are safe to print into the build plan
+func newBuildPlanComposeSpec(project *composetypes.Project) buildPlanComposeSpec {
+	spec := buildPlanComposeSpec{
+		Services: []buildPlanComposeService{},
+		Volumes:  []string{},
+		Networks: []string{},
+	}
+	if project == nil {
+		return spec
+	}
+	for _, service := range project.Services {
+		composeService := buildPlanComposeService{
+			Name:   service.Name,
+			Image:  service.Image,
+			Labels: map[string]string{},
+		}
+		if service.Build != nil {
+			composeService.Context = service.Build.Context
+			composeService.Dockerfile = service.Build.Dockerfile
+			composeService.Target = service.Build.Target
+		}
+		// only the lagoon labels are used by the build
+		for key, value := range service.Labels {
+			if strings.HasPrefix(key, "lagoon.") {
+				composeService.Labels[key] = value
+			}
+		}
+		for _, volume := range service.Volumes {
+			composeService.Volumes = append(composeService.Volumes, volume.Target)
+		}
+		spec.Services = append(spec.Services, composeService)
+	}
+	for name := range project.Volumes {
+		spec.Volumes = append(spec.Volumes, name)
+	}
+	sort.Strings(spec.Volumes)
+	for name := range project.Networks {
+		spec.Networks = append(spec.Networks, name)
+	}
+	sort.Strings(spec.Networks)
+	return spec
+}
+
+// buildPlanImageBuild removes the values of the build arguments and the registry passwords from the image build configuration
+func buildPlanImageBuild(build imageBuild) imageBuild {
+	buildArguments := map[string]string{}
+	for name := range build.BuildArguments {
+		buildArguments[name] = buildPlanRedacted
+	}
+	build.BuildArguments = buildArguments
+	registries := []generator.ContainerRegistry{}
+	for _, registry := range build.ContainerRegistries {
+		if registry.Password != "" {
+			registry.Password = buildPlanRedacted
+		}
+		registries = append(registries, registry)
+	}
+	build.ContainerRegistries = registries
+	return build
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	identifyCmd.AddCommand(buildPlanIdentify)
+	buildPlanIdentify.Flags().Bool("yaml", false, "flag to output the build plan in YAML instead of JSON")
+	buildPlanIdentify.Flags().String("state-file", "", "the path to a file from 'collect environment' to use instead of the cluster")
+}
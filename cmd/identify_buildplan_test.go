@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/uselagoon/build-deploy-tool/internal/collector"
+	"github.com/uselagoon/build-deploy-tool/internal/dbaasclient"
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/k8s"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"github.com/uselagoon/build-deploy-tool/internal/testdata"
+
+	// changes the testing to source from root so paths to test resources must be defined from repo root
+	_ "github.com/uselagoon/build-deploy-tool/internal/testing"
+)
+
+func TestBuildPlanIdentification(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          testdata.TestData
+		namespace     string
+		seedDir       string
+		wantPrimary   string
+		wantCreated   ingressIdentifyJSON
+		wantCronjobs  []string
+		wantDBaaS     []string
+		wantCompose   []string
+		wantSpec      buildPlanComposeSpec
+		wantCreate    []BuildPlanObject
+		wantDelete    []BuildPlanObject
+		wantImageName []string
+	}{
+		{
+			name: "basic-deployment",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/basic/lagoon.yml",
+					ImageReferences: map[string]string{
+						"node": "harbor.example/example-project/main/node@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+					},
+				}, true),
+			namespace:   "example-project-main",
+			seedDir:     "internal/testdata/basic/cleanup-seed/basic-deployment",
+			wantPrimary: "https://example.com",
+			wantCreated: ingressIdentifyJSON{
+				Secondary:     []string{"example.com"},
+				Autogenerated: []string{"node"},
+			},
+			wantCronjobs: []string{},
+			wantDBaaS:    []string{},
+			wantCompose:  []string{"node"},
+			wantSpec: buildPlanComposeSpec{
+				Services: []buildPlanComposeService{
+					{
+						Name:       "node",
+						Context:    "internal/testdata/basic/docker",
+						Dockerfile: "basic.dockerfile",
+						Labels: map[string]string{
+							"lagoon.type":                    "basic",
+							"lagoon.service.usecomposeports": "true",
+						},
+						Volumes: []string{"/app"},
+					},
+				},
+				Volumes:  []string{},
+				Networks: []string{"amazeeio-network", "default"},
+			},
+			wantImageName: []string{"node"},
+			wantCreate: []BuildPlanObject{
+				{Kind: "Service", Name: "node"},
+				{Kind: "Deployment", Name: "node"},
+				{Kind: "Ingress", Name: "node"},
+				{Kind: "Ingress", Name: "example.com"},
+			},
+			wantDelete: []BuildPlanObject{
+				{Kind: "MariaDBConsumer", Name: "mariadb"},
+				{Kind: "Deployment", Name: "basic"},
+				{Kind: "Service", Name: "basic"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			helpers.UnsetEnvVars(nil) //unset variables before running tests
+			// set the environment variables from args
+			savedTemplates, err := os.MkdirTemp("", "testoutput")
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			generator, err := testdata.SetupEnvironment(generator.GeneratorInput{}, savedTemplates, tt.args)
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			defer os.RemoveAll(savedTemplates)
+
+			ts := dbaasclient.TestDBaaSHTTPServer()
+			defer ts.Close()
+			err = os.Setenv("DBAAS_OPERATOR_HTTP", ts.URL)
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+
+			client, err := k8s.NewFakeClient(tt.namespace)
+			if err != nil {
+				t.Errorf("error creating fake client")
+			}
+			err = k8s.SeedFakeData(client, tt.namespace, tt.seedDir)
+			if err != nil {
+				t.Errorf("error seeding fake data: %v", err)
+			}
+			col := collector.NewCollector(client)
+			plan, err := BuildPlanIdentification(col, generator)
+			if err != nil {
+				t.Errorf("BuildPlanIdentification() error = %v", err)
+				return
+			}
+			if plan.APIVersion != BuildPlanAPIVersion || plan.Kind != BuildPlanKind {
+				t.Errorf("BuildPlanIdentification() apiVersion/kind = %v/%v", plan.APIVersion, plan.Kind)
+			}
+			if plan.Ingress.Primary != tt.wantPrimary {
+				t.Errorf("BuildPlanIdentification() primary ingress = %v, want %v", plan.Ingress.Primary, tt.wantPrimary)
+			}
+			if !reflect.DeepEqual(plan.Ingress.Created, tt.wantCreated) {
+				t.Errorf("BuildPlanIdentification() created ingress = %v, want %v", plan.Ingress.Created, tt.wantCreated)
+			}
+			if !reflect.DeepEqual(plan.NativeCronjobs, tt.wantCronjobs) {
+				t.Errorf("BuildPlanIdentification() native cronjobs = %v, want %v", plan.NativeCronjobs, tt.wantCronjobs)
+			}
+			if !reflect.DeepEqual(plan.DBaaS, tt.wantDBaaS) {
+				t.Errorf("BuildPlanIdentification() dbaas = %v, want %v", plan.DBaaS, tt.wantDBaaS)
+			}
+			var compose []string
+			for _, s := range plan.Compose.Order {
+				compose = append(compose, s.Name)
+			}
+			if !reflect.DeepEqual(compose, tt.wantCompose) {
+				t.Errorf("BuildPlanIdentification() compose order = %v, want %v", compose, tt.wantCompose)
+			}
+			if !reflect.DeepEqual(plan.Compose.Spec, tt.wantSpec) {
+				t.Errorf("BuildPlanIdentification() compose spec = %v, want %v", plan.Compose.Spec, tt.wantSpec)
+			}
+			var images []string
+			for _, i := range plan.ImageBuild.Images {
+				images = append(images, i.Name)
+			}
+			if !reflect.DeepEqual(images, tt.wantImageName) {
+				t.Errorf("BuildPlanIdentification() images = %v, want %v", images, tt.wantImageName)
+			}
+			if !reflect.DeepEqual(plan.Create, tt.wantCreate) {
+				t.Errorf("BuildPlanIdentification() create = %v, want %v", plan.Create, tt.wantCreate)
+			}
+			if !reflect.DeepEqual(plan.Delete, tt.wantDelete) {
+				t.Errorf("BuildPlanIdentification() delete = %v, want %v", plan.Delete, tt.wantDelete)
+			}
+		})
+	}
+}
+
+func TestBuildPlanSecrets(t *testing.T) {
+	helpers.UnsetEnvVars(nil) //unset variables before running tests
+	namespace := "example-project-main"
+	secrets := []string{
+		"super-secret-runtime-value",
+		"super-secret-build-value",
+		"super-secret-registry-password",
+		"thisisafakekey",
+	}
+	args := testdata.GetSeedData(
+		testdata.TestData{
+			ProjectName:     "example-project",
+			EnvironmentName: "main",
+			Branch:          "main",
+			LagoonYAML:      "internal/testdata/basic/lagoon.container-registry.yml",
+			ImageReferences: map[string]string{
+				"node": "harbor.example/example-project/main/node@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+			},
+			ProjectVariables: []lagoon.EnvironmentVariable{
+				{
+					Name:  "RUNTIME_SECRET",
+					Value: secrets[0],
+					Scope: "runtime",
+				},
+				{
+					Name:  "BUILD_SECRET",
+					Value: secrets[1],
+					Scope: "build",
+				},
+				{
+					Name:  "REGISTRY_PASSWORD",
+					Value: secrets[2],
+					Scope: "container_registry",
+				},
+			},
+		}, true)
+	savedTemplates, err := os.MkdirTemp("", "testoutput")
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	defer os.RemoveAll(savedTemplates)
+	input, err := testdata.SetupEnvironment(generator.GeneratorInput{}, savedTemplates, args)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	ts := dbaasclient.TestDBaaSHTTPServer()
+	defer ts.Close()
+	t.Setenv("DBAAS_OPERATOR_HTTP", ts.URL)
+	client, err := k8s.NewFakeClient(namespace)
+	if err != nil {
+		t.Errorf("error creating fake client")
+	}
+	plan, err := BuildPlanIdentification(collector.NewCollector(client), input)
+	if err != nil {
+		t.Fatalf("BuildPlanIdentification() error = %v", err)
+	}
+	planJSON, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatalf("couldn't marshal build plan: %v", err)
+	}
+	for _, secret := range secrets {
+		if strings.Contains(string(planJSON), secret) {
+			t.Errorf("BuildPlanIdentification() build plan contains the secret value %s", secret)
+		}
+	}
+	// the names of the variables are still in the build plan
+	for _, name := range []string{"RUNTIME_SECRET", "BUILD_SECRET", "REGISTRY_PASSWORD"} {
+		if !strings.Contains(string(planJSON), name) {
+			t.Errorf("BuildPlanIdentification() build plan is missing the variable %s", name)
+		}
+	}
+}
@@ -53,13 +53,21 @@ type imageBuilds struct {
 // * eventually other information like build args etc
 func ImageBuildConfigurationIdentification(g generator.GeneratorInput) (imageBuild, error) {
 
-	lServices := imageBuild{}
 	lagoonBuild, err := generator.NewGenerator(
 		g,
 	)
 	if err != nil {
-		return lServices, err
+		return imageBuild{}, err
+	}
+	// the image builds log in to the container registries, so their credentials are resolved here
+	if err := generator.ResolveContainerRegistryCredentials(lagoonBuild.BuildValues); err != nil {
+		return imageBuild{}, err
 	}
+	return imageBuildConfiguration(lagoonBuild), nil
+}
+
+func imageBuildConfiguration(lagoonBuild *generator.Generator) imageBuild {
+	lServices := imageBuild{}
 	lServices.BuildKit = lagoonBuild.BuildValues.DockerBuildKit
 	lServices.BuildArguments = lagoonBuild.BuildValues.ImageBuildArguments
 	for _, service := range lagoonBuild.BuildValues.Services {
@@ -72,7 +80,7 @@ func ImageBuildConfigurationIdentification(g generator.GeneratorInput) (imageBui
 	}
 	lServices.ForcePullImages = lagoonBuild.BuildValues.ForcePullImages
 	lServices.ContainerRegistries = lagoonBuild.BuildValues.ContainerRegistry
-	return lServices, nil
+	return lServices
 }
 
 func init() {
@@ -524,6 +524,7 @@ func TestImageBuildConfigurationIdentification(t *testing.T) {
 				ContainerRegistries: []generator.ContainerRegistry{
 					{
 						Name:           "my-custom-registry",
+						Type:           "static",
 						Username:       "registry_user",
 						Password:       "REGISTRY_PASSWORD",
 						SecretName:     "lagoon-private-registry-my-custom-registry",
@@ -652,6 +653,7 @@ func TestImageBuildConfigurationIdentification(t *testing.T) {
 				ContainerRegistries: []generator.ContainerRegistry{
 					{
 						Name:           "my-custom-registry",
+						Type:           "static",
 						Username:       "registry_user",
 						Password:       "REGISTRY_PASSWORD",
 						SecretName:     "lagoon-private-registry-my-custom-registry",
@@ -662,6 +664,7 @@ func TestImageBuildConfigurationIdentification(t *testing.T) {
 					},
 					{
 						Name:           "my-other-custom-registry",
+						Type:           "static",
 						Username:       "registry_user2",
 						Password:       "REGISTRY_PASSWORD2",
 						SecretName:     "lagoon-private-registry-my-other-custom-registry",
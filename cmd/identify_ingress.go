@@ -12,6 +12,7 @@ type ingressIdentifyJSON struct {
 	Primary       string   `json:"primary"`
 	Secondary     []string `json:"secondary"`
 	Autogenerated []string `json:"autogenerated"`
+	Redirects     []string `json:"redirects,omitempty"`
 }
 
 var primaryIngressIdentify = &cobra.Command{
@@ -23,7 +24,7 @@ var primaryIngressIdentify = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		primary, _, _, err := IdentifyPrimaryIngress(generator)
+		primary, _, _, _, err := IdentifyPrimaryIngress(generator)
 		if err != nil {
 			return err
 		}
@@ -41,7 +42,7 @@ var ingressIdentify = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		primary, secondary, autogen, err := IdentifyPrimaryIngress(generator)
+		primary, secondary, autogen, redirects, err := IdentifyPrimaryIngress(generator)
 		if err != nil {
 			return err
 		}
@@ -49,6 +50,7 @@ var ingressIdentify = &cobra.Command{
 			Primary:       primary,
 			Secondary:     secondary,
 			Autogenerated: autogen,
+			Redirects:     redirects,
 		}
 		retJSON, _ := json.Marshal(ret)
 		fmt.Println(string(retJSON))
@@ -56,16 +58,16 @@ var ingressIdentify = &cobra.Command{
 	},
 }
 
-// IdentifyPrimaryIngress .
-func IdentifyPrimaryIngress(g generator.GeneratorInput) (string, []string, []string, error) {
+// IdentifyPrimaryIngress returns the primary route, all the routes, the autogenerated routes, and the redirects of an environment
+func IdentifyPrimaryIngress(g generator.GeneratorInput) (string, []string, []string, []string, error) {
 	lagoonBuild, err := generator.NewGenerator(
 		g,
 	)
 	if err != nil {
-		return "", nil, nil, err
+		return "", nil, nil, nil, err
 	}
 
-	return lagoonBuild.BuildValues.Route, lagoonBuild.BuildValues.Routes, lagoonBuild.BuildValues.AutogeneratedRoutes, nil
+	return lagoonBuild.BuildValues.Route, lagoonBuild.BuildValues.Routes, lagoonBuild.BuildValues.AutogeneratedRoutes, lagoonBuild.BuildValues.RedirectRoutes, nil
 }
 
 var autogenIngressIdentify = &cobra.Command{
@@ -99,7 +101,11 @@ func CreatedIngressIdentification(g generator.GeneratorInput) ([]string, []strin
 	if err != nil {
 		return nil, nil, err
 	}
+	autogenIngress, secondary := createdIngress(lagoonBuild)
+	return autogenIngress, secondary, nil
+}
 
+func createdIngress(lagoonBuild *generator.Generator) ([]string, []string) {
 	autogenIngress := []string{}
 	// generate the templates
 	for _, route := range lagoonBuild.AutogeneratedRoutes.Routes {
@@ -114,7 +120,7 @@ func CreatedIngressIdentification(g generator.GeneratorInput) ([]string, []strin
 	for _, route := range lagoonBuild.ActiveStandbyRoutes.Routes {
 		secondary = append(secondary, route.IngressName)
 	}
-	return autogenIngress, secondary, nil
+	return autogenIngress, secondary
 }
 
 func init() {
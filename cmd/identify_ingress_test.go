@@ -17,12 +17,13 @@ import (
 
 func TestIdentifyRoute(t *testing.T) {
 	tests := []struct {
-		name        string
-		args        testdata.TestData
-		want        string
-		wantJSON    string
-		wantRemain  []string
-		wantautoGen []string
+		name          string
+		args          testdata.TestData
+		want          string
+		wantJSON      string
+		wantRemain    []string
+		wantautoGen   []string
+		wantRedirects []string
 	}{
 		{
 			name: "test1 check LAGOON_FASTLY_SERVICE_IDS with secret no values",
@@ -329,6 +330,21 @@ func TestIdentifyRoute(t *testing.T) {
 			wantautoGen: []string{"https://nginx-example-project-main.example.com"},
 			wantJSON:    `{"primary":"https://wild.example.com","secondary":["https://nginx-example-project-main.example.com","https://wild.example.com","https://alt.example.com","https://www.example.com","https://en.example.com"],"autogenerated":["https://nginx-example-project-main.example.com"]}`,
 		},
+		{
+			name: "test19 redirects",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "redirects",
+					Branch:          "redirects",
+					LagoonYAML:      "internal/testdata/node/lagoon.yml",
+				}, true),
+			want:          "https://example.com",
+			wantRemain:    []string{"https://node-example-project-redirects.example.com", "https://example.com", "https://www.example.com"},
+			wantautoGen:   []string{"https://node-example-project-redirects.example.com"},
+			wantRedirects: []string{"https://old.example.com", "https://promo.example.com"},
+			wantJSON:      `{"primary":"https://example.com","secondary":["https://node-example-project-redirects.example.com","https://example.com","https://www.example.com"],"autogenerated":["https://node-example-project-redirects.example.com"],"redirects":["https://old.example.com","https://promo.example.com"]}`,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -342,7 +358,7 @@ func TestIdentifyRoute(t *testing.T) {
 			if err != nil {
 				t.Errorf("%v", err)
 			}
-			primary, remainders, autogen, err := IdentifyPrimaryIngress(generator)
+			primary, remainders, autogen, redirects, err := IdentifyPrimaryIngress(generator)
 			if err != nil {
 				t.Errorf("%v", err)
 			}
@@ -359,10 +375,17 @@ func TestIdentifyRoute(t *testing.T) {
 				t.Errorf("returned autogen %v doesn't match want %v", autogen, tt.wantautoGen)
 			}
 
+			if len(redirects) > 0 || len(tt.wantRedirects) > 0 {
+				if !reflect.DeepEqual(redirects, tt.wantRedirects) {
+					t.Errorf("returned redirects %v doesn't match want %v", redirects, tt.wantRedirects)
+				}
+			}
+
 			ret := ingressIdentifyJSON{
 				Primary:       primary,
 				Secondary:     remainders,
 				Autogenerated: autogen,
+				Redirects:     redirects,
 			}
 			retJSON, _ := json.Marshal(ret)
 
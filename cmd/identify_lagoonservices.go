@@ -5,10 +5,8 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
-	"github.com/uselagoon/build-deploy-tool/internal/collector"
 	"github.com/uselagoon/build-deploy-tool/internal/helpers"
 	"github.com/uselagoon/build-deploy-tool/internal/identify"
-	"github.com/uselagoon/build-deploy-tool/internal/k8s"
 )
 
 type LagoonServices struct {
@@ -25,12 +23,6 @@ var lagoonServiceIdentify = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		client, err := k8s.NewClient()
-		if err != nil {
-			return err
-		}
-		// create a collector
-		col := collector.NewCollector(client)
 		images, err := rootCmd.PersistentFlags().GetString("images")
 		if err != nil {
 			return fmt.Errorf("error reading images flag: %v", err)
@@ -47,9 +39,18 @@ var lagoonServiceIdentify = &cobra.Command{
 		if namespace == "" {
 			return fmt.Errorf("unable to detect namespace")
 		}
+		stateFile, err := cmd.Flags().GetString("state-file")
+		if err != nil {
+			return fmt.Errorf("error reading state-file flag: %v", err)
+		}
+		// create a collector
+		col, err := newCollector(stateFile, namespace)
+		if err != nil {
+			return err
+		}
 		gen.Namespace = namespace
 		gen.ImageReferences = imageRefs.Images
-		services, _, _, _, _, _, _, _, err := identify.GetCurrentState(col, gen)
+		services, _, _, _, _, _, _, _, _, _, err := identify.GetCurrentState(col, gen)
 		if err != nil {
 			return err
 		}
@@ -61,4 +62,5 @@ var lagoonServiceIdentify = &cobra.Command{
 
 func init() {
 	identifyCmd.AddCommand(lagoonServiceIdentify)
+	lagoonServiceIdentify.Flags().String("state-file", "", "the path to a file from 'collect environment' to use instead of the cluster")
 }
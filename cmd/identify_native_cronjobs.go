@@ -34,16 +34,19 @@ func IdentifyNativeCronjobs(g generator.GeneratorInput) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	nativeCronjobsBytes, _ := json.Marshal(nativeCronjobNames(*lagoonBuild.BuildValues))
 
+	return string(nativeCronjobsBytes), nil
+}
+
+func nativeCronjobNames(buildValues generator.BuildValues) []string {
 	nativeCronjobs := []string{}
-	for _, service := range lagoonBuild.BuildValues.Services {
+	for _, service := range buildValues.Services {
 		for _, nc := range service.NativeCronjobs {
 			nativeCronjobs = append(nativeCronjobs, nc.Name)
 		}
 	}
-	nativeCronjobsBytes, _ := json.Marshal(nativeCronjobs)
-
-	return string(nativeCronjobsBytes), nil
+	return nativeCronjobs
 }
 
 func init() {
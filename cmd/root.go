@@ -19,10 +19,15 @@ limitations under the License.
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/uselagoon/build-deploy-tool/internal/buildlog"
 	"github.com/uselagoon/build-deploy-tool/internal/dbaasclient"
 	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/registryauth"
+	"github.com/uselagoon/build-deploy-tool/internal/secretref"
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -32,8 +37,25 @@ var rootCmd = &cobra.Command{
 	Long: `A tool to help with generating Lagoon resources for Lagoon builds
 This tool will read a .lagoon.yml file and also all the required environment variables from
 within a Lagoon build to help with generating the resources`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		eventLog, err := cmd.Flags().GetString("event-log")
+		if err != nil {
+			return fmt.Errorf("error reading event-log flag: %v", err)
+		}
+		eventLog = helpers.GetEnv("LAGOON_BUILD_EVENT_LOG", eventLog, false)
+		logger, err := buildlog.Open(eventLog)
+		if err != nil {
+			return err
+		}
+		buildlog.SetDefault(logger)
+		commandStart = time.Now()
+		return nil
+	},
 }
 
+// the time the command started, used for the timing event of the command
+var commandStart time.Time
+
 var templateCmd = &cobra.Command{
 	Use:     "template",
 	Aliases: []string{"t"},
@@ -79,12 +101,34 @@ var runCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	cmd, err := rootCmd.ExecuteC()
+	logger := buildlog.Default()
+	defer logger.Close()
+	if !commandStart.IsZero() {
+		logger.Timing(cmd.CommandPath(), time.Since(commandStart), nil)
+	}
+	if err != nil {
 		fmt.Println(err)
+		logger.Error(cmd.CommandPath(), err)
+		logger.Close()
 		os.Exit(1)
 	}
 }
 
+// exitCommand writes the timing event of the command, and the error event if there is an error, then closes the event log
+// and exits with the code. Commands that exit with their own exit codes use this instead of os.Exit so their events are still written
+func exitCommand(cmd *cobra.Command, code int, err error) {
+	logger := buildlog.Default()
+	if !commandStart.IsZero() {
+		logger.Timing(cmd.CommandPath(), time.Since(commandStart), nil)
+	}
+	if err != nil {
+		logger.Error(cmd.CommandPath(), err)
+	}
+	logger.Close()
+	os.Exit(code)
+}
+
 // version/build information (populated at build time by make file)
 var (
 	bdtName    = "build-deploy-tool"
@@ -155,6 +199,8 @@ func init() {
 	rootCmd.PersistentFlags().StringP("saved-templates-path", "T", "/kubectl-build-deploy/lagoon/services-routes",
 		"Path to where the resulting templates are saved")
 	rootCmd.PersistentFlags().String("default-backup-schedule", "", "The default backup schedule to use")
+	rootCmd.PersistentFlags().String("service-types-dir", "", "The directory containing additional service type definitions")
+	rootCmd.PersistentFlags().String("policy-file", "", "The cluster policy file that generated objects are evaluated against")
 	rootCmd.PersistentFlags().StringP("monitoring-config", "M", "",
 		"The monitoring contact config if known")
 	rootCmd.PersistentFlags().StringP("monitoring-status-page-id", "m", "",
@@ -173,6 +219,8 @@ func init() {
 		"JSON representation of service:image reference")
 	rootCmd.PersistentFlags().StringP("dbaas-creds", "", "",
 		"JSON representation of dbaas credential references")
+	rootCmd.PersistentFlags().String("event-log", "",
+		"The file to append the build events to as JSON lines")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -274,8 +322,19 @@ func GenerateInput(rootCmd cobra.Command, debug bool) (generator.GeneratorInput,
 	if err != nil {
 		return generator.GeneratorInput{}, fmt.Errorf("error reading default-backup-schedule flag: %v", err)
 	}
+	serviceTypesDir, err := rootCmd.PersistentFlags().GetString("service-types-dir")
+	if err != nil {
+		return generator.GeneratorInput{}, fmt.Errorf("error reading service-types-dir flag: %v", err)
+	}
+	policyFile, err := rootCmd.PersistentFlags().GetString("policy-file")
+	if err != nil {
+		return generator.GeneratorInput{}, fmt.Errorf("error reading policy-file flag: %v", err)
+	}
 	// create a dbaas client with the default configuration
 	dbaas := dbaasclient.NewClient(dbaasclient.Client{})
+	// create a container registry credential client with the default configuration
+	registryAuth := registryauth.NewClient(registryauth.Client{})
+	secretRef := secretref.NewClient(secretref.Client{})
 	return generator.GeneratorInput{
 		Debug:                    debug,
 		LagoonYAML:               lagoonYAML,
@@ -301,6 +360,10 @@ func GenerateInput(rootCmd cobra.Command, debug bool) (generator.GeneratorInput,
 		IgnoreMissingEnvFiles:    ignoreMissingEnvFiles,
 		IgnoreNonStringKeyErrors: ignoreNonStringKeyErrors,
 		DBaaSClient:              dbaas,
+		RegistryAuthClient:       registryAuth,
+		SecretRefClient:          secretRef,
 		DefaultBackupSchedule:    defaultBackupSchedule,
+		ServiceTypesDir:          serviceTypesDir,
+		PolicyFile:               policyFile,
 	}, nil
 }
@@ -4,27 +4,23 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"github.com/uselagoon/build-deploy-tool/internal/buildlog"
 	"github.com/uselagoon/build-deploy-tool/internal/cleanup"
-	"github.com/uselagoon/build-deploy-tool/internal/collector"
 	"github.com/uselagoon/build-deploy-tool/internal/helpers"
-	"github.com/uselagoon/build-deploy-tool/internal/k8s"
 )
 
 var cleanupCmd = &cobra.Command{
 	Use:     "cleanup",
 	Aliases: []string{"clean", "cu", "c"},
 	Short:   "Cleanup old services",
+	Long: `Cleanup old services
+Any services, volumes, dbaas consumers, horizontalpodautoscalers, and poddisruptionbudgets that are no longer generated
+by the build are reported, and are only removed from the environment if the delete flag is set`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		deleteServices, err := cmd.Flags().GetBool("delete")
 		if err != nil {
 			return fmt.Errorf("error reading domain flag: %v", err)
 		}
-		client, err := k8s.NewClient()
-		if err != nil {
-			return err
-		}
-		// create a collector
-		col := collector.NewCollector(client)
 		gen, err := GenerateInput(*rootCmd, false)
 		if err != nil {
 			return err
@@ -45,12 +41,46 @@ var cleanupCmd = &cobra.Command{
 		if namespace == "" {
 			return fmt.Errorf("unable to detect namespace")
 		}
+		stateFile, err := cmd.Flags().GetString("state-file")
+		if err != nil {
+			return fmt.Errorf("error reading state-file flag: %v", err)
+		}
+		// deletions against a state file are only made to the copy of the state in memory
+		if deleteServices && stateFile != "" {
+			return fmt.Errorf("the delete flag can't be used with a state file, nothing would be removed from the environment")
+		}
+		// create a collector
+		col, err := newCollector(stateFile, namespace)
+		if err != nil {
+			return err
+		}
 		gen.Namespace = namespace
 		gen.ImageReferences = imageRefs.Images
-		_, _, _, _, _, _, err = cleanup.RunCleanup(col, gen, deleteServices)
+		mariadb, mongodb, postgresql, deployments, volumes, services, hpas, pdbs, err := cleanup.RunCleanup(col, gen, deleteServices)
 		if err != nil {
 			return err
 		}
+		action := "would remove"
+		if deleteServices {
+			action = "removed"
+		}
+		for _, removed := range []struct {
+			kind  string
+			names []string
+		}{
+			{"Deployment", deployments},
+			{"PersistentVolumeClaim", volumes},
+			{"Service", services},
+			{"MariaDBConsumer", mariadb},
+			{"MongoDBConsumer", mongodb},
+			{"PostgreSQLConsumer", postgresql},
+			{"HorizontalPodAutoscaler", hpas},
+			{"PodDisruptionBudget", pdbs},
+		} {
+			for _, name := range removed.names {
+				buildlog.Decide(cmd.CommandPath(), action, map[string]string{"kind": removed.kind, "name": name})
+			}
+		}
 		return nil
 	},
 }
@@ -58,4 +88,5 @@ var cleanupCmd = &cobra.Command{
 func init() {
 	runCmd.AddCommand(cleanupCmd)
 	cleanupCmd.Flags().Bool("delete", false, "flag to actually delete services")
+	cleanupCmd.Flags().String("state-file", "", "the path to a file from 'collect environment' to use instead of the cluster")
 }
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/uselagoon/build-deploy-tool/internal/buildlog"
+	"github.com/uselagoon/build-deploy-tool/internal/dbaaswait"
+	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/k8s"
+	servicestemplates "github.com/uselagoon/build-deploy-tool/internal/templating"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var dbaasWaitCmd = &cobra.Command{
+	Use:     "dbaas-wait",
+	Aliases: []string{"dw"},
+	Short:   "Wait for the dbaas consumers of a Lagoon build to be provisioned",
+	Long: `Wait for the dbaas consumers of a Lagoon build to be provisioned
+This will watch all the MariaDBConsumer, MongoDBConsumer and PostgreSQLConsumer resources generated for the build
+until the dbaas-operator has provisioned a database for each of them. Exits with an error as soon as a consumer is marked
+as failed by the operator, or if the consumers are not provisioned before the timeout`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			return fmt.Errorf("error reading timeout flag: %v", err)
+		}
+		interval, err := cmd.Flags().GetDuration("poll-interval")
+		if err != nil {
+			return fmt.Errorf("error reading poll-interval flag: %v", err)
+		}
+		gen, err := GenerateInput(*rootCmd, false)
+		if err != nil {
+			return err
+		}
+		namespace := helpers.GetEnv("NAMESPACE", "", false)
+		namespace, err = helpers.GetNamespace(namespace, "/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+		if err != nil {
+			return err
+		}
+		if namespace == "" {
+			return fmt.Errorf("unable to detect namespace")
+		}
+		gen.Namespace = namespace
+		consumers, err := DBaaSConsumers(gen)
+		if err != nil {
+			return err
+		}
+		if len(consumers) == 0 {
+			fmt.Println("No database consumers to wait for")
+			return nil
+		}
+		client, err := k8s.NewClient()
+		if err != nil {
+			return err
+		}
+		w := dbaaswait.Waiter{
+			Client:    client,
+			Namespace: namespace,
+			Timeout:   timeout,
+			Interval:  interval,
+			Out:       os.Stdout,
+		}
+		statuses, err := w.Run(context.Background(), consumers)
+		for _, s := range statuses {
+			buildlog.Decide(cmd.CommandPath(), consumerStatus(s), map[string]string{"kind": s.Kind, "name": s.Name, "message": s.Message})
+		}
+		return err
+	},
+}
+
+func consumerStatus(s dbaaswait.ConsumerStatus) string {
+	switch {
+	case s.Failed:
+		return "failed"
+	case s.Provisioned:
+		return "provisioned"
+	}
+	return "pending"
+}
+
+// DBaaSConsumers returns the dbaas consumers that the build generates
+func DBaaSConsumers(g generator.GeneratorInput) ([]client.Object, error) {
+	lagoonBuild, err := generator.NewGenerator(
+		g,
+	)
+	if err != nil {
+		return nil, err
+	}
+	dbaas, err := servicestemplates.GenerateDBaaSTemplate(*lagoonBuild.BuildValues)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	var consumers []client.Object
+	for idx := range dbaas.MariaDB {
+		consumers = append(consumers, &dbaas.MariaDB[idx])
+	}
+	for idx := range dbaas.MongoDB {
+		consumers = append(consumers, &dbaas.MongoDB[idx])
+	}
+	for idx := range dbaas.PostgreSQL {
+		consumers = append(consumers, &dbaas.PostgreSQL[idx])
+	}
+	return consumers, nil
+}
+
+func init() {
+	runCmd.AddCommand(dbaasWaitCmd)
+	dbaasWaitCmd.Flags().Duration("timeout", 5*time.Minute, "how long to wait for the database consumers to be provisioned")
+	dbaasWaitCmd.Flags().Duration("poll-interval", 5*time.Second, "how often to check the status of the database consumers")
+}
@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/uselagoon/build-deploy-tool/internal/buildlog"
+	"github.com/uselagoon/build-deploy-tool/internal/deploy"
+	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/k8s"
+	servicestemplates "github.com/uselagoon/build-deploy-tool/internal/templating"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var deployCmd = &cobra.Command{
+	Use:     "deploy",
+	Aliases: []string{"dep", "d"},
+	Short:   "Apply the generated resources for a Lagoon build",
+	Long: `Apply the generated resources for a Lagoon build
+This will generate the same resources as the lagoon-services, ingress, autogenerated-ingress, dbaas and backup-schedule
+templates and server-side apply them directly into the environment. The cluster policy is evaluated against all of the
+resources first, and nothing is applied if any of them violate it`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			return fmt.Errorf("error reading dry-run flag: %v", err)
+		}
+		outputJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return fmt.Errorf("error reading json flag: %v", err)
+		}
+		gen, err := GenerateInput(*rootCmd, false)
+		if err != nil {
+			return err
+		}
+		images, err := rootCmd.PersistentFlags().GetString("images")
+		if err != nil {
+			return fmt.Errorf("error reading images flag: %v", err)
+		}
+		imageRefs, err := loadImagesFromFile(images)
+		if err != nil {
+			return err
+		}
+		namespace := helpers.GetEnv("NAMESPACE", "", false)
+		namespace, err = helpers.GetNamespace(namespace, "/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+		if err != nil {
+			return err
+		}
+		if namespace == "" {
+			return fmt.Errorf("unable to detect namespace")
+		}
+		gen.Namespace = namespace
+		gen.ImageReferences = imageRefs.Images
+		client, err := k8s.NewClient()
+		if err != nil {
+			return err
+		}
+		objects, err := DeployObjectGeneration(gen)
+		if err != nil {
+			return err
+		}
+		results, applyErr := deploy.Apply(context.Background(), client, namespace, objects, dryRun)
+		for _, r := range results {
+			buildlog.Decide(cmd.CommandPath(), r.Action, map[string]string{"kind": r.Kind, "name": r.Name})
+		}
+		if outputJSON {
+			rBytes, err := json.Marshal(results)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(rBytes))
+		} else {
+			for _, r := range results {
+				fmt.Println(r.String())
+			}
+		}
+		return applyErr
+	},
+}
+
+// DeployObjectGeneration generates all the resources that the template commands would write to disk
+// and returns them as objects that can be applied directly to the environment. The cluster policy is evaluated
+// against the full set of objects, and none are returned if any of them violate it
+func DeployObjectGeneration(g generator.GeneratorInput) ([]client.Object, error) {
+	lagoonBuild, err := generator.NewGenerator(
+		g,
+	)
+	if err != nil {
+		return nil, err
+	}
+	// the registry secrets contain the credentials of the container registries, so they are resolved here
+	if err := generator.ResolveContainerRegistryCredentials(lagoonBuild.BuildValues); err != nil {
+		return nil, err
+	}
+	objects, err := deployObjects(lagoonBuild)
+	if err != nil {
+		return nil, err
+	}
+	if err := evaluatePolicy(lagoonBuild.BuildValues, objects); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+func deployObjects(lagoonBuild *generator.Generator) ([]client.Object, error) {
+	var objects []client.Object
+
+	secrets, err := servicestemplates.GenerateRegistrySecretTemplate(*lagoonBuild.BuildValues)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	for idx := range secrets {
+		objects = append(objects, &secrets[idx])
+	}
+	services, err := servicestemplates.GenerateServiceTemplate(*lagoonBuild.BuildValues)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	for idx := range services {
+		objects = append(objects, &services[idx])
+	}
+	pvcs, err := servicestemplates.GeneratePVCTemplate(*lagoonBuild.BuildValues)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	for idx := range pvcs {
+		objects = append(objects, &pvcs[idx])
+	}
+	deployments, err := servicestemplates.GenerateDeploymentTemplate(*lagoonBuild.BuildValues)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	for idx := range deployments {
+		objects = append(objects, &deployments[idx])
+	}
+	hpas, err := servicestemplates.GenerateHorizontalPodAutoscalerTemplate(*lagoonBuild.BuildValues)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	for idx := range hpas {
+		objects = append(objects, &hpas[idx])
+	}
+	pdbs, err := servicestemplates.GeneratePodDisruptionBudgetTemplate(*lagoonBuild.BuildValues)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	for idx := range pdbs {
+		objects = append(objects, &pdbs[idx])
+	}
+	cronjobs, err := servicestemplates.GenerateCronjobTemplate(*lagoonBuild.BuildValues)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	for idx := range cronjobs {
+		objects = append(objects, &cronjobs[idx])
+	}
+	if lagoonBuild.BuildValues.IsolationNetworkPolicy {
+		np, err := servicestemplates.GenerateNetworkPolicy(*lagoonBuild.BuildValues)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't generate template: %v", err)
+		}
+		objects = append(objects, np)
+	}
+	serviceNetPols, err := servicestemplates.GenerateServiceNetworkPolicies(*lagoonBuild.BuildValues)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	for idx := range serviceNetPols {
+		objects = append(objects, &serviceNetPols[idx])
+	}
+
+	// generate the ingress, active/standby routes replace any environment defined routes with the same domain
+	// in the same way the template command overwrites the files it writes
+	routes := lagoonBuild.AutogeneratedRoutes.Routes
+	routes = append(routes, lagoonBuild.MainRoutes.Routes...)
+	if *lagoonBuild.ActiveEnvironment || *lagoonBuild.StandbyEnvironment {
+		routes = append(routes, lagoonBuild.ActiveStandbyRoutes.Routes...)
+	}
+	routeIndex := map[string]int{}
+	for _, route := range routes {
+		_, routeObjects, err := generateRouteTemplate(route, *lagoonBuild.BuildValues)
+		if err != nil {
+			return nil, err
+		}
+		for _, routeObject := range routeObjects {
+			// routes can template objects of different kinds with the same name
+			key := fmt.Sprintf("%s/%s", routeObject.GetObjectKind().GroupVersionKind().Kind, routeObject.GetName())
+			if idx, ok := routeIndex[key]; ok {
+				objects[idx] = routeObject
+				continue
+			}
+			routeIndex[key] = len(objects)
+			objects = append(objects, routeObject)
+		}
+	}
+
+	dbaas, err := servicestemplates.GenerateDBaaSTemplate(*lagoonBuild.BuildValues)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	for idx := range dbaas.MariaDB {
+		objects = append(objects, &dbaas.MariaDB[idx])
+	}
+	for idx := range dbaas.MongoDB {
+		objects = append(objects, &dbaas.MongoDB[idx])
+	}
+	for idx := range dbaas.PostgreSQL {
+		objects = append(objects, &dbaas.PostgreSQL[idx])
+	}
+
+	// use a copy of the build values so the read replica changes don't leak back into the generator
+	backupValues := *lagoonBuild.BuildValues
+	repServices, err := dbaasReadReplicaServices(backupValues.Services)
+	if err != nil {
+		return nil, err
+	}
+	backupValues.Services = repServices
+	schedules, err := servicestemplates.GenerateBackupSchedule(backupValues)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	for idx := range schedules.K8upV1 {
+		objects = append(objects, &schedules.K8upV1[idx])
+	}
+	for idx := range schedules.K8upV1alpha1 {
+		objects = append(objects, &schedules.K8upV1alpha1[idx])
+	}
+	for idx := range schedules.Secrets {
+		objects = append(objects, &schedules.Secrets[idx])
+	}
+	pbps, err := servicestemplates.GeneratePreBackupPod(backupValues)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	for idx := range pbps {
+		objects = append(objects, &pbps[idx])
+	}
+	return objects, nil
+}
+
+func init() {
+	runCmd.AddCommand(deployCmd)
+	deployCmd.Flags().Bool("dry-run", false, "flag to perform a server-side dry run of the apply")
+	deployCmd.Flags().Bool("json", false, "flag to output the apply results in JSON")
+}
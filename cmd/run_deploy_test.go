@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/uselagoon/build-deploy-tool/internal/dbaasclient"
+	"github.com/uselagoon/build-deploy-tool/internal/deploy"
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/k8s"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"github.com/uselagoon/build-deploy-tool/internal/testdata"
+
+	// changes the testing to source from root so paths to test resources must be defined from repo root
+	_ "github.com/uselagoon/build-deploy-tool/internal/testing"
+)
+
+func TestDeployObjectGeneration(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       testdata.TestData
+		namespace  string
+		want       []deploy.Result
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "test1 - basic deployment",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/basic/lagoon.yml",
+					ImageReferences: map[string]string{
+						"node": "harbor.example/example-project/main/node@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+					},
+				}, true),
+			namespace: "example-project-main",
+			want: []deploy.Result{
+				{Kind: "Service", Name: "node", Action: "created"},
+				{Kind: "Deployment", Name: "node", Action: "created"},
+				{Kind: "Ingress", Name: "node", Action: "created"},
+				{Kind: "Ingress", Name: "example.com", Action: "created"},
+			},
+		},
+		{
+			name: "test2 - gateway api deployment",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/basic/lagoon.yml",
+					ImageReferences: map[string]string{
+						"node": "harbor.example/example-project/main/node@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+					},
+					ProjectVariables: []lagoon.EnvironmentVariable{
+						{Name: "LAGOON_FEATURE_FLAG_GATEWAY_API", Value: "enabled", Scope: "build"},
+						{Name: "LAGOON_FEATURE_FLAG_GATEWAY_API_PARENT", Value: "lagoon-gateway/lagoon", Scope: "build"},
+						{Name: "LAGOON_FEATURE_FLAG_GATEWAY_API_CERTIFICATE_ISSUER", Value: "lagoon-acme", Scope: "build"},
+					},
+				}, true),
+			namespace: "example-project-main",
+			want: []deploy.Result{
+				{Kind: "Service", Name: "node", Action: "created"},
+				{Kind: "Deployment", Name: "node", Action: "created"},
+				{Kind: "Certificate", Name: "node-tls", Action: "created"},
+				{Kind: "Certificate", Name: "example.com-tls", Action: "created"},
+				{Kind: "ReferenceGrant", Name: "node-tls", Action: "created"},
+				{Kind: "ReferenceGrant", Name: "example.com-tls", Action: "created"},
+				{Kind: "HTTPRoute", Name: "node", Action: "created"},
+				{Kind: "HTTPRoute", Name: "example.com", Action: "created"},
+				{Kind: "HTTPRoute", Name: "example.com-redirect", Action: "created"},
+			},
+		},
+		{
+			name: "test3 - deployment that violates the policy",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/basic/lagoon.yml",
+					ImageReferences: map[string]string{
+						"node": "harbor.example/example-project/main/node@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+					},
+					BuildPodVariables: []helpers.EnvironmentVariable{
+						{Name: "LAGOON_POLICY_FILE", Value: "internal/testdata/basic/policy-error.yml"},
+					},
+				}, true),
+			namespace:  "example-project-main",
+			wantErr:    true,
+			wantErrMsg: "generated templates violate the cluster policy",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			helpers.UnsetEnvVars(nil) //unset variables before running tests
+			// set the environment variables from args
+			savedTemplates, err := os.MkdirTemp("", "testoutput")
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			generator, err := testdata.SetupEnvironment(generator.GeneratorInput{}, savedTemplates, tt.args)
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			defer os.RemoveAll(savedTemplates)
+
+			ts := dbaasclient.TestDBaaSHTTPServer()
+			defer ts.Close()
+			err = os.Setenv("DBAAS_OPERATOR_HTTP", ts.URL)
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+
+			t.Cleanup(func() {
+				helpers.UnsetEnvVars(tt.args.BuildPodVariables)
+			})
+			objects, err := DeployObjectGeneration(generator)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DeployObjectGeneration() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), tt.wantErrMsg) {
+					t.Errorf("DeployObjectGeneration() error = %v, wantErr %v", err, tt.wantErrMsg)
+				}
+				if objects != nil {
+					t.Errorf("DeployObjectGeneration() = %v, want no objects", objects)
+				}
+				return
+			}
+			client, err := k8s.NewFakeClient(tt.namespace)
+			if err != nil {
+				t.Errorf("error creating fake client")
+			}
+			got, err := deploy.Apply(context.Background(), client, tt.namespace, objects, false)
+			if err != nil {
+				t.Errorf("Apply() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DeployObjectGeneration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
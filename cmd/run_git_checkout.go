@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/uselagoon/build-deploy-tool/internal/buildlog"
+	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/gitcheckout"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+)
+
+var gitCheckoutCmd = &cobra.Command{
+	Use:     "git-checkout",
+	Aliases: []string{"gco"},
+	Short:   "Check out the source repository of a Lagoon build",
+	Long: `Check out the source repository of a Lagoon build
+For branch and promote builds the ref is checked out, for pullrequest builds the head sha is merged into the base sha.
+SSH repositories use the SSH_PRIVATE_KEY of the build, and http or https repositories use any credentials defined
+by the GITREPO_ build variables`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		directory, err := cmd.Flags().GetString("directory")
+		if err != nil {
+			return fmt.Errorf("error reading directory flag: %v", err)
+		}
+		depth, err := cmd.Flags().GetInt("depth")
+		if err != nil {
+			return fmt.Errorf("error reading depth flag: %v", err)
+		}
+		submodules, err := cmd.Flags().GetBool("submodules")
+		if err != nil {
+			return fmt.Errorf("error reading submodules flag: %v", err)
+		}
+		ref, err := cmd.Flags().GetString("ref")
+		if err != nil {
+			return fmt.Errorf("error reading ref flag: %v", err)
+		}
+		opts, err := GitCheckoutOptions(ref)
+		if err != nil {
+			return err
+		}
+		opts.Directory = directory
+		opts.Depth = depth
+		opts.Submodules = submodules
+		opts.Out = os.Stdout
+		sha, err := gitcheckout.Checkout(context.Background(), opts)
+		if err != nil {
+			var mergeErr *gitcheckout.MergeConflictError
+			if errors.As(err, &mergeErr) {
+				fmt.Printf("Unable to merge %s into %s, the following files have conflicts:\n", mergeErr.HeadSHA, mergeErr.BaseSHA)
+				for _, c := range mergeErr.Conflicts {
+					fmt.Printf("  %s: %s\n", c.Path, c.Reason)
+				}
+			}
+			return err
+		}
+		fmt.Printf("Checked out %s\n", sha)
+		buildlog.Decide(cmd.CommandPath(), "checked out", map[string]string{"ref": opts.Ref, "sha": sha, "buildType": opts.BuildType})
+		return nil
+	},
+}
+
+// GitCheckoutOptions returns the checkout options from the build pod environment variables
+func GitCheckoutOptions(ref string) (gitcheckout.Options, error) {
+	// because this runs before anything has been checked out in the repo, we have to query variables directly
+	opts := gitcheckout.Options{
+		Repository:    helpers.GetEnv("SOURCE_REPOSITORY", "", false),
+		BuildType:     helpers.GetEnv("BUILD_TYPE", "", false),
+		PRHeadSHA:     helpers.GetEnv("PR_HEAD_SHA", "", false),
+		PRBaseSHA:     helpers.GetEnv("PR_BASE_SHA", "", false),
+		SSHPrivateKey: helpers.GetEnv("SSH_PRIVATE_KEY", "", false),
+	}
+	if ref == "" {
+		ref = helpers.GetEnv("GIT_REF", "", false)
+	}
+	if ref == "" {
+		if branch := helpers.GetEnv("BRANCH", "", false); branch != "" {
+			ref = fmt.Sprintf("origin/%s", branch)
+		}
+	}
+	opts.Ref = ref
+	if opts.BuildType != "pullrequest" && opts.Ref == "" {
+		return opts, fmt.Errorf("no ref to check out, set GIT_REF or BRANCH")
+	}
+	if strings.HasPrefix(opts.Repository, "http://") || strings.HasPrefix(opts.Repository, "https://") {
+		urls, err := configureGitCredentials(generator.GetLagoonEnvVars())
+		if err != nil {
+			return opts, err
+		}
+		opts.Credentials = urls
+	}
+	return opts, nil
+}
+
+func init() {
+	runCmd.AddCommand(gitCheckoutCmd)
+	gitCheckoutCmd.Flags().String("directory", ".", "The directory to check the repository out into")
+	gitCheckoutCmd.Flags().Int("depth", 10, "The number of commits to fetch for branch and promote builds, 0 fetches the full history")
+	gitCheckoutCmd.Flags().Bool("submodules", true, "Initialise and update any submodules")
+	gitCheckoutCmd.Flags().String("ref", "", "The branch (as origin/<branch>), tag or sha to check out, defaults to GIT_REF or origin/BRANCH")
+}
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/uselagoon/build-deploy-tool/internal/hooks"
@@ -17,17 +16,18 @@ var runHookCmd = &cobra.Command{
 		hookName, err := cmd.Flags().GetString("hook-name")
 		if err != nil {
 			fmt.Printf("error reading hook-name flag: %v\n", err)
-			os.Exit(1)
+			exitCommand(cmd, 1, err)
 		}
 		hookDir, err := cmd.Flags().GetString("hook-directory")
 		if err != nil {
 			fmt.Printf("error reading hook-directory flag: %v\n", err)
-			os.Exit(1)
+			exitCommand(cmd, 1, err)
 		}
 		dir := fmt.Sprintf("/kubectl-build-deploy/hooks/%s", hookDir)
 		err = hooks.RunHooks(hookName, dir)
 		if err != nil {
-			os.Exit(1)
+			// the failed hook is already recorded as an error event of the hook step
+			exitCommand(cmd, 1, nil)
 		}
 	},
 }
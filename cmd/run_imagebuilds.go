@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/uselagoon/build-deploy-tool/internal/buildlog"
+	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/imagebuild"
+	"sigs.k8s.io/yaml"
+)
+
+var runImageBuilds = &cobra.Command{
+	Use:     "image-builds",
+	Aliases: []string{"image-build", "img-build", "ib"},
+	Short:   "Build and push the images for a Lagoon build",
+	Long: `Build and push the images for a Lagoon build
+This uses the same configuration as 'identify image-builds' to build, or pull, all the images for the build
+and push them to the registry. The resulting image references are written to the images file that is used
+by 'template lagoon-services --images'`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		imagesOutput, err := cmd.Flags().GetString("images-output")
+		if err != nil {
+			return fmt.Errorf("error reading images-output flag: %v", err)
+		}
+		parallel, err := cmd.Flags().GetInt("parallel")
+		if err != nil {
+			return fmt.Errorf("error reading parallel flag: %v", err)
+		}
+		pushRetries, err := cmd.Flags().GetInt("push-retries")
+		if err != nil {
+			return fmt.Errorf("error reading push-retries flag: %v", err)
+		}
+		gen, err := GenerateInput(*rootCmd, false)
+		if err != nil {
+			return err
+		}
+		images, err := RunImageBuilds(gen, imagebuild.NewDockerBuilder(), imagesOutput, parallel, pushRetries)
+		for _, image := range slices.Sorted(maps.Keys(images)) {
+			buildlog.Decide(cmd.CommandPath(), "pushed", map[string]string{"image": image, "reference": images[image]})
+		}
+		return err
+	},
+}
+
+// RunImageBuilds builds and pushes the images using the image build configuration identified for the build
+// and writes the resulting image references to the images file
+func RunImageBuilds(g generator.GeneratorInput, builder imagebuild.Builder, imagesOutput string, parallel, pushRetries int) (map[string]string, error) {
+	ib, err := ImageBuildConfigurationIdentification(g)
+	if err != nil {
+		return nil, err
+	}
+	config := imagebuild.Config{
+		BuildType:           helpers.GetEnv("BUILD_TYPE", g.BuildType, g.Debug),
+		BuildKit:            ib.BuildKit,
+		BuildArguments:      ib.BuildArguments,
+		ContainerRegistries: ib.ContainerRegistries,
+		ForcePullImages:     ib.ForcePullImages,
+		Parallel:            parallel,
+		PushRetries:         pushRetries,
+		Out:                 os.Stdout,
+	}
+	for _, image := range ib.Images {
+		config.Images = append(config.Images, imagebuild.Image{
+			Name:       image.Name,
+			ImageBuild: image.ImageBuild,
+		})
+	}
+	images, err := imagebuild.Run(context.Background(), builder, config)
+	if err != nil {
+		return nil, err
+	}
+	imageRefs := ImageReferences{Images: images}
+	iBytes, err := yaml.Marshal(imageRefs)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate images file: %v", err)
+	}
+	if err := os.WriteFile(imagesOutput, iBytes, 0644); err != nil {
+		return nil, fmt.Errorf("couldn't write file %v: %v", imagesOutput, err)
+	}
+	return images, nil
+}
+
+func init() {
+	runCmd.AddCommand(runImageBuilds)
+	runImageBuilds.Flags().String("images-output", "/kubectl-build-deploy/images.yaml", "the path to write the images file to")
+	runImageBuilds.Flags().Int("parallel", 4, "the number of images to build or push at the same time")
+	runImageBuilds.Flags().Int("push-retries", 4, "the number of times to attempt to push an image")
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/imagebuild"
+	"github.com/uselagoon/build-deploy-tool/internal/testdata"
+
+	// changes the testing to source from root so paths to test resources must be defined from repo root
+	_ "github.com/uselagoon/build-deploy-tool/internal/testing"
+)
+
+func TestRunImageBuilds(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      testdata.TestData
+		want      map[string]string
+		wantCalls []string
+		wantErr   bool
+	}{
+		{
+			name: "test1 basic deployment",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					Namespace:       "example-project-main",
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/basic/lagoon.yml",
+				}, true),
+			want: map[string]string{
+				"node": "harbor.example/example-project/main/node@sha256:ac5e97520f626ad10924faf80b57d182a5a2fe07d05ec534371ea40c67d3f1a2",
+			},
+			wantCalls: []string{
+				"build example-project-main-node",
+				"push example-project-main-node harbor.example/example-project/main/node:latest",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			helpers.UnsetEnvVars(nil) //unset variables before running tests
+			// set the environment variables from args
+			savedTemplates, err := os.MkdirTemp("", "testoutput")
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			generator, err := testdata.SetupEnvironment(generator.GeneratorInput{}, savedTemplates, tt.args)
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			defer os.RemoveAll(savedTemplates)
+
+			imagesFile := filepath.Join(savedTemplates, "images.yaml")
+			b := &imagebuild.FakeBuilder{}
+			if _, err := RunImageBuilds(generator, b, imagesFile, 1, 1); (err != nil) != tt.wantErr {
+				t.Errorf("RunImageBuilds() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(b.Calls, tt.wantCalls) {
+				t.Errorf("RunImageBuilds() calls = %v, want %v", b.Calls, tt.wantCalls)
+			}
+			// the images file must be readable by the commands that consume it
+			got, err := loadImagesFromFile(imagesFile)
+			if err != nil {
+				t.Errorf("%v", err)
+				return
+			}
+			if !reflect.DeepEqual(got.Images, tt.want) {
+				t.Errorf("RunImageBuilds() images = %v, want %v", got.Images, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/uselagoon/build-deploy-tool/internal/buildlog"
+	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/k8s"
+	"github.com/uselagoon/build-deploy-tool/internal/rollout"
+	servicestemplates "github.com/uselagoon/build-deploy-tool/internal/templating"
+)
+
+const (
+	// the exit code used when a deployment fails to roll out
+	rolloutFailedExitCode = 1
+	// the exit code used when the deployments don't roll out before the timeout
+	rolloutTimeoutExitCode = 2
+)
+
+var monitorRolloutCmd = &cobra.Command{
+	Use:     "monitor-rollout",
+	Aliases: []string{"monitor", "mr"},
+	Short:   "Monitor the rollout of the deployments for a Lagoon build",
+	Long: `Monitor the rollout of the deployments for a Lagoon build
+This will watch the deployments generated for the build until they have rolled out, reporting any pod issues
+like ImagePullBackOff, CrashLoopBackOff or failed probes along with the last log lines of the containers.
+Exits with code 1 if a deployment fails, or code 2 if the deployments don't roll out before the timeout`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			return fmt.Errorf("error reading timeout flag: %v", err)
+		}
+		interval, err := cmd.Flags().GetDuration("poll-interval")
+		if err != nil {
+			return fmt.Errorf("error reading poll-interval flag: %v", err)
+		}
+		if interval <= 0 {
+			return fmt.Errorf("the poll-interval must be greater than 0")
+		}
+		logLines, err := cmd.Flags().GetInt64("log-lines")
+		if err != nil {
+			return fmt.Errorf("error reading log-lines flag: %v", err)
+		}
+		services, err := cmd.Flags().GetStringSlice("service")
+		if err != nil {
+			return fmt.Errorf("error reading service flag: %v", err)
+		}
+		gen, err := GenerateInput(*rootCmd, false)
+		if err != nil {
+			return err
+		}
+		images, err := rootCmd.PersistentFlags().GetString("images")
+		if err != nil {
+			return fmt.Errorf("error reading images flag: %v", err)
+		}
+		imageRefs, err := loadImagesFromFile(images)
+		if err != nil {
+			return err
+		}
+		namespace := helpers.GetEnv("NAMESPACE", "", false)
+		namespace, err = helpers.GetNamespace(namespace, "/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+		if err != nil {
+			return err
+		}
+		if namespace == "" {
+			return fmt.Errorf("unable to detect namespace")
+		}
+		gen.Namespace = namespace
+		gen.ImageReferences = imageRefs.Images
+		if len(services) == 0 {
+			services, err = RolloutServices(gen)
+			if err != nil {
+				return err
+			}
+		}
+		client, err := k8s.NewClient()
+		if err != nil {
+			return err
+		}
+		clientset, err := k8s.NewClientset()
+		if err != nil {
+			return err
+		}
+		m := rollout.Monitor{
+			Client:    client,
+			Logs:      rollout.ClientsetLogs{Clientset: clientset},
+			Namespace: namespace,
+			Timeout:   timeout,
+			Interval:  interval,
+			LogLines:  logLines,
+			Out:       os.Stdout,
+		}
+		statuses, err := m.Run(context.Background(), services)
+		for _, s := range statuses {
+			buildlog.Decide(cmd.CommandPath(), rolloutStatus(s), map[string]string{"service": s.Service, "message": s.Message, "issues": fmt.Sprintf("%d", len(s.Issues))})
+		}
+		switch {
+		case errors.Is(err, rollout.ErrRolloutTimeout):
+			fmt.Println(err.Error())
+			exitCommand(cmd, rolloutTimeoutExitCode, err)
+		case errors.Is(err, rollout.ErrRolloutFailed):
+			fmt.Println(err.Error())
+			exitCommand(cmd, rolloutFailedExitCode, err)
+		}
+		return err
+	},
+}
+
+func rolloutStatus(s rollout.ServiceStatus) string {
+	switch {
+	case s.Failed:
+		return "failed"
+	case s.Complete:
+		return "complete"
+	}
+	return "pending"
+}
+
+// RolloutServices returns the services that the build generates deployments for, these are the values of the `lagoon.sh/service`
+// label on the deployments that the monitor will watch
+func RolloutServices(g generator.GeneratorInput) ([]string, error) {
+	lagoonBuild, err := generator.NewGenerator(
+		g,
+	)
+	if err != nil {
+		return nil, err
+	}
+	deployments, err := servicestemplates.GenerateDeploymentTemplate(*lagoonBuild.BuildValues)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	var services []string
+	for _, d := range deployments {
+		services = append(services, d.Labels["lagoon.sh/service"])
+	}
+	return services, nil
+}
+
+func init() {
+	runCmd.AddCommand(monitorRolloutCmd)
+	monitorRolloutCmd.Flags().Duration("timeout", 20*time.Minute, "how long to wait for the deployments to roll out")
+	monitorRolloutCmd.Flags().Duration("poll-interval", 5*time.Second, "how often to check the status of the deployments")
+	monitorRolloutCmd.Flags().Int64("log-lines", 20, "the number of container log lines to show for a failed rollout")
+	monitorRolloutCmd.Flags().StringSlice("service", nil, "only monitor the deployments for these services, defaults to all services in the build")
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/uselagoon/build-deploy-tool/internal/buildlog"
+	"github.com/uselagoon/build-deploy-tool/internal/cleanup"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+)
+
+var routeCleanupCmd = &cobra.Command{
+	Use:     "route-cleanup",
+	Aliases: []string{"rc"},
+	Short:   "Cleanup routes and certificates that are no longer required",
+	Long: `Cleanup routes and certificates that are no longer required
+
+Removes autogenerated routes that are no longer generated, and routes that were removed from the .lagoon.yml or Lagoon API
+if the project has routes in the Lagoon API or 'LAGOON_FEATURE_FLAG_CLEANUP_REMOVED_LAGOON_ROUTES' is enabled.
+The certificates of removed routes, and of any ingress in the namespace that no longer use tls-acme, are removed. Secrets with
+certificates issued by cert-manager or Let's Encrypt are removed with the certificate of an ingress that no longer uses tls-acme,
+and with the certificate of a removed route only if --remove-route-secrets is set. Secrets with user supplied certificates are
+always retained.
+Without --delete nothing is removed and the report shows what would be removed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		performDeletion, err := cmd.Flags().GetBool("delete")
+		if err != nil {
+			return fmt.Errorf("error reading delete flag: %v", err)
+		}
+		removeRouteSecrets, err := cmd.Flags().GetBool("remove-route-secrets")
+		if err != nil {
+			return fmt.Errorf("error reading remove-route-secrets flag: %v", err)
+		}
+		jsonOutput, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return fmt.Errorf("error reading json flag: %v", err)
+		}
+		stateFile, err := cmd.Flags().GetString("state-file")
+		if err != nil {
+			return fmt.Errorf("error reading state-file flag: %v", err)
+		}
+		// deletions against a state file are only made to the copy of the state in memory
+		if performDeletion && stateFile != "" {
+			return fmt.Errorf("the delete flag can't be used with a state file, nothing would be removed from the environment")
+		}
+		gen, err := GenerateInput(*rootCmd, false)
+		if err != nil {
+			return err
+		}
+		namespace := helpers.GetEnv("NAMESPACE", "", false)
+		namespace, err = helpers.GetNamespace(namespace, "/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+		if err != nil {
+			return err
+		}
+		if namespace == "" {
+			return fmt.Errorf("unable to detect namespace")
+		}
+		col, err := newCollector(stateFile, namespace)
+		if err != nil {
+			return err
+		}
+		gen.Namespace = namespace
+		report, err := cleanup.RunRouteCleanup(col, gen, performDeletion, removeRouteSecrets)
+		if err != nil {
+			return err
+		}
+		for _, route := range report.Routes {
+			buildlog.Decide(cmd.CommandPath(), route.Action, map[string]string{"kind": route.Kind, "name": route.Name, "reason": route.Reason})
+		}
+		for _, cert := range report.Certificates {
+			fields := map[string]string{"route": cert.Route, "secret": cert.SecretName, "reason": cert.Reason, "policy": string(cert.Policy)}
+			if cert.ReferenceGrant != "" {
+				fields["referenceGrant"] = cert.ReferenceGrant
+			}
+			buildlog.Decide(cmd.CommandPath(), fmt.Sprintf("certificate %s, secret %s", cert.Certificate, cert.Secret), fields)
+		}
+		if jsonOutput {
+			reportJSON, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return fmt.Errorf("couldn't marshal route cleanup report: %v", err)
+			}
+			fmt.Println(string(reportJSON))
+			return nil
+		}
+		printRouteCleanupReport(report)
+		return nil
+	},
+}
+
+func printRouteCleanupReport(report *cleanup.RouteCleanupReport) {
+	if len(report.Routes) == 0 && len(report.Certificates) == 0 {
+		fmt.Println("No route cleanup required")
+		return
+	}
+	removedRoutes := false
+	for _, route := range report.Routes {
+		if route.Reason == cleanup.ReasonRouteRemoved {
+			removedRoutes = true
+		}
+	}
+	if removedRoutes {
+		fmt.Println(">> Lagoon detected routes that have been removed from the .lagoon.yml or Lagoon API")
+		switch {
+		case report.APIRoutesCleanup:
+			fmt.Println("> As this project has routes managed in the API, these routes will be cleaned up.")
+			fmt.Println("> If you need these routes, you should add them to the API.")
+		case report.RemovedRoutesCleanup:
+			fmt.Println("> If you need these routes, you should update your .lagoon.yml file and make sure the routes exist.")
+			fmt.Println("> 'LAGOON_FEATURE_FLAG_CLEANUP_REMOVED_LAGOON_ROUTES=enabled' is configured and the following routes will be removed.")
+			fmt.Println("> You should remove this variable if you don't want routes to be removed automatically")
+		default:
+			fmt.Println("> If you need these routes, you should update your .lagoon.yml file and make sure the routes exist.")
+			fmt.Println("> If you no longer need these routes, you can instruct Lagoon to remove it from the environment by setting the following variable")
+			fmt.Println("> 'LAGOON_FEATURE_FLAG_CLEANUP_REMOVED_LAGOON_ROUTES=enabled' as a BUILD scoped variable to this environment or project")
+			fmt.Println("> You should remove this variable after the deployment has been completed, otherwise future route removals will happen automatically")
+		}
+		if !report.APIRoutesCleanup {
+			fmt.Println("> Future releases of Lagoon may remove routes automatically, you should ensure that your routes are up always up to date if you see this warning")
+		}
+	}
+	for _, route := range report.Routes {
+		switch route.Action {
+		case cleanup.ActionRemoved:
+			fmt.Printf(">> Removed %s %s, the %s\n", route.Kind, route.Name, route.Reason)
+		case cleanup.ActionError:
+			fmt.Printf("!! Error removing %s %s: %s\n", route.Kind, route.Name, route.Error)
+		default:
+			fmt.Printf("> The %s %s would be removed, the %s\n", route.Kind, route.Name, route.Reason)
+		}
+	}
+	for _, cert := range report.Certificates {
+		policy := ""
+		if cert.Policy != "" {
+			policy = fmt.Sprintf(" (%s)", cert.Policy)
+		}
+		referenceGrant := ""
+		if cert.ReferenceGrant != "" {
+			referenceGrant = fmt.Sprintf(", referencegrant %s", cert.ReferenceGrant)
+		}
+		fmt.Printf(">> Certificate %s for %s, %s: certificate %s%s, secret %s%s\n", cert.SecretName, cert.Route, cert.Reason, cert.Certificate, referenceGrant, cert.Secret, policy)
+		if cert.Error != "" {
+			fmt.Printf("!! Error cleaning up certificate %s: %s\n", cert.SecretName, cert.Error)
+		}
+	}
+}
+
+func init() {
+	runCmd.AddCommand(routeCleanupCmd)
+	routeCleanupCmd.Flags().Bool("delete", false, "flag to actually delete the routes and certificates")
+	routeCleanupCmd.Flags().Bool("remove-route-secrets", false, "flag to also delete the cert-manager or Let's Encrypt issued secrets of removed routes")
+	routeCleanupCmd.Flags().Bool("json", false, "flag to output the route cleanup report in JSON")
+	routeCleanupCmd.Flags().String("state-file", "", "the path to a file from 'collect environment' to use instead of the cluster")
+}
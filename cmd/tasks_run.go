@@ -4,9 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/uselagoon/build-deploy-tool/internal/buildlog"
 	"github.com/uselagoon/build-deploy-tool/internal/generator"
 	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
 	"github.com/uselagoon/build-deploy-tool/internal/tasklib"
@@ -64,13 +64,13 @@ var tasksPreRun = &cobra.Command{
 		taskIterator, err := iterateTaskGenerator(true, unidleThenRun, buildValues, "Pre-Rollout", true)
 		if err != nil {
 			fmt.Println("Pre-rollout Tasks Failed with the following error: ", err.Error())
-			os.Exit(1)
+			exitCommand(cmd, 1, err)
 		}
 
 		err = runTasks(taskIterator, buildValues.LagoonYAML.Tasks.Prerollout, lagoonConditionalEvaluationEnvironment)
 		if err != nil {
 			fmt.Println("Pre-rollout Tasks Failed with the following error: ", err.Error())
-			os.Exit(1)
+			exitCommand(cmd, 1, err)
 		}
 		fmt.Println("Pre-rollout Tasks Complete")
 		return nil
@@ -96,12 +96,12 @@ var tasksPostRun = &cobra.Command{
 		taskIterator, err := iterateTaskGenerator(false, runCleanTaskInEnvironment, buildValues, "Post-Rollout", true)
 		if err != nil {
 			fmt.Println("Pre-rollout Tasks Failed with the following error: ", err.Error())
-			os.Exit(1)
+			exitCommand(cmd, 1, err)
 		}
 		err = runTasks(taskIterator, buildValues.LagoonYAML.Tasks.Postrollout, lagoonConditionalEvaluationEnvironment)
 		if err != nil {
 			fmt.Println("Post-rollout Tasks Failed with the following error: ", err.Error())
-			os.Exit(1)
+			exitCommand(cmd, 1, err)
 		}
 		fmt.Println("Post-rollout Tasks Complete")
 		return nil
@@ -166,6 +166,10 @@ func iterateTaskGenerator(allowDeployMissingErrors bool, taskRunner runTaskInEnv
 			if task.ScaleWaitTime == 0 {
 				task.ScaleWaitTime = buildValues.TaskScaleWaitTime
 			}
+			policy, err := task.Policy()
+			if err != nil {
+				return true, err
+			}
 			runTask, err := evaluateWhenConditionsForTaskInEnvironment(lagoonConditionalEvaluationEnvironment, task, debug)
 			if err != nil {
 				return true, err
@@ -173,17 +177,23 @@ func iterateTaskGenerator(allowDeployMissingErrors bool, taskRunner runTaskInEnv
 			if runTask {
 				err := taskRunner(buildValues.Namespace, prePost, task)
 				if err != nil {
-					switch e := err.(type) {
-					case *lagoon.DeploymentMissingError:
-						if allowDeployMissingErrors {
-							if debug {
-								fmt.Println("No running deployment found, skipping")
-							}
-						} else {
-							return true, e
+					if _, ok := err.(*lagoon.DeploymentMissingError); ok && allowDeployMissingErrors {
+						if debug {
+							fmt.Println("No running deployment found, skipping")
+						}
+						continue
+					}
+					// the onFailure of the task decides if a failed task stops the build
+					switch policy.OnFailure {
+					case lagoon.TaskOnFailureContinue:
+						fmt.Printf("Task `%v` failed, continuing as the task is defined with onFailure: %s\n", task.Name, policy.OnFailure)
+					case lagoon.TaskOnFailureWarn:
+						fmt.Printf("Task `%v` failed, continuing with a warning as the task is defined with onFailure: %s\n", task.Name, policy.OnFailure)
+						if err := buildlog.Warn(fmt.Sprintf("%s %s", prePost, task.Name), fmt.Sprintf("%s:%s", prePost, task.Name)); err != nil {
+							return true, err
 						}
 					default:
-						return true, e
+						return true, err
 					}
 				}
 			} else {
@@ -240,6 +250,10 @@ func runCleanTaskInEnvironment(namespace string, prePost string, incoming lagoon
 	task.Name = incoming.Name
 	task.ScaleMaxIterations = incoming.ScaleMaxIterations
 	task.ScaleWaitTime = incoming.ScaleWaitTime
+	task.Timeout = incoming.Timeout
+	task.Retries = incoming.Retries
+	task.RetryDelay = incoming.RetryDelay
+	task.OnFailure = incoming.OnFailure
 	err := lagoon.ExecuteTaskInEnvironment(task, prePost)
 	return err
 }
@@ -2,8 +2,12 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/uselagoon/build-deploy-tool/internal/buildlog"
 	"github.com/uselagoon/build-deploy-tool/internal/generator"
 	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
 	"github.com/uselagoon/build-deploy-tool/internal/tasklib"
@@ -161,11 +165,12 @@ func Test_iterateTaskGenerator(t *testing.T) {
 		buildValues              generator.BuildValues
 	}
 	tests := []struct {
-		name      string
-		debug     bool
-		prePost   string
-		args      args
-		wantError bool
+		name         string
+		debug        bool
+		prePost      string
+		args         args
+		wantError    bool
+		wantWarnings string
 	}{
 		{name: "Runs with no errors",
 			args: args{
@@ -223,9 +228,57 @@ func Test_iterateTaskGenerator(t *testing.T) {
 			prePost:   "PostRollout",
 			wantError: true,
 		},
+		{name: "Continues after a failed task with onFailure continue",
+			args: args{
+				allowDeployMissingErrors: false,
+				taskRunner: func(namespace string, prePost string, incoming lagoon.Task) error {
+					return &lagoon.TaskExitError{ExitCode: 1}
+				},
+				tasks: []lagoon.Task{
+					{Name: "drush cr", OnFailure: "continue"},
+				},
+				buildValues: generator.BuildValues{Namespace: "empty"},
+			},
+			prePost:   "PostRollout",
+			wantError: false,
+		},
+		{name: "Continues after a failed task with onFailure warn and records the warning",
+			args: args{
+				allowDeployMissingErrors: false,
+				taskRunner: func(namespace string, prePost string, incoming lagoon.Task) error {
+					return &lagoon.TaskExitError{ExitCode: 1}
+				},
+				tasks: []lagoon.Task{
+					{Name: "drush cr", OnFailure: "warn"},
+				},
+				buildValues: generator.BuildValues{Namespace: "empty"},
+			},
+			prePost:      "PostRollout",
+			wantError:    false,
+			wantWarnings: "PostRollout:drush cr\n",
+		},
+		{name: "Stops with an invalid task policy",
+			args: args{
+				allowDeployMissingErrors: true,
+				taskRunner: func(namespace string, prePost string, incoming lagoon.Task) error {
+					return nil
+				},
+				tasks: []lagoon.Task{
+					{Name: "drush cr", Timeout: "forever"},
+				},
+				buildValues: generator.BuildValues{Namespace: "empty"},
+			},
+			prePost:   "PreRollout",
+			wantError: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			warnings := filepath.Join(t.TempDir(), "warnings")
+			logger := buildlog.New(io.Discard, nil)
+			logger.SetWarningsFile(warnings)
+			buildlog.SetDefault(logger)
+			defer buildlog.SetDefault(buildlog.New(os.Stdout, nil))
 			got, _ := iterateTaskGenerator(tt.args.allowDeployMissingErrors, tt.args.taskRunner, tt.args.buildValues, tt.prePost, tt.debug)
 			_, err := got(tasklib.TaskEnvironment{}, tt.args.tasks)
 
@@ -236,6 +289,11 @@ func Test_iterateTaskGenerator(t *testing.T) {
 			if !tt.wantError && err != nil {
 				t.Errorf("No error expected")
 			}
+
+			wBytes, _ := os.ReadFile(warnings)
+			if string(wBytes) != tt.wantWarnings {
+				t.Errorf("warnings = %q, want %q", string(wBytes), tt.wantWarnings)
+			}
 		})
 	}
 }
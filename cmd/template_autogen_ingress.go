@@ -5,8 +5,6 @@ import (
 
 	"github.com/spf13/cobra"
 	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
-	"github.com/uselagoon/build-deploy-tool/internal/helpers"
-	servicestemplates "github.com/uselagoon/build-deploy-tool/internal/templating"
 )
 
 var autogenRouteGeneration = &cobra.Command{
@@ -31,24 +29,21 @@ func AutogeneratedIngressGeneration(g generator.GeneratorInput) error {
 		return err
 	}
 	savedTemplates := g.SavedTemplatesPath
+	templates := newPolicyTemplates(lagoonBuild.BuildValues)
 	// generate the templates
 	for _, route := range lagoonBuild.AutogeneratedRoutes.Routes {
 		// autogenerated routes use the `servicename` as the name of the ingress resource, use `IngressName` in routev2 to handle this
 		if g.Debug {
 			fmt.Printf("Templating autogenerated ingress manifest for %s to %s\n", route.Domain, fmt.Sprintf("%s/%s.yaml", savedTemplates, route.LagoonService))
 		}
-		ingress, err := servicestemplates.GenerateIngressTemplate(route, *lagoonBuild.BuildValues)
+		templateYAML, objects, err := generateRouteTemplate(route, *lagoonBuild.BuildValues)
 		if err != nil {
-			return fmt.Errorf("couldn't generate template: %v", err)
-		}
-		templateYAML, err := servicestemplates.TemplateIngress(ingress)
-		if err != nil {
-			return fmt.Errorf("couldn't generate template: %v", err)
+			return err
 		}
-		helpers.WriteTemplateFile(fmt.Sprintf("%s/%s.yaml", savedTemplates, route.LagoonService), templateYAML)
+		templates.add(fmt.Sprintf("%s/%s.yaml", savedTemplates, route.LagoonService), templateYAML, objects...)
 	}
 
-	return nil
+	return templates.write()
 }
 
 func init() {
@@ -6,7 +6,6 @@ import (
 
 	"github.com/spf13/cobra"
 	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
-	"github.com/uselagoon/build-deploy-tool/internal/helpers"
 	servicestemplates "github.com/uselagoon/build-deploy-tool/internal/templating"
 	"sigs.k8s.io/yaml"
 )
@@ -40,28 +39,11 @@ func BackupTemplateGeneration(g generator.GeneratorInput) error {
 		return err
 	}
 	savedTemplates := g.SavedTemplatesPath
+	templates := newPolicyTemplates(lagoonBuild.BuildValues)
 
-	// TODO: the dbaas consumers aren't known when the generator runs currently
-	// so this is a small helper function to collect this from the build stage
-	// this will eventually need to be collected directly by the generator or some other component
-	// of the generator in the future, but since backups are the only thing that need to know this at this stage
-	repServices := []generator.ServiceValues{}
-	for _, s := range lagoonBuild.BuildValues.Services {
-		rawYAML, err := os.ReadFile(fmt.Sprintf("/kubectl-build-deploy/%s-values.yaml", s.Name))
-		if err != nil {
-			repServices = append(repServices, s)
-			// skip this one
-			continue
-		}
-		dbaasValues := &readReplicaValues{}
-		err = yaml.Unmarshal(rawYAML, dbaasValues)
-		if err != nil {
-			return fmt.Errorf("couldn't read %v: %v", fmt.Sprintf("/kubectl-build-deploy/%s-values.yaml", s.Name), err)
-		}
-		if dbaasValues.ReadReplicaHosts != "" {
-			s.DBaasReadReplica = true
-		}
-		repServices = append(repServices, s)
+	repServices, err := dbaasReadReplicaServices(lagoonBuild.BuildValues.Services)
+	if err != nil {
+		return err
 	}
 	lagoonBuild.BuildValues.Services = repServices
 
@@ -75,7 +57,7 @@ func BackupTemplateGeneration(g generator.GeneratorInput) error {
 		return fmt.Errorf("couldn't generate template: %v", err)
 	}
 	if len(templateYAML) > 0 {
-		helpers.WriteTemplateFile(fmt.Sprintf("%s/%s.yaml", savedTemplates, "k8up-lagoon-backup-schedule"), templateYAML)
+		templates.add(fmt.Sprintf("%s/%s.yaml", savedTemplates, "k8up-lagoon-backup-schedule"), templateYAML, scheduleObjects(schedules)...)
 	}
 	// generate any prebackuppod templates
 	pbps, err := servicestemplates.GeneratePreBackupPod(*lagoonBuild.BuildValues)
@@ -87,9 +69,35 @@ func BackupTemplateGeneration(g generator.GeneratorInput) error {
 		return fmt.Errorf("couldn't generate template: %v", err)
 	}
 	if len(templateYAML) > 0 {
-		helpers.WriteTemplateFile(fmt.Sprintf("%s/%s.yaml", savedTemplates, "prebackuppods"), templateYAML)
+		templates.add(fmt.Sprintf("%s/%s.yaml", savedTemplates, "prebackuppods"), templateYAML, preBackupPodObjects(pbps)...)
+	}
+	return templates.write()
+}
+
+// TODO: the dbaas consumers aren't known when the generator runs currently
+// so this is a small helper function to collect this from the build stage
+// this will eventually need to be collected directly by the generator or some other component
+// of the generator in the future, but since backups are the only thing that need to know this at this stage
+func dbaasReadReplicaServices(services []generator.ServiceValues) ([]generator.ServiceValues, error) {
+	repServices := []generator.ServiceValues{}
+	for _, s := range services {
+		rawYAML, err := os.ReadFile(fmt.Sprintf("/kubectl-build-deploy/%s-values.yaml", s.Name))
+		if err != nil {
+			repServices = append(repServices, s)
+			// skip this one
+			continue
+		}
+		dbaasValues := &readReplicaValues{}
+		err = yaml.Unmarshal(rawYAML, dbaasValues)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read %v: %v", fmt.Sprintf("/kubectl-build-deploy/%s-values.yaml", s.Name), err)
+		}
+		if dbaasValues.ReadReplicaHosts != "" {
+			s.DBaasReadReplica = true
+		}
+		repServices = append(repServices, s)
 	}
-	return nil
+	return repServices, nil
 }
 
 func init() {
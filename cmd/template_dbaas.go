@@ -5,7 +5,6 @@ import (
 
 	"github.com/spf13/cobra"
 	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
-	"github.com/uselagoon/build-deploy-tool/internal/helpers"
 	servicestemplates "github.com/uselagoon/build-deploy-tool/internal/templating"
 )
 
@@ -32,6 +31,7 @@ func DBaaSTemplateGeneration(g generator.GeneratorInput,
 		return err
 	}
 	savedTemplates := g.SavedTemplatesPath
+	templates := newPolicyTemplates(lagoonBuild.BuildValues)
 
 	dbaas, err := servicestemplates.GenerateDBaaSTemplate(*lagoonBuild.BuildValues)
 	if err != nil {
@@ -42,12 +42,12 @@ func DBaaSTemplateGeneration(g generator.GeneratorInput,
 		return fmt.Errorf("couldn't generate template: %v", err)
 	}
 	if len(templateYAML) > 0 {
-		helpers.WriteTemplateFile(fmt.Sprintf("%s/%s.yaml", savedTemplates, "dbaas"), templateYAML)
+		templates.add(fmt.Sprintf("%s/%s.yaml", savedTemplates, "dbaas"), templateYAML, dbaasObjects(dbaas)...)
 		if g.Debug {
 			fmt.Printf("Templating dbaas consumers to %s\n", fmt.Sprintf("%s/%s.yaml", savedTemplates, "dbaas"))
 		}
 	}
-	return nil
+	return templates.write()
 }
 
 func init() {
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/uselagoon/build-deploy-tool/internal/collector"
+	"github.com/uselagoon/build-deploy-tool/internal/deploy"
+	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/k8s"
+)
+
+// the exit code used when there are changes, errors exit with 1 so that they can be told apart
+const templateDiffChangesExitCode = 2
+
+var templateDiff = &cobra.Command{
+	Use:     "diff",
+	Aliases: []string{"df"},
+	Short:   "Show the changes a Lagoon build would make to the environment",
+	Long: `Show the changes a Lagoon build would make to the environment
+This generates the same resources as 'run deploy' and compares them against the resources in the environment.
+Fields that are populated by the api, and the lagoon.sh/version and lagoon.sh/configMapSha labels and annotations are ignored.
+If there are any changes the command will exit with code 2`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return fmt.Errorf("error reading json flag: %v", err)
+		}
+		gen, err := GenerateInput(*rootCmd, false)
+		if err != nil {
+			return err
+		}
+		images, err := rootCmd.PersistentFlags().GetString("images")
+		if err != nil {
+			return fmt.Errorf("error reading images flag: %v", err)
+		}
+		imageRefs, err := loadImagesFromFile(images)
+		if err != nil {
+			return err
+		}
+		namespace := helpers.GetEnv("NAMESPACE", "", false)
+		namespace, err = helpers.GetNamespace(namespace, "/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+		if err != nil {
+			return err
+		}
+		if namespace == "" {
+			return fmt.Errorf("unable to detect namespace")
+		}
+		gen.Namespace = namespace
+		gen.ImageReferences = imageRefs.Images
+		client, err := k8s.NewClient()
+		if err != nil {
+			return err
+		}
+		changes, err := TemplateDiff(collector.NewCollector(client), gen)
+		if err != nil {
+			return err
+		}
+		if outputJSON {
+			cBytes, err := json.Marshal(changes)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(cBytes))
+		} else {
+			for _, c := range changes {
+				if c.Diff != "" {
+					fmt.Print(c.Diff)
+				}
+			}
+			for _, c := range changes {
+				fmt.Println(c.String())
+			}
+		}
+		if deploy.Changed(changes) {
+			exitCommand(cmd, templateDiffChangesExitCode, nil)
+		}
+		return nil
+	},
+}
+
+// TemplateDiff generates the resources for the build and compares them against the resources collected from the environment
+func TemplateDiff(c *collector.Collector, g generator.GeneratorInput) ([]deploy.Change, error) {
+	objects, err := DeployObjectGeneration(g)
+	if err != nil {
+		return nil, err
+	}
+	state, err := c.Collect(context.Background(), g.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	return deploy.Diff(c.Client.Scheme(), state, objects)
+}
+
+func init() {
+	templateCmd.AddCommand(templateDiff)
+	templateDiff.Flags().Bool("json", false, "flag to output the changes in JSON")
+}
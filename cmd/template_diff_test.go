@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/uselagoon/build-deploy-tool/internal/collector"
+	"github.com/uselagoon/build-deploy-tool/internal/dbaasclient"
+	"github.com/uselagoon/build-deploy-tool/internal/deploy"
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/k8s"
+	"github.com/uselagoon/build-deploy-tool/internal/testdata"
+
+	// changes the testing to source from root so paths to test resources must be defined from repo root
+	_ "github.com/uselagoon/build-deploy-tool/internal/testing"
+)
+
+func TestTemplateDiff(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      testdata.TestData
+		deployed  testdata.TestData
+		namespace string
+		want      []string
+	}{
+		{
+			name: "test1 - new image and lagoon version",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonVersion:   "v2.21.0",
+					LagoonYAML:      "internal/testdata/basic/lagoon.yml",
+					ImageReferences: map[string]string{
+						"node": "harbor.example/example-project/main/node@sha256:e90daba405cbf33bab23fe8a021146811b2c258df5f2afe7dadc92c0778eef45",
+					},
+				}, true),
+			deployed: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonVersion:   "v2.20.0",
+					LagoonYAML:      "internal/testdata/basic/lagoon.yml",
+					ImageReferences: map[string]string{
+						"node": "harbor.example/example-project/main/node@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+					},
+				}, true),
+			namespace: "example-project-main",
+			want: []string{
+				"Service/node unchanged",
+				"Deployment/node update",
+				"Ingress/node unchanged",
+				"Ingress/example.com unchanged",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			helpers.UnsetEnvVars(nil) //unset variables before running tests
+			// set the environment variables from args
+			savedTemplates, err := os.MkdirTemp("", "testoutput")
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			defer os.RemoveAll(savedTemplates)
+
+			ts := dbaasclient.TestDBaaSHTTPServer()
+			defer ts.Close()
+			err = os.Setenv("DBAAS_OPERATOR_HTTP", ts.URL)
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+
+			client, err := k8s.NewFakeClient(tt.namespace)
+			if err != nil {
+				t.Errorf("error creating fake client")
+			}
+			// deploy the previous build into the environment
+			deployed, err := testdata.SetupEnvironment(generator.GeneratorInput{}, savedTemplates, tt.deployed)
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			objects, err := DeployObjectGeneration(deployed)
+			if err != nil {
+				t.Errorf("DeployObjectGeneration() error = %v", err)
+			}
+			_, err = deploy.Apply(context.Background(), client, tt.namespace, objects, false)
+			if err != nil {
+				t.Errorf("Apply() error = %v", err)
+			}
+
+			helpers.UnsetEnvVars(nil)
+			generator, err := testdata.SetupEnvironment(generator.GeneratorInput{}, savedTemplates, tt.args)
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			generator.Namespace = tt.namespace
+			changes, err := TemplateDiff(collector.NewCollector(client), generator)
+			if err != nil {
+				t.Errorf("TemplateDiff() error = %v", err)
+				return
+			}
+			var got []string
+			for _, c := range changes {
+				got = append(got, c.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TemplateDiff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
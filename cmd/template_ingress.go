@@ -5,8 +5,9 @@ import (
 
 	"github.com/spf13/cobra"
 	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
-	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
 	servicestemplates "github.com/uselagoon/build-deploy-tool/internal/templating"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var routeGeneration = &cobra.Command{
@@ -31,20 +32,17 @@ func IngressTemplateGeneration(g generator.GeneratorInput) error {
 		return err
 	}
 	savedTemplates := g.SavedTemplatesPath
+	templates := newPolicyTemplates(lagoonBuild.BuildValues)
 	// generate the templates
 	for _, route := range lagoonBuild.MainRoutes.Routes {
 		if g.Debug {
 			fmt.Printf("Templating ingress manifest for %s to %s\n", route.Domain, fmt.Sprintf("%s/%s.yaml", savedTemplates, route.Domain))
 		}
-		ingress, err := servicestemplates.GenerateIngressTemplate(route, *lagoonBuild.BuildValues)
+		templateYAML, objects, err := generateRouteTemplate(route, *lagoonBuild.BuildValues)
 		if err != nil {
-			return fmt.Errorf("couldn't generate template: %v", err)
-		}
-		templateYAML, err := servicestemplates.TemplateIngress(ingress)
-		if err != nil {
-			return fmt.Errorf("couldn't generate template: %v", err)
+			return err
 		}
-		helpers.WriteTemplateFile(fmt.Sprintf("%s/%s.yaml", savedTemplates, route.Domain), templateYAML)
+		templates.add(fmt.Sprintf("%s/%s.yaml", savedTemplates, route.Domain), templateYAML, objects...)
 	}
 	if *lagoonBuild.ActiveEnvironment || *lagoonBuild.StandbyEnvironment {
 		// active/standby routes should not be changed by any environment defined routes.
@@ -56,18 +54,53 @@ func IngressTemplateGeneration(g generator.GeneratorInput) error {
 			if g.Debug {
 				fmt.Printf("Templating active/standby ingress manifest for %s to %s\n", route.Domain, fmt.Sprintf("%s/%s.yaml", savedTemplates, route.Domain))
 			}
-			ingress, err := servicestemplates.GenerateIngressTemplate(route, *lagoonBuild.BuildValues)
+			templateYAML, objects, err := generateRouteTemplate(route, *lagoonBuild.BuildValues)
 			if err != nil {
-				return fmt.Errorf("couldn't generate template: %v", err)
+				return err
 			}
-			templateYAML, err := servicestemplates.TemplateIngress(ingress)
-			if err != nil {
-				return fmt.Errorf("couldn't generate template: %v", err)
-			}
-			helpers.WriteTemplateFile(fmt.Sprintf("%s/%s.yaml", savedTemplates, route.Domain), templateYAML)
+			templates.add(fmt.Sprintf("%s/%s.yaml", savedTemplates, route.Domain), templateYAML, objects...)
 		}
 	}
-	return nil
+	return templates.write()
+}
+
+// generateRouteTemplate generates the template for a route, if the gateway api is enabled the route is templated as httproutes
+// instead of an ingress, along with the certificate for the route
+func generateRouteTemplate(route lagoon.RouteV2, buildValues generator.BuildValues) ([]byte, []client.Object, error) {
+	if buildValues.Gateway != nil {
+		httpRoutes, err := servicestemplates.GenerateHTTPRouteTemplate(route, buildValues)
+		if err != nil {
+			return nil, nil, fmt.Errorf("couldn't generate template: %v", err)
+		}
+		templateYAML, err := servicestemplates.TemplateHTTPRoutes(httpRoutes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("couldn't generate template: %v", err)
+		}
+		var objects []client.Object
+		for idx := range httpRoutes {
+			objects = append(objects, &httpRoutes[idx])
+		}
+		certificate, err := servicestemplates.GenerateHTTPRouteCertificate(route, buildValues)
+		if err != nil {
+			return nil, nil, fmt.Errorf("couldn't generate template: %v", err)
+		}
+		certificateYAML, err := servicestemplates.TemplateHTTPRouteCertificate(certificate)
+		if err != nil {
+			return nil, nil, fmt.Errorf("couldn't generate template: %v", err)
+		}
+		templateYAML = append(templateYAML, certificateYAML...)
+		objects = append(objects, certificate...)
+		return templateYAML, objects, nil
+	}
+	ingress, err := servicestemplates.GenerateIngressTemplate(route, buildValues)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	templateYAML, err := servicestemplates.TemplateIngress(ingress)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	return templateYAML, []client.Object{ingress}, nil
 }
 
 func init() {
@@ -367,6 +367,25 @@ func TestTemplateRoutes(t *testing.T) {
 			wantErr:    true,
 			wantErrMsg: "this environment requests 2 custom routes, this would exceed the route quota of 1",
 		},
+		{
+			name: "exceed route quota with redirects",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "redirects",
+					Branch:          "redirects",
+					LagoonYAML:      "internal/testdata/node/lagoon.yml",
+					ProjectVariables: []lagoon.EnvironmentVariable{
+						{
+							Name:  "LAGOON_ROUTE_QUOTA",
+							Value: "3",
+							Scope: "internal_system",
+						},
+					},
+				}, true),
+			wantErr:    true,
+			wantErrMsg: "this environment requests 4 custom routes, this would exceed the route quota of 3",
+		},
 		{
 			name: "test24 unidler request verification disable",
 			args: testdata.GetSeedData(
@@ -476,6 +495,88 @@ func TestTemplateRoutes(t *testing.T) {
 				}, true),
 			want: "internal/testdata/node/ingress-templates/api-defined-routes-with-lagoon-yml-fastly",
 		},
+		{
+			name: "gateway-api-httproutes",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "hsts",
+					Branch:          "hsts",
+					LagoonYAML:      "internal/testdata/node/lagoon.yml",
+					ProjectVariables: []lagoon.EnvironmentVariable{
+						{
+							Name:  "LAGOON_FEATURE_FLAG_GATEWAY_API",
+							Value: "enabled",
+							Scope: "build",
+						},
+						{
+							Name:  "LAGOON_FEATURE_FLAG_GATEWAY_API_PARENT",
+							Value: "lagoon-gateway/lagoon",
+							Scope: "build",
+						},
+						{
+							Name:  "LAGOON_FEATURE_FLAG_GATEWAY_API_CERTIFICATE_ISSUER",
+							Value: "lagoon-acme",
+							Scope: "build",
+						},
+					},
+				}, true),
+			want: "internal/testdata/node/ingress-templates/gateway-api-httproutes",
+		},
+		{
+			name: "gateway-api-httproutes no certificate issuer",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "hsts",
+					Branch:          "hsts",
+					LagoonYAML:      "internal/testdata/node/lagoon.yml",
+					ProjectVariables: []lagoon.EnvironmentVariable{
+						{
+							Name:  "LAGOON_FEATURE_FLAG_GATEWAY_API",
+							Value: "enabled",
+							Scope: "build",
+						},
+						{
+							Name:  "LAGOON_FEATURE_FLAG_GATEWAY_API_PARENT",
+							Value: "lagoon-gateway/lagoon",
+							Scope: "build",
+						},
+					},
+				}, true),
+			wantErr:    true,
+			wantErrMsg: "the route example.com uses tls-acme but no certificate issuer is defined for the gateway api, contact your Lagoon administrator",
+		},
+		{
+			name: "redirect-routes",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "redirects",
+					Branch:          "redirects",
+					LagoonYAML:      "internal/testdata/node/lagoon.yml",
+				}, true),
+			want: "internal/testdata/node/ingress-templates/redirect-routes",
+		},
+		{
+			name: "gateway-api-httproutes no parent gateway",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "hsts",
+					Branch:          "hsts",
+					LagoonYAML:      "internal/testdata/node/lagoon.yml",
+					ProjectVariables: []lagoon.EnvironmentVariable{
+						{
+							Name:  "LAGOON_FEATURE_FLAG_GATEWAY_API",
+							Value: "enabled",
+							Scope: "build",
+						},
+					},
+				}, true),
+			wantErr:    true,
+			wantErrMsg: "the gateway api is enabled but no parent gateway is defined, contact your Lagoon administrator",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
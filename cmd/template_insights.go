@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	servicestemplates "github.com/uselagoon/build-deploy-tool/internal/templating"
+)
+
+var insightsGeneration = &cobra.Command{
+	Use:     "insights",
+	Aliases: []string{"in"},
+	Short:   "Generate the insights configmap templates for a Lagoon build",
+	Long: `Generate the insights configmap templates for a Lagoon build
+The image inspect and sbom data for each service is read from the 'insights' field of the images file, and is stored
+gzip compressed in the lagoon-insights-image-<service> and lagoon-insights-sbom-<service> configmaps.
+Any data that is too large to store in a configmap is reported as an error once the other templates have been generated.
+If a custom Dependency Track endpoint and api key are defined, the api is checked and the sbom configmaps are annotated
+with the endpoint, any problem with the api is reported as an error once the templates have been generated.
+The insights handler labels the configmaps with lagoon.sh/insightsProcessed once it has processed the data, the templates
+must replace any existing configmaps so that this label is removed and the new data is processed`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		gen, err := GenerateInput(*rootCmd, false)
+		if err != nil {
+			return err
+		}
+		images, err := rootCmd.PersistentFlags().GetString("images")
+		if err != nil {
+			return fmt.Errorf("error reading images flag: %v", err)
+		}
+		imageRefs, err := loadImagesFromFile(images)
+		if err != nil {
+			return err
+		}
+		gen.ImageReferences = imageRefs.Images
+		return InsightsTemplateGeneration(gen, imageRefs.Insights)
+	},
+}
+
+// InsightsTemplateGeneration .
+func InsightsTemplateGeneration(g generator.GeneratorInput, insights map[string]servicestemplates.ImageInsights) error {
+	lagoonBuild, err := generator.NewGenerator(
+		g,
+	)
+	if err != nil {
+		return err
+	}
+	savedTemplates := g.SavedTemplatesPath
+	templates := newPolicyTemplates(lagoonBuild.BuildValues)
+	coreEnabled := strings.ToLower(generator.CheckFeatureFlag("INSIGHTS_CORE_ENABLED", lagoonBuild.BuildValues.EnvironmentVariables, g.Debug)) == "true"
+	// support a custom dependency track integration, any problems with it are only warnings
+	var warnings []error
+	dependencyTrackEndpoint, err := dependencyTrackEndpoint(lagoonBuild.BuildValues.EnvironmentVariables, g.Debug)
+	if err != nil {
+		warnings = append(warnings, fmt.Errorf("custom Dependency Track not enabled: %v", err))
+	}
+	configMaps, sizeErrors, err := servicestemplates.GenerateInsightsConfigMaps(*lagoonBuild.BuildValues, insights, coreEnabled, dependencyTrackEndpoint)
+	if err != nil {
+		return fmt.Errorf("couldn't generate template: %v", err)
+	}
+	warnings = append(warnings, sizeErrors...)
+	for _, cm := range configMaps {
+		templateBytes, err := servicestemplates.TemplateConfigMap(cm)
+		if err != nil {
+			return fmt.Errorf("couldn't generate template: %v", err)
+		}
+		if len(templateBytes) > 0 {
+			if g.Debug {
+				fmt.Printf("Templating insights configmap %s\n", fmt.Sprintf("%s/%s-configmap.yaml", savedTemplates, cm.Name))
+			}
+			templates.add(fmt.Sprintf("%s/%s-configmap.yaml", savedTemplates, cm.Name), templateBytes, &cm)
+		}
+	}
+	if err := templates.write(); err != nil {
+		return err
+	}
+	return errors.Join(warnings...)
+}
+
+// dependencyTrackEndpoint returns the custom dependency track endpoint if one is defined, and the api key can access the api
+func dependencyTrackEndpoint(vars []lagoon.EnvironmentVariable, debug bool) (string, error) {
+	apiEndpoint := generator.CheckFeatureFlag("INSIGHTS_DEPENDENCY_TRACK_API_ENDPOINT", vars, debug)
+	if apiEndpoint == "" {
+		return "", nil
+	}
+	apiKey := generator.CheckFeatureFlag("INSIGHTS_DEPENDENCY_TRACK_API_KEY", vars, debug)
+	if apiKey == "" {
+		return "", fmt.Errorf("missing LAGOON_FEATURE_FLAG_INSIGHTS_DEPENDENCY_TRACK_API_KEY")
+	}
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/v1/project?pageSize=1", apiEndpoint), nil)
+	if err != nil {
+		return "", fmt.Errorf("api error: %v", err)
+	}
+	req.Header.Set("X-Api-Key", apiKey)
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("api error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", fmt.Errorf("api error: the api responded with status %d", resp.StatusCode)
+	}
+	return apiEndpoint, nil
+}
+
+func init() {
+	templateCmd.AddCommand(insightsGeneration)
+}
@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andreyvit/diff"
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"github.com/uselagoon/build-deploy-tool/internal/testdata"
+
+	// changes the testing to source from root so paths to test resources must be defined from repo root
+	_ "github.com/uselagoon/build-deploy-tool/internal/testing"
+)
+
+func TestInsightsTemplateGeneration(t *testing.T) {
+	// a dependency track api that only accepts the api key `dt-api-key`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/project" || r.Header.Get("X-Api-Key") != "dt-api-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("[]"))
+	}))
+	defer ts.Close()
+	tests := []struct {
+		name        string
+		description string
+		args        testdata.TestData
+		images      string
+		want        string
+		wantErr     string
+	}{
+		{
+			name:        "test-basic-insights",
+			description: "image inspect and sbom configmaps for a basic deployment",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/basic/lagoon.yml",
+				}, true),
+			images: "internal/testdata/basic/images-insights.yaml",
+			want:   "internal/testdata/basic/insights-templates/test-basic-insights",
+		},
+		{
+			name:        "test-basic-insights-core-enabled",
+			description: "insights configmaps with the core insights feature flag enabled",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/basic/lagoon.yml",
+					ProjectVariables: []lagoon.EnvironmentVariable{
+						{
+							Name:  "LAGOON_FEATURE_FLAG_INSIGHTS_CORE_ENABLED",
+							Value: "true",
+							Scope: "build",
+						},
+					},
+				}, true),
+			images: "internal/testdata/basic/images-insights.yaml",
+			want:   "internal/testdata/basic/insights-templates/test-basic-insights-core-enabled",
+		},
+		{
+			name:        "test-basic-insights-dependency-track",
+			description: "the sbom configmaps are annotated with a custom dependency track endpoint",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/basic/lagoon.yml",
+					ProjectVariables: []lagoon.EnvironmentVariable{
+						{
+							Name:  "LAGOON_FEATURE_FLAG_INSIGHTS_DEPENDENCY_TRACK_API_ENDPOINT",
+							Value: ts.URL,
+							Scope: "build",
+						},
+						{
+							Name:  "LAGOON_FEATURE_FLAG_INSIGHTS_DEPENDENCY_TRACK_API_KEY",
+							Value: "dt-api-key",
+							Scope: "build",
+						},
+					},
+				}, true),
+			images: "internal/testdata/basic/images-insights.yaml",
+			want:   "internal/testdata/basic/insights-templates/test-basic-insights-dependency-track",
+		},
+		{
+			name:        "test-basic-insights-dependency-track-api-error",
+			description: "a custom dependency track endpoint that can't be accessed is a warning, and the configmaps aren't annotated",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/basic/lagoon.yml",
+					ProjectVariables: []lagoon.EnvironmentVariable{
+						{
+							Name:  "LAGOON_FEATURE_FLAG_INSIGHTS_DEPENDENCY_TRACK_API_ENDPOINT",
+							Value: ts.URL,
+							Scope: "build",
+						},
+						{
+							Name:  "LAGOON_FEATURE_FLAG_INSIGHTS_DEPENDENCY_TRACK_API_KEY",
+							Value: "invalid-api-key",
+							Scope: "build",
+						},
+					},
+				}, true),
+			images:  "internal/testdata/basic/images-insights.yaml",
+			want:    "internal/testdata/basic/insights-templates/test-basic-insights",
+			wantErr: "custom Dependency Track not enabled: api error: the api responded with status 401",
+		},
+		{
+			name:        "test-basic-insights-dependency-track-missing-key",
+			description: "a custom dependency track endpoint without an api key is a warning",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/basic/lagoon.yml",
+					ProjectVariables: []lagoon.EnvironmentVariable{
+						{
+							Name:  "LAGOON_FEATURE_FLAG_INSIGHTS_DEPENDENCY_TRACK_API_ENDPOINT",
+							Value: ts.URL,
+							Scope: "build",
+						},
+					},
+				}, true),
+			images:  "internal/testdata/basic/images-insights.yaml",
+			want:    "internal/testdata/basic/insights-templates/test-basic-insights",
+			wantErr: "custom Dependency Track not enabled: missing LAGOON_FEATURE_FLAG_INSIGHTS_DEPENDENCY_TRACK_API_KEY",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			helpers.UnsetEnvVars(nil) //unset variables before running tests
+			// set the environment variables from args
+			savedTemplates, err := os.MkdirTemp("", "testoutput")
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			generator, err := testdata.SetupEnvironment(generator.GeneratorInput{}, savedTemplates, tt.args)
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			defer os.RemoveAll(savedTemplates)
+
+			imageRefs, err := loadImagesFromFile(tt.images)
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			generator.ImageReferences = imageRefs.Images
+			err = InsightsTemplateGeneration(generator, imageRefs.Insights)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("InsightsTemplateGeneration() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("%v", err)
+			}
+
+			files, err := os.ReadDir(savedTemplates)
+			if err != nil {
+				t.Errorf("couldn't read directory %v: %v", savedTemplates, err)
+			}
+			results, err := os.ReadDir(tt.want)
+			if err != nil {
+				t.Errorf("couldn't read directory %v: %v", tt.want, err)
+			}
+			if len(files) != len(results) {
+				for _, f := range files {
+					f1, err := os.ReadFile(fmt.Sprintf("%s/%s", savedTemplates, f.Name()))
+					if err != nil {
+						t.Errorf("couldn't read file %v: %v", savedTemplates, err)
+					}
+					fmt.Println(string(f1))
+				}
+				t.Errorf("number of generated templates doesn't match results %v/%v: %v", len(files), len(results), err)
+			}
+			fCount := 0
+			for _, f := range files {
+				for _, r := range results {
+					if f.Name() == r.Name() {
+						fCount++
+						f1, err := os.ReadFile(fmt.Sprintf("%s/%s", savedTemplates, f.Name()))
+						if err != nil {
+							t.Errorf("couldn't read file %v: %v", savedTemplates, err)
+						}
+						r1, err := os.ReadFile(fmt.Sprintf("%s/%s", tt.want, f.Name()))
+						if err != nil {
+							t.Errorf("couldn't read file %v: %v", tt.want, err)
+						}
+						// the dependency track endpoint is a different address on every run
+						f1 = []byte(strings.ReplaceAll(string(f1), ts.URL, "https://dependencytrack.example.com"))
+						if !reflect.DeepEqual(f1, r1) {
+							t.Errorf("InsightsTemplateGeneration() = \n%v", diff.LineDiff(string(r1), string(f1)))
+						}
+					}
+				}
+			}
+			if fCount != len(files) {
+				for _, f := range files {
+					f1, err := os.ReadFile(fmt.Sprintf("%s/%s", savedTemplates, f.Name()))
+					if err != nil {
+						t.Errorf("couldn't read file %v: %v", savedTemplates, err)
+					}
+					fmt.Println(string(f1))
+				}
+				t.Errorf("resulting templates do not match")
+			}
+			t.Cleanup(func() {
+				helpers.UnsetEnvVars(nil)
+			})
+		})
+	}
+}
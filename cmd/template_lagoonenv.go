@@ -8,7 +8,6 @@ import (
 
 	"github.com/spf13/cobra"
 	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
-	"github.com/uselagoon/build-deploy-tool/internal/helpers"
 	servicestemplates "github.com/uselagoon/build-deploy-tool/internal/templating"
 )
 
@@ -86,6 +85,7 @@ func LagoonEnvTemplateGeneration(
 		return err
 	}
 	savedTemplates := g.SavedTemplatesPath
+	templates := newPolicyTemplates(lagoonBuild.BuildValues)
 	// if the routes have been passed from the command line, use them instead. we do this since lagoon currently doesn't enforce route state to match
 	// what is in the `.lagoon.yml` file, so there may be items that exist in the cluster that don't exist in yaml
 	// eventually once route state enforcement is enforced, or the tool can reconcile what is in the cluster itself rather than in bash
@@ -94,6 +94,12 @@ func LagoonEnvTemplateGeneration(
 	if routes != "" {
 		lagoonBuild.BuildValues.Routes = strings.Split(routes, ",")
 	}
+	// secret references are only used by the lagoon-env secret, and are only resolved when it is templated
+	if name != "lagoon-platform-env" {
+		if err := generator.ResolveSecretReferences(lagoonBuild.BuildValues, g.Debug); err != nil {
+			return err
+		}
+	}
 	cm, err := servicestemplates.GenerateLagoonEnvSecret(name, *lagoonBuild.BuildValues)
 	if err != nil {
 		return fmt.Errorf("couldn't generate template: %v", err)
@@ -106,9 +112,24 @@ func LagoonEnvTemplateGeneration(
 		if g.Debug {
 			fmt.Printf("Templating lagoon-env secret %s\n", fmt.Sprintf("%s/%s-secret.yaml", savedTemplates, name))
 		}
-		helpers.WriteTemplateFile(fmt.Sprintf("%s/%s-secret.yaml", savedTemplates, name), templateBytes)
+		templates.add(fmt.Sprintf("%s/%s-secret.yaml", savedTemplates, name), templateBytes, &cm)
+	}
+	// any secret references that are synced from a secret store are merged into the secret by an ExternalSecret
+	es, err := servicestemplates.GenerateLagoonEnvExternalSecret(name, *lagoonBuild.BuildValues)
+	if err != nil {
+		return fmt.Errorf("couldn't generate template: %v", err)
+	}
+	if es != nil {
+		templateBytes, err := servicestemplates.TemplateExternalSecret(*es)
+		if err != nil {
+			return fmt.Errorf("couldn't generate template: %v", err)
+		}
+		if g.Debug {
+			fmt.Printf("Templating lagoon-env external secret %s\n", fmt.Sprintf("%s/%s-externalsecret.yaml", savedTemplates, name))
+		}
+		templates.add(fmt.Sprintf("%s/%s-externalsecret.yaml", savedTemplates, name), templateBytes, es)
 	}
-	return nil
+	return templates.write()
 }
 
 func init() {
@@ -11,6 +11,7 @@ import (
 	"github.com/uselagoon/build-deploy-tool/internal/generator"
 	"github.com/uselagoon/build-deploy-tool/internal/helpers"
 	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"github.com/uselagoon/build-deploy-tool/internal/secretref/secretreftest"
 	"github.com/uselagoon/build-deploy-tool/internal/testdata"
 )
 
@@ -269,6 +270,87 @@ func TestLagoonEnvTemplateGeneration(t *testing.T) {
 			secretName: "lagoon-platform-env",
 			want:       "internal/testdata/basic/secret-templates/lagoon-platform-env-with-configmap-vars",
 		},
+		{
+			name:        "test-basic-deployment-lagoon-env-secret-references",
+			description: "a lagoon-env secret with secret references resolved by the build",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/basic/lagoon.yml",
+					ProjectVariables: []lagoon.EnvironmentVariable{
+						{
+							Name:  "MY_SPECIAL_VARIABLE",
+							Value: "myspecialvariable",
+							Scope: "global",
+						},
+						{
+							Name:  "API_KEY",
+							Value: "ref+vault://secret/data/example-project#api-key",
+							Scope: "runtime",
+						},
+						{
+							Name:  "DB_PASSWORD",
+							Value: "ref+file://secrets.yml#password",
+							Scope: "runtime",
+						},
+					},
+				}, true),
+			vars: []helpers.EnvironmentVariable{
+				{
+					Name:  "LAGOON_SECRET_RESOLVER_VAULT_TOKEN",
+					Value: "lagoon-token",
+				},
+				{
+					Name:  "LAGOON_SECRET_RESOLVER_FILE_ROOT",
+					Value: "internal/secretref/test-resources",
+				},
+			},
+			secretName: "lagoon-env",
+			want:       "internal/testdata/basic/secret-templates/test-basic-deployment-lagoon-env-secret-references",
+		},
+		{
+			name:        "test-basic-deployment-lagoon-env-external-secrets",
+			description: "a lagoon-env secret with secret references synced by an ExternalSecret",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/basic/lagoon.yml",
+					ProjectVariables: []lagoon.EnvironmentVariable{
+						{
+							Name:  "MY_SPECIAL_VARIABLE",
+							Value: "myspecialvariable",
+							Scope: "global",
+						},
+						{
+							Name:  "API_KEY",
+							Value: "ref+vault://secret/data/example-project#api-key",
+							Scope: "runtime",
+						},
+						{
+							Name:  "DB_PASSWORD",
+							Value: "ref+file://secrets.yml#password",
+							Scope: "runtime",
+						},
+						{
+							Name:  "LAGOON_FEATURE_FLAG_EXTERNAL_SECRETS",
+							Value: "enabled",
+							Scope: "build",
+						},
+					},
+				}, true),
+			vars: []helpers.EnvironmentVariable{
+				{
+					Name:  "LAGOON_SECRET_RESOLVER_FILE_ROOT",
+					Value: "internal/secretref/test-resources",
+				},
+			},
+			secretName: "lagoon-env",
+			want:       "internal/testdata/basic/secret-templates/test-basic-deployment-lagoon-env-external-secrets",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -296,6 +378,12 @@ func TestLagoonEnvTemplateGeneration(t *testing.T) {
 			if err != nil {
 				t.Errorf("%v", err)
 			}
+			sts := secretreftest.NewServer()
+			defer sts.Close()
+			err = os.Setenv("LAGOON_SECRET_RESOLVER_VAULT_HTTP", sts.URL)
+			if err != nil {
+				t.Errorf("%v", err)
+			}
 			dbaasCreds := &DBaaSCredRefs{}
 			if tt.dbaasCreds != "" {
 				dbaasCreds, err = loadCredsFromFile(tt.dbaasCreds)
@@ -369,3 +457,44 @@ func TestLagoonEnvTemplateGeneration(t *testing.T) {
 		})
 	}
 }
+
+func TestGeneratorSecretReferences(t *testing.T) {
+	helpers.UnsetEnvVars(nil) //unset variables before running tests
+	// secret references are only resolved when the lagoon-env secret is templated, so the generator doesn't
+	// need access to the secret store and other commands never read secrets
+	args := testdata.GetSeedData(
+		testdata.TestData{
+			ProjectName:     "example-project",
+			EnvironmentName: "main",
+			Branch:          "main",
+			LagoonYAML:      "internal/testdata/basic/lagoon.yml",
+			ProjectVariables: []lagoon.EnvironmentVariable{
+				{
+					Name:  "API_KEY",
+					Value: "ref+vault://secret/data/example-project#api-key",
+					Scope: "runtime",
+				},
+			},
+		}, true)
+	savedTemplates, err := os.MkdirTemp("", "testoutput")
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	defer os.RemoveAll(savedTemplates)
+	input, err := testdata.SetupEnvironment(generator.GeneratorInput{}, savedTemplates, args)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	// the secret store isn't available, resolving the reference would fail
+	t.Setenv("LAGOON_SECRET_RESOLVER_VAULT_HTTP", "http://127.0.0.1:1")
+	lagoonBuild, err := generator.NewGenerator(input)
+	if err != nil {
+		t.Fatalf("NewGenerator() error = %v", err)
+	}
+	if got := lagoonBuild.BuildValues.LagoonEnvVariables["API_KEY"]; got != "ref+vault://secret/data/example-project#api-key" {
+		t.Errorf("NewGenerator() API_KEY = %v, want the unresolved reference", got)
+	}
+	if err := LagoonEnvTemplateGeneration("lagoon-env", input, ""); err == nil {
+		t.Errorf("LagoonEnvTemplateGeneration() expected an error resolving the reference")
+	}
+}
@@ -6,13 +6,13 @@ import (
 
 	"github.com/spf13/cobra"
 	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
-	"github.com/uselagoon/build-deploy-tool/internal/helpers"
 	servicestemplates "github.com/uselagoon/build-deploy-tool/internal/templating"
 	"sigs.k8s.io/yaml"
 )
 
 type ImageReferences struct {
-	Images map[string]string `json:"images"`
+	Images   map[string]string                          `json:"images"`
+	Insights map[string]servicestemplates.ImageInsights `json:"insights,omitempty"`
 }
 
 var lagoonServiceGeneration = &cobra.Command{
@@ -58,7 +58,12 @@ func LagoonServiceTemplateGeneration(g generator.GeneratorInput) error {
 		return err
 	}
 	savedTemplates := g.SavedTemplatesPath
+	templates := newPolicyTemplates(lagoonBuild.BuildValues)
 
+	// the registry secrets contain the credentials of the container registries, so they are resolved here
+	if err := generator.ResolveContainerRegistryCredentials(lagoonBuild.BuildValues); err != nil {
+		return err
+	}
 	// generate the templates
 	secrets, err := servicestemplates.GenerateRegistrySecretTemplate(*lagoonBuild.BuildValues)
 	if err != nil {
@@ -72,7 +77,7 @@ func LagoonServiceTemplateGeneration(g generator.GeneratorInput) error {
 		if g.Debug {
 			fmt.Printf("Templating registry secret manifests %s\n", fmt.Sprintf("%s/%s.yaml", savedTemplates, secret.Name))
 		}
-		helpers.WriteTemplateFile(fmt.Sprintf("%s/%s.yaml", savedTemplates, secret.Name), templateBytes)
+		templates.add(fmt.Sprintf("%s/%s.yaml", savedTemplates, secret.Name), templateBytes, &secret)
 	}
 	services, err := servicestemplates.GenerateServiceTemplate(*lagoonBuild.BuildValues)
 	if err != nil {
@@ -86,7 +91,7 @@ func LagoonServiceTemplateGeneration(g generator.GeneratorInput) error {
 		if g.Debug {
 			fmt.Printf("Templating service manifests %s\n", fmt.Sprintf("%s/service-%s.yaml", savedTemplates, d.Name))
 		}
-		helpers.WriteTemplateFile(fmt.Sprintf("%s/service-%s.yaml", savedTemplates, d.Name), templateBytes)
+		templates.add(fmt.Sprintf("%s/service-%s.yaml", savedTemplates, d.Name), templateBytes, &d)
 	}
 	pvcs, err := servicestemplates.GeneratePVCTemplate(*lagoonBuild.BuildValues)
 	if err != nil {
@@ -100,7 +105,7 @@ func LagoonServiceTemplateGeneration(g generator.GeneratorInput) error {
 		if g.Debug {
 			fmt.Printf("Templating pvc manifests %s\n", fmt.Sprintf("%s/pvc-%s.yaml", savedTemplates, d.Name))
 		}
-		helpers.WriteTemplateFile(fmt.Sprintf("%s/pvc-%s.yaml", savedTemplates, d.Name), templateBytes)
+		templates.add(fmt.Sprintf("%s/pvc-%s.yaml", savedTemplates, d.Name), templateBytes, &d)
 	}
 	deployments, err := servicestemplates.GenerateDeploymentTemplate(*lagoonBuild.BuildValues)
 	if err != nil {
@@ -114,7 +119,35 @@ func LagoonServiceTemplateGeneration(g generator.GeneratorInput) error {
 		if g.Debug {
 			fmt.Printf("Templating deployment manifests %s\n", fmt.Sprintf("%s/deployment-%s.yaml", savedTemplates, d.Name))
 		}
-		helpers.WriteTemplateFile(fmt.Sprintf("%s/deployment-%s.yaml", savedTemplates, d.Name), templateBytes)
+		templates.add(fmt.Sprintf("%s/deployment-%s.yaml", savedTemplates, d.Name), templateBytes, &d)
+	}
+	hpas, err := servicestemplates.GenerateHorizontalPodAutoscalerTemplate(*lagoonBuild.BuildValues)
+	if err != nil {
+		return fmt.Errorf("couldn't generate template: %v", err)
+	}
+	for _, d := range hpas {
+		templateBytes, err := servicestemplates.TemplateHorizontalPodAutoscaler(d)
+		if err != nil {
+			return fmt.Errorf("couldn't generate template: %v", err)
+		}
+		if g.Debug {
+			fmt.Printf("Templating horizontalpodautoscaler manifests %s\n", fmt.Sprintf("%s/hpa-%s.yaml", savedTemplates, d.Name))
+		}
+		templates.add(fmt.Sprintf("%s/hpa-%s.yaml", savedTemplates, d.Name), templateBytes, &d)
+	}
+	pdbs, err := servicestemplates.GeneratePodDisruptionBudgetTemplate(*lagoonBuild.BuildValues)
+	if err != nil {
+		return fmt.Errorf("couldn't generate template: %v", err)
+	}
+	for _, d := range pdbs {
+		templateBytes, err := servicestemplates.TemplatePodDisruptionBudget(d)
+		if err != nil {
+			return fmt.Errorf("couldn't generate template: %v", err)
+		}
+		if g.Debug {
+			fmt.Printf("Templating poddisruptionbudget manifests %s\n", fmt.Sprintf("%s/pdb-%s.yaml", savedTemplates, d.Name))
+		}
+		templates.add(fmt.Sprintf("%s/pdb-%s.yaml", savedTemplates, d.Name), templateBytes, &d)
 	}
 	cronjobs, err := servicestemplates.GenerateCronjobTemplate(*lagoonBuild.BuildValues)
 	if err != nil {
@@ -128,7 +161,7 @@ func LagoonServiceTemplateGeneration(g generator.GeneratorInput) error {
 		if g.Debug {
 			fmt.Printf("Templating cronjob manifests %s\n", fmt.Sprintf("%s/cronjob-%s.yaml", savedTemplates, d.Name))
 		}
-		helpers.WriteTemplateFile(fmt.Sprintf("%s/cronjob-%s.yaml", savedTemplates, d.Name), templateBytes)
+		templates.add(fmt.Sprintf("%s/cronjob-%s.yaml", savedTemplates, d.Name), templateBytes, &d)
 	}
 	if lagoonBuild.BuildValues.IsolationNetworkPolicy {
 		// if isolation network policies are enabled, template that here
@@ -143,7 +176,7 @@ func LagoonServiceTemplateGeneration(g generator.GeneratorInput) error {
 		if g.Debug {
 			fmt.Printf("Templating networkpolicy manifest %s\n", fmt.Sprintf("%s/isolation-network-policy.yaml", savedTemplates))
 		}
-		helpers.WriteTemplateFile(fmt.Sprintf("%s/isolation-network-policy.yaml", savedTemplates), templateBytes)
+		templates.add(fmt.Sprintf("%s/isolation-network-policy.yaml", savedTemplates), templateBytes, np)
 	}
 	serviceNetPols, err := servicestemplates.GenerateServiceNetworkPolicies(*lagoonBuild.BuildValues)
 	if err != nil {
@@ -157,9 +190,9 @@ func LagoonServiceTemplateGeneration(g generator.GeneratorInput) error {
 		if g.Debug {
 			fmt.Printf("Templating networkpolicy manifests %s\n", fmt.Sprintf("%s/networkpolicy-%s.yaml", savedTemplates, serviceNetPol.Name))
 		}
-		helpers.WriteTemplateFile(fmt.Sprintf("%s/networkpolicy-%s.yaml", savedTemplates, serviceNetPol.Name), templateBytes)
+		templates.add(fmt.Sprintf("%s/networkpolicy-%s.yaml", savedTemplates, serviceNetPol.Name), templateBytes, &serviceNetPol)
 	}
-	return nil
+	return templates.write()
 }
 
 func init() {
@@ -25,6 +25,7 @@ func TestTemplateLagoonServices(t *testing.T) {
 		want        string
 		imageData   string
 		vars        []helpers.EnvironmentVariable
+		wantErr     bool
 	}{
 		{
 			name:        "test1-basic-deployment",
@@ -68,6 +69,111 @@ func TestTemplateLagoonServices(t *testing.T) {
 				}, true),
 			want: "internal/testdata/basic/service-templates/test1-basic-deployment",
 		},
+		{
+			name:        "test1-basic-deployment-policy-warning",
+			description: "tests a basic deployment with policy warnings still writes the templates",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/basic/lagoon.container-registry-deep.yml",
+					ImageReferences: map[string]string{
+						"node": "harbor.example/example-project/main/node@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+					},
+					ProjectVariables: []lagoon.EnvironmentVariable{
+						{
+							Name:  "REGISTRY_PASSWORD",
+							Value: "myenvvarregistrypassword",
+							Scope: "container_registry",
+						},
+						{
+							Name:  "REGISTRY_DOCKERHUB_USERNAME",
+							Value: "dockerhubusername",
+							Scope: "container_registry",
+						},
+						{
+							Name:  "REGISTRY_DOCKERHUB_PASSWORD",
+							Value: "dockerhubpassword",
+							Scope: "container_registry",
+						},
+						{
+							Name:  "REGISTRY_MY_OTHER_REGISTRY_USERNAME",
+							Value: "otherusername",
+							Scope: "container_registry",
+						},
+						{
+							Name:  "REGISTRY_MY_OTHER_REGISTRY_PASSWORD",
+							Value: "otherpassword",
+							Scope: "container_registry",
+						},
+					},
+				}, true),
+			vars: []helpers.EnvironmentVariable{
+				{Name: "LAGOON_POLICY_FILE", Value: "internal/testdata/basic/policy-warning.yml"},
+			},
+			want: "internal/testdata/basic/service-templates/test1-basic-deployment",
+		},
+		{
+			name:        "test1-basic-deployment-policy-error",
+			description: "tests a basic deployment that violates the policy doesn't write any templates",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/basic/lagoon.container-registry-deep.yml",
+					ImageReferences: map[string]string{
+						"node": "harbor.example/example-project/main/node@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+					},
+					ProjectVariables: []lagoon.EnvironmentVariable{
+						{
+							Name:  "REGISTRY_PASSWORD",
+							Value: "myenvvarregistrypassword",
+							Scope: "container_registry",
+						},
+						{
+							Name:  "REGISTRY_DOCKERHUB_USERNAME",
+							Value: "dockerhubusername",
+							Scope: "container_registry",
+						},
+						{
+							Name:  "REGISTRY_DOCKERHUB_PASSWORD",
+							Value: "dockerhubpassword",
+							Scope: "container_registry",
+						},
+						{
+							Name:  "REGISTRY_MY_OTHER_REGISTRY_USERNAME",
+							Value: "otherusername",
+							Scope: "container_registry",
+						},
+						{
+							Name:  "REGISTRY_MY_OTHER_REGISTRY_PASSWORD",
+							Value: "otherpassword",
+							Scope: "container_registry",
+						},
+					},
+				}, true),
+			vars: []helpers.EnvironmentVariable{
+				{Name: "LAGOON_POLICY_FILE", Value: "internal/testdata/basic/policy-error.yml"},
+			},
+			wantErr: true,
+		},
+		{
+			name:        "test1-basic-deployment-strategy",
+			description: "tests a basic deployment with a zero downtime deployment strategy",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/basic/lagoon.deployment-strategy.yml",
+					ImageReferences: map[string]string{
+						"node": "harbor.example/example-project/main/node@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+					},
+				}, true),
+			want: "internal/testdata/basic/service-templates/test1-basic-deployment-strategy",
+		},
 		{
 			name:        "test2-nginx-php",
 			description: "tests an nginx-php deployment",
@@ -87,6 +193,54 @@ func TestTemplateLagoonServices(t *testing.T) {
 				}, true),
 			want: "internal/testdata/complex/service-templates/test2-nginx-php",
 		},
+		{
+			name:        "test2-nginx-php-autoscaling",
+			description: "tests an nginx-php deployment with a horizontalpodautoscaler and poddisruptionbudgets",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/complex/lagoon.autoscaling.yml",
+					ImageReferences: map[string]string{
+						"nginx":   "harbor.example/example-project/main/nginx@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"php":     "harbor.example/example-project/main/php@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"cli":     "harbor.example/example-project/main/cli@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"redis":   "harbor.example/example-project/main/redis@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"varnish": "harbor.example/example-project/main/varnish@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+					},
+				}, true),
+			want: "internal/testdata/complex/service-templates/test2-nginx-php-autoscaling",
+		},
+		{
+			name:        "test2-nginx-php-resources",
+			description: "tests an nginx-php deployment with resource and probe overrides",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/complex/lagoon.resources.yml",
+					ImageReferences: map[string]string{
+						"nginx":   "harbor.example/example-project/main/nginx@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"php":     "harbor.example/example-project/main/php@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"cli":     "harbor.example/example-project/main/cli@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"redis":   "harbor.example/example-project/main/redis@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"varnish": "harbor.example/example-project/main/varnish@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+					},
+				}, true),
+			vars: []helpers.EnvironmentVariable{
+				{
+					Name:  "ADMIN_LAGOON_FEATURE_FLAG_CONTAINER_MEMORY_LIMIT",
+					Value: "1Gi",
+				},
+				{
+					Name:  "ADMIN_LAGOON_FEATURE_FLAG_CONTAINER_MAX_MEMORY",
+					Value: "4Gi",
+				},
+			},
+			want: "internal/testdata/complex/service-templates/test2-nginx-php-resources",
+		},
 		{
 			name:        "test2a-nginx-php",
 			description: "tests an nginx-php deployment using images from images.yaml (same result as test2)",
@@ -653,6 +807,28 @@ func TestTemplateLagoonServices(t *testing.T) {
 				}, true),
 			want: "internal/testdata/basic/service-templates/test-basic-deployment-revision-history",
 		},
+		{
+			name:        "test-basic-custom-service-type",
+			description: "tests a service type loaded from a service types directory",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/basic/lagoon.memcached.yml",
+					ImageReferences: map[string]string{
+						"node":      "harbor.example/example-project/main/node@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"memcached": "harbor.example/example-project/main/memcached@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+					},
+				}, true),
+			vars: []helpers.EnvironmentVariable{
+				{
+					Name:  "LAGOON_SERVICE_TYPES_DIR",
+					Value: "internal/testdata/basic/service-types",
+				},
+			},
+			want: "internal/testdata/basic/service-templates/test-basic-custom-service-type",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -663,6 +839,10 @@ func TestTemplateLagoonServices(t *testing.T) {
 					t.Errorf("%v", err)
 				}
 			}
+			t.Cleanup(func() {
+				helpers.UnsetEnvVars(tt.vars)
+				helpers.UnsetEnvVars(tt.args.BuildPodVariables)
+			})
 			// set the environment variables from args
 			savedTemplates, err := os.MkdirTemp("", "testoutput")
 			if err != nil {
@@ -689,8 +869,19 @@ func TestTemplateLagoonServices(t *testing.T) {
 				generator.ImageReferences = imageRefs.Images
 			}
 			err = LagoonServiceTemplateGeneration(generator)
-			if err != nil {
-				t.Errorf("%v", err)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LagoonServiceTemplateGeneration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				// nothing should be written if the templates fail the policy
+				files, err := os.ReadDir(savedTemplates)
+				if err != nil {
+					t.Errorf("couldn't read directory %v: %v", savedTemplates, err)
+				}
+				if len(files) != 0 {
+					t.Errorf("templates were written when generation failed: %v", len(files))
+				}
+				return
 			}
 
 			files, err := os.ReadDir(savedTemplates)
@@ -740,10 +931,6 @@ func TestTemplateLagoonServices(t *testing.T) {
 				}
 				t.Errorf("resulting templates do not match")
 			}
-			t.Cleanup(func() {
-				helpers.UnsetEnvVars(tt.vars)
-				helpers.UnsetEnvVars(tt.args.BuildPodVariables)
-			})
 		})
 	}
 }
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	servicestemplates "github.com/uselagoon/build-deploy-tool/internal/templating"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type templateFile struct {
+	path string
+	data []byte
+}
+
+// policyTemplates collects the generated templates and the objects in them, so that the cluster policy
+// can be evaluated against every object before any of the templates are written
+type policyTemplates struct {
+	buildValues *generator.BuildValues
+	objects     []client.Object
+	files       []templateFile
+}
+
+func newPolicyTemplates(buildValues *generator.BuildValues) *policyTemplates {
+	return &policyTemplates{buildValues: buildValues}
+}
+
+// add a template file and the objects that were templated into it
+func (t *policyTemplates) add(path string, data []byte, objects ...client.Object) {
+	t.files = append(t.files, templateFile{path: path, data: data})
+	t.objects = append(t.objects, objects...)
+}
+
+// write evaluates the policy against the collected objects, warnings are printed and any errors will
+// prevent all of the templates from being written
+func (t *policyTemplates) write() error {
+	if err := evaluatePolicy(t.buildValues, t.objects); err != nil {
+		return err
+	}
+	for _, file := range t.files {
+		helpers.WriteTemplateFile(file.path, file.data)
+	}
+	return nil
+}
+
+// evaluatePolicy evaluates the policy against the objects, warnings are printed and any errors are returned
+func evaluatePolicy(buildValues *generator.BuildValues, objects []client.Object) error {
+	violations := buildValues.Policy.Evaluate(buildValues.EnvironmentType, objects...)
+	for _, warning := range violations.Warnings() {
+		fmt.Printf("Policy %s\n", warning.String())
+	}
+	if policyErrors := violations.Errors(); len(policyErrors) > 0 {
+		var errs []error
+		for _, policyError := range policyErrors {
+			errs = append(errs, errors.New(policyError.String()))
+		}
+		return fmt.Errorf("generated templates violate the cluster policy, contact your Lagoon administrator:\n%v", errors.Join(errs...))
+	}
+	return nil
+}
+
+// scheduleObjects returns the objects in the backup schedule templates
+func scheduleObjects(schedules *servicestemplates.BackupSchedule) []client.Object {
+	var objects []client.Object
+	if schedules == nil {
+		return objects
+	}
+	for idx := range schedules.K8upV1 {
+		objects = append(objects, &schedules.K8upV1[idx])
+	}
+	for idx := range schedules.K8upV1alpha1 {
+		objects = append(objects, &schedules.K8upV1alpha1[idx])
+	}
+	for idx := range schedules.Secrets {
+		objects = append(objects, &schedules.Secrets[idx])
+	}
+	return objects
+}
+
+// preBackupPodObjects returns the objects in the prebackuppod templates
+func preBackupPodObjects(pbps []k8upv1.PreBackupPod) []client.Object {
+	var objects []client.Object
+	for idx := range pbps {
+		objects = append(objects, &pbps[idx])
+	}
+	return objects
+}
+
+// dbaasObjects returns the objects in the dbaas consumer templates
+func dbaasObjects(dbaas *servicestemplates.DBaaSTemplates) []client.Object {
+	var objects []client.Object
+	if dbaas == nil {
+		return objects
+	}
+	for idx := range dbaas.MariaDB {
+		objects = append(objects, &dbaas.MariaDB[idx])
+	}
+	for idx := range dbaas.MongoDB {
+		objects = append(objects, &dbaas.MongoDB[idx])
+	}
+	for idx := range dbaas.PostgreSQL {
+		objects = append(objects, &dbaas.PostgreSQL[idx])
+	}
+	return objects
+}
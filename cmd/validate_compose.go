@@ -3,11 +3,13 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 
 	composetypes "github.com/compose-spec/compose-go/types"
 	"github.com/spf13/cobra"
+	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
 	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"github.com/uselagoon/build-deploy-tool/internal/servicetypes"
 )
 
 var validateDockerCompose = &cobra.Command{
@@ -20,27 +22,80 @@ var validateDockerCompose = &cobra.Command{
 		ignoreMissingEnvFiles, err := rootCmd.PersistentFlags().GetBool("ignore-missing-env-files")
 		if err != nil {
 			fmt.Println(fmt.Errorf("error reading ignore-missing-env-files flag: %v", err))
-			os.Exit(1)
+			exitCommand(cmd, 1, nil)
 		}
 		ignoreNonStringKeyErrors, err := rootCmd.PersistentFlags().GetBool("ignore-non-string-key-errors")
 		if err != nil {
 			fmt.Println(fmt.Errorf("error reading ignore-non-string-key-errors flag: %v", err))
-			os.Exit(1)
+			exitCommand(cmd, 1, nil)
 		}
 		lagoonYamlFile, err := cmd.Flags().GetString("lagoon-yml")
 		if err != nil {
 			fmt.Println(fmt.Errorf("error reading lagoon-yml flag: %v", err))
-			os.Exit(1)
+			exitCommand(cmd, 1, nil)
 		}
 		outputJSON, err := cmd.Flags().GetBool("json")
 		if err != nil {
 			fmt.Println(fmt.Errorf("error reading json flag: %v", err))
-			os.Exit(1)
+			exitCommand(cmd, 1, nil)
+		}
+		lint, err := cmd.Flags().GetBool("lint")
+		if err != nil {
+			fmt.Println(fmt.Errorf("error reading lint flag: %v", err))
+			exitCommand(cmd, 1, nil)
+		}
+		if lint {
+			lintFormat, err := cmd.Flags().GetString("lint-format")
+			if err != nil {
+				fmt.Println(fmt.Errorf("error reading lint-format flag: %v", err))
+				exitCommand(cmd, 1, nil)
+			}
+			lintStrict, err := cmd.Flags().GetBool("lint-strict")
+			if err != nil {
+				fmt.Println(fmt.Errorf("error reading lint-strict flag: %v", err))
+				exitCommand(cmd, 1, nil)
+			}
+			serviceTypesDir, err := rootCmd.PersistentFlags().GetString("service-types-dir")
+			if err != nil {
+				fmt.Println(fmt.Errorf("error reading service-types-dir flag: %v", err))
+				exitCommand(cmd, 1, nil)
+			}
+			environmentName, err := rootCmd.PersistentFlags().GetString("environment-name")
+			if err != nil {
+				fmt.Println(fmt.Errorf("error reading environment-name flag: %v", err))
+				exitCommand(cmd, 1, nil)
+			}
+			issues, err := LintDockerComposeLabels(lagoonYamlFile, serviceTypesDir, environmentName, ignoreNonStringKeyErrors, ignoreMissingEnvFiles)
+			if err != nil {
+				fmt.Println(err.Error())
+				exitCommand(cmd, 1, nil)
+			}
+			switch lintFormat {
+			case "json":
+				report := map[string]interface{}{
+					"issues":   issues,
+					"errors":   len(issues.Errors()),
+					"warnings": len(issues.Warnings()),
+				}
+				rBytes, _ := json.Marshal(report)
+				fmt.Println(string(rBytes))
+			case "text":
+				for _, issue := range issues {
+					fmt.Println(issue.String())
+				}
+			default:
+				fmt.Printf("unsupported lint-format %s, must be one of text or json\n", lintFormat)
+				exitCommand(cmd, 1, nil)
+			}
+			if len(issues.Errors()) > 0 || (lintStrict && len(issues) > 0) {
+				exitCommand(cmd, 1, nil)
+			}
+			return
 		}
 		spec, svcOrder, err := ValidateDockerCompose(lagoonYamlFile, ignoreNonStringKeyErrors, ignoreMissingEnvFiles)
 		if err != nil && !outputJSON {
 			fmt.Println(err.Error())
-			os.Exit(1)
+			exitCommand(cmd, 1, nil)
 		}
 		if outputJSON {
 			result := map[string]interface{}{
@@ -62,13 +117,13 @@ var validateDockerComposeWithErrors = &cobra.Command{
 		lagoonYamlFile, err := cmd.Flags().GetString("lagoon-yml")
 		if err != nil {
 			fmt.Println(fmt.Errorf("error reading lagoon-yml flag: %v", err))
-			os.Exit(1)
+			exitCommand(cmd, 1, nil)
 		}
 
 		err = validateDockerComposeWithError(lagoonYamlFile)
 		if err != nil {
 			fmt.Println(err.Error())
-			os.Exit(1)
+			exitCommand(cmd, 1, nil)
 		}
 	},
 }
@@ -82,6 +137,30 @@ func ValidateDockerCompose(file string, ignoreErrors, ignoreMisEnvFiles bool) (*
 	return composeSpec, serviceOrder, nil
 }
 
+// LintDockerComposeLabels checks the lagoon labels of the services and volumes in the docker-compose file referenced in the .lagoon.yml file,
+// any additional service types in the service types directory are loaded first so that services can use them. The service types are
+// resolved with any overrides for the environment in the .lagoon.yml or the `LAGOON_SERVICE_TYPES` variable, like they are in a build
+func LintDockerComposeLabels(file, serviceTypesDir, environmentName string, ignoreErrors, ignoreMisEnvFiles bool) (lagoon.LabelIssues, error) {
+	if err := servicetypes.LoadServiceTypes(helpers.GetEnv("LAGOON_SERVICE_TYPES_DIR", serviceTypesDir, false)); err != nil {
+		return nil, err
+	}
+	composeSpec, _, _, err := lagoon.UnmarshaDockerComposeYAML(file, ignoreErrors, ignoreMisEnvFiles, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	lYAML := &lagoon.YAML{}
+	if err := lagoon.UnmarshalLagoonYAML(file, lYAML, helpers.GetEnv("PROJECT", "", false)); err != nil {
+		return nil, err
+	}
+	lagoonServiceTypes, _ := lagoon.GetLagoonVariable("LAGOON_SERVICE_TYPES", nil, generator.GetLagoonEnvVars())
+	buildValues := &generator.BuildValues{
+		LagoonYAML:           *lYAML,
+		Environment:          helpers.GetEnv("ENVIRONMENT", environmentName, false),
+		ServiceTypeOverrides: lagoonServiceTypes,
+	}
+	return lagoon.LintDockerComposeLabels(lYAML.DockerComposeYAML, composeSpec, generator.ServiceTypeOverrides(buildValues, composeSpec))
+}
+
 // validateDockerComposeWithErrors validate a docker-compose file yaml structure properly
 func validateDockerComposeWithError(file string) error {
 	err := lagoon.ValidateUnmarshalDockerComposeYAML(file)
@@ -96,6 +175,12 @@ func init() {
 	validateCmd.AddCommand(validateDockerComposeWithErrors)
 	validateDockerCompose.Flags().Bool("json", false,
 		"Flag output the resulting docker-compose file in JSON.")
+	validateDockerCompose.Flags().Bool("lint", false,
+		"Lint the lagoon labels in the docker-compose file instead of validating it, exits with an error if any labels will fail a build.")
+	validateDockerCompose.Flags().String("lint-format", "text",
+		"The format of the lint report, one of text or json.")
+	validateDockerCompose.Flags().Bool("lint-strict", false,
+		"Exit with an error if the lint report has any warnings.")
 	validateDockerCompose.Flags().StringP("lagoon-yml", "", ".lagoon.yml",
 		"The .lagoon.yml file to read.")
 	validateDockerComposeWithErrors.Flags().StringP("lagoon-yml", "", ".lagoon.yml",
@@ -90,3 +90,70 @@ func TestValidateDockerCompose(t *testing.T) {
 		})
 	}
 }
+
+func TestLintDockerComposeLabels(t *testing.T) {
+	tests := []struct {
+		name            string
+		file            string
+		serviceTypesDir string
+		environment     string
+		serviceTypes    string
+		wantErrors      int
+		wantWarnings    int
+	}{
+		{
+			name: "valid labels",
+			file: "internal/testdata/basic/lagoon.yml",
+		},
+		{
+			name:         "unknown and unused labels",
+			file:         "internal/lagoon/test-resources/docker-compose-labels/lagoon.yml",
+			wantErrors:   4,
+			wantWarnings: 7,
+		},
+		{
+			name:       "custom service type without the service types directory",
+			file:       "internal/testdata/basic/lagoon.memcached.yml",
+			wantErrors: 1,
+		},
+		{
+			name:            "custom service type",
+			file:            "internal/testdata/basic/lagoon.memcached.yml",
+			serviceTypesDir: "internal/testdata/basic/service-types",
+		},
+		{
+			name:         "service type overridden in the .lagoon.yml",
+			file:         "internal/lagoon/test-resources/docker-compose-labels/lagoon.types.yml",
+			environment:  "main",
+			wantErrors:   3,
+			wantWarnings: 7,
+		},
+		{
+			name:         "service type overridden in the .lagoon.yml for another environment",
+			file:         "internal/lagoon/test-resources/docker-compose-labels/lagoon.types.yml",
+			environment:  "dev",
+			wantErrors:   4,
+			wantWarnings: 7,
+		},
+		{
+			name:         "service type overridden by LAGOON_SERVICE_TYPES",
+			file:         "internal/lagoon/test-resources/docker-compose-labels/lagoon.yml",
+			environment:  "main",
+			serviceTypes: `[{"name":"LAGOON_SERVICE_TYPES","value":"node:none","scope":"build"}]`,
+			wantErrors:   4,
+			wantWarnings: 6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LAGOON_PROJECT_VARIABLES", tt.serviceTypes)
+			got, err := LintDockerComposeLabels(tt.file, tt.serviceTypesDir, tt.environment, true, true)
+			if err != nil {
+				t.Fatalf("LintDockerComposeLabels() error = %v", err)
+			}
+			if len(got.Errors()) != tt.wantErrors || len(got.Warnings()) != tt.wantWarnings {
+				t.Errorf("LintDockerComposeLabels() errors = %d, warnings = %d, want %d and %d: %v", len(got.Errors()), len(got.Warnings()), tt.wantErrors, tt.wantWarnings, got)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
 	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
 	"sigs.k8s.io/yaml"
 )
@@ -19,34 +21,59 @@ var validateLagoonYml = &cobra.Command{
 		lagoonYAML, err := rootCmd.PersistentFlags().GetString("lagoon-yml")
 		if err != nil {
 			fmt.Println(fmt.Errorf("error reading lagoon-yml flag: %v", err))
-			os.Exit(1)
+			exitCommand(cmd, 1, nil)
 		}
 		lagoonYAMLOverride, err := rootCmd.PersistentFlags().GetString("lagoon-yml-override")
 		if err != nil {
 			fmt.Println(fmt.Errorf("error reading lagoon-yml-override flag: %v", err))
-			os.Exit(1)
+			exitCommand(cmd, 1, nil)
 		}
 		projectName, err := rootCmd.PersistentFlags().GetString("project-name")
 		if err != nil {
 			fmt.Println(fmt.Errorf("error reading project-name flag: %v", err))
-			os.Exit(1)
+			exitCommand(cmd, 1, nil)
 		}
 		printOutput, err := cmd.Flags().GetBool("print-resulting-lagoonyml")
 		if err != nil {
 			fmt.Println(fmt.Errorf("error reading print-resulting-lagoonyml flag: %v", err))
-			os.Exit(1)
+			exitCommand(cmd, 1, nil)
 		}
 		outputJSON, err := cmd.Flags().GetBool("json")
 		if err != nil {
 			fmt.Println(fmt.Errorf("error reading json flag: %v", err))
-			os.Exit(1)
+			exitCommand(cmd, 1, nil)
+		}
+		printSchema, err := cmd.Flags().GetBool("print-schema")
+		if err != nil {
+			fmt.Println(fmt.Errorf("error reading print-schema flag: %v", err))
+			exitCommand(cmd, 1, nil)
+		}
+		if printSchema {
+			fmt.Println(string(lagoon.Schema()))
+			return
+		}
+
+		issues, err := ValidateLagoonYmlSchema(lagoonYAML, lagoonYAMLOverride, "LAGOON_YAML_OVERRIDE", projectName, false)
+		if err != nil {
+			fmt.Println("Could not validate your .lagoon.yml -", err.Error())
+			exitCommand(cmd, 1, nil)
+		}
+		for _, issue := range issues.Warnings() {
+			fmt.Println(issue.String())
+		}
+		for _, issue := range issues.Errors() {
+			fmt.Println(issue.String())
+		}
+		if errs := issues.Errors(); len(errs) > 0 {
+			fmt.Printf("Could not validate your .lagoon.yml - found %d schema errors\n", len(errs))
+			exitCommand(cmd, 1, nil)
 		}
 
 		lYAML := &lagoon.YAML{}
 		err = ValidateLagoonYml(lagoonYAML, lagoonYAMLOverride, "LAGOON_YAML_OVERRIDE", lYAML, projectName, false)
 		if err != nil {
 			fmt.Println("Could not validate your .lagoon.yml -", err.Error())
-			os.Exit(1)
+			exitCommand(cmd, 1, nil)
 		}
 
 		if printOutput {
@@ -54,14 +81,14 @@ var validateLagoonYml = &cobra.Command{
 				resultingBS, err := json.Marshal(lYAML)
 				if err != nil {
 					fmt.Println("Unable to unmarshal resulting yml for printing: ", err)
-					os.Exit(1)
+					exitCommand(cmd, 1, nil)
 				}
 				fmt.Println(string(resultingBS))
 			} else {
 				resultingBS, err := yaml.Marshal(lYAML)
 				if err != nil {
 					fmt.Println("Unable to unmarshal resulting yml for printing: ", err)
-					os.Exit(1)
+					exitCommand(cmd, 1, nil)
 				}
 				fmt.Println(string(resultingBS))
 			}
@@ -91,9 +118,40 @@ func ValidateLagoonYml(lagoonYml string, lagoonYmlOverride string, lagoonYmlEnvV
 	return nil
 }
 
+// ValidateLagoonYmlSchema validates the .lagoon.yml, the override file and the override environment variable against the schema.
+// Each file is checked separately so the issues have the line and column of the file they are in
+func ValidateLagoonYmlSchema(lagoonYml string, lagoonYmlOverride string, lagoonYmlEnvVar string, projectName string, debug bool) (lagoon.SchemaIssues, error) {
+	issues, err := lagoon.ValidateLagoonYAMLSchema(lagoonYml, projectName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(lagoonYmlOverride); err == nil {
+		overrideIssues, err := lagoon.ValidateLagoonYAMLSchema(lagoonYmlOverride, projectName)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, overrideIssues...)
+	}
+	envLagoonYamlStringBase64 := helpers.GetEnv(lagoonYmlEnvVar, "", debug)
+	if envLagoonYamlStringBase64 != "" {
+		envLagoonYamlString, err := base64.StdEncoding.DecodeString(envLagoonYamlStringBase64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode %v - is it base64 encoded?", lagoonYmlEnvVar)
+		}
+		envIssues, err := lagoon.ValidateLagoonYAMLSchemaBytes(lagoonYmlEnvVar, envLagoonYamlString, projectName)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, envIssues...)
+	}
+	return issues, nil
+}
+
 func init() {
 	validateLagoonYml.PersistentFlags().BoolP("print-resulting-lagoonyml", "", false,
 		"Display the resulting, post merging, lagoon.yml file.")
+	validateLagoonYml.Flags().Bool("print-schema", false,
+		"Print the JSON schema for .lagoon.yml, this can be used by editors to validate the file.")
 	validateLagoonYml.Flags().Bool("json", false,
 		"Flag output the resulting .lagoon.yml file in JSON.")
 	validateCmd.AddCommand(validateLagoonYml)
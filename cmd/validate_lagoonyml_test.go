@@ -225,3 +225,78 @@ func TestSinglelineCronjobs(t *testing.T) {
 	}
 
 }
+
+func TestValidateLagoonYmlSchema(t *testing.T) {
+	type args struct {
+		lagoonYml                string
+		lagoonOverrideYml        string
+		lagoonOverrideEnvVarFile string
+		projectName              string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "warnings only",
+			args: args{
+				lagoonYml: "internal/testdata/validate-lagoon-yml/schema/lagoon.yml",
+			},
+			want: []string{
+				"internal/testdata/validate-lagoon-yml/schema/lagoon.yml:4:12: warning: environment_variables.git_sha: deprecated: boolean values should not be quoted",
+			},
+		},
+		{
+			name: "errors in the override file and variable",
+			args: args{
+				lagoonYml:                "internal/testdata/validate-lagoon-yml/schema/lagoon.yml",
+				lagoonOverrideYml:        "internal/testdata/validate-lagoon-yml/schema/lagoon-override.yml",
+				lagoonOverrideEnvVarFile: "internal/testdata/validate-lagoon-yml/schema/lagoon-override-env.yml",
+			},
+			want: []string{
+				"internal/testdata/validate-lagoon-yml/schema/lagoon.yml:4:12: warning: environment_variables.git_sha: deprecated: boolean values should not be quoted",
+				"internal/testdata/validate-lagoon-yml/schema/lagoon-override.yml:7:18: error: tasks.pre-rollout.0.run.retries: Must be greater than or equal to 0",
+				"VALIDATE_LAGOON_YML_TEST_ENV:7:20: error: tasks.post-rollout.0.run.onFailure: must be one of the following: \"fail\", \"continue\", \"warn\"",
+			},
+		},
+		{
+			name: "invalid override yaml",
+			args: args{
+				lagoonYml:         "internal/testdata/validate-lagoon-yml/test6/lagoon.yml",
+				lagoonOverrideYml: "internal/testdata/validate-lagoon-yml/test6/lagoon-override.yml",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const testEnvVar = "VALIDATE_LAGOON_YML_TEST_ENV"
+			os.Setenv(testEnvVar, "")
+			if tt.args.lagoonOverrideEnvVarFile != "" {
+				lagoonOverrideEnvVarFileContents, err := os.ReadFile(tt.args.lagoonOverrideEnvVarFile)
+				if err != nil {
+					t.Errorf("Unable to read contents of env var test file '%v'", tt.args.lagoonOverrideEnvVarFile)
+				}
+				os.Setenv(testEnvVar, base64.StdEncoding.EncodeToString(lagoonOverrideEnvVarFileContents))
+			}
+			defer os.Unsetenv(testEnvVar)
+			issues, err := ValidateLagoonYmlSchema(tt.args.lagoonYml, tt.args.lagoonOverrideYml, testEnvVar, tt.args.projectName, false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLagoonYmlSchema() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			got := []string{}
+			for _, issue := range issues {
+				got = append(got, issue.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateLagoonYmlSchema() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package buildlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// EventType is the type of a build event
+type EventType string
+
+const (
+	StepStart EventType = "step_start"
+	StepEnd   EventType = "step_end"
+	Warning   EventType = "warning"
+	Error     EventType = "error"
+	Timing    EventType = "timing"
+	Decision  EventType = "decision"
+)
+
+// the status of a step when it ends
+const (
+	StatusCompleted    = "completed"
+	StatusWithWarnings = "warnings"
+	StatusFailed       = "failed"
+)
+
+// DefaultWarningsFile is the file the legacy build script reads to count the warnings in a build
+const DefaultWarningsFile = "/tmp/warnings"
+
+// Event is a single line in the event log
+type Event struct {
+	Time            time.Time         `json:"time"`
+	Type            EventType         `json:"type"`
+	Step            string            `json:"step,omitempty"`
+	Message         string            `json:"message,omitempty"`
+	Status          string            `json:"status,omitempty"`
+	DurationSeconds float64           `json:"durationSeconds,omitempty"`
+	Fields          map[string]string `json:"fields,omitempty"`
+}
+
+// Logger renders the human readable build log and writes the events as JSON lines to the sink
+type Logger struct {
+	mu           sync.Mutex
+	out          io.Writer
+	sink         io.Writer
+	closer       io.Closer
+	warningsFile string
+	now          func() time.Time
+}
+
+// New returns a logger that renders the build log to out and writes events to sink, if sink is nil no events are written
+func New(out, sink io.Writer) *Logger {
+	return &Logger{
+		out:          out,
+		sink:         sink,
+		warningsFile: DefaultWarningsFile,
+		now:          time.Now,
+	}
+}
+
+// Open returns a logger that renders the build log to stdout and appends events to the file at path,
+// if path is empty no events are written
+func Open(path string) (*Logger, error) {
+	if path == "" {
+		return New(os.Stdout, nil), nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open event log %v: %v", path, err)
+	}
+	l := New(os.Stdout, f)
+	l.closer = f
+	return l, nil
+}
+
+// SetWarningsFile changes the file that warnings are appended to, if file is empty warnings are not recorded to a file
+func (l *Logger) SetWarningsFile(file string) {
+	l.warningsFile = file
+}
+
+// Close closes the event sink if it was opened by the logger
+func (l *Logger) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+// Emit writes the event to the sink, the time is set if it is not provided
+func (l *Logger) Emit(event Event) {
+	if l.sink == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = l.now()
+	}
+	eBytes, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sink.Write(append(eBytes, '\n'))
+}
+
+// Step is a step of the build that was started with StepStart
+type Step struct {
+	logger   *Logger
+	name     string
+	start    time.Time
+	warnings bool
+	fields   map[string]string
+}
+
+// StepStart renders the step header for the build log parser and emits a step start event
+func (l *Logger) StepStart(name string) *Step {
+	s := &Step{
+		logger: l,
+		name:   name,
+		start:  l.now(),
+	}
+	fmt.Fprintf(l.out, "##############################################\nBEGIN %s\n##############################################\n", name)
+	l.Emit(Event{Time: s.start, Type: StepStart, Step: name})
+	return s
+}
+
+// Name returns the name of the step
+func (s *Step) Name() string {
+	return s.name
+}
+
+// Warning records a warning against the step, the step footer will show that the step completed with warnings
+func (s *Step) Warning(message string) error {
+	s.warnings = true
+	return s.logger.Warning(s.name, message)
+}
+
+// Field adds a field to the step end event
+func (s *Step) Field(key, value string) {
+	if s.fields == nil {
+		s.fields = map[string]string{}
+	}
+	s.fields[key] = value
+}
+
+// End renders the step footer for the build log parser and emits a step end event
+func (s *Step) End() {
+	s.end(nil)
+}
+
+// Fail renders the step footer for the build log parser and emits a step end event with the error
+func (s *Step) Fail(err error) {
+	s.end(err)
+}
+
+func (s *Step) end(err error) {
+	et := s.logger.now()
+	duration := et.Sub(s.start)
+	diff := time.Time{}.Add(duration)
+	tz, _ := et.Zone()
+	status := StatusCompleted
+	suffix := ""
+	if s.warnings {
+		status = StatusWithWarnings
+		suffix = " WithWarnings"
+	}
+	event := Event{
+		Time:            et,
+		Type:            StepEnd,
+		Step:            s.name,
+		Status:          status,
+		DurationSeconds: duration.Seconds(),
+		Fields:          s.fields,
+	}
+	if err != nil {
+		event.Status = StatusFailed
+		event.Message = err.Error()
+	}
+	fmt.Fprintf(s.logger.out, "##############################################\nSTEP %s: Completed at %s (%s) Duration %s Elapsed %s%s\n##############################################\n", s.name, et.Format("2006-01-02 15:04:05"), tz, diff.Format("15:04:05"), diff.Format("15:04:05"), suffix)
+	s.logger.Emit(event)
+}
+
+// Warning appends the warning to the warnings file so that the build is flagged as having warnings, and emits a warning event
+func (l *Logger) Warning(step, message string) error {
+	l.Emit(Event{Type: Warning, Step: step, Message: message})
+	if l.warningsFile == "" {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.warningsFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("couldn't open warnings file %v: %v", l.warningsFile, err)
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "%s\n", message); err != nil {
+		return fmt.Errorf("couldn't write to warnings file %v: %v", l.warningsFile, err)
+	}
+	return nil
+}
+
+// Error emits an error event
+func (l *Logger) Error(step string, err error) {
+	l.Emit(Event{Type: Error, Step: step, Message: err.Error()})
+}
+
+// Timing emits a timing event for something that isn't a step of the build
+func (l *Logger) Timing(step string, duration time.Duration, fields map[string]string) {
+	l.Emit(Event{Type: Timing, Step: step, DurationSeconds: duration.Seconds(), Fields: fields})
+}
+
+// Decision emits an event for a decision the build made, like the action taken for a resource
+func (l *Logger) Decision(step, message string, fields map[string]string) {
+	l.Emit(Event{Type: Decision, Step: step, Message: message, Fields: fields})
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultLogger = New(os.Stdout, nil)
+)
+
+// Default returns the logger used by the package level functions
+func Default() *Logger {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultLogger
+}
+
+// SetDefault replaces the logger used by the package level functions
+func SetDefault(l *Logger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLogger = l
+}
+
+// StartStep calls StepStart on the default logger
+func StartStep(name string) *Step {
+	return Default().StepStart(name)
+}
+
+// Warn calls Warning on the default logger
+func Warn(step, message string) error {
+	return Default().Warning(step, message)
+}
+
+// Err calls Error on the default logger
+func Err(step string, err error) {
+	Default().Error(step, err)
+}
+
+// Time calls Timing on the default logger
+func Time(step string, duration time.Duration, fields map[string]string) {
+	Default().Timing(step, duration, fields)
+}
+
+// Decide calls Decision on the default logger
+func Decide(step, message string, fields map[string]string) {
+	Default().Decision(step, message, fields)
+}
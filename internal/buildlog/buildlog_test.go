@@ -0,0 +1,148 @@
+package buildlog
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andreyvit/diff"
+)
+
+func testLogger(t *testing.T) (*Logger, *bytes.Buffer, *bytes.Buffer, string) {
+	out := &bytes.Buffer{}
+	sink := &bytes.Buffer{}
+	l := New(out, sink)
+	warnings := filepath.Join(t.TempDir(), "warnings")
+	l.SetWarningsFile(warnings)
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	calls := 0
+	// every call to now advances the clock by 90 seconds
+	l.now = func() time.Time {
+		ts := start.Add(time.Duration(calls) * 90 * time.Second)
+		calls++
+		return ts
+	}
+	return l, out, sink, warnings
+}
+
+func TestSteps(t *testing.T) {
+	tests := []struct {
+		name         string
+		run          func(l *Logger)
+		wantOut      string
+		wantEvents   string
+		wantWarnings string
+	}{
+		{
+			name: "completed step",
+			run: func(l *Logger) {
+				s := l.StepStart("Pre-Rollout Tasks")
+				s.End()
+			},
+			wantOut: `##############################################
+BEGIN Pre-Rollout Tasks
+##############################################
+##############################################
+STEP Pre-Rollout Tasks: Completed at 2024-01-02 03:05:35 (UTC) Duration 00:01:30 Elapsed 00:01:30
+##############################################
+`,
+			wantEvents: `{"time":"2024-01-02T03:04:05Z","type":"step_start","step":"Pre-Rollout Tasks"}
+{"time":"2024-01-02T03:05:35Z","type":"step_end","step":"Pre-Rollout Tasks","status":"completed","durationSeconds":90}
+`,
+		},
+		{
+			name: "step with warnings",
+			run: func(l *Logger) {
+				s := l.StepStart("pre-rollout - 0")
+				s.Warning("pre-rollout:0")
+				s.End()
+			},
+			wantOut: `##############################################
+BEGIN pre-rollout - 0
+##############################################
+##############################################
+STEP pre-rollout - 0: Completed at 2024-01-02 03:07:05 (UTC) Duration 00:03:00 Elapsed 00:03:00 WithWarnings
+##############################################
+`,
+			wantEvents: `{"time":"2024-01-02T03:04:05Z","type":"step_start","step":"pre-rollout - 0"}
+{"time":"2024-01-02T03:05:35Z","type":"warning","step":"pre-rollout - 0","message":"pre-rollout:0"}
+{"time":"2024-01-02T03:07:05Z","type":"step_end","step":"pre-rollout - 0","status":"warnings","durationSeconds":180}
+`,
+			wantWarnings: "pre-rollout:0\n",
+		},
+		{
+			name: "failed step",
+			run: func(l *Logger) {
+				s := l.StepStart("Post-Rollout drush cr")
+				s.Fail(fmt.Errorf("command terminated with exit code 1"))
+			},
+			wantOut: `##############################################
+BEGIN Post-Rollout drush cr
+##############################################
+##############################################
+STEP Post-Rollout drush cr: Completed at 2024-01-02 03:05:35 (UTC) Duration 00:01:30 Elapsed 00:01:30
+##############################################
+`,
+			wantEvents: `{"time":"2024-01-02T03:04:05Z","type":"step_start","step":"Post-Rollout drush cr"}
+{"time":"2024-01-02T03:05:35Z","type":"step_end","step":"Post-Rollout drush cr","message":"command terminated with exit code 1","status":"failed","durationSeconds":90}
+`,
+		},
+		{
+			name: "errors, timings and decisions",
+			run: func(l *Logger) {
+				l.Error("lagoon-build run deploy", fmt.Errorf("unable to detect namespace"))
+				l.Timing("lagoon-build run deploy", 2500*time.Millisecond, nil)
+				l.Decision("lagoon-build run deploy", "created", map[string]string{"kind": "Deployment", "name": "nginx"})
+			},
+			wantEvents: `{"time":"2024-01-02T03:04:05Z","type":"error","step":"lagoon-build run deploy","message":"unable to detect namespace"}
+{"time":"2024-01-02T03:05:35Z","type":"timing","step":"lagoon-build run deploy","durationSeconds":2.5}
+{"time":"2024-01-02T03:07:05Z","type":"decision","step":"lagoon-build run deploy","message":"created","fields":{"kind":"Deployment","name":"nginx"}}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, out, sink, warnings := testLogger(t)
+			tt.run(l)
+			if !reflect.DeepEqual(out.String(), tt.wantOut) {
+				t.Errorf("build log = \n%v", diff.LineDiff(tt.wantOut, out.String()))
+			}
+			if !reflect.DeepEqual(sink.String(), tt.wantEvents) {
+				t.Errorf("events = \n%v", diff.LineDiff(tt.wantEvents, sink.String()))
+			}
+			wBytes, err := os.ReadFile(warnings)
+			if err != nil && !os.IsNotExist(err) {
+				t.Errorf("couldn't read warnings file: %v", err)
+			}
+			if string(wBytes) != tt.wantWarnings {
+				t.Errorf("warnings = %q, want %q", string(wBytes), tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	for i := 0; i < 2; i++ {
+		l, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		l.SetWarningsFile("")
+		l.Decision("test", fmt.Sprintf("run %d", i), nil)
+		if err := l.Close(); err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+	}
+	eBytes, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("couldn't read event log: %v", err)
+	}
+	if got := bytes.Count(eBytes, []byte("\n")); got != 2 {
+		t.Errorf("event log has %d lines, want 2 as the log should be appended to", got)
+	}
+}
@@ -10,10 +10,39 @@ import (
 	client "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func RunCleanup(c *collector.Collector, gen generator.GeneratorInput, performDeletion bool) ([]string, []string, []string, []string, []string, []string, error) {
-	_, mariadbDelete, mongodbDelete, postgresqlDelete, depDelete, volDelete, servDelete, state, err := identify.GetCurrentState(c, gen)
+func RunCleanup(c *collector.Collector, gen generator.GeneratorInput, performDeletion bool) ([]string, []string, []string, []string, []string, []string, []string, []string, error) {
+	_, mariadbDelete, mongodbDelete, postgresqlDelete, depDelete, volDelete, servDelete, hpaDelete, pdbDelete, state, err := identify.GetCurrentState(c, gen)
 	if err != nil {
-		return nil, nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+	var hpasToDelete, pdbsToDelete []string
+	ctx := context.Background()
+	// horizontalpodautoscalers and poddisruptionbudgets don't hold any data, but one that is left behind will continue to
+	// scale or restrict a deployment that no longer requests it
+	if len(hpaDelete) > 0 || len(pdbDelete) > 0 {
+		fmt.Println(`>> Lagoon detected autoscaling that has been removed from the docker-compose or .lagoon.yml file`)
+		for _, i := range hpaDelete {
+			hpasToDelete = append(hpasToDelete, i.Name)
+			if performDeletion {
+				fmt.Printf(">> Removing horizontalpodautoscaler %s\n", i.Name)
+				if err := c.Client.Delete(ctx, &i); err != nil {
+					fmt.Printf("!! Error removing horizontalpodautoscaler %s\n", i.Name)
+				}
+			} else {
+				fmt.Printf(">> Would remove horizontalpodautoscaler %s\n", i.Name)
+			}
+		}
+		for _, i := range pdbDelete {
+			pdbsToDelete = append(pdbsToDelete, i.Name)
+			if performDeletion {
+				fmt.Printf(">> Removing poddisruptionbudget %s\n", i.Name)
+				if err := c.Client.Delete(ctx, &i); err != nil {
+					fmt.Printf("!! Error removing poddisruptionbudget %s\n", i.Name)
+				}
+			} else {
+				fmt.Printf(">> Would remove poddisruptionbudget %s\n", i.Name)
+			}
+		}
 	}
 	if len(mariadbDelete) > 0 || len(mongodbDelete) > 0 || len(postgresqlDelete) > 0 || len(depDelete) > 0 || len(volDelete) > 0 || len(servDelete) > 0 {
 		fmt.Println(`>> Lagoon detected services or volumes that have been removed from the docker-compose file`)
@@ -32,7 +61,6 @@ func RunCleanup(c *collector.Collector, gen generator.GeneratorInput, performDel
 		fmt.Println(`> Future releases of Lagoon may remove services automatically, you should ensure that your services are up always up to date if you see this warning."`)
 
 		var mariaDBToDelete, mongoDBToDelete, postgresToDelete, volumesToDelete, servicesToDelete, deploymentsToDelete []string
-		ctx := context.Background()
 		for _, i := range depDelete {
 			deploymentsToDelete = append(deploymentsToDelete, i.Name)
 			if performDeletion {
@@ -111,9 +139,9 @@ func RunCleanup(c *collector.Collector, gen generator.GeneratorInput, performDel
 				fmt.Printf(">> Would remove postgresql consumer %s and associated components\n", i.Name)
 			}
 		}
-		return mariaDBToDelete, mongoDBToDelete, postgresToDelete, deploymentsToDelete, volumesToDelete, servicesToDelete, nil
+		return mariaDBToDelete, mongoDBToDelete, postgresToDelete, deploymentsToDelete, volumesToDelete, servicesToDelete, hpasToDelete, pdbsToDelete, nil
 	} else {
-		return nil, nil, nil, nil, nil, nil, nil
+		return nil, nil, nil, nil, nil, nil, hpasToDelete, pdbsToDelete, nil
 	}
 }
 
@@ -31,6 +31,8 @@ func TestRunCleanup(t *testing.T) {
 		wantDep        []string
 		wantVol        []string
 		wantServ       []string
+		wantHPAs       []string
+		wantPDBs       []string
 	}{
 		{
 			name: "basic deployment",
@@ -118,6 +120,76 @@ func TestRunCleanup(t *testing.T) {
 			wantDep:        []string{"nginx-php", "cli", "redis", "varnish"},
 			wantServ:       []string{"nginx-php", "redis", "varnish"},
 		},
+		{
+			name: "complex-nginx-autoscaling",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/complex/lagoon.autoscaling.yml",
+					ImageReferences: map[string]string{
+						"nginx":   "harbor.example/example-project/main/nginx@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"php":     "harbor.example/example-project/main/php@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"cli":     "harbor.example/example-project/main/cli@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"redis":   "harbor.example/example-project/main/redis@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"varnish": "harbor.example/example-project/main/varnish@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+					},
+				}, true),
+			deleteServices: false,
+			namespace:      "example-project-main",
+			seedDir:        "internal/testdata/complex/service-templates/test2-nginx-php-autoscaling",
+			wantDep:        []string{"nginx-php", "cli", "redis", "varnish"},
+			wantServ:       []string{"nginx-php", "redis", "varnish"},
+			wantHPAs:       []string{"nginx-php"},
+			wantPDBs:       []string{"nginx-php", "varnish"},
+		},
+		{
+			name: "complex-nginx-autoscaling-removed",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/complex/lagoon.varnish.yml",
+					ImageReferences: map[string]string{
+						"nginx":   "harbor.example/example-project/main/nginx@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"php":     "harbor.example/example-project/main/php@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"cli":     "harbor.example/example-project/main/cli@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"redis":   "harbor.example/example-project/main/redis@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"varnish": "harbor.example/example-project/main/varnish@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+					},
+				}, true),
+			deleteServices: false,
+			namespace:      "example-project-main",
+			seedDir:        "internal/testdata/complex/service-templates/test2-nginx-php-autoscaling",
+			wantDep:        []string{"nginx-php", "cli", "redis", "varnish"},
+			wantServ:       []string{"nginx-php", "redis", "varnish"},
+			wantHPAs:       []string{"nginx-php"},
+			wantPDBs:       []string{"nginx-php", "varnish"},
+		},
+		{
+			name: "complex-nginx-autoscaling-removed-delete",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/complex/lagoon.varnish.yml",
+					ImageReferences: map[string]string{
+						"nginx":   "harbor.example/example-project/main/nginx@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"php":     "harbor.example/example-project/main/php@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"cli":     "harbor.example/example-project/main/cli@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"redis":   "harbor.example/example-project/main/redis@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+						"varnish": "harbor.example/example-project/main/varnish@sha256:b2001babafaa8128fe89aa8fd11832cade59931d14c3de5b3ca32e2a010fbaa8",
+					},
+				}, true),
+			deleteServices: true,
+			namespace:      "example-project-main",
+			seedDir:        "internal/testdata/complex/service-templates/test2-nginx-php-autoscaling",
+			wantDep:        []string{"nginx-php", "cli", "redis", "varnish"},
+			wantServ:       []string{"nginx-php", "redis", "varnish"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -220,7 +292,7 @@ func TestRunCleanup(t *testing.T) {
 				}
 				want = false
 			}
-			mdb, mongdb, psqdb, dep, vol, serv, err := RunCleanup(col, generator, tt.deleteServices)
+			mdb, mongdb, psqdb, dep, vol, serv, _, _, err := RunCleanup(col, generator, tt.deleteServices)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("RunCleanup() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -285,6 +357,20 @@ func TestRunCleanup(t *testing.T) {
 					}
 				}
 			}
+			// orphaned horizontalpodautoscalers and poddisruptionbudgets are only removed if deletion is enabled
+			var hpas, pdbs []string
+			for _, i1 := range afterState.HPAs.Items {
+				hpas = append(hpas, i1.Name)
+			}
+			for _, i1 := range afterState.PDBs.Items {
+				pdbs = append(pdbs, i1.Name)
+			}
+			if !reflect.DeepEqual(hpas, tt.wantHPAs) {
+				t.Errorf("RunCleanup() horizontalpodautoscalers %v, want %v", hpas, tt.wantHPAs)
+			}
+			if !reflect.DeepEqual(pdbs, tt.wantPDBs) {
+				t.Errorf("RunCleanup() poddisruptionbudgets %v, want %v", pdbs, tt.wantPDBs)
+			}
 		})
 	}
 }
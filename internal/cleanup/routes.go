@@ -0,0 +1,396 @@
+package cleanup
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/uselagoon/build-deploy-tool/internal/collector"
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	corev1 "k8s.io/api/core/v1"
+	networkv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// CertificatePolicy is how the tls secret of a route is treated when the certificates of the route are cleaned up
+type CertificatePolicy string
+
+const (
+	// IssuerManaged certificates are issued by cert-manager or Let's Encrypt, they are reissued if they are needed again
+	// so the secret is removed with the certificate
+	IssuerManaged CertificatePolicy = "issuer-managed"
+	// UserSupplied certificates were added to the environment by a user and can't be recovered, the secret is always retained
+	UserSupplied CertificatePolicy = "user-supplied"
+)
+
+const (
+	ActionRemoved     = "removed"
+	ActionWouldRemove = "would remove"
+	ActionRetained    = "retained"
+	ActionNotFound    = "not found"
+	ActionError       = "error"
+)
+
+const (
+	ReasonAutogeneratedRemoved = "autogenerated route is no longer generated"
+	ReasonRouteRemoved         = "route was removed from the .lagoon.yml or Lagoon API"
+	ReasonTLSAcmeDisabled      = "tls-acme is disabled"
+)
+
+var certificateGVK = schema.GroupVersionKind{
+	Group:   "cert-manager.io",
+	Version: "v1",
+	Kind:    "Certificate",
+}
+
+// RouteCleanupReport is what was, or would be, removed by a route cleanup
+type RouteCleanupReport struct {
+	// RemovedRoutesCleanup is true if routes removed from the .lagoon.yml or Lagoon API are removed from the environment
+	RemovedRoutesCleanup bool `json:"removedRoutesCleanup"`
+	// APIRoutesCleanup is true if the routes are removed because the project has routes managed in the Lagoon API
+	APIRoutesCleanup bool `json:"apiRoutesCleanup"`
+	// RouteSecretsCleanup is true if the issuer managed secrets of removed routes are removed, by default they are retained
+	RouteSecretsCleanup bool                 `json:"routeSecretsCleanup"`
+	Routes              []RouteRemoval       `json:"routes"`
+	Certificates        []CertificateRemoval `json:"certificates"`
+}
+
+// RouteRemoval is a route that was, or would be, removed from the environment
+type RouteRemoval struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// CertificateRemoval is the certificate of a route, the cert-manager certificate is always removed and the secret
+// is only removed if the certificate policy allows it. The certificates of httproutes can also have a referencegrant
+// that allows a gateway in another namespace to use the secret, it is removed with the certificate
+type CertificateRemoval struct {
+	Route          string            `json:"route"`
+	SecretName     string            `json:"secretName"`
+	Reason         string            `json:"reason"`
+	Policy         CertificatePolicy `json:"policy,omitempty"`
+	Issuer         string            `json:"issuer,omitempty"`
+	Certificate    string            `json:"certificate"`
+	ReferenceGrant string            `json:"referenceGrant,omitempty"`
+	Secret         string            `json:"secret"`
+	Error          string            `json:"error,omitempty"`
+	httpRoute      bool
+}
+
+// RunRouteCleanup removes the routes in the environment that the build no longer generates, and the certificates of the routes
+// that were removed or no longer request certificates. Nothing is removed unless performDeletion is true, and the issuer managed
+// secrets of removed routes are only removed if removeRouteSecrets is true
+func RunRouteCleanup(c *collector.Collector, gen generator.GeneratorInput, performDeletion, removeRouteSecrets bool) (*RouteCleanupReport, error) {
+	lagoonBuild, err := generator.NewGenerator(gen)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	ingress, err := c.CollectAllIngress(ctx, gen.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	httpRoutes, err := c.CollectHTTPRoutes(ctx, gen.Namespace)
+	if err != nil {
+		// handle if gateway api crds not installed
+		if !strings.Contains(err.Error(), "no matches for kind") {
+			return nil, err
+		}
+		httpRoutes = &gatewayv1.HTTPRouteList{}
+	}
+	tlsSecrets, err := c.CollectTLSSecrets(ctx, gen.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// the routes that the build generates, autogenerated routes use the service name
+	var autogenerated, custom []string
+	for _, route := range lagoonBuild.AutogeneratedRoutes.Routes {
+		autogenerated = append(autogenerated, route.LagoonService)
+	}
+	for _, route := range lagoonBuild.MainRoutes.Routes {
+		custom = append(custom, route.IngressName)
+	}
+	for _, route := range lagoonBuild.ActiveStandbyRoutes.Routes {
+		custom = append(custom, route.IngressName)
+	}
+
+	report := &RouteCleanupReport{
+		RouteSecretsCleanup: removeRouteSecrets,
+		Routes:              []RouteRemoval{},
+		Certificates:        []CertificateRemoval{},
+	}
+	// routes removed from the .lagoon.yml are only removed if the project has routes in the api, or the flag is enabled
+	apiRoutesCleanup, _ := lagoon.GetLagoonVariable("LAGOON_API_ROUTES_CLEANUP", []string{"internal_system"}, lagoonBuild.BuildValues.EnvironmentVariables)
+	if apiRoutesCleanup != nil && apiRoutesCleanup.Value == "true" {
+		report.APIRoutesCleanup = true
+		report.RemovedRoutesCleanup = true
+	}
+	if strings.ToLower(generator.CheckFeatureFlag("CLEANUP_REMOVED_LAGOON_ROUTES", lagoonBuild.BuildValues.EnvironmentVariables, gen.Debug)) == "enabled" {
+		report.RemovedRoutesCleanup = true
+	}
+
+	removal := func(labels map[string]string, name string) (string, bool) {
+		// routes for acme challenges, and routes that have been labelled to be kept are never removed
+		if labels["acme.cert-manager.io/http01-solver"] == "true" || labels["lagoon.sh/remove"] == "false" {
+			return "", false
+		}
+		if labels["lagoon.sh/autogenerated"] == "true" {
+			return ReasonAutogeneratedRemoved, !slices.Contains(autogenerated, name)
+		}
+		return ReasonRouteRemoved, !slices.Contains(custom, name)
+	}
+	// check if the route can be removed in this cleanup
+	canRemove := func(reason string) bool {
+		return performDeletion && (reason != ReasonRouteRemoved || report.RemovedRoutesCleanup)
+	}
+
+	var keptIngress []networkv1.Ingress
+	var keptHTTPRoutes []gatewayv1.HTTPRoute
+	for _, i := range ingress.Items {
+		// ingress that weren't created by lagoon are never removed, they are only checked for certificates that are no longer requested
+		if _, ok := i.Labels["lagoon.sh/service"]; !ok {
+			keptIngress = append(keptIngress, i)
+			continue
+		}
+		reason, remove := removal(i.Labels, i.Name)
+		if !remove {
+			keptIngress = append(keptIngress, i)
+			continue
+		}
+		if reason == ReasonRouteRemoved {
+			// the certificates of removed custom routes are cleaned up to prevent any renewal attempts
+			for _, secretName := range ingressSecretNames(i) {
+				report.Certificates = append(report.Certificates, CertificateRemoval{
+					Route:      i.Name,
+					SecretName: secretName,
+					Reason:     reason,
+				})
+			}
+		}
+		report.Routes = append(report.Routes, removeRoute(ctx, c.Client, &i, "Ingress", reason, canRemove(reason)))
+	}
+	for _, i := range httpRoutes.Items {
+		// redirect routes are removed with the route they redirect for
+		if i.Labels["route.lagoon.sh/redirect"] == "true" {
+			continue
+		}
+		reason, remove := removal(i.Labels, i.Name)
+		if !remove {
+			keptHTTPRoutes = append(keptHTTPRoutes, i)
+			continue
+		}
+		// the certificates of httproutes are created by the build and not by cert-manager, so they are cleaned up
+		// for autogenerated routes too
+		if secretName, ok := httpRouteSecretName(i); ok {
+			report.Certificates = append(report.Certificates, CertificateRemoval{
+				Route:      i.Name,
+				SecretName: secretName,
+				Reason:     reason,
+				httpRoute:  true,
+			})
+		}
+		report.Routes = append(report.Routes, removeRoute(ctx, c.Client, &i, "HTTPRoute", reason, canRemove(reason)))
+		for _, r := range httpRoutes.Items {
+			if r.Name == fmt.Sprintf("%s-redirect", i.Name) {
+				report.Routes = append(report.Routes, removeRoute(ctx, c.Client, &r, "HTTPRoute", reason, canRemove(reason)))
+			}
+		}
+	}
+
+	// ingress that no longer request certificates have their certificates cleaned up to prevent reissuing attempts
+	for _, i := range keptIngress {
+		if i.Annotations["kubernetes.io/tls-acme"] != "false" {
+			continue
+		}
+		for _, secretName := range ingressSecretNames(i) {
+			report.Certificates = append(report.Certificates, CertificateRemoval{
+				Route:      i.Name,
+				SecretName: secretName,
+				Reason:     ReasonTLSAcmeDisabled,
+			})
+		}
+	}
+	for _, i := range keptHTTPRoutes {
+		if i.Annotations["kubernetes.io/tls-acme"] != "false" {
+			continue
+		}
+		if secretName, ok := httpRouteSecretName(i); ok {
+			report.Certificates = append(report.Certificates, CertificateRemoval{
+				Route:      i.Name,
+				SecretName: secretName,
+				Reason:     ReasonTLSAcmeDisabled,
+				httpRoute:  true,
+			})
+		}
+	}
+	for idx := range report.Certificates {
+		cert := &report.Certificates[idx]
+		remove := canRemove(cert.Reason)
+		// a secret that is still used by another route is never removed, an ingress and httproute can have the same name
+		inUse := false
+		for _, i := range keptIngress {
+			if (cert.httpRoute || i.Name != cert.Route) && slices.Contains(ingressSecretNames(i), cert.SecretName) {
+				inUse = true
+			}
+		}
+		for _, i := range keptHTTPRoutes {
+			if i.Annotations["kubernetes.io/tls-acme"] == "false" || (cert.httpRoute && i.Name == cert.Route) {
+				continue
+			}
+			if secretName, ok := httpRouteSecretName(i); ok && secretName == cert.SecretName {
+				inUse = true
+			}
+		}
+		if inUse {
+			cert.Certificate = ActionRetained
+			if cert.httpRoute {
+				cert.ReferenceGrant = ActionRetained
+			}
+			cert.Secret = ActionRetained
+			continue
+		}
+		cert.Certificate = removeCertificate(ctx, c.Client, gen.Namespace, cert, remove)
+		if cert.httpRoute {
+			cert.ReferenceGrant = removeReferenceGrant(ctx, c.Client, gen.Namespace, cert, remove)
+		}
+		cert.Secret = ActionNotFound
+		for _, secret := range tlsSecrets.Items {
+			if secret.Name != cert.SecretName {
+				continue
+			}
+			cert.Policy, cert.Issuer = certificatePolicy(secret)
+			// the secrets of removed routes are retained unless requested, so the certificate can be reused if the route is added again
+			if cert.Policy == UserSupplied || (cert.Reason == ReasonRouteRemoved && !removeRouteSecrets) {
+				cert.Secret = ActionRetained
+				break
+			}
+			cert.Secret = ActionWouldRemove
+			if remove {
+				cert.Secret = ActionRemoved
+				if err := c.Client.Delete(ctx, &secret); err != nil && !apierrors.IsNotFound(err) {
+					cert.Secret = ActionError
+					cert.Error = fmt.Sprintf("couldn't remove secret %s: %v", secret.Name, err)
+				}
+			}
+		}
+	}
+	return report, nil
+}
+
+func removeRoute(ctx context.Context, c client.Client, obj client.Object, kind, reason string, remove bool) RouteRemoval {
+	result := RouteRemoval{
+		Kind:   kind,
+		Name:   obj.GetName(),
+		Reason: reason,
+		Action: ActionWouldRemove,
+	}
+	if !remove {
+		return result
+	}
+	result.Action = ActionRemoved
+	if err := c.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+		result.Action = ActionError
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// removeCertificate removes the cert-manager certificate for a tls secret, the certificate uses the same name as the secret
+func removeCertificate(ctx context.Context, c client.Client, namespace string, cert *CertificateRemoval, remove bool) string {
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(certificateGVK)
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: cert.SecretName}, certificate); err != nil {
+		// handle if cert-manager crds not installed
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return ActionNotFound
+		}
+		cert.Error = fmt.Sprintf("couldn't get certificate %s: %v", cert.SecretName, err)
+		return ActionError
+	}
+	if !remove {
+		return ActionWouldRemove
+	}
+	if err := c.Delete(ctx, certificate); err != nil && !apierrors.IsNotFound(err) {
+		cert.Error = fmt.Sprintf("couldn't remove certificate %s: %v", cert.SecretName, err)
+		return ActionError
+	}
+	return ActionRemoved
+}
+
+// removeReferenceGrant removes the referencegrant that allows a gateway in another namespace to use the tls secret of an httproute,
+// the referencegrant uses the same name as the secret
+func removeReferenceGrant(ctx context.Context, c client.Client, namespace string, cert *CertificateRemoval, remove bool) string {
+	referenceGrant := &gatewayv1beta1.ReferenceGrant{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: cert.SecretName}, referenceGrant); err != nil {
+		// handle if gateway api crds not installed
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return ActionNotFound
+		}
+		cert.Error = fmt.Sprintf("couldn't get referencegrant %s: %v", cert.SecretName, err)
+		return ActionError
+	}
+	if !remove {
+		return ActionWouldRemove
+	}
+	if err := c.Delete(ctx, referenceGrant); err != nil && !apierrors.IsNotFound(err) {
+		cert.Error = fmt.Sprintf("couldn't remove referencegrant %s: %v", cert.SecretName, err)
+		return ActionError
+	}
+	return ActionRemoved
+}
+
+// certificatePolicy checks if the certificate in a tls secret was issued by cert-manager or Let's Encrypt. Anything that
+// can't be identified as issuer managed, including secrets with a certificate that can't be parsed, is treated as user supplied
+func certificatePolicy(secret corev1.Secret) (CertificatePolicy, string) {
+	if issuer, ok := secret.Annotations["cert-manager.io/issuer-name"]; ok {
+		return IssuerManaged, issuer
+	}
+	block, _ := pem.Decode(secret.Data[corev1.TLSCertKey])
+	if block == nil {
+		return UserSupplied, ""
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return UserSupplied, ""
+	}
+	for _, organization := range cert.Issuer.Organization {
+		if strings.Contains(organization, "Let's Encrypt") {
+			return IssuerManaged, cert.Issuer.String()
+		}
+	}
+	return UserSupplied, cert.Issuer.String()
+}
+
+func ingressSecretNames(ingress networkv1.Ingress) []string {
+	var secretNames []string
+	for _, tls := range ingress.Spec.TLS {
+		if tls.SecretName != "" {
+			secretNames = append(secretNames, tls.SecretName)
+		}
+	}
+	return secretNames
+}
+
+// httpRouteSecretName returns the name of the tls secret of an httproute. The secret is named from the service label of the route
+// the same way the build names it, redirect routes of a route share the secret of the route
+func httpRouteSecretName(route gatewayv1.HTTPRoute) (string, bool) {
+	service, ok := route.Labels["lagoon.sh/service"]
+	if !ok || service == "" {
+		return "", false
+	}
+	return fmt.Sprintf("%s-tls", service), true
+}
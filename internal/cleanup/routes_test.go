@@ -0,0 +1,464 @@
+package cleanup
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/uselagoon/build-deploy-tool/internal/collector"
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/k8s"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"github.com/uselagoon/build-deploy-tool/internal/testdata"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	// changes the testing to source from root so paths to test resources must be defined from repo root
+	_ "github.com/uselagoon/build-deploy-tool/internal/testing"
+)
+
+func TestRunRouteCleanup(t *testing.T) {
+	tests := []struct {
+		name                string
+		args                testdata.TestData
+		performDeletion     bool
+		removeRouteSecrets  bool
+		seedDir             string
+		want                *RouteCleanupReport
+		wantRoutes          []string
+		wantSecrets         []string
+		wantCertificates    []string
+		wantReferenceGrants []string
+	}{
+		{
+			name: "dry run",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/node/lagoon.yml",
+				}, true),
+			seedDir: "internal/testdata/node/route-cleanup-seed/route-cleanup",
+			want: &RouteCleanupReport{
+				Routes: []RouteRemoval{
+					{Kind: "Ingress", Name: "oldnode", Reason: ReasonAutogeneratedRemoved, Action: ActionWouldRemove},
+					{Kind: "Ingress", Name: "www.example.com", Reason: ReasonRouteRemoved, Action: ActionWouldRemove},
+					{Kind: "HTTPRoute", Name: "old.example.com", Reason: ReasonRouteRemoved, Action: ActionWouldRemove},
+					{Kind: "HTTPRoute", Name: "old.example.com-redirect", Reason: ReasonRouteRemoved, Action: ActionWouldRemove},
+				},
+				Certificates: []CertificateRemoval{
+					{
+						Route:       "www.example.com",
+						SecretName:  "www.example.com-tls",
+						Reason:      ReasonRouteRemoved,
+						Policy:      IssuerManaged,
+						Issuer:      "CN=R3,O=Let's Encrypt,C=US",
+						Certificate: ActionWouldRemove,
+						Secret:      ActionRetained,
+					},
+					{
+						Route:          "old.example.com",
+						SecretName:     "old.example.com-tls",
+						Reason:         ReasonRouteRemoved,
+						Policy:         IssuerManaged,
+						Issuer:         "lagoon-issuer",
+						Certificate:    ActionWouldRemove,
+						ReferenceGrant: ActionWouldRemove,
+						Secret:         ActionRetained,
+						httpRoute:      true,
+					},
+					{
+						Route:       "example.com",
+						SecretName:  "example.com-tls",
+						Reason:      ReasonTLSAcmeDisabled,
+						Policy:      UserSupplied,
+						Issuer:      "CN=example.com,O=Example Org,C=AU",
+						Certificate: ActionWouldRemove,
+						Secret:      ActionRetained,
+					},
+					{
+						Route:       "manual.example.com",
+						SecretName:  "manual.example.com-tls",
+						Reason:      ReasonTLSAcmeDisabled,
+						Policy:      IssuerManaged,
+						Issuer:      "CN=R3,O=Let's Encrypt,C=US",
+						Certificate: ActionWouldRemove,
+						Secret:      ActionWouldRemove,
+					},
+					{
+						Route:          "node",
+						SecretName:     "node-tls",
+						Reason:         ReasonTLSAcmeDisabled,
+						Certificate:    ActionRetained,
+						ReferenceGrant: ActionRetained,
+						Secret:         ActionRetained,
+						httpRoute:      true,
+					},
+				},
+			},
+			wantRoutes:          []string{"example.com", "keep.example.com", "manual.example.com", "node", "oldnode", "www.example.com", "node", "old.example.com", "old.example.com-redirect"},
+			wantSecrets:         []string{"example.com-tls", "manual.example.com-tls", "node-tls", "old.example.com-tls", "www.example.com-tls"},
+			wantCertificates:    []string{"example.com-tls", "manual.example.com-tls", "node-tls", "old.example.com-tls", "www.example.com-tls"},
+			wantReferenceGrants: []string{"old.example.com-tls"},
+		},
+		{
+			name: "delete without removed routes cleanup",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/node/lagoon.yml",
+				}, true),
+			performDeletion: true,
+			seedDir:         "internal/testdata/node/route-cleanup-seed/route-cleanup",
+			want: &RouteCleanupReport{
+				Routes: []RouteRemoval{
+					{Kind: "Ingress", Name: "oldnode", Reason: ReasonAutogeneratedRemoved, Action: ActionRemoved},
+					{Kind: "Ingress", Name: "www.example.com", Reason: ReasonRouteRemoved, Action: ActionWouldRemove},
+					{Kind: "HTTPRoute", Name: "old.example.com", Reason: ReasonRouteRemoved, Action: ActionWouldRemove},
+					{Kind: "HTTPRoute", Name: "old.example.com-redirect", Reason: ReasonRouteRemoved, Action: ActionWouldRemove},
+				},
+				Certificates: []CertificateRemoval{
+					{
+						Route:       "www.example.com",
+						SecretName:  "www.example.com-tls",
+						Reason:      ReasonRouteRemoved,
+						Policy:      IssuerManaged,
+						Issuer:      "CN=R3,O=Let's Encrypt,C=US",
+						Certificate: ActionWouldRemove,
+						Secret:      ActionRetained,
+					},
+					{
+						Route:          "old.example.com",
+						SecretName:     "old.example.com-tls",
+						Reason:         ReasonRouteRemoved,
+						Policy:         IssuerManaged,
+						Issuer:         "lagoon-issuer",
+						Certificate:    ActionWouldRemove,
+						ReferenceGrant: ActionWouldRemove,
+						Secret:         ActionRetained,
+						httpRoute:      true,
+					},
+					{
+						Route:       "example.com",
+						SecretName:  "example.com-tls",
+						Reason:      ReasonTLSAcmeDisabled,
+						Policy:      UserSupplied,
+						Issuer:      "CN=example.com,O=Example Org,C=AU",
+						Certificate: ActionRemoved,
+						Secret:      ActionRetained,
+					},
+					{
+						Route:       "manual.example.com",
+						SecretName:  "manual.example.com-tls",
+						Reason:      ReasonTLSAcmeDisabled,
+						Policy:      IssuerManaged,
+						Issuer:      "CN=R3,O=Let's Encrypt,C=US",
+						Certificate: ActionRemoved,
+						Secret:      ActionRemoved,
+					},
+					{
+						Route:          "node",
+						SecretName:     "node-tls",
+						Reason:         ReasonTLSAcmeDisabled,
+						Certificate:    ActionRetained,
+						ReferenceGrant: ActionRetained,
+						Secret:         ActionRetained,
+						httpRoute:      true,
+					},
+				},
+			},
+			wantRoutes:          []string{"example.com", "keep.example.com", "manual.example.com", "node", "www.example.com", "node", "old.example.com", "old.example.com-redirect"},
+			wantSecrets:         []string{"example.com-tls", "node-tls", "old.example.com-tls", "www.example.com-tls"},
+			wantCertificates:    []string{"node-tls", "old.example.com-tls", "www.example.com-tls"},
+			wantReferenceGrants: []string{"old.example.com-tls"},
+		},
+		{
+			name: "delete with removed routes cleanup",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/node/lagoon.yml",
+					ProjectVariables: []lagoon.EnvironmentVariable{
+						{
+							Name:  "LAGOON_FEATURE_FLAG_CLEANUP_REMOVED_LAGOON_ROUTES",
+							Value: "enabled",
+							Scope: "build",
+						},
+					},
+				}, true),
+			performDeletion: true,
+			seedDir:         "internal/testdata/node/route-cleanup-seed/route-cleanup",
+			want: &RouteCleanupReport{
+				RemovedRoutesCleanup: true,
+				Routes: []RouteRemoval{
+					{Kind: "Ingress", Name: "oldnode", Reason: ReasonAutogeneratedRemoved, Action: ActionRemoved},
+					{Kind: "Ingress", Name: "www.example.com", Reason: ReasonRouteRemoved, Action: ActionRemoved},
+					{Kind: "HTTPRoute", Name: "old.example.com", Reason: ReasonRouteRemoved, Action: ActionRemoved},
+					{Kind: "HTTPRoute", Name: "old.example.com-redirect", Reason: ReasonRouteRemoved, Action: ActionRemoved},
+				},
+				Certificates: []CertificateRemoval{
+					{
+						Route:       "www.example.com",
+						SecretName:  "www.example.com-tls",
+						Reason:      ReasonRouteRemoved,
+						Policy:      IssuerManaged,
+						Issuer:      "CN=R3,O=Let's Encrypt,C=US",
+						Certificate: ActionRemoved,
+						Secret:      ActionRetained,
+					},
+					{
+						Route:          "old.example.com",
+						SecretName:     "old.example.com-tls",
+						Reason:         ReasonRouteRemoved,
+						Policy:         IssuerManaged,
+						Issuer:         "lagoon-issuer",
+						Certificate:    ActionRemoved,
+						ReferenceGrant: ActionRemoved,
+						Secret:         ActionRetained,
+						httpRoute:      true,
+					},
+					{
+						Route:       "example.com",
+						SecretName:  "example.com-tls",
+						Reason:      ReasonTLSAcmeDisabled,
+						Policy:      UserSupplied,
+						Issuer:      "CN=example.com,O=Example Org,C=AU",
+						Certificate: ActionRemoved,
+						Secret:      ActionRetained,
+					},
+					{
+						Route:       "manual.example.com",
+						SecretName:  "manual.example.com-tls",
+						Reason:      ReasonTLSAcmeDisabled,
+						Policy:      IssuerManaged,
+						Issuer:      "CN=R3,O=Let's Encrypt,C=US",
+						Certificate: ActionRemoved,
+						Secret:      ActionRemoved,
+					},
+					{
+						Route:          "node",
+						SecretName:     "node-tls",
+						Reason:         ReasonTLSAcmeDisabled,
+						Certificate:    ActionRetained,
+						ReferenceGrant: ActionRetained,
+						Secret:         ActionRetained,
+						httpRoute:      true,
+					},
+				},
+			},
+			wantRoutes:       []string{"example.com", "keep.example.com", "manual.example.com", "node", "node"},
+			wantSecrets:      []string{"example.com-tls", "node-tls", "old.example.com-tls", "www.example.com-tls"},
+			wantCertificates: []string{"node-tls"},
+		},
+		{
+			name: "delete with removed routes and route secrets cleanup",
+			args: testdata.GetSeedData(
+				testdata.TestData{
+					ProjectName:     "example-project",
+					EnvironmentName: "main",
+					Branch:          "main",
+					LagoonYAML:      "internal/testdata/node/lagoon.yml",
+					ProjectVariables: []lagoon.EnvironmentVariable{
+						{
+							Name:  "LAGOON_FEATURE_FLAG_CLEANUP_REMOVED_LAGOON_ROUTES",
+							Value: "enabled",
+							Scope: "build",
+						},
+					},
+				}, true),
+			performDeletion:    true,
+			removeRouteSecrets: true,
+			seedDir:            "internal/testdata/node/route-cleanup-seed/route-cleanup",
+			want: &RouteCleanupReport{
+				RemovedRoutesCleanup: true,
+				RouteSecretsCleanup:  true,
+				Routes: []RouteRemoval{
+					{Kind: "Ingress", Name: "oldnode", Reason: ReasonAutogeneratedRemoved, Action: ActionRemoved},
+					{Kind: "Ingress", Name: "www.example.com", Reason: ReasonRouteRemoved, Action: ActionRemoved},
+					{Kind: "HTTPRoute", Name: "old.example.com", Reason: ReasonRouteRemoved, Action: ActionRemoved},
+					{Kind: "HTTPRoute", Name: "old.example.com-redirect", Reason: ReasonRouteRemoved, Action: ActionRemoved},
+				},
+				Certificates: []CertificateRemoval{
+					{
+						Route:       "www.example.com",
+						SecretName:  "www.example.com-tls",
+						Reason:      ReasonRouteRemoved,
+						Policy:      IssuerManaged,
+						Issuer:      "CN=R3,O=Let's Encrypt,C=US",
+						Certificate: ActionRemoved,
+						Secret:      ActionRemoved,
+					},
+					{
+						Route:          "old.example.com",
+						SecretName:     "old.example.com-tls",
+						Reason:         ReasonRouteRemoved,
+						Policy:         IssuerManaged,
+						Issuer:         "lagoon-issuer",
+						Certificate:    ActionRemoved,
+						ReferenceGrant: ActionRemoved,
+						Secret:         ActionRemoved,
+						httpRoute:      true,
+					},
+					{
+						Route:       "example.com",
+						SecretName:  "example.com-tls",
+						Reason:      ReasonTLSAcmeDisabled,
+						Policy:      UserSupplied,
+						Issuer:      "CN=example.com,O=Example Org,C=AU",
+						Certificate: ActionRemoved,
+						Secret:      ActionRetained,
+					},
+					{
+						Route:       "manual.example.com",
+						SecretName:  "manual.example.com-tls",
+						Reason:      ReasonTLSAcmeDisabled,
+						Policy:      IssuerManaged,
+						Issuer:      "CN=R3,O=Let's Encrypt,C=US",
+						Certificate: ActionRemoved,
+						Secret:      ActionRemoved,
+					},
+					{
+						Route:          "node",
+						SecretName:     "node-tls",
+						Reason:         ReasonTLSAcmeDisabled,
+						Certificate:    ActionRetained,
+						ReferenceGrant: ActionRetained,
+						Secret:         ActionRetained,
+						httpRoute:      true,
+					},
+				},
+			},
+			wantRoutes:       []string{"example.com", "keep.example.com", "manual.example.com", "node", "node"},
+			wantSecrets:      []string{"example.com-tls", "node-tls"},
+			wantCertificates: []string{"node-tls"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			helpers.UnsetEnvVars(nil) //unset variables before running tests
+			namespace := "example-project-main"
+			gen, err := testdata.SetupEnvironment(generator.GeneratorInput{}, "testoutput", tt.args)
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			gen.Namespace = namespace
+			client, err := k8s.NewFakeClient(namespace)
+			if err != nil {
+				t.Errorf("error creating fake client")
+			}
+			err = k8s.SeedFakeData(client, namespace, tt.seedDir)
+			if err != nil {
+				t.Errorf("error seeding fake data: %v", err)
+			}
+			col := collector.NewCollector(client)
+			got, err := RunRouteCleanup(col, gen, tt.performDeletion, tt.removeRouteSecrets)
+			if err != nil {
+				t.Errorf("RunRouteCleanup() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RunRouteCleanup() = %v, want %v", got, tt.want)
+			}
+
+			// check what is left in the environment after the cleanup
+			ctx := context.Background()
+			var routes []string
+			ingress, _ := col.CollectAllIngress(ctx, namespace)
+			for _, i := range ingress.Items {
+				routes = append(routes, i.Name)
+			}
+			httpRoutes, _ := col.CollectHTTPRoutes(ctx, namespace)
+			for _, i := range httpRoutes.Items {
+				routes = append(routes, i.Name)
+			}
+			if !reflect.DeepEqual(routes, tt.wantRoutes) {
+				t.Errorf("RunRouteCleanup() routes = %v, want %v", routes, tt.wantRoutes)
+			}
+			var secrets []string
+			tlsSecrets, _ := col.CollectTLSSecrets(ctx, namespace)
+			for _, i := range tlsSecrets.Items {
+				secrets = append(secrets, i.Name)
+			}
+			if !reflect.DeepEqual(secrets, tt.wantSecrets) {
+				t.Errorf("RunRouteCleanup() secrets = %v, want %v", secrets, tt.wantSecrets)
+			}
+			var certificates []string
+			certificateList := &unstructured.UnstructuredList{}
+			certificateList.SetGroupVersionKind(certificateGVK.GroupVersion().WithKind("CertificateList"))
+			if err := client.List(ctx, certificateList); err != nil {
+				t.Errorf("couldn't list certificates: %v", err)
+			}
+			for _, i := range certificateList.Items {
+				certificates = append(certificates, i.GetName())
+			}
+			if !reflect.DeepEqual(certificates, tt.wantCertificates) {
+				t.Errorf("RunRouteCleanup() certificates = %v, want %v", certificates, tt.wantCertificates)
+			}
+			var referenceGrants []string
+			referenceGrantList := &gatewayv1beta1.ReferenceGrantList{}
+			if err := client.List(ctx, referenceGrantList); err != nil {
+				t.Errorf("couldn't list referencegrants: %v", err)
+			}
+			for _, i := range referenceGrantList.Items {
+				referenceGrants = append(referenceGrants, i.Name)
+			}
+			if !reflect.DeepEqual(referenceGrants, tt.wantReferenceGrants) {
+				t.Errorf("RunRouteCleanup() referencegrants = %v, want %v", referenceGrants, tt.wantReferenceGrants)
+			}
+		})
+	}
+}
+
+func Test_certificatePolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		secret     corev1.Secret
+		wantPolicy CertificatePolicy
+		wantIssuer string
+	}{
+		{
+			name: "no certificate",
+			secret: corev1.Secret{
+				Data: map[string][]byte{},
+			},
+			wantPolicy: UserSupplied,
+		},
+		{
+			name: "cert-manager issued",
+			secret: func() corev1.Secret {
+				s := corev1.Secret{}
+				s.Annotations = map[string]string{"cert-manager.io/issuer-name": "lagoon-issuer"}
+				return s
+			}(),
+			wantPolicy: IssuerManaged,
+			wantIssuer: "lagoon-issuer",
+		},
+		{
+			name: "invalid certificate",
+			secret: corev1.Secret{
+				Data: map[string][]byte{
+					corev1.TLSCertKey: []byte("-----BEGIN CERTIFICATE-----\nbm90IGEgY2VydGlmaWNhdGU=\n-----END CERTIFICATE-----\n"),
+				},
+			},
+			wantPolicy: UserSupplied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, issuer := certificatePolicy(tt.secret)
+			if policy != tt.wantPolicy {
+				t.Errorf("certificatePolicy() policy = %v, want %v", policy, tt.wantPolicy)
+			}
+			if issuer != tt.wantIssuer {
+				t.Errorf("certificatePolicy() issuer = %v, want %v", issuer, tt.wantIssuer)
+			}
+		})
+	}
+}
@@ -2,21 +2,28 @@ package collector
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"reflect"
+	"slices"
 	"strings"
 
 	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
 	k8upv1alpha1 "github.com/vshn/k8up/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 
 	mariadbv1 "github.com/amazeeio/dbaas-operator/apis/mariadb/v1"
 	mongodbv1 "github.com/amazeeio/dbaas-operator/apis/mongodb/v1"
 	postgresv1 "github.com/amazeeio/dbaas-operator/apis/postgres/v1"
 	client "sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 )
 
 type Collector struct {
@@ -24,20 +31,77 @@ type Collector struct {
 }
 
 type LagoonEnvState struct {
-	Deployments           *appsv1.DeploymentList             `json:"deployments"`
-	Cronjobs              *batchv1.CronJobList               `json:"cronjobs"`
-	Ingress               *networkv1.IngressList             `json:"ingress"`
-	Services              *corev1.ServiceList                `json:"services"`
-	Secrets               *corev1.SecretList                 `json:"secrets"`
-	PVCs                  *corev1.PersistentVolumeClaimList  `json:"pvcs"`
-	SchedulesV1           *k8upv1.ScheduleList               `json:"schedulesv1"`
-	SchedulesV1Alpha1     *k8upv1alpha1.ScheduleList         `json:"schedulesv1alpha1"`
-	PreBackupPodsV1       *k8upv1.PreBackupPodList           `json:"prebackuppodsv1"`
-	PreBackupPodsV1Alpha1 *k8upv1alpha1.PreBackupPodList     `json:"prebackuppodsv1alpha1"`
-	MariaDBConsumers      *mariadbv1.MariaDBConsumerList     `json:"mariadbconsumers"`
-	MongoDBConsumers      *mongodbv1.MongoDBConsumerList     `json:"mongodbconsumers"`
-	PostgreSQLConsumers   *postgresv1.PostgreSQLConsumerList `json:"postgresqlconsumers"`
-	NetworkPolicies       *networkv1.NetworkPolicyList       `json:"networkpolicies"`
+	Deployments           *appsv1.DeploymentList                     `json:"deployments"`
+	Cronjobs              *batchv1.CronJobList                       `json:"cronjobs"`
+	Ingress               *networkv1.IngressList                     `json:"ingress"`
+	Services              *corev1.ServiceList                        `json:"services"`
+	Secrets               *corev1.SecretList                         `json:"secrets"`
+	TLSSecrets            *corev1.SecretList                         `json:"tlssecrets"`
+	PVCs                  *corev1.PersistentVolumeClaimList          `json:"pvcs"`
+	SchedulesV1           *k8upv1.ScheduleList                       `json:"schedulesv1"`
+	SchedulesV1Alpha1     *k8upv1alpha1.ScheduleList                 `json:"schedulesv1alpha1"`
+	PreBackupPodsV1       *k8upv1.PreBackupPodList                   `json:"prebackuppodsv1"`
+	PreBackupPodsV1Alpha1 *k8upv1alpha1.PreBackupPodList             `json:"prebackuppodsv1alpha1"`
+	MariaDBConsumers      *mariadbv1.MariaDBConsumerList             `json:"mariadbconsumers"`
+	MongoDBConsumers      *mongodbv1.MongoDBConsumerList             `json:"mongodbconsumers"`
+	PostgreSQLConsumers   *postgresv1.PostgreSQLConsumerList         `json:"postgresqlconsumers"`
+	NetworkPolicies       *networkv1.NetworkPolicyList               `json:"networkpolicies"`
+	HPAs                  *autoscalingv2.HorizontalPodAutoscalerList `json:"hpas"`
+	PDBs                  *policyv1.PodDisruptionBudgetList          `json:"pdbs"`
+	HTTPRoutes            *gatewayv1.HTTPRouteList                   `json:"httproutes"`
+}
+
+// LoadState reads a LagoonEnvState from a file created by `collect environment`
+func LoadState(file string) (*LagoonEnvState, error) {
+	stateBytes, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read file %v: %v", file, err)
+	}
+	state := &LagoonEnvState{}
+	if err := json.Unmarshal(stateBytes, state); err != nil {
+		return nil, fmt.Errorf("error unmarshalling state file %v: %v", file, err)
+	}
+	return state, nil
+}
+
+// Objects returns all the objects in the state, lists that weren't collected are skipped
+func (s *LagoonEnvState) Objects() ([]client.Object, error) {
+	var objects []client.Object
+	for _, list := range []client.ObjectList{
+		s.Deployments,
+		s.Cronjobs,
+		s.Ingress,
+		s.Services,
+		s.Secrets,
+		s.TLSSecrets,
+		s.PVCs,
+		s.SchedulesV1,
+		s.SchedulesV1Alpha1,
+		s.PreBackupPodsV1,
+		s.PreBackupPodsV1Alpha1,
+		s.MariaDBConsumers,
+		s.MongoDBConsumers,
+		s.PostgreSQLConsumers,
+		s.NetworkPolicies,
+		s.HPAs,
+		s.PDBs,
+		s.HTTPRoutes,
+	} {
+		// the state stores pointers to the lists, so a list that wasn't collected isn't a nil interface
+		if list == nil || reflect.ValueOf(list).IsNil() {
+			continue
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if obj, ok := item.(client.Object); ok {
+				objects = append(objects, obj)
+			}
+		}
+	}
+	return objects, nil
 }
 
 func NewCollector(client client.Client) *Collector {
@@ -69,6 +133,17 @@ func (c *Collector) Collect(ctx context.Context, namespace string) (*LagoonEnvSt
 	if err != nil {
 		return nil, err
 	}
+	// tls secrets that have lagoon labels are already collected with the secrets
+	tlsSecrets, err := c.CollectTLSSecrets(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	state.TLSSecrets = &corev1.SecretList{Items: []corev1.Secret{}}
+	for _, tlsSecret := range tlsSecrets.Items {
+		if !slices.ContainsFunc(state.Secrets.Items, func(secret corev1.Secret) bool { return secret.Name == tlsSecret.Name }) {
+			state.TLSSecrets.Items = append(state.TLSSecrets.Items, tlsSecret)
+		}
+	}
 	state.PVCs, err = c.CollectPVCs(ctx, namespace)
 	if err != nil {
 		return nil, err
@@ -133,5 +208,21 @@ func (c *Collector) Collect(ctx context.Context, namespace string) (*LagoonEnvSt
 	if err != nil {
 		return nil, err
 	}
+	state.HPAs, err = c.CollectHorizontalPodAutoscalers(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	state.PDBs, err = c.CollectPodDisruptionBudgets(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	state.HTTPRoutes, err = c.CollectHTTPRoutes(ctx, namespace)
+	if err != nil {
+		// handle if gateway api crds not installed
+		if !strings.Contains(err.Error(), "no matches for kind") {
+			fmt.Fprintln(os.Stderr, err)
+			return nil, err
+		}
+	}
 	return &state, nil
 }
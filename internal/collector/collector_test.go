@@ -55,6 +55,16 @@ func TestCollector_Collect(t *testing.T) {
 			want:    "testdata/result/result-3",
 			wantErr: false,
 		},
+		{
+			name: "list-environment-autoscaling",
+			args: args{
+				ctx:       context.Background(),
+				namespace: "example-project-main",
+			},
+			seedDir: "testdata/seed/seed-4",
+			want:    "testdata/result/result-4",
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -113,6 +123,12 @@ func TestCollector_Collect(t *testing.T) {
 			if len(got.NetworkPolicies.Items) > 0 {
 				checkResult(t, fmt.Sprintf("%s/%s", tt.want, "lagoon-networkpolicies.yaml"), got.NetworkPolicies)
 			}
+			if len(got.HPAs.Items) > 0 {
+				checkResult(t, fmt.Sprintf("%s/%s", tt.want, "lagoon-horizontalpodautoscalers.yaml"), got.HPAs)
+			}
+			if len(got.PDBs.Items) > 0 {
+				checkResult(t, fmt.Sprintf("%s/%s", tt.want, "lagoon-poddisruptionbudgets.yaml"), got.PDBs)
+			}
 		})
 	}
 }
@@ -0,0 +1,26 @@
+package collector
+
+import (
+	"context"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func (c *Collector) CollectHorizontalPodAutoscalers(ctx context.Context, namespace string) (*autoscalingv2.HorizontalPodAutoscalerList, error) {
+	labelRequirements1, _ := labels.NewRequirement("lagoon.sh/service", selection.Exists, nil)
+	listOption := (&client.ListOptions{}).ApplyOptions([]client.ListOption{
+		client.InNamespace(namespace),
+		client.MatchingLabelsSelector{
+			Selector: labels.NewSelector().Add(*labelRequirements1),
+		},
+	})
+	list := &autoscalingv2.HorizontalPodAutoscalerList{}
+	err := c.Client.List(ctx, list, listOption)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
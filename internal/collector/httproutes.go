@@ -0,0 +1,26 @@
+package collector
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func (c *Collector) CollectHTTPRoutes(ctx context.Context, namespace string) (*gatewayv1.HTTPRouteList, error) {
+	labelRequirements1, _ := labels.NewRequirement("lagoon.sh/service", selection.Exists, nil)
+	listOption := (&client.ListOptions{}).ApplyOptions([]client.ListOption{
+		client.InNamespace(namespace),
+		client.MatchingLabelsSelector{
+			Selector: labels.NewSelector().Add(*labelRequirements1),
+		},
+	})
+	list := &gatewayv1.HTTPRouteList{}
+	err := c.Client.List(ctx, list, listOption)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
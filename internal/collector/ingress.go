@@ -24,3 +24,14 @@ func (c *Collector) CollectIngress(ctx context.Context, namespace string) (*netw
 	}
 	return list, nil
 }
+
+// CollectAllIngress collects all the ingress in the namespace, including any ingress that were added by users
+// and don't have any lagoon labels
+func (c *Collector) CollectAllIngress(ctx context.Context, namespace string) (*networkv1.IngressList, error) {
+	list := &networkv1.IngressList{}
+	err := c.Client.List(ctx, list, client.InNamespace(namespace))
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -1,7 +1,10 @@
 package collector
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -9,18 +12,82 @@ import (
 	client "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// the prefix of a secret value that has been replaced with a hash of the value
+const secretHashPrefix = "sha256:"
+
+// CollectSecrets collects the secrets for the services in the namespace, and the secrets created from templates by the build
+// like the lagoon-env and registry secrets which don't have a service label. Label selectors can't match either label, so both are
+// collected and combined. The values of the secrets are replaced with a hash of the value, so they can be compared without being
+// stored in the collected state
 func (c *Collector) CollectSecrets(ctx context.Context, namespace string) (*corev1.SecretList, error) {
-	labelRequirements1, _ := labels.NewRequirement("lagoon.sh/service", selection.Exists, nil)
-	listOption := (&client.ListOptions{}).ApplyOptions([]client.ListOption{
-		client.InNamespace(namespace),
-		client.MatchingLabelsSelector{
-			Selector: labels.NewSelector().Add(*labelRequirements1),
-		},
-	})
+	list := &corev1.SecretList{Items: []corev1.Secret{}}
+	names := map[string]bool{}
+	for _, label := range []string{"lagoon.sh/service", "lagoon.sh/template"} {
+		labelRequirements1, _ := labels.NewRequirement(label, selection.Exists, nil)
+		listOption := (&client.ListOptions{}).ApplyOptions([]client.ListOption{
+			client.InNamespace(namespace),
+			client.MatchingLabelsSelector{
+				Selector: labels.NewSelector().Add(*labelRequirements1),
+			},
+		})
+		labelList := &corev1.SecretList{}
+		err := c.Client.List(ctx, labelList, listOption)
+		if err != nil {
+			return nil, err
+		}
+		for _, secret := range labelList.Items {
+			if !names[secret.Name] {
+				names[secret.Name] = true
+				RedactSecret(&secret)
+				list.Items = append(list.Items, secret)
+			}
+		}
+	}
+	return list, nil
+}
+
+// RedactSecret replaces the values of a secret with a hash of the value, any string data is moved into the data
+// the same way the api does when the secret is created. Values that are already a hash are not changed
+func RedactSecret(secret *corev1.Secret) {
+	if len(secret.Data) == 0 && len(secret.StringData) == 0 {
+		return
+	}
+	data := map[string][]byte{}
+	for k, v := range secret.Data {
+		data[k] = hashSecretValue(v)
+	}
+	for k, v := range secret.StringData {
+		data[k] = hashSecretValue([]byte(v))
+	}
+	secret.Data = data
+	secret.StringData = nil
+}
+
+func hashSecretValue(value []byte) []byte {
+	if bytes.HasPrefix(value, []byte(secretHashPrefix)) {
+		return value
+	}
+	sum := sha256.Sum256(value)
+	return []byte(secretHashPrefix + hex.EncodeToString(sum[:]))
+}
+
+// CollectTLSSecrets collects the tls secrets in the namespace, these are created by cert-manager or added by users
+// for routes so they don't have any lagoon labels. The certificate is used to identify the issuer, so only the private key
+// is replaced with a hash
+func (c *Collector) CollectTLSSecrets(ctx context.Context, namespace string) (*corev1.SecretList, error) {
 	list := &corev1.SecretList{}
-	err := c.Client.List(ctx, list, listOption)
+	err := c.Client.List(ctx, list, client.InNamespace(namespace))
 	if err != nil {
 		return nil, err
 	}
-	return list, nil
+	tlsSecrets := &corev1.SecretList{}
+	for _, secret := range list.Items {
+		if secret.Type == corev1.SecretTypeTLS {
+			if key, ok := secret.Data[corev1.TLSPrivateKeyKey]; ok {
+				secret.Data[corev1.TLSPrivateKeyKey] = hashSecretValue(key)
+			}
+			tlsSecrets.Items = append(tlsSecrets.Items, secret)
+		}
+	}
+	return tlsSecrets, nil
 }
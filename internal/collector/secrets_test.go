@@ -42,6 +42,16 @@ func TestCollector_CollectSecrets(t *testing.T) {
 			want:    "testdata/result/result-1/lagoon-secrets.yaml",
 			wantErr: false,
 		},
+		{
+			name: "list-secrets-templates",
+			args: args{
+				ctx:       context.Background(),
+				namespace: "example-project-main",
+			},
+			seedDir: "testdata/seed/seed-5",
+			want:    "testdata/result/result-5/lagoon-secrets.yaml",
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,225 @@
+package dbaaswait
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	mariadbv1 "github.com/amazeeio/dbaas-operator/apis/mariadb/v1"
+	mongodbv1 "github.com/amazeeio/dbaas-operator/apis/mongodb/v1"
+	postgresv1 "github.com/amazeeio/dbaas-operator/apis/postgres/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	// ErrConsumerTimeout is returned when the consumers haven't been provisioned before the waiter timeout
+	ErrConsumerTimeout = errors.New("timed out waiting for database consumers to be provisioned")
+	// ErrConsumerFailed is returned when the dbaas-operator marks a consumer as failed
+	ErrConsumerFailed = errors.New("failed to provision a database")
+)
+
+// FailedAnnotation is set to "true" by the dbaas-operator when it is unable to provision a consumer
+const FailedAnnotation = "dbaas.amazee.io/failed"
+
+// the reason given for a failed consumer when the operator hasn't recorded one
+const defaultFailedReason = "Contact your support team to investigate."
+
+// Waiter watches the dbaas consumers of a build until the dbaas-operator has provisioned them
+type Waiter struct {
+	Client    client.Client
+	Namespace string
+	Timeout   time.Duration
+	Interval  time.Duration
+	Out       io.Writer
+}
+
+// ConsumerStatus is the current state of a dbaas consumer
+type ConsumerStatus struct {
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	Provisioned bool   `json:"provisioned"`
+	Failed      bool   `json:"failed"`
+	Message     string `json:"message"`
+}
+
+func (c ConsumerStatus) String() string {
+	return fmt.Sprintf("%s/%s: %s", c.Kind, c.Name, c.Message)
+}
+
+type consumerUpdate struct {
+	idx    int
+	status ConsumerStatus
+	err    error
+}
+
+// Run watches all the consumers concurrently until they have all been provisioned, one of them fails, or the timeout is reached.
+// Changes in the status of a consumer are written to the waiter output as they are detected.
+func (w *Waiter) Run(ctx context.Context, consumers []client.Object) ([]ConsumerStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.Timeout)
+	defer cancel()
+	statuses := make([]ConsumerStatus, len(consumers))
+	updates := make(chan consumerUpdate)
+	var wg sync.WaitGroup
+	for idx, consumer := range consumers {
+		kind, err := consumerKind(consumer)
+		if err != nil {
+			return nil, err
+		}
+		statuses[idx] = ConsumerStatus{Kind: kind, Name: consumer.GetName()}
+		wg.Add(1)
+		go func(idx int, consumer client.Object, kind string) {
+			defer wg.Done()
+			w.watch(ctx, idx, consumer, kind, updates)
+		}(idx, consumer, kind)
+	}
+	go func() {
+		wg.Wait()
+		close(updates)
+	}()
+
+	var runErr error
+	for update := range updates {
+		if runErr != nil {
+			// drain the remaining updates once the wait has failed
+			continue
+		}
+		if update.err != nil {
+			runErr = update.err
+			cancel()
+			continue
+		}
+		previous := statuses[update.idx].Message
+		statuses[update.idx] = update.status
+		if update.status.Message != previous {
+			fmt.Fprintln(w.Out, update.status.String())
+		}
+		if update.status.Failed {
+			// fail fast, there is no point waiting for the other consumers
+			runErr = ErrConsumerFailed
+			cancel()
+		}
+	}
+	if runErr != nil {
+		w.report(statuses)
+		return statuses, runErr
+	}
+	for _, status := range statuses {
+		if !status.Provisioned {
+			w.report(statuses)
+			return statuses, ErrConsumerTimeout
+		}
+	}
+	return statuses, nil
+}
+
+// watch polls a consumer until it is provisioned, has failed, or the context is cancelled
+func (w *Waiter) watch(ctx context.Context, idx int, consumer client.Object, kind string, updates chan<- consumerUpdate) {
+	for {
+		status, err := w.check(ctx, consumer, kind)
+		if err != nil {
+			if ctx.Err() == nil {
+				updates <- consumerUpdate{idx: idx, err: err}
+			}
+			return
+		}
+		updates <- consumerUpdate{idx: idx, status: status}
+		if status.Provisioned || status.Failed {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.Interval):
+		}
+	}
+}
+
+// check returns the current status of a consumer, a consumer is provisioned once the operator has set the database in the consumer spec
+func (w *Waiter) check(ctx context.Context, consumer client.Object, kind string) (ConsumerStatus, error) {
+	status := ConsumerStatus{Kind: kind, Name: consumer.GetName()}
+	current := consumer.DeepCopyObject().(client.Object)
+	if err := w.Client.Get(ctx, client.ObjectKey{Namespace: w.Namespace, Name: consumer.GetName()}, current); err != nil {
+		if apierrors.IsNotFound(err) {
+			status.Message = "waiting for consumer to be created"
+			return status, nil
+		}
+		return status, fmt.Errorf("unable to get %s %s: %v", kind, consumer.GetName(), err)
+	}
+	if current.GetAnnotations()[FailedAnnotation] == "true" {
+		reason, err := w.failedReason(ctx, kind, consumer.GetName())
+		if err != nil {
+			return status, err
+		}
+		status.Failed = true
+		status.Message = fmt.Sprintf("%s: %s", ErrConsumerFailed.Error(), reason)
+		return status, nil
+	}
+	if database := consumerDatabase(current); database != "" {
+		status.Provisioned = true
+		status.Message = fmt.Sprintf("provisioned database %s", database)
+		return status, nil
+	}
+	status.Message = "waiting for the dbaas-operator to provision the database"
+	return status, nil
+}
+
+// failedReason returns the message of the most recent warning event the operator recorded against the consumer
+func (w *Waiter) failedReason(ctx context.Context, kind, name string) (string, error) {
+	events := &corev1.EventList{}
+	if err := w.Client.List(ctx, events, client.InNamespace(w.Namespace)); err != nil {
+		return "", fmt.Errorf("unable to list events for %s %s: %v", kind, name, err)
+	}
+	sort.SliceStable(events.Items, func(i, j int) bool {
+		return events.Items[i].LastTimestamp.Before(&events.Items[j].LastTimestamp)
+	})
+	reason := defaultFailedReason
+	for _, event := range events.Items {
+		if event.InvolvedObject.Kind != kind || event.InvolvedObject.Name != name || event.Type != corev1.EventTypeWarning {
+			continue
+		}
+		reason = event.Message
+	}
+	return reason, nil
+}
+
+// report writes the final status of all the consumers
+func (w *Waiter) report(statuses []ConsumerStatus) {
+	fmt.Fprintln(w.Out, "Database consumer status:")
+	for _, status := range statuses {
+		message := status.Message
+		if message == "" {
+			message = "not checked"
+		}
+		fmt.Fprintf(w.Out, "  %s/%s: %s\n", status.Kind, status.Name, message)
+	}
+}
+
+func consumerKind(consumer client.Object) (string, error) {
+	switch consumer.(type) {
+	case *mariadbv1.MariaDBConsumer:
+		return "MariaDBConsumer", nil
+	case *mongodbv1.MongoDBConsumer:
+		return "MongoDBConsumer", nil
+	case *postgresv1.PostgreSQLConsumer:
+		return "PostgreSQLConsumer", nil
+	}
+	return "", fmt.Errorf("unsupported dbaas consumer type %T", consumer)
+}
+
+func consumerDatabase(consumer client.Object) string {
+	switch c := consumer.(type) {
+	case *mariadbv1.MariaDBConsumer:
+		return c.Spec.Consumer.Database
+	case *mongodbv1.MongoDBConsumer:
+		return c.Spec.Consumer.Database
+	case *postgresv1.PostgreSQLConsumer:
+		return c.Spec.Consumer.Database
+	}
+	return ""
+}
@@ -0,0 +1,167 @@
+package dbaaswait
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	mariadbv1 "github.com/amazeeio/dbaas-operator/apis/mariadb/v1"
+	mongodbv1 "github.com/amazeeio/dbaas-operator/apis/mongodb/v1"
+	postgresv1 "github.com/amazeeio/dbaas-operator/apis/postgres/v1"
+	"github.com/uselagoon/build-deploy-tool/internal/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func mariadb(name, database string, annotations map[string]string) *mariadbv1.MariaDBConsumer {
+	c := &mariadbv1.MariaDBConsumer{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations},
+	}
+	c.Spec.Consumer.Database = database
+	return c
+}
+
+func mongodb(name, database string) *mongodbv1.MongoDBConsumer {
+	c := &mongodbv1.MongoDBConsumer{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+	}
+	c.Spec.Consumer.Database = database
+	return c
+}
+
+func postgres(name, database string) *postgresv1.PostgreSQLConsumer {
+	c := &postgresv1.PostgreSQLConsumer{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+	}
+	c.Spec.Consumer.Database = database
+	return c
+}
+
+func TestWaiterRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		consumers  []client.Object
+		objects    []client.Object
+		provision  string
+		wantErr    error
+		wantOutput []string
+	}{
+		{
+			name:      "all consumers provisioned",
+			consumers: []client.Object{mariadb("mariadb", "", nil), mongodb("mongodb", ""), postgres("postgres", "")},
+			objects:   []client.Object{mariadb("mariadb", "mariadb_abcd", nil), mongodb("mongodb", "mongodb_abcd"), postgres("postgres", "postgres_abcd")},
+			wantOutput: []string{
+				"MariaDBConsumer/mariadb: provisioned database mariadb_abcd",
+				"MongoDBConsumer/mongodb: provisioned database mongodb_abcd",
+				"PostgreSQLConsumer/postgres: provisioned database postgres_abcd",
+			},
+		},
+		{
+			name:      "consumer provisioned while waiting",
+			consumers: []client.Object{mariadb("mariadb", "", nil)},
+			objects:   []client.Object{mariadb("mariadb", "", nil)},
+			provision: "mariadb_abcd",
+			wantOutput: []string{
+				"MariaDBConsumer/mariadb: waiting for the dbaas-operator to provision the database",
+				"MariaDBConsumer/mariadb: provisioned database mariadb_abcd",
+			},
+		},
+		{
+			name:      "failed consumer fails fast with the operator reason",
+			consumers: []client.Object{mariadb("mariadb", "", nil), postgres("postgres", "")},
+			objects: []client.Object{
+				mariadb("mariadb", "", map[string]string{FailedAnnotation: "true"}),
+				postgres("postgres", ""),
+				&corev1.Event{
+					ObjectMeta:     metav1.ObjectMeta{Name: "mariadb.1"},
+					InvolvedObject: corev1.ObjectReference{Kind: "MariaDBConsumer", Name: "mariadb"},
+					Type:           corev1.EventTypeWarning,
+					Reason:         "ProvisionFailed",
+					Message:        "no providers available for environment production",
+				},
+			},
+			wantErr: ErrConsumerFailed,
+			wantOutput: []string{
+				"MariaDBConsumer/mariadb: failed to provision a database: no providers available for environment production",
+				"Database consumer status:",
+			},
+		},
+		{
+			name:      "failed consumer without an event",
+			consumers: []client.Object{mariadb("mariadb", "", nil)},
+			objects:   []client.Object{mariadb("mariadb", "", map[string]string{FailedAnnotation: "true"})},
+			wantErr:   ErrConsumerFailed,
+			wantOutput: []string{
+				"MariaDBConsumer/mariadb: failed to provision a database: Contact your support team to investigate.",
+			},
+		},
+		{
+			name:      "missing consumer times out",
+			consumers: []client.Object{mongodb("mongodb", "")},
+			wantErr:   ErrConsumerTimeout,
+			wantOutput: []string{
+				"MongoDBConsumer/mongodb: waiting for consumer to be created",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace := "example-project-main"
+			client, err := k8s.NewFakeClient(namespace)
+			if err != nil {
+				t.Errorf("error creating fake client")
+			}
+			for _, obj := range tt.objects {
+				obj.SetNamespace(namespace)
+				if err := client.Create(context.Background(), obj); err != nil {
+					t.Errorf("error seeding fake data: %v", err)
+				}
+			}
+			timeout := 200 * time.Millisecond
+			if tt.wantErr == ErrConsumerFailed {
+				// a failed consumer should not wait for the timeout
+				timeout = time.Minute
+			}
+			if tt.provision != "" {
+				// the operator sets the database of the mariadb consumer while the waiter is running
+				go func() {
+					time.Sleep(50 * time.Millisecond)
+					existing := &mariadbv1.MariaDBConsumer{}
+					if err := client.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: "mariadb"}, existing); err != nil {
+						t.Errorf("error getting consumer: %v", err)
+						return
+					}
+					existing.Spec.Consumer.Database = tt.provision
+					if err := client.Update(context.Background(), existing); err != nil {
+						t.Errorf("error provisioning consumer: %v", err)
+					}
+				}()
+			}
+			out := &bytes.Buffer{}
+			w := Waiter{
+				Client:    client,
+				Namespace: namespace,
+				Timeout:   timeout,
+				Interval:  10 * time.Millisecond,
+				Out:       out,
+			}
+			start := time.Now()
+			_, err = w.Run(context.Background(), tt.consumers)
+			if err != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if time.Since(start) > 10*time.Second {
+				t.Errorf("Run() took %v", time.Since(start))
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("Run() output missing %q, got:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
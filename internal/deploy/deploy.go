@@ -0,0 +1,176 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// FieldManager is the field manager used for all server-side apply requests made by the tool
+const FieldManager = "build-deploy-tool"
+
+// the order that kinds are applied in, anything that other resources depend on (secrets, volumes, services)
+// needs to exist before the deployments that consume them are rolled out
+// kinds that aren't in this list are applied last in the order they were provided
+var applyOrder = []string{
+	"Secret",
+	"PersistentVolumeClaim",
+	"Service",
+	"NetworkPolicy",
+	"MariaDBConsumer",
+	"MongoDBConsumer",
+	"PostgreSQLConsumer",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"PodDisruptionBudget",
+	"CronJob",
+	"Certificate",
+	"ReferenceGrant",
+	"Ingress",
+	"HTTPRoute",
+	"Schedule",
+	"PreBackupPod",
+}
+
+// Result is the outcome of applying a single object
+type Result struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (r Result) String() string {
+	if r.Error != "" {
+		return fmt.Sprintf("%s/%s %s: %s", r.Kind, r.Name, r.Action, r.Error)
+	}
+	return fmt.Sprintf("%s/%s %s", r.Kind, r.Name, r.Action)
+}
+
+func kindWeight(kind string) int {
+	for idx, k := range applyOrder {
+		if k == kind {
+			return idx
+		}
+	}
+	return len(applyOrder)
+}
+
+// SortObjects orders the provided objects using the apply order, objects of the same kind retain the order they were provided in
+func SortObjects(c client.Client, objects []client.Object) error {
+	return sortObjects(c.Scheme(), objects)
+}
+
+func sortObjects(scheme *runtime.Scheme, objects []client.Object) error {
+	kinds := map[client.Object]string{}
+	for _, obj := range objects {
+		gvk, err := apiutil.GVKForObject(obj, scheme)
+		if err != nil {
+			return err
+		}
+		kinds[obj] = gvk.Kind
+	}
+	sort.SliceStable(objects, func(i, j int) bool {
+		return kindWeight(kinds[objects[i]]) < kindWeight(kinds[objects[j]])
+	})
+	return nil
+}
+
+// Apply will server-side apply all the provided objects into the namespace in the apply order.
+// A result is returned for every object that was attempted, if an object fails to apply then no further objects are applied
+// as later objects are likely to depend on it.
+func Apply(ctx context.Context, c client.Client, namespace string, objects []client.Object, dryRun bool) ([]Result, error) {
+	var results []Result
+	if err := SortObjects(c, objects); err != nil {
+		return nil, err
+	}
+	for _, obj := range objects {
+		result, err := applyObject(ctx, c, namespace, obj, dryRun)
+		results = append(results, result)
+		if err != nil {
+			return results, fmt.Errorf("unable to apply %s/%s: %v", result.Kind, result.Name, err)
+		}
+	}
+	return results, nil
+}
+
+func applyObject(ctx context.Context, c client.Client, namespace string, obj client.Object, dryRun bool) (Result, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return Result{Name: obj.GetName(), Action: "failed", Error: err.Error()}, err
+	}
+	result := Result{
+		Kind: gvk.Kind,
+		Name: obj.GetName(),
+	}
+	raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		result.Action = "failed"
+		result.Error = err.Error()
+		return result, err
+	}
+	u := &unstructured.Unstructured{Object: raw}
+	u.SetGroupVersionKind(gvk)
+	u.SetNamespace(namespace)
+	// these are populated by the api and should never be sent in an apply request
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(u.Object, "metadata", "resourceVersion")
+	unstructured.RemoveNestedField(u.Object, "metadata", "managedFields")
+	unstructured.RemoveNestedField(u.Object, "status")
+
+	// get the current version of the object so that the result can report what changed
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(gvk)
+	exists := true
+	err = c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: obj.GetName()}, existing)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			result.Action = "failed"
+			result.Error = err.Error()
+			return result, err
+		}
+		exists = false
+	}
+
+	opts := []client.ApplyOption{client.FieldOwner(FieldManager), client.ForceOwnership}
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	// the apply request returns the object as the api stores it (or would store it in a dry run), so it is compared
+	// against the live object rather than the desired object, which never contains the fields the api or other managers set
+	applied := u.DeepCopy()
+	if err := c.Apply(ctx, client.ApplyConfigurationFromUnstructured(applied), opts...); err != nil {
+		result.Action = "failed"
+		result.Error = err.Error()
+		return result, err
+	}
+	switch {
+	case !exists:
+		result.Action = "created"
+	case equality.Semantic.DeepEqual(liveFields(existing), liveFields(applied)):
+		result.Action = "unchanged"
+	default:
+		result.Action = "configured"
+	}
+	if dryRun {
+		result.Action = fmt.Sprintf("%s (dry run)", result.Action)
+	}
+	return result, nil
+}
+
+// liveFields strips the fields that every apply request changes and the status that the tool doesn't manage,
+// so that the live object before and after an apply can be compared
+func liveFields(u *unstructured.Unstructured) map[string]interface{} {
+	c := u.DeepCopy()
+	unstructured.RemoveNestedField(c.Object, "metadata", "resourceVersion")
+	unstructured.RemoveNestedField(c.Object, "metadata", "managedFields")
+	unstructured.RemoveNestedField(c.Object, "status")
+	return c.Object
+}
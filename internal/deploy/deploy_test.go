@@ -0,0 +1,187 @@
+package deploy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/k8s"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func testObjects() []client.Object {
+	return []client.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node",
+				Labels: map[string]string{
+					"lagoon.sh/service": "node",
+				},
+			},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: helpers.Int32Ptr(1),
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app.kubernetes.io/instance": "node"},
+				},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{"app.kubernetes.io/instance": "node"},
+					},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{Name: "node", Image: "harbor.example/example-project/main/node@sha256:abcdef"},
+						},
+					},
+				},
+			},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node",
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+				Resources: corev1.VolumeResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceStorage: resource.MustParse("5Gi"),
+					},
+				},
+			},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node",
+			},
+			Spec: corev1.ServiceSpec{
+				Ports: []corev1.ServicePort{
+					{Name: "http", Port: 3000},
+				},
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "lagoon-private-registry-my-registry",
+			},
+			StringData: map[string]string{
+				"key": "value",
+			},
+		},
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		dryRun    bool
+		reapply   bool
+		modify    func(objects []client.Object)
+		live      func(c client.Client) error
+		want      []Result
+	}{
+		{
+			name:      "new-environment",
+			namespace: "example-project-main",
+			want: []Result{
+				{Kind: "Secret", Name: "lagoon-private-registry-my-registry", Action: "created"},
+				{Kind: "PersistentVolumeClaim", Name: "node", Action: "created"},
+				{Kind: "Service", Name: "node", Action: "created"},
+				{Kind: "Deployment", Name: "node", Action: "created"},
+			},
+		},
+		{
+			name:      "new-environment-dry-run",
+			namespace: "example-project-main",
+			dryRun:    true,
+			want: []Result{
+				{Kind: "Secret", Name: "lagoon-private-registry-my-registry", Action: "created (dry run)"},
+				{Kind: "PersistentVolumeClaim", Name: "node", Action: "created (dry run)"},
+				{Kind: "Service", Name: "node", Action: "created (dry run)"},
+				{Kind: "Deployment", Name: "node", Action: "created (dry run)"},
+			},
+		},
+		{
+			name:      "existing-environment-reapply",
+			namespace: "example-project-main",
+			reapply:   true,
+			want: []Result{
+				{Kind: "Secret", Name: "lagoon-private-registry-my-registry", Action: "unchanged"},
+				{Kind: "PersistentVolumeClaim", Name: "node", Action: "unchanged"},
+				{Kind: "Service", Name: "node", Action: "unchanged"},
+				{Kind: "Deployment", Name: "node", Action: "unchanged"},
+			},
+		},
+		{
+			name:      "existing-environment-changed-by-other-managers",
+			namespace: "example-project-main",
+			reapply:   true,
+			live: func(c client.Client) error {
+				// the api and controllers set fields that the tool never sends
+				deployment := &appsv1.Deployment{}
+				if err := c.Get(context.Background(), client.ObjectKey{Namespace: "example-project-main", Name: "node"}, deployment); err != nil {
+					return err
+				}
+				deployment.SetAnnotations(map[string]string{"deployment.kubernetes.io/revision": "1"})
+				deployment.Spec.RevisionHistoryLimit = helpers.Int32Ptr(10)
+				deployment.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyAlways
+				return c.Update(context.Background(), deployment, client.FieldOwner("kube-controller-manager"))
+			},
+			want: []Result{
+				{Kind: "Secret", Name: "lagoon-private-registry-my-registry", Action: "unchanged"},
+				{Kind: "PersistentVolumeClaim", Name: "node", Action: "unchanged"},
+				{Kind: "Service", Name: "node", Action: "unchanged"},
+				{Kind: "Deployment", Name: "node", Action: "unchanged"},
+			},
+		},
+		{
+			name:      "existing-environment-new-image",
+			namespace: "example-project-main",
+			reapply:   true,
+			modify: func(objects []client.Object) {
+				deployment := objects[0].(*appsv1.Deployment)
+				deployment.Spec.Template.Spec.Containers[0].Image = "harbor.example/example-project/main/node@sha256:123456"
+			},
+			want: []Result{
+				{Kind: "Secret", Name: "lagoon-private-registry-my-registry", Action: "unchanged"},
+				{Kind: "PersistentVolumeClaim", Name: "node", Action: "unchanged"},
+				{Kind: "Service", Name: "node", Action: "unchanged"},
+				{Kind: "Deployment", Name: "node", Action: "configured"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := k8s.NewFakeClient(tt.namespace)
+			if err != nil {
+				t.Errorf("error creating fake client")
+			}
+			if tt.reapply {
+				if _, err := Apply(context.Background(), client, tt.namespace, testObjects(), false); err != nil {
+					t.Errorf("Apply() error = %v", err)
+				}
+			}
+			if tt.live != nil {
+				if err := tt.live(client); err != nil {
+					t.Errorf("error changing the live objects: %v", err)
+				}
+			}
+			objects := testObjects()
+			if tt.modify != nil {
+				tt.modify(objects)
+			}
+			got, err := Apply(context.Background(), client, tt.namespace, objects, tt.dryRun)
+			if err != nil {
+				t.Errorf("Apply() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
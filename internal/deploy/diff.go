@@ -0,0 +1,363 @@
+package deploy
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/uselagoon/build-deploy-tool/internal/collector"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+)
+
+// the number of unchanged lines shown around each change in a diff
+const diffContext = 3
+
+// fields that are populated by the api, these are never set by the templates
+var serverFields = [][]string{
+	{"metadata", "creationTimestamp"},
+	{"metadata", "deletionTimestamp"},
+	{"metadata", "generation"},
+	{"metadata", "managedFields"},
+	{"metadata", "namespace"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "selfLink"},
+	{"metadata", "uid"},
+	{"status"},
+}
+
+// labels and annotations that change on every build, a change to only these isn't a change to the environment
+var ignoredKeys = []string{
+	"lagoon.sh/version",
+	"lagoon.sh/configMapSha",
+}
+
+// Change is the difference between a generated object and the object in the environment
+type Change struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	Diff   string `json:"diff,omitempty"`
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s/%s %s", c.Kind, c.Name, c.Action)
+}
+
+// Changed returns true if any of the changes would modify the environment
+func Changed(changes []Change) bool {
+	for _, c := range changes {
+		if c.Action != "unchanged" {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff compares the generated objects against the objects in the collected environment state and returns a change for every
+// generated object in the apply order. Only the fields that are set in the generated object are compared, so fields that are
+// defaulted by the api are not reported as changes.
+func Diff(scheme *runtime.Scheme, state *collector.LagoonEnvState, objects []client.Object) ([]Change, error) {
+	live, err := stateObjects(scheme, state)
+	if err != nil {
+		return nil, err
+	}
+	if err := sortObjects(scheme, objects); err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for _, obj := range objects {
+		gvk, err := apiutil.GVKForObject(obj, scheme)
+		if err != nil {
+			return nil, err
+		}
+		change := Change{
+			Kind: gvk.Kind,
+			Name: obj.GetName(),
+		}
+		desired, err := normalise(obj)
+		if err != nil {
+			return nil, err
+		}
+		if gvk.Kind == "Secret" {
+			secretStringData(desired)
+		}
+		var current map[string]interface{}
+		existing, ok := live[objectKey(gvk.GroupKind().String(), obj.GetName())]
+		if ok {
+			liveObj, err := normalise(existing)
+			if err != nil {
+				return nil, err
+			}
+			current = prune(liveObj, desired).(map[string]interface{})
+		}
+		if gvk.Kind == "Secret" {
+			if ok {
+				maskSecret(current, desired)
+			}
+			maskSecret(desired, nil)
+		}
+		desiredYAML, err := yaml.Marshal(desired)
+		if err != nil {
+			return nil, err
+		}
+		var liveYAML []byte
+		if ok {
+			liveYAML, err = yaml.Marshal(current)
+			if err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case !ok:
+			change.Action = "create"
+		case string(liveYAML) == string(desiredYAML):
+			change.Action = "unchanged"
+		default:
+			change.Action = "update"
+		}
+		if change.Action != "unchanged" {
+			change.Diff = unified(fmt.Sprintf("live/%s/%s", change.Kind, change.Name), fmt.Sprintf("generated/%s/%s", change.Kind, change.Name), string(liveYAML), string(desiredYAML))
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func objectKey(groupKind, name string) string {
+	return fmt.Sprintf("%s/%s", groupKind, name)
+}
+
+// stateObjects indexes all the objects in the environment state by their group, kind and name
+func stateObjects(scheme *runtime.Scheme, state *collector.LagoonEnvState) (map[string]client.Object, error) {
+	objects := map[string]client.Object{}
+	if state == nil {
+		return objects, nil
+	}
+	items, err := state.Objects()
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range items {
+		gvk, err := apiutil.GVKForObject(obj, scheme)
+		if err != nil {
+			return nil, err
+		}
+		objects[objectKey(gvk.GroupKind().String(), obj.GetName())] = obj
+	}
+	return objects, nil
+}
+
+// normalise converts the object into a map with all the server populated fields and the ignored labels and annotations removed
+func normalise(obj client.Object) (map[string]interface{}, error) {
+	// collected secrets only contain a hash of the values, so the values of both secrets are compared as hashes
+	if secret, ok := obj.(*corev1.Secret); ok {
+		redacted := secret.DeepCopy()
+		collector.RedactSecret(redacted)
+		obj = redacted
+	}
+	raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	// the type information isn't populated on objects from the api, the kind is already part of the change
+	delete(raw, "apiVersion")
+	delete(raw, "kind")
+	for _, field := range serverFields {
+		removeField(raw, field)
+	}
+	clean(raw)
+	return raw, nil
+}
+
+func removeField(obj map[string]interface{}, field []string) {
+	for idx, f := range field {
+		if idx == len(field)-1 {
+			delete(obj, f)
+			return
+		}
+		next, ok := obj[f].(map[string]interface{})
+		if !ok {
+			return
+		}
+		obj = next
+	}
+}
+
+// clean removes the ignored labels and annotations wherever they appear in the object (this includes pod templates),
+// then removes any empty values so that an empty map in a template and a missing field in the api are treated the same
+func clean(obj map[string]interface{}) {
+	for k, v := range obj {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			if k == "labels" || k == "annotations" {
+				for _, key := range ignoredKeys {
+					delete(value, key)
+				}
+			}
+			clean(value)
+			if len(value) == 0 {
+				delete(obj, k)
+			}
+		case []interface{}:
+			for _, item := range value {
+				if m, ok := item.(map[string]interface{}); ok {
+					clean(m)
+				}
+			}
+			if len(value) == 0 {
+				delete(obj, k)
+			}
+		case nil:
+			delete(obj, k)
+		}
+	}
+}
+
+// prune removes any fields from the live object that aren't set in the desired object
+func prune(live, desired interface{}) interface{} {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			return live
+		}
+		pruned := map[string]interface{}{}
+		for k, v := range d {
+			if lv, ok := l[k]; ok {
+				pruned[k] = prune(lv, v)
+			}
+		}
+		return pruned
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok {
+			return live
+		}
+		// items that only exist in the live list are kept so that removals are shown
+		pruned := make([]interface{}, len(l))
+		for idx := range l {
+			if idx < len(d) {
+				pruned[idx] = prune(l[idx], d[idx])
+			} else {
+				pruned[idx] = l[idx]
+			}
+		}
+		return pruned
+	}
+	return live
+}
+
+// secretStringData moves any string data in a secret into the data, the api does the same when the secret is created
+func secretStringData(secret map[string]interface{}) {
+	stringData, ok := secret["stringData"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	data, ok := secret["data"].(map[string]interface{})
+	if !ok {
+		data = map[string]interface{}{}
+	}
+	for k, v := range stringData {
+		data[k] = base64.StdEncoding.EncodeToString([]byte(fmt.Sprint(v)))
+	}
+	secret["data"] = data
+	delete(secret, "stringData")
+}
+
+// maskSecret replaces the values in a secret so they aren't printed in the diff, values that are different to
+// the other secret are marked so that the change is still shown
+func maskSecret(secret, other map[string]interface{}) {
+	for _, field := range []string{"data", "stringData"} {
+		values, ok := secret[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		otherValues, _ := other[field].(map[string]interface{})
+		for k, v := range values {
+			if other != nil && otherValues[k] != v {
+				values[k] = "*** (changed)"
+				continue
+			}
+			values[k] = "***"
+		}
+	}
+}
+
+// unified returns a unified style diff of the two documents, only showing the lines around each change
+func unified(from, to, a, b string) string {
+	lines := lineDiff(splitLines(a), splitLines(b))
+	show := make([]bool, len(lines))
+	for idx, line := range lines {
+		if strings.HasPrefix(line, "-") || strings.HasPrefix(line, "+") {
+			for c := idx - diffContext; c <= idx+diffContext; c++ {
+				if c >= 0 && c < len(lines) {
+					show[c] = true
+				}
+			}
+		}
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", from, to)
+	for idx, line := range lines {
+		if !show[idx] {
+			continue
+		}
+		if idx > 0 && !show[idx-1] {
+			out.WriteString("@@\n")
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// lineDiff returns the lines of both documents prefixed with "-" if they were removed, "+" if they were added
+// or " " if they are in both, using the longest common subsequence of lines
+func lineDiff(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, "+"+b[j])
+	}
+	return lines
+}
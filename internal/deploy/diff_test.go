@@ -0,0 +1,156 @@
+package deploy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/uselagoon/build-deploy-tool/internal/collector"
+	"github.com/uselagoon/build-deploy-tool/internal/k8s"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// liveState returns the test objects as they would be returned from the api, with server populated and defaulted fields
+func liveState(modify func(*appsv1.Deployment, *corev1.PersistentVolumeClaim, *corev1.Service, *corev1.Secret)) *collector.LagoonEnvState {
+	objects := testObjects()
+	dep := objects[0].(*appsv1.Deployment)
+	pvc := objects[1].(*corev1.PersistentVolumeClaim)
+	svc := objects[2].(*corev1.Service)
+	secret := objects[3].(*corev1.Secret)
+	for _, obj := range objects {
+		obj.SetNamespace("example-project-main")
+		obj.SetUID(types.UID("9d5c5dc4-4fa1-4b31-8b5e-0c1f3d6d2f0a"))
+		obj.SetResourceVersion("12345")
+		obj.SetCreationTimestamp(metav1.Now())
+		obj.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: FieldManager, Operation: metav1.ManagedFieldsOperationApply}})
+	}
+	dep.Labels["lagoon.sh/version"] = "v2.20.0"
+	dep.Spec.Template.Annotations = map[string]string{"lagoon.sh/configMapSha": "abcdef"}
+	dep.Spec.RevisionHistoryLimit = func(i int32) *int32 { return &i }(10)
+	dep.Spec.Template.Spec.Containers[0].TerminationMessagePath = "/dev/termination-log"
+	dep.Status.Replicas = 1
+	svc.Spec.ClusterIP = "10.0.0.1"
+	svc.Spec.Ports[0].Protocol = corev1.ProtocolTCP
+	// the api converts string data to data
+	secret.Data = map[string][]byte{"key": []byte("value")}
+	secret.StringData = nil
+	if modify != nil {
+		modify(dep, pvc, svc, secret)
+	}
+	return &collector.LagoonEnvState{
+		Deployments: &appsv1.DeploymentList{Items: []appsv1.Deployment{*dep}},
+		PVCs:        &corev1.PersistentVolumeClaimList{Items: []corev1.PersistentVolumeClaim{*pvc}},
+		Services:    &corev1.ServiceList{Items: []corev1.Service{*svc}},
+		Secrets:     &corev1.SecretList{Items: []corev1.Secret{*secret}},
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name        string
+		state       *collector.LagoonEnvState
+		want        []string
+		wantDiff    []string
+		wantNotDiff []string
+		wantChanged bool
+	}{
+		{
+			name:  "new environment",
+			state: &collector.LagoonEnvState{},
+			want: []string{
+				"Secret/lagoon-private-registry-my-registry create",
+				"PersistentVolumeClaim/node create",
+				"Service/node create",
+				"Deployment/node create",
+			},
+			wantDiff: []string{
+				"+++ generated/Deployment/node",
+				"+      - image: harbor.example/example-project/main/node@sha256:abcdef",
+			},
+			wantNotDiff: []string{"value"},
+			wantChanged: true,
+		},
+		{
+			name:  "unchanged environment",
+			state: liveState(nil),
+			want: []string{
+				"Secret/lagoon-private-registry-my-registry unchanged",
+				"PersistentVolumeClaim/node unchanged",
+				"Service/node unchanged",
+				"Deployment/node unchanged",
+			},
+		},
+		{
+			name: "unchanged collected secret",
+			state: liveState(func(_ *appsv1.Deployment, _ *corev1.PersistentVolumeClaim, _ *corev1.Service, secret *corev1.Secret) {
+				// the collector only stores a hash of the secret values
+				collector.RedactSecret(secret)
+			}),
+			want: []string{
+				"Secret/lagoon-private-registry-my-registry unchanged",
+				"PersistentVolumeClaim/node unchanged",
+				"Service/node unchanged",
+				"Deployment/node unchanged",
+			},
+		},
+		{
+			name: "changed image and secret",
+			state: liveState(func(dep *appsv1.Deployment, _ *corev1.PersistentVolumeClaim, _ *corev1.Service, secret *corev1.Secret) {
+				dep.Spec.Template.Spec.Containers[0].Image = "harbor.example/example-project/main/node@sha256:123456"
+				secret.Data["key"] = []byte("old-value")
+			}),
+			want: []string{
+				"Secret/lagoon-private-registry-my-registry update",
+				"PersistentVolumeClaim/node unchanged",
+				"Service/node unchanged",
+				"Deployment/node update",
+			},
+			wantDiff: []string{
+				"--- live/Deployment/node",
+				"-      - image: harbor.example/example-project/main/node@sha256:123456",
+				"+      - image: harbor.example/example-project/main/node@sha256:abcdef",
+				"-  key: '*** (changed)'",
+			},
+			wantNotDiff: []string{"old-value", "terminationMessagePath", "clusterIP"},
+			wantChanged: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := k8s.NewFakeClient("example-project-main")
+			if err != nil {
+				t.Errorf("error creating fake client")
+			}
+			changes, err := Diff(client.Scheme(), tt.state, testObjects())
+			if err != nil {
+				t.Errorf("Diff() error = %v", err)
+				return
+			}
+			var got []string
+			var diffs strings.Builder
+			for _, c := range changes {
+				got = append(got, c.String())
+				diffs.WriteString(c.Diff)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+			for _, want := range tt.wantDiff {
+				if !strings.Contains(diffs.String(), want) {
+					t.Errorf("Diff() missing %q, got:\n%s", want, diffs.String())
+				}
+			}
+			for _, notWant := range tt.wantNotDiff {
+				if strings.Contains(diffs.String(), notWant) {
+					t.Errorf("Diff() should not contain %q, got:\n%s", notWant, diffs.String())
+				}
+			}
+			if Changed(changes) != tt.wantChanged {
+				t.Errorf("Changed() = %v, want %v", Changed(changes), tt.wantChanged)
+			}
+		})
+	}
+}
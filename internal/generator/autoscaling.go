@@ -0,0 +1,181 @@
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// the cpu utilization target used when an autoscaler is requested without any targets
+const defaultTargetCPUUtilization = int32(80)
+
+// Autoscaling is the calculated autoscaling and disruption budget configuration for a service
+type Autoscaling struct {
+	MinReplicas             int32               `json:"minReplicas,omitempty"`
+	MaxReplicas             int32               `json:"maxReplicas,omitempty"`
+	TargetCPUUtilization    *int32              `json:"targetCPUUtilization,omitempty"`
+	TargetMemoryUtilization *int32              `json:"targetMemoryUtilization,omitempty"`
+	MinAvailable            *intstr.IntOrString `json:"minAvailable,omitempty"`
+	MaxUnavailable          *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// HasHorizontalPodAutoscaler returns true if a horizontalpodautoscaler should manage the replicas of the service
+func (a *Autoscaling) HasHorizontalPodAutoscaler() bool {
+	return a != nil && a.MaxReplicas > 0
+}
+
+// HasPodDisruptionBudget returns true if a poddisruptionbudget should be created for the service
+func (a *Autoscaling) HasPodDisruptionBudget() bool {
+	return a != nil && (a.MinAvailable != nil || a.MaxUnavailable != nil)
+}
+
+// generateAutoscaling calculates the autoscaling configuration for a service from the `lagoon.autoscaling.*` labels in the
+// docker-compose file, any values in the `autoscaling` block of the environment in the .lagoon.yml file take precedence over the labels
+func generateAutoscaling(
+	buildValues *BuildValues,
+	service ServiceValues,
+	composeLabels map[string]string,
+) (*Autoscaling, error) {
+	composeService := service.Name
+	config := lagoon.Autoscaling{}
+	var err error
+	if config.MinReplicas, err = autoscalingInt32Label(composeLabels, composeService, "lagoon.autoscaling.minreplicas"); err != nil {
+		return nil, err
+	}
+	if config.MaxReplicas, err = autoscalingInt32Label(composeLabels, composeService, "lagoon.autoscaling.maxreplicas"); err != nil {
+		return nil, err
+	}
+	if config.TargetCPUUtilization, err = autoscalingInt32Label(composeLabels, composeService, "lagoon.autoscaling.targetcpu"); err != nil {
+		return nil, err
+	}
+	if config.TargetMemoryUtilization, err = autoscalingInt32Label(composeLabels, composeService, "lagoon.autoscaling.targetmemory"); err != nil {
+		return nil, err
+	}
+	if value := lagoon.CheckDockerComposeLagoonLabel(composeLabels, "lagoon.autoscaling.minavailable"); value != "" {
+		minAvailable := intstr.Parse(value)
+		config.MinAvailable = &minAvailable
+	}
+	if value := lagoon.CheckDockerComposeLagoonLabel(composeLabels, "lagoon.autoscaling.maxunavailable"); value != "" {
+		maxUnavailable := intstr.Parse(value)
+		config.MaxUnavailable = &maxUnavailable
+	}
+
+	// values in the .lagoon.yml override the values from the labels
+	if override, ok := buildValues.LagoonYAML.Environments[buildValues.Branch].Autoscaling[composeService]; ok {
+		if override.MinReplicas != nil {
+			config.MinReplicas = override.MinReplicas
+		}
+		if override.MaxReplicas != nil {
+			config.MaxReplicas = override.MaxReplicas
+		}
+		if override.TargetCPUUtilization != nil {
+			config.TargetCPUUtilization = override.TargetCPUUtilization
+		}
+		if override.TargetMemoryUtilization != nil {
+			config.TargetMemoryUtilization = override.TargetMemoryUtilization
+		}
+		if override.MinAvailable != nil {
+			config.MinAvailable = override.MinAvailable
+		}
+		if override.MaxUnavailable != nil {
+			config.MaxUnavailable = override.MaxUnavailable
+		}
+	}
+
+	hasHPA := config.MinReplicas != nil || config.MaxReplicas != nil || config.TargetCPUUtilization != nil || config.TargetMemoryUtilization != nil
+	hasPDB := config.MinAvailable != nil || config.MaxUnavailable != nil
+	if !hasHPA && !hasPDB {
+		return nil, nil
+	}
+
+	// services that use a ReadWriteOnce volume can't run more than one replica
+	if usesRWOVolume(buildValues, service) {
+		return nil, fmt.Errorf("autoscaling is not supported for service %s, it uses a ReadWriteOnce volume", composeService)
+	}
+
+	autoscaling := &Autoscaling{}
+	if hasHPA {
+		if config.MaxReplicas == nil {
+			return nil, fmt.Errorf("autoscaling maxReplicas must be defined for service %s", composeService)
+		}
+		autoscaling.MaxReplicas = *config.MaxReplicas
+		// the minimum defaults to the replicas the service would otherwise have
+		autoscaling.MinReplicas = 1
+		if service.Replicas > 1 {
+			autoscaling.MinReplicas = service.Replicas
+		}
+		if config.MinReplicas != nil {
+			autoscaling.MinReplicas = *config.MinReplicas
+		}
+		if autoscaling.MinReplicas < 1 {
+			return nil, fmt.Errorf("autoscaling minReplicas for service %s must be at least 1", composeService)
+		}
+		if autoscaling.MaxReplicas < autoscaling.MinReplicas {
+			return nil, fmt.Errorf("autoscaling maxReplicas %d for service %s must not be less than minReplicas %d", autoscaling.MaxReplicas, composeService, autoscaling.MinReplicas)
+		}
+		if config.TargetCPUUtilization != nil && *config.TargetCPUUtilization < 1 {
+			return nil, fmt.Errorf("autoscaling targetCPUUtilization for service %s must be greater than 0", composeService)
+		}
+		if config.TargetMemoryUtilization != nil && *config.TargetMemoryUtilization < 1 {
+			return nil, fmt.Errorf("autoscaling targetMemoryUtilization for service %s must be greater than 0", composeService)
+		}
+		autoscaling.TargetCPUUtilization = config.TargetCPUUtilization
+		autoscaling.TargetMemoryUtilization = config.TargetMemoryUtilization
+		if autoscaling.TargetCPUUtilization == nil && autoscaling.TargetMemoryUtilization == nil {
+			target := defaultTargetCPUUtilization
+			autoscaling.TargetCPUUtilization = &target
+		}
+	}
+	if hasPDB {
+		if config.MinAvailable != nil && config.MaxUnavailable != nil {
+			return nil, fmt.Errorf("autoscaling for service %s can only define one of minAvailable or maxUnavailable", composeService)
+		}
+		if err := validateDisruptionValue(config.MinAvailable); err != nil {
+			return nil, fmt.Errorf("autoscaling minAvailable for service %s is not valid: %v", composeService, err)
+		}
+		if err := validateDisruptionValue(config.MaxUnavailable); err != nil {
+			return nil, fmt.Errorf("autoscaling maxUnavailable for service %s is not valid: %v", composeService, err)
+		}
+		autoscaling.MinAvailable = config.MinAvailable
+		autoscaling.MaxUnavailable = config.MaxUnavailable
+	}
+	return autoscaling, nil
+}
+
+func autoscalingInt32Label(composeLabels map[string]string, composeService, label string) (*int32, error) {
+	value := lagoon.CheckDockerComposeLagoonLabel(composeLabels, label)
+	if value == "" {
+		return nil, nil
+	}
+	i, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("the provided value %s for label %s on service %s is not a valid integer: %v", value, label, composeService, err)
+	}
+	i32 := int32(i)
+	return &i32, nil
+}
+
+// validateDisruptionValue checks a disruption budget value is a positive integer or a percentage
+func validateDisruptionValue(value *intstr.IntOrString) error {
+	if value == nil {
+		return nil
+	}
+	if value.Type == intstr.Int {
+		if value.IntVal < 0 {
+			return fmt.Errorf("%d must not be negative", value.IntVal)
+		}
+		return nil
+	}
+	percent, ok := strings.CutSuffix(value.StrVal, "%")
+	if !ok {
+		return fmt.Errorf("%s must be an integer or a percentage", value.StrVal)
+	}
+	p, err := strconv.Atoi(percent)
+	if err != nil || p < 0 || p > 100 {
+		return fmt.Errorf("%s must be a percentage between 0%% and 100%%", value.StrVal)
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func Test_generateAutoscaling(t *testing.T) {
+	minAvailable := intstr.FromInt32(1)
+	maxUnavailable := intstr.FromString("25%")
+	type args struct {
+		buildValues    *BuildValues
+		composeService string
+		lagoonType     string
+		replicas       int32
+		volumes        []ServiceVolume
+		labels         map[string]string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *Autoscaling
+		wantErr string
+	}{
+		{
+			name: "no autoscaling",
+			args: args{
+				buildValues:    &BuildValues{},
+				composeService: "nginx",
+				lagoonType:     "nginx-php-persistent",
+				labels:         map[string]string{"lagoon.type": "nginx-php-persistent"},
+			},
+		},
+		{
+			name: "labels with default cpu target",
+			args: args{
+				buildValues:    &BuildValues{},
+				composeService: "nginx",
+				lagoonType:     "nginx-php-persistent",
+				replicas:       2,
+				labels: map[string]string{
+					"lagoon.autoscaling.maxreplicas":  "5",
+					"lagoon.autoscaling.minavailable": "1",
+				},
+			},
+			want: &Autoscaling{
+				MinReplicas:          2,
+				MaxReplicas:          5,
+				TargetCPUUtilization: helpers.Int32Ptr(80),
+				MinAvailable:         &minAvailable,
+			},
+		},
+		{
+			name: "lagoon.yml overrides labels",
+			args: args{
+				buildValues: &BuildValues{
+					Branch: "main",
+					LagoonYAML: lagoon.YAML{
+						Environments: lagoon.Environments{
+							"main": lagoon.Environment{
+								Autoscaling: map[string]lagoon.Autoscaling{
+									"node": {
+										MaxReplicas:             helpers.Int32Ptr(10),
+										TargetMemoryUtilization: helpers.Int32Ptr(75),
+										MaxUnavailable:          &maxUnavailable,
+									},
+								},
+							},
+						},
+					},
+				},
+				composeService: "node",
+				lagoonType:     "node",
+				labels: map[string]string{
+					"lagoon.autoscaling.minreplicas": "2",
+					"lagoon.autoscaling.maxreplicas": "4",
+					"lagoon.autoscaling.targetcpu":   "60",
+				},
+			},
+			want: &Autoscaling{
+				MinReplicas:             2,
+				MaxReplicas:             10,
+				TargetCPUUtilization:    helpers.Int32Ptr(60),
+				TargetMemoryUtilization: helpers.Int32Ptr(75),
+				MaxUnavailable:          &maxUnavailable,
+			},
+		},
+		{
+			name: "missing max replicas",
+			args: args{
+				buildValues:    &BuildValues{},
+				composeService: "node",
+				lagoonType:     "node",
+				labels:         map[string]string{"lagoon.autoscaling.minreplicas": "2"},
+			},
+			wantErr: "autoscaling maxReplicas must be defined for service node",
+		},
+		{
+			name: "max less than min",
+			args: args{
+				buildValues:    &BuildValues{},
+				composeService: "node",
+				lagoonType:     "node",
+				labels: map[string]string{
+					"lagoon.autoscaling.minreplicas": "3",
+					"lagoon.autoscaling.maxreplicas": "2",
+				},
+			},
+			wantErr: "autoscaling maxReplicas 2 for service node must not be less than minReplicas 3",
+		},
+		{
+			name: "invalid label",
+			args: args{
+				buildValues:    &BuildValues{},
+				composeService: "node",
+				lagoonType:     "node",
+				labels:         map[string]string{"lagoon.autoscaling.maxreplicas": "lots"},
+			},
+			wantErr: `the provided value lots for label lagoon.autoscaling.maxreplicas on service node is not a valid integer: strconv.ParseInt: parsing "lots": invalid syntax`,
+		},
+		{
+			name: "both disruption values",
+			args: args{
+				buildValues:    &BuildValues{},
+				composeService: "node",
+				lagoonType:     "node",
+				labels: map[string]string{
+					"lagoon.autoscaling.minavailable":   "1",
+					"lagoon.autoscaling.maxunavailable": "1",
+				},
+			},
+			wantErr: "autoscaling for service node can only define one of minAvailable or maxUnavailable",
+		},
+		{
+			name: "invalid percentage",
+			args: args{
+				buildValues:    &BuildValues{},
+				composeService: "node",
+				lagoonType:     "node",
+				labels:         map[string]string{"lagoon.autoscaling.maxunavailable": "half"},
+			},
+			wantErr: "autoscaling maxUnavailable for service node is not valid: half must be an integer or a percentage",
+		},
+		{
+			name: "readwriteonce volume",
+			args: args{
+				buildValues:    &BuildValues{},
+				composeService: "mariadb",
+				lagoonType:     "mariadb-single",
+				labels:         map[string]string{"lagoon.autoscaling.maxreplicas": "2"},
+			},
+			wantErr: "autoscaling is not supported for service mariadb, it uses a ReadWriteOnce volume",
+		},
+		{
+			name: "readwritemany volume converted to readwriteonce",
+			args: args{
+				buildValues:    &BuildValues{RWX2RWO: true},
+				composeService: "nginx",
+				lagoonType:     "nginx-php-persistent",
+				labels:         map[string]string{"lagoon.autoscaling.maxreplicas": "2"},
+			},
+			wantErr: "autoscaling is not supported for service nginx, it uses a ReadWriteOnce volume",
+		},
+		{
+			name: "consumed volume converted to readwriteonce",
+			args: args{
+				buildValues:    &BuildValues{RWX2RWO: true},
+				composeService: "worker",
+				lagoonType:     "worker-persistent",
+				labels:         map[string]string{"lagoon.autoscaling.maxreplicas": "2"},
+			},
+			wantErr: "autoscaling is not supported for service worker, it uses a ReadWriteOnce volume",
+		},
+		{
+			name: "consumed volume in ci",
+			args: args{
+				buildValues:    &BuildValues{IsCI: true},
+				composeService: "cli",
+				lagoonType:     "cli-persistent",
+				labels:         map[string]string{"lagoon.autoscaling.maxreplicas": "2"},
+			},
+			wantErr: "autoscaling is not supported for service cli, it uses a ReadWriteOnce volume",
+		},
+		{
+			name: "additional volume converted to readwriteonce",
+			args: args{
+				buildValues:    &BuildValues{RWX2RWO: true},
+				composeService: "node",
+				lagoonType:     "node",
+				volumes: []ServiceVolume{
+					{ComposeVolume: ComposeVolume{Name: "custom-files", Size: "5Gi"}, Path: "/app/files"},
+				},
+				labels: map[string]string{"lagoon.autoscaling.maxreplicas": "2"},
+			},
+			wantErr: "autoscaling is not supported for service node, it uses a ReadWriteOnce volume",
+		},
+		{
+			name: "additional volume",
+			args: args{
+				buildValues:    &BuildValues{},
+				composeService: "node",
+				lagoonType:     "node",
+				volumes: []ServiceVolume{
+					{ComposeVolume: ComposeVolume{Name: "custom-files", Size: "5Gi"}, Path: "/app/files"},
+				},
+				labels: map[string]string{"lagoon.autoscaling.maxreplicas": "2"},
+			},
+			want: &Autoscaling{
+				MinReplicas:          1,
+				MaxReplicas:          2,
+				TargetCPUUtilization: helpers.Int32Ptr(80),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generateAutoscaling(tt.args.buildValues, ServiceValues{
+				Name:              tt.args.composeService,
+				Type:              tt.args.lagoonType,
+				Replicas:          tt.args.replicas,
+				AdditionalVolumes: tt.args.volumes,
+			}, tt.args.labels)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("generateAutoscaling() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("generateAutoscaling() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("generateAutoscaling() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,14 @@
 package generator
 
 import (
+	"time"
+
 	composetypes "github.com/compose-spec/compose-go/types"
 	"github.com/uselagoon/build-deploy-tool/internal/dbaasclient"
 	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"github.com/uselagoon/build-deploy-tool/internal/policy"
+	"github.com/uselagoon/build-deploy-tool/internal/registryauth"
+	"github.com/uselagoon/build-deploy-tool/internal/secretref"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -42,6 +47,7 @@ type BuildValues struct {
 	Route                         string                       `json:"route" description:"this stores the primary determined route after all have been calculated"`
 	Routes                        []string                     `json:"routes" description:"this stores all routes after they are calculated"`
 	AutogeneratedRoutes           []string                     `json:"autogeneratedRoutes" description:"this stores autogenerated routes after they are calculated"`
+	RedirectRoutes                []string                     `json:"redirectRoutes" description:"this stores the redirect domains after they are calculated, these are not served by the environment"`
 	AutogeneratedRoutesFastly     bool                         `json:"autogeneratedRoutesFastly" deprecated:"true" description:"the flag to determine if autogenerated routes should receive fastly annotations"`
 	Services                      []ServiceValues              `json:"services" description:"stores all the computed values for all docker-compose services for this environment"`
 	Backup                        BackupConfiguration          `json:"backup" description:"stores backup configuration"`
@@ -51,6 +57,7 @@ type BuildValues struct {
 	DBaaSEnvironmentTypeOverrides *lagoon.EnvironmentVariable  `json:"dbaasEnvironmentTypeOverrides" description:"stores any dbaas type overrides"`
 	DBaaSFallbackSingle           bool                         `json:"dbaasFallbackSingle" description:"the fallback flag to define if a single pod should be used if no provider is found"`
 	IngressClass                  string                       `json:"ingressClass" description:"the ingress class used for this environment"`
+	Gateway                       *Gateway                     `json:"gateway,omitempty" description:"the parent gateway of the httproutes if the cluster uses the gateway api instead of ingress"`
 	TaskScaleMaxIterations        int                          `json:"taskScaleMaxIterations" description:"the number of attempts to wait for pods to scale for pre and post rollout tasks"`
 	TaskScaleWaitTime             int                          `json:"taskScaleWaitTime" description:"the time to wait for pods to scale for pre and post rollout tasks"`
 	DynamicSecretMounts           []DynamicSecretMounts        `json:"dynamicSecretMounts" description:"stores any dynamic secret mount definitions"`
@@ -59,10 +66,13 @@ type BuildValues struct {
 	ImageCache                    string                       `json:"imageCache" description:"if an imagecache has been provided for images outside of the imageregistry"`
 	DefaultBackupSchedule         string                       `json:"defaultBackupSchedule" description:"the default backup scheduled"`
 	DBaaSClient                   *dbaasclient.Client          `json:"-" description:"used to store connection information for the dbaas operator endpoint"`
+	RegistryAuthClient            *registryauth.Client         `json:"-" description:"used to get the credentials of the container registries from the credential providers"`
+	SecretRefClient               *secretref.Client            `json:"-" description:"used to resolve secret references in the lagoon-env variables"`
 	ImageReferences               map[string]string            `json:"imageReferences" description:"the post image build phase storage location of images for this build"`
 	Resources                     Resources                    `json:"resources" description:"this stores resource overrides for this environment"`
 	CronjobsDisabled              bool                         `json:"cronjobsDisabled" description:"this controls whether cronjobs are enabled for this environment or not"`
 	FeatureFlags                  map[string]bool              `json:"-" description:"these are used by templating systems to turn on or off certain functionality based on if feature flags are defined"`
+	Policy                        *policy.Policy               `json:"-" description:"the cluster policy that the generated objects are evaluated against"`
 	ImageRegistry                 string                       `json:"imageRegistry" description:"the image registry in use for this environment, usually harbor"`
 	DockerBuildKit                *bool                        `json:"dockerBuildKit" description:"the flag to determine if docker buildkit is used"`
 	ImageBuildArguments           map[string]string            `json:"imageBuildArguments" description:"where the calculated image build arguments are stored"`
@@ -90,6 +100,7 @@ type BuildValues struct {
 	ConfigSSHHost                 string                       `json:"configSSHHost"`
 	ConfigSSHPort                 string                       `json:"configSSHPort"`
 	LagoonEnvVariables            map[string]string            `json:"lagoonEnvVariables" description:"map of variables that will be saved into the lagoon-env secret"`
+	LagoonEnvSecretReferences     []SecretReference            `json:"lagoonEnvSecretReferences,omitempty" description:"the lagoon-env variables that are synced from a secret store by an ExternalSecret"`
 	LagoonPlatformEnvVariables    map[string]string            `json:"lagoonPlatformEnvVariables" description:"map of variables that will be saved into the lagoon-platform-env secret"`
 	AutoMountServiceAccountToken  bool                         `json:"autoMountServiceAccountToken" description:"flag to enable automounting the service account token"`
 	DeploymentRevisionHistory     *int32                       `json:"deploymentRevisionHistory" description:"how many replicasets to retain"`
@@ -98,6 +109,13 @@ type BuildValues struct {
 type Resources struct {
 	Limits   ResourceLimits   `json:"limits"`
 	Requests ResourceRequests `json:"requests"`
+	Maximum  ResourceMaximum  `json:"maximum"`
+}
+
+// ResourceMaximum is the most cpu or memory a service can request or be limited to in the .lagoon.yml overrides
+type ResourceMaximum struct {
+	CPU    string `json:"cpu"`
+	Memory string `json:"memory"`
 }
 
 type ResourceLimits struct {
@@ -110,14 +128,17 @@ type ResourceRequests struct {
 }
 
 type ContainerRegistry struct {
-	Name           string `json:"name" description:"name of the registry collected from the .lagoon.yml file"`
-	Username       string `json:"username" description:"the username to use to log in to the registry"`
-	Password       string `json:"password" description:"the password or password variable reference to use to log in to the registry"`
-	URL            string `json:"url" description:"the registry url"`
-	UsernameSource string `json:"usernameSource" description:"information regarding the source of the username"`
-	PasswordSource string `json:"passwordSource" description:"information regarding the source of the password"`
-	SecretName     string `json:"secretName" description:"the name of the secret to be created for this registry"`
-	IsDockerHub    *bool  `json:"isDockerHub" description:"if this registry is dockerhub or not"`
+	Name           string     `json:"name" description:"name of the registry collected from the .lagoon.yml file"`
+	Type           string     `json:"type" description:"the type of the registry, this is the credential provider used to get the credentials"`
+	Username       string     `json:"username" description:"the username to use to log in to the registry"`
+	Password       string     `json:"password" description:"the password or password variable reference to use to log in to the registry"`
+	URL            string     `json:"url" description:"the registry url"`
+	TokenURL       string     `json:"tokenURL,omitempty" description:"the token endpoint used to exchange the username and password for a short lived token"`
+	UsernameSource string     `json:"usernameSource" description:"information regarding the source of the username"`
+	PasswordSource string     `json:"passwordSource" description:"information regarding the source of the password"`
+	SecretName     string     `json:"secretName" description:"the name of the secret to be created for this registry"`
+	IsDockerHub    *bool      `json:"isDockerHub" description:"if this registry is dockerhub or not"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty" description:"when the credentials expire if the credential provider issues short lived tokens"`
 }
 
 type PodSecurityContext struct {
@@ -174,44 +195,55 @@ type ServiceVolume struct {
 
 // ServiceValues is the values for a specific service used by a lagoon build
 type ServiceValues struct {
-	Name                                   string                  `json:"name"`         // the actual compose service name
-	OverrideName                           string                  `json:"overrideName"` // if an override name is provided, use it
-	Type                                   string                  `json:"type"`
-	AutogeneratedRoutesEnabled             bool                    `json:"autogeneratedRoutesEnabled"`
-	AutogeneratedRoutesTLSAcme             bool                    `json:"autogeneratedRoutesTLSAcme"`
-	AutogeneratedRoutesRequestVerification bool                    `json:"autogeneratedRoutesRequestVerification"`
-	AutogeneratedRouteDomain               string                  `json:"autogeneratedRouteDomain"`
-	ShortAutogeneratedRouteDomain          string                  `json:"shortAutogeneratedRouteDomain"`
-	DBaaSEnvironment                       string                  `json:"dbaasEnvironment"`
-	NativeCronjobs                         []lagoon.Cronjob        `json:"nativeCronjobs"`
-	InPodCronjobs                          []lagoon.Cronjob        `json:"inPodCronjobs"`
-	DeploymentServiceType                  string                  `json:"deploymentServiceType"`
-	ServicePort                            int32                   `json:"servicePort,omitempty"`
-	PersistentVolumePath                   string                  `json:"persistentVolumePath,omitempty"`
-	PersistentVolumeName                   string                  `json:"persistentVolumeName,omitempty"`
-	PersistentVolumeSize                   string                  `json:"persistentVolumeSize,omitempty"`
-	UseSpotInstances                       bool                    `json:"useSpot"`
-	ForceSpotInstances                     bool                    `json:"forceUseSpot"`
-	CronjobUseSpotInstances                bool                    `json:"cronjobUseSpot"`
-	CronjobForceSpotInstances              bool                    `json:"cronjobForceUseSpot"`
-	Replicas                               int32                   `json:"replicas"`
-	LinkedService                          *ServiceValues          `json:"linkedService"`
-	PodSecurityContext                     PodSecurityContext      `json:"podSecurityContext"`
-	AdditionalServicePorts                 []AdditionalServicePort `json:"additionalServicePorts,omitempty"`
-	NodeSelectors                          *map[string]string      `json:"nodeSelectors"`
-	Tolerations                            *[]corev1.Toleration    `json:"tolerations"`
-	Affinity                               *corev1.Affinity        `json:"affinity"`
-	CronjobNodeSelectors                   *map[string]string      `json:"cronjobNodeSelectors"`
-	CronjobTolerations                     *[]corev1.Toleration    `json:"cronjobTolerations"`
-	CronjobAffinity                        *corev1.Affinity        `json:"cronjobAffinity"`
-	DBaasReadReplica                       bool                    `json:"dBaasReadReplica"`
-	ImageBuild                             *ImageBuild             `json:"docker,omitempty"`
-	BackupsEnabled                         bool                    `json:"backupsEnabled"`
-	IsDBaaS                                bool                    `json:"isDBaaS"`
-	IsSingle                               bool                    `json:"isSingle"`
-	AdditionalVolumes                      []ServiceVolume         `json:"additionalVolumes,omitempty"`
-	CreateDefaultVolume                    bool                    `json:"createDefaultVolume"`
-	ExternalServiceName                    string                  `json:"externalServiceName,omitempty"`
+	Name                                   string                       `json:"name"`         // the actual compose service name
+	OverrideName                           string                       `json:"overrideName"` // if an override name is provided, use it
+	Type                                   string                       `json:"type"`
+	AutogeneratedRoutesEnabled             bool                         `json:"autogeneratedRoutesEnabled"`
+	AutogeneratedRoutesTLSAcme             bool                         `json:"autogeneratedRoutesTLSAcme"`
+	AutogeneratedRoutesRequestVerification bool                         `json:"autogeneratedRoutesRequestVerification"`
+	AutogeneratedRouteDomain               string                       `json:"autogeneratedRouteDomain"`
+	ShortAutogeneratedRouteDomain          string                       `json:"shortAutogeneratedRouteDomain"`
+	DBaaSEnvironment                       string                       `json:"dbaasEnvironment"`
+	NativeCronjobs                         []lagoon.Cronjob             `json:"nativeCronjobs"`
+	InPodCronjobs                          []lagoon.Cronjob             `json:"inPodCronjobs"`
+	DeploymentServiceType                  string                       `json:"deploymentServiceType"`
+	ServicePort                            int32                        `json:"servicePort,omitempty"`
+	PersistentVolumePath                   string                       `json:"persistentVolumePath,omitempty"`
+	PersistentVolumeName                   string                       `json:"persistentVolumeName,omitempty"`
+	PersistentVolumeSize                   string                       `json:"persistentVolumeSize,omitempty"`
+	UseSpotInstances                       bool                         `json:"useSpot"`
+	ForceSpotInstances                     bool                         `json:"forceUseSpot"`
+	CronjobUseSpotInstances                bool                         `json:"cronjobUseSpot"`
+	CronjobForceSpotInstances              bool                         `json:"cronjobForceUseSpot"`
+	Replicas                               int32                        `json:"replicas"`
+	Autoscaling                            *Autoscaling                 `json:"autoscaling,omitempty"`
+	LinkedService                          *ServiceValues               `json:"linkedService"`
+	PodSecurityContext                     PodSecurityContext           `json:"podSecurityContext"`
+	AdditionalServicePorts                 []AdditionalServicePort      `json:"additionalServicePorts,omitempty"`
+	NodeSelectors                          *map[string]string           `json:"nodeSelectors"`
+	Tolerations                            *[]corev1.Toleration         `json:"tolerations"`
+	Affinity                               *corev1.Affinity             `json:"affinity"`
+	CronjobNodeSelectors                   *map[string]string           `json:"cronjobNodeSelectors"`
+	CronjobTolerations                     *[]corev1.Toleration         `json:"cronjobTolerations"`
+	CronjobAffinity                        *corev1.Affinity             `json:"cronjobAffinity"`
+	DBaasReadReplica                       bool                         `json:"dBaasReadReplica"`
+	ImageBuild                             *ImageBuild                  `json:"docker,omitempty"`
+	BackupsEnabled                         bool                         `json:"backupsEnabled"`
+	IsDBaaS                                bool                         `json:"isDBaaS"`
+	IsSingle                               bool                         `json:"isSingle"`
+	AdditionalVolumes                      []ServiceVolume              `json:"additionalVolumes,omitempty"`
+	CreateDefaultVolume                    bool                         `json:"createDefaultVolume"`
+	ExternalServiceName                    string                       `json:"externalServiceName,omitempty"`
+	Resources                              *corev1.ResourceRequirements `json:"resources,omitempty"`
+	Probes                                 *ServiceProbes               `json:"probes,omitempty"`
+	DeploymentStrategy                     *DeploymentStrategy          `json:"deploymentStrategy,omitempty"`
+}
+
+// ServiceProbes are the probe overrides of a service from the .lagoon.yml
+type ServiceProbes struct {
+	Readiness *lagoon.Probe `json:"readiness,omitempty"`
+	Liveness  *lagoon.Probe `json:"liveness,omitempty"`
+	Startup   *lagoon.Probe `json:"startup,omitempty"`
 }
 
 type ExternalService struct {
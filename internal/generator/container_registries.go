@@ -8,6 +8,7 @@ import (
 
 	"github.com/uselagoon/build-deploy-tool/internal/helpers"
 	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"github.com/uselagoon/build-deploy-tool/internal/registryauth"
 	machinerynamespace "github.com/uselagoon/machinery/utils/namespace"
 	"k8s.io/apimachinery/pkg/util/validation"
 )
@@ -60,8 +61,13 @@ func configureContainerRegistries(buildValues *BuildValues) error {
 			username, _ = lagoon.GetLagoonVariable(fmt.Sprintf("REGISTRY_%s_USERNAME", helpers.FixServiceName(n)), []string{"container_registry"}, buildValues.EnvironmentVariables)
 			usernameSource = fmt.Sprintf("Lagoon API environment variable %s", fmt.Sprintf("REGISTRY_%s_USERNAME", n))
 		}
+		registryType := cr.Type
+		if registryType == "" {
+			registryType = registryauth.TypeStatic
+		}
 		if username == nil {
-			if cr.Username == "" {
+			// the token endpoint of a token exchange registry can provide the username
+			if cr.Username == "" && registryType != registryauth.TypeTokenExchange {
 				return fmt.Errorf("no username defined for registry %s", n)
 			}
 			username = &lagoon.EnvironmentVariable{Value: cr.Username}
@@ -83,11 +89,21 @@ func configureContainerRegistries(buildValues *BuildValues) error {
 		if err := validation.IsDNS1123Subdomain(strings.ToLower(secretName)); err != nil {
 			secretName = fmt.Sprintf("%s-%s", secretName[:len(secretName)-10], helpers.GetMD5HashWithNewLine(machinerynamespace.MakeSafe(n))[:5])
 		}
+		// the credentials are only requested from the provider of the registry type when they are needed, see ResolveContainerRegistryCredentials
+		registryAuth := buildValues.RegistryAuthClient
+		if registryAuth == nil {
+			registryAuth = registryauth.NewClient(registryauth.Client{})
+		}
+		if !registryAuth.Supports(registryType) {
+			return fmt.Errorf("container registry %s type %s is not supported, must be one of %s", n, registryType, strings.Join(registryAuth.Types(), ", "))
+		}
 		buildValues.ContainerRegistry = append(buildValues.ContainerRegistry, ContainerRegistry{
 			Name:           n,
+			Type:           registryType,
 			Username:       username.Value,
 			Password:       password.Value,
 			URL:            eru,
+			TokenURL:       cr.TokenURL,
 			UsernameSource: usernameSource,
 			PasswordSource: passwordSource,
 			SecretName:     secretName,
@@ -100,3 +116,38 @@ func configureContainerRegistries(buildValues *BuildValues) error {
 	})
 	return nil
 }
+
+// ResolveContainerRegistryCredentials gets the credentials of the container registries from the provider of the registry type.
+// This is only done when the image builds are identified or the registry secrets are templated, so that other commands
+// that use the generator never request short lived tokens from a token endpoint
+func ResolveContainerRegistryCredentials(buildValues *BuildValues) error {
+	registryAuth := buildValues.RegistryAuthClient
+	if registryAuth == nil {
+		registryAuth = registryauth.NewClient(registryauth.Client{})
+	}
+	for idx, cr := range buildValues.ContainerRegistry {
+		if cr.Type == registryauth.TypeStatic {
+			// static registries use the username and password as they are provided
+			continue
+		}
+		// the username and password are exchanged for a short lived token by token exchange registries
+		credentials, err := registryAuth.Credentials(cr.Type, registryauth.Registry{
+			Name:     cr.Name,
+			URL:      cr.URL,
+			Username: cr.Username,
+			Password: cr.Password,
+			TokenURL: cr.TokenURL,
+		})
+		if err != nil {
+			return err
+		}
+		if credentials.Username != cr.Username {
+			buildValues.ContainerRegistry[idx].UsernameSource = fmt.Sprintf("%s credential provider", cr.Type)
+		}
+		buildValues.ContainerRegistry[idx].PasswordSource = fmt.Sprintf("%s credential provider", cr.Type)
+		buildValues.ContainerRegistry[idx].Username = credentials.Username
+		buildValues.ContainerRegistry[idx].Password = credentials.Password
+		buildValues.ContainerRegistry[idx].ExpiresAt = credentials.ExpiresAt
+	}
+	return nil
+}
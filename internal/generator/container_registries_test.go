@@ -0,0 +1,254 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"github.com/uselagoon/build-deploy-tool/internal/registryauth"
+)
+
+func Test_configureContainerRegistries(t *testing.T) {
+	tests := []struct {
+		name       string
+		registries map[string]lagoon.ContainerRegistry
+		vars       []lagoon.EnvironmentVariable
+		want       []ContainerRegistry
+		wantErr    string
+	}{
+		{
+			name: "static registry",
+			registries: map[string]lagoon.ContainerRegistry{
+				"my-registry": {
+					Username: "registry_user",
+					Password: "REGISTRY_PASSWORD",
+					URL:      "registry.example.com",
+				},
+			},
+			want: []ContainerRegistry{
+				{
+					Name:           "my-registry",
+					Type:           "static",
+					Username:       "registry_user",
+					Password:       "REGISTRY_PASSWORD",
+					URL:            "registry.example.com",
+					UsernameSource: ".lagoon.yml",
+					PasswordSource: ".lagoon.yml (we recommend using an environment variable, see the docs on container-registries for more information)",
+					SecretName:     "lagoon-private-registry-my-registry",
+					IsDockerHub:    helpers.BoolPtr(false),
+				},
+			},
+		},
+		{
+			name: "token exchange registry",
+			registries: map[string]lagoon.ContainerRegistry{
+				"my-registry": {
+					Type:     "token-exchange",
+					Username: "registry_user",
+					Password: "REGISTRY_TOKEN_PASSWORD",
+					URL:      "registry.example.com",
+					TokenURL: "http://127.0.0.1:1/token",
+				},
+			},
+			vars: []lagoon.EnvironmentVariable{
+				{Name: "REGISTRY_TOKEN_PASSWORD", Value: "REGISTRY_PASSWORD", Scope: "container_registry"},
+			},
+			want: []ContainerRegistry{
+				{
+					Name:           "my-registry",
+					Type:           "token-exchange",
+					Username:       "registry_user",
+					Password:       "REGISTRY_PASSWORD",
+					URL:            "registry.example.com",
+					TokenURL:       "http://127.0.0.1:1/token",
+					UsernameSource: ".lagoon.yml",
+					PasswordSource: "Lagoon API environment variable REGISTRY_TOKEN_PASSWORD",
+					SecretName:     "lagoon-private-registry-my-registry",
+					IsDockerHub:    helpers.BoolPtr(false),
+				},
+			},
+		},
+		{
+			name: "token exchange registry without a username",
+			registries: map[string]lagoon.ContainerRegistry{
+				"my-registry": {
+					Type:     "token-exchange",
+					Password: "REGISTRY_PASSWORD",
+					URL:      "registry.example.com",
+					TokenURL: "http://127.0.0.1:1/token",
+				},
+			},
+			want: []ContainerRegistry{
+				{
+					Name:           "my-registry",
+					Type:           "token-exchange",
+					Password:       "REGISTRY_PASSWORD",
+					URL:            "registry.example.com",
+					TokenURL:       "http://127.0.0.1:1/token",
+					UsernameSource: ".lagoon.yml",
+					PasswordSource: ".lagoon.yml (we recommend using an environment variable, see the docs on container-registries for more information)",
+					SecretName:     "lagoon-private-registry-my-registry",
+					IsDockerHub:    helpers.BoolPtr(false),
+				},
+			},
+		},
+		{
+			name: "static registry without a username",
+			registries: map[string]lagoon.ContainerRegistry{
+				"my-registry": {
+					Password: "REGISTRY_PASSWORD",
+					URL:      "registry.example.com",
+				},
+			},
+			wantErr: "no username defined for registry my-registry",
+		},
+		{
+			name: "unsupported registry type",
+			registries: map[string]lagoon.ContainerRegistry{
+				"my-registry": {
+					Type:     "oidc",
+					Username: "registry_user",
+					Password: "REGISTRY_PASSWORD",
+					URL:      "registry.example.com",
+				},
+			},
+			wantErr: "container registry my-registry type oidc is not supported, must be one of static, token-exchange",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buildValues := &BuildValues{
+				LagoonYAML: lagoon.YAML{
+					ContainerRegistries: tt.registries,
+				},
+				EnvironmentVariables: tt.vars,
+				RegistryAuthClient: registryauth.NewClient(registryauth.Client{
+					RetryMax:     5,
+					RetryWaitMin: time.Duration(10) * time.Millisecond,
+					RetryWaitMax: time.Duration(50) * time.Millisecond,
+				}),
+			}
+			err := configureContainerRegistries(buildValues)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("configureContainerRegistries() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("configureContainerRegistries() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(buildValues.ContainerRegistry, tt.want) {
+				t.Errorf("configureContainerRegistries() = %v, want %v", buildValues.ContainerRegistry, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ResolveContainerRegistryCredentials(t *testing.T) {
+	ts := registryauth.TestTokenExchangeHTTPServer()
+	defer ts.Close()
+	expiresAt := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		registries []ContainerRegistry
+		want       []ContainerRegistry
+		wantErr    string
+	}{
+		{
+			name: "static registry",
+			registries: []ContainerRegistry{
+				{
+					Name:           "my-registry",
+					Type:           "static",
+					Username:       "registry_user",
+					Password:       "REGISTRY_PASSWORD",
+					URL:            "registry.example.com",
+					UsernameSource: ".lagoon.yml",
+					PasswordSource: ".lagoon.yml",
+				},
+			},
+			want: []ContainerRegistry{
+				{
+					Name:           "my-registry",
+					Type:           "static",
+					Username:       "registry_user",
+					Password:       "REGISTRY_PASSWORD",
+					URL:            "registry.example.com",
+					UsernameSource: ".lagoon.yml",
+					PasswordSource: ".lagoon.yml",
+				},
+			},
+		},
+		{
+			name: "token exchange registry",
+			registries: []ContainerRegistry{
+				{
+					Name:           "my-registry",
+					Type:           "token-exchange",
+					Username:       "registry_user",
+					Password:       "REGISTRY_PASSWORD",
+					URL:            "registry.example.com",
+					TokenURL:       ts.URL + "/token",
+					UsernameSource: ".lagoon.yml",
+					PasswordSource: "Lagoon API environment variable REGISTRY_TOKEN_PASSWORD",
+				},
+			},
+			want: []ContainerRegistry{
+				{
+					Name:           "my-registry",
+					Type:           "token-exchange",
+					Username:       "oauth2accesstoken",
+					Password:       "short-lived-token",
+					URL:            "registry.example.com",
+					TokenURL:       ts.URL + "/token",
+					UsernameSource: "token-exchange credential provider",
+					PasswordSource: "token-exchange credential provider",
+					ExpiresAt:      &expiresAt,
+				},
+			},
+		},
+		{
+			name: "token exchange registry without a username",
+			registries: []ContainerRegistry{
+				{
+					Name:     "my-registry",
+					Type:     "token-exchange",
+					Password: "REGISTRY_PASSWORD",
+					URL:      "registry.example.com",
+					TokenURL: ts.URL + "/token",
+				},
+			},
+			wantErr: "couldn't get credentials for container registry my-registry: token endpoint responded with status 401: invalid credentials",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buildValues := &BuildValues{
+				ContainerRegistry: tt.registries,
+				RegistryAuthClient: registryauth.NewClient(registryauth.Client{
+					RetryMax:     5,
+					RetryWaitMin: time.Duration(10) * time.Millisecond,
+					RetryWaitMax: time.Duration(50) * time.Millisecond,
+				}),
+			}
+			err := ResolveContainerRegistryCredentials(buildValues)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("ResolveContainerRegistryCredentials() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("ResolveContainerRegistryCredentials() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(buildValues.ContainerRegistry, tt.want) {
+				t.Errorf("ResolveContainerRegistryCredentials() = %v, want %v", buildValues.ContainerRegistry, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Gateway is the parent gateway that httproutes are attached to
+type Gateway struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// CertificateIssuer is the cert-manager cluster issuer used for the certificates of httproutes that use tls-acme
+	CertificateIssuer string `json:"certificateIssuer,omitempty"`
+}
+
+// generateGateway checks if the cluster uses the gateway api for routes, the parent gateway is defined by the remote cluster
+// as `namespace/name`, or just `name` if the gateway is in the same namespace as the environment
+func generateGateway(buildValues BuildValues, debug bool) (*Gateway, error) {
+	if CheckFeatureFlag("GATEWAY_API", buildValues.EnvironmentVariables, debug) != "enabled" {
+		return nil, nil
+	}
+	parent := CheckFeatureFlag("GATEWAY_API_PARENT", buildValues.EnvironmentVariables, debug)
+	if parent == "" {
+		return nil, fmt.Errorf("the gateway api is enabled but no parent gateway is defined, contact your Lagoon administrator")
+	}
+	gateway := &Gateway{Name: parent}
+	if namespace, name, ok := strings.Cut(parent, "/"); ok {
+		gateway.Namespace, gateway.Name = namespace, name
+		if errs := validation.IsDNS1123Label(gateway.Namespace); errs != nil {
+			return nil, fmt.Errorf("the parent gateway namespace %s is not valid: %v", gateway.Namespace, strings.Join(errs, ", "))
+		}
+	}
+	if errs := validation.IsDNS1123Subdomain(gateway.Name); errs != nil {
+		return nil, fmt.Errorf("the parent gateway name %s is not valid: %v", gateway.Name, strings.Join(errs, ", "))
+	}
+	// cert-manager doesn't issue certificates for httproutes, so certificates are requested from this issuer instead
+	gateway.CertificateIssuer = CheckFeatureFlag("GATEWAY_API_CERTIFICATE_ISSUER", buildValues.EnvironmentVariables, debug)
+	if gateway.CertificateIssuer != "" {
+		if errs := validation.IsDNS1123Subdomain(gateway.CertificateIssuer); errs != nil {
+			return nil, fmt.Errorf("the gateway certificate issuer %s is not valid: %v", gateway.CertificateIssuer, strings.Join(errs, ", "))
+		}
+	}
+	return gateway, nil
+}
@@ -0,0 +1,91 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+)
+
+func Test_generateGateway(t *testing.T) {
+	tests := []struct {
+		name      string
+		variables []lagoon.EnvironmentVariable
+		want      *Gateway
+		wantErr   string
+	}{
+		{
+			name: "gateway api not enabled",
+			variables: []lagoon.EnvironmentVariable{
+				{Name: "LAGOON_FEATURE_FLAG_GATEWAY_API_PARENT", Value: "lagoon-gateway/lagoon", Scope: "build"},
+			},
+		},
+		{
+			name: "parent gateway in another namespace",
+			variables: []lagoon.EnvironmentVariable{
+				{Name: "LAGOON_FEATURE_FLAG_GATEWAY_API", Value: "enabled", Scope: "build"},
+				{Name: "LAGOON_FEATURE_FLAG_GATEWAY_API_PARENT", Value: "lagoon-gateway/lagoon", Scope: "build"},
+			},
+			want: &Gateway{Namespace: "lagoon-gateway", Name: "lagoon"},
+		},
+		{
+			name: "parent gateway in the environment namespace",
+			variables: []lagoon.EnvironmentVariable{
+				{Name: "LAGOON_FEATURE_FLAG_GATEWAY_API", Value: "enabled", Scope: "build"},
+				{Name: "LAGOON_FEATURE_FLAG_GATEWAY_API_PARENT", Value: "lagoon", Scope: "build"},
+			},
+			want: &Gateway{Name: "lagoon"},
+		},
+		{
+			name: "parent gateway with a certificate issuer",
+			variables: []lagoon.EnvironmentVariable{
+				{Name: "LAGOON_FEATURE_FLAG_GATEWAY_API", Value: "enabled", Scope: "build"},
+				{Name: "LAGOON_FEATURE_FLAG_GATEWAY_API_PARENT", Value: "lagoon-gateway/lagoon", Scope: "build"},
+				{Name: "LAGOON_FEATURE_FLAG_GATEWAY_API_CERTIFICATE_ISSUER", Value: "lagoon-acme", Scope: "build"},
+			},
+			want: &Gateway{Namespace: "lagoon-gateway", Name: "lagoon", CertificateIssuer: "lagoon-acme"},
+		},
+		{
+			name: "invalid certificate issuer",
+			variables: []lagoon.EnvironmentVariable{
+				{Name: "LAGOON_FEATURE_FLAG_GATEWAY_API", Value: "enabled", Scope: "build"},
+				{Name: "LAGOON_FEATURE_FLAG_GATEWAY_API_PARENT", Value: "lagoon", Scope: "build"},
+				{Name: "LAGOON_FEATURE_FLAG_GATEWAY_API_CERTIFICATE_ISSUER", Value: "Lagoon_Acme", Scope: "build"},
+			},
+			wantErr: "the gateway certificate issuer Lagoon_Acme is not valid: a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')",
+		},
+		{
+			name: "no parent gateway",
+			variables: []lagoon.EnvironmentVariable{
+				{Name: "LAGOON_FEATURE_FLAG_GATEWAY_API", Value: "enabled", Scope: "build"},
+			},
+			wantErr: "the gateway api is enabled but no parent gateway is defined, contact your Lagoon administrator",
+		},
+		{
+			name: "invalid parent gateway namespace",
+			variables: []lagoon.EnvironmentVariable{
+				{Name: "LAGOON_FEATURE_FLAG_GATEWAY_API", Value: "enabled", Scope: "build"},
+				{Name: "LAGOON_FEATURE_FLAG_GATEWAY_API_PARENT", Value: "lagoon.gateway/lagoon", Scope: "build"},
+			},
+			wantErr: "the parent gateway namespace lagoon.gateway is not valid: must not contain dots",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generateGateway(BuildValues{EnvironmentVariables: tt.variables}, false)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("generateGateway() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("generateGateway() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("generateGateway() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -11,6 +11,10 @@ import (
 	"github.com/uselagoon/build-deploy-tool/internal/dbaasclient"
 	"github.com/uselagoon/build-deploy-tool/internal/helpers"
 	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"github.com/uselagoon/build-deploy-tool/internal/policy"
+	"github.com/uselagoon/build-deploy-tool/internal/registryauth"
+	"github.com/uselagoon/build-deploy-tool/internal/secretref"
+	"github.com/uselagoon/build-deploy-tool/internal/servicetypes"
 )
 
 type Generator struct {
@@ -56,6 +60,8 @@ type GeneratorInput struct {
 	IgnoreMissingEnvFiles      bool
 	Debug                      bool
 	DBaaSClient                *dbaasclient.Client
+	RegistryAuthClient         *registryauth.Client
+	SecretRefClient            *secretref.Client
 	ImageReferences            map[string]string
 	Namespace                  string
 	DefaultBackupSchedule      string
@@ -73,6 +79,8 @@ type GeneratorInput struct {
 	ConfigSSHPort              string
 	DBaaSVariables             map[string]string
 	ConfigMapVars              map[string]string
+	ServiceTypesDir            string
+	PolicyFile                 string
 }
 
 func NewGenerator(
@@ -118,9 +126,23 @@ func NewGenerator(
 	dynamicDBaaSSecrets := helpers.GetEnv("DYNAMIC_DBAAS_SECRETS", strings.Join(generator.DynamicDBaaSSecrets, ","), generator.Debug)
 	imageCacheBuildArgsJSON := helpers.GetEnv("LAGOON_CACHE_BUILD_ARGS", generator.ImageCacheBuildArgsJSON, generator.Debug)
 	buildValues.SSHPrivateKey = helpers.GetEnv("SSH_PRIVATE_KEY", generator.SSHPrivateKey, generator.Debug)
+	serviceTypesDir := helpers.GetEnv("LAGOON_SERVICE_TYPES_DIR", generator.ServiceTypesDir, generator.Debug)
+	policyFile := helpers.GetEnv("LAGOON_POLICY_FILE", generator.PolicyFile, generator.Debug)
 	// this is used by CI systems to influence builds, it is rarely used and should probably be abandoned
 	buildValues.IsCI = helpers.GetEnvBool("CI", generator.CI, generator.Debug)
 
+	// load any additional service types, these are validated before anything else is done with the build
+	if err := servicetypes.LoadServiceTypes(serviceTypesDir); err != nil {
+		return nil, err
+	}
+
+	// load the cluster policy, the generated objects are evaluated against this before they are written
+	clusterPolicy, err := policy.LoadPolicy(policyFile)
+	if err != nil {
+		return nil, err
+	}
+	buildValues.Policy = clusterPolicy
+
 	// add dbaas credentials to build values for injection into configmap
 	buildValues.LagoonPlatformEnvVariables = generator.ConfigMapVars
 
@@ -149,7 +171,7 @@ func NewGenerator(
 	// try source the namespace from the generator, but whatever is defined in the service account location
 	// should be used if one exists, falls back to whatever came in via generator
 	namespace := helpers.GetEnv("NAMESPACE", generator.Namespace, generator.Debug)
-	namespace, err := helpers.GetNamespace(namespace, "/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	namespace, err = helpers.GetNamespace(namespace, "/var/run/secrets/kubernetes.io/serviceaccount/namespace")
 	if err != nil {
 		// a file was found, but there was an issue accessing it
 		return nil, err
@@ -175,6 +197,8 @@ func NewGenerator(
 
 	//add the dbaas client to build values too
 	buildValues.DBaaSClient = generator.DBaaSClient
+	buildValues.RegistryAuthClient = generator.RegistryAuthClient
+	buildValues.SecretRefClient = generator.SecretRefClient
 
 	buildValues.DefaultBackupSchedule = defaultBackupSchedule
 
@@ -352,6 +376,12 @@ func NewGenerator(
 	ingressClass := CheckFeatureFlag("INGRESS_CLASS", buildValues.EnvironmentVariables, generator.Debug)
 	buildValues.IngressClass = ingressClass
 
+	// check the environment for the GATEWAY_API flag, routes are generated as httproutes attached to the parent gateway instead of ingress
+	buildValues.Gateway, err = generateGateway(buildValues, generator.Debug)
+	if err != nil {
+		return nil, err
+	}
+
 	// check for rootless workloads
 	rootlessWorkloads := CheckFeatureFlag("ROOTLESS_WORKLOAD", buildValues.EnvironmentVariables, generator.Debug)
 	if rootlessWorkloads == "enabled" {
@@ -381,6 +411,8 @@ func NewGenerator(
 	buildValues.Resources.Limits.Memory = CheckAdminFeatureFlag("CONTAINER_MEMORY_LIMIT", false)
 	buildValues.Resources.Limits.EphemeralStorage = CheckAdminFeatureFlag("EPHEMERAL_STORAGE_LIMIT", false)
 	buildValues.Resources.Requests.EphemeralStorage = CheckAdminFeatureFlag("EPHEMERAL_STORAGE_REQUESTS", false)
+	buildValues.Resources.Maximum.CPU = CheckAdminFeatureFlag("CONTAINER_MAX_CPU", false)
+	buildValues.Resources.Maximum.Memory = CheckAdminFeatureFlag("CONTAINER_MAX_MEMORY", false)
 	automount, _ := strconv.ParseBool(CheckAdminFeatureFlag("AUTOMOUNT_SERVICE_ACCOUNT_TOKEN", false))
 	buildValues.AutoMountServiceAccountToken = automount
 	// validate that what is provided
@@ -402,6 +434,18 @@ func NewGenerator(
 			return nil, fmt.Errorf("provided  ephemeral storage requests %s is not a valid resource quantity, contact your Lagoon administrator", buildValues.Resources.Requests.EphemeralStorage)
 		}
 	}
+	if buildValues.Resources.Maximum.CPU != "" {
+		err := ValidateResourceQuantity(buildValues.Resources.Maximum.CPU)
+		if err != nil {
+			return nil, fmt.Errorf("provided maximum cpu %s is not a valid resource quantity, contact your Lagoon administrator", buildValues.Resources.Maximum.CPU)
+		}
+	}
+	if buildValues.Resources.Maximum.Memory != "" {
+		err := ValidateResourceQuantity(buildValues.Resources.Maximum.Memory)
+		if err != nil {
+			return nil, fmt.Errorf("provided maximum memory %s is not a valid resource quantity, contact your Lagoon administrator", buildValues.Resources.Maximum.Memory)
+		}
+	}
 
 	// get any variables from the API here that could be used to influence a build or services within the environment
 	// collect docker buildkit value
@@ -504,8 +548,16 @@ func NewGenerator(
 	if err != nil {
 		return nil, err
 	}
+	// redirects are templated with the main routes, but they aren't served by the environment so they are stored separately
+	buildValues.RedirectRoutes = []string{}
+	for _, route := range mainRoutes.Routes {
+		if route.Redirect != nil {
+			buildValues.RedirectRoutes = append(buildValues.RedirectRoutes, fmt.Sprintf("https://%s", route.Domain))
+		}
+	}
 	if buildValues.RouteQuota != nil {
-		customRoutes := len(buildValues.Routes) - len(buildValues.AutogeneratedRoutes)
+		// redirects aren't served by the environment, but they still count as custom routes for the route quota
+		customRoutes := len(buildValues.Routes) - len(buildValues.AutogeneratedRoutes) + len(buildValues.RedirectRoutes)
 		if customRoutes > *buildValues.RouteQuota && *buildValues.RouteQuota != -1 {
 			return nil, fmt.Errorf("this environment requests %d custom routes, this would exceed the route quota of %d", customRoutes, *buildValues.RouteQuota)
 		}
@@ -530,6 +582,7 @@ func NewGenerator(
 	for k, v := range generator.DBaaSVariables {
 		lagoonEnv[k] = v
 	}
+	// any secret references in the lagoon-env variables are resolved when the lagoon-env secret is templated
 	buildValues.LagoonEnvVariables = lagoonEnv
 	// filter out variables that exist in the lagoon-env secret from the platform-env secret
 	for ck := range buildValues.LagoonEnvVariables {
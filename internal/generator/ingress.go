@@ -4,7 +4,6 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	"github.com/uselagoon/build-deploy-tool/internal/helpers"
 	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
@@ -97,6 +96,14 @@ func generateRoutes(
 		}
 	}
 
+	// redirects are validated against all the routes the environment serves, so they are generated last.
+	// they are templated with the main routes, but they are not routes the environment serves so they aren't in the remainders
+	redirectRoutes, err := generateRedirectRoutes(buildValues, *autogenRoutes, *mainRoutes, *activeStanbyRoutes)
+	if err != nil {
+		return "", []string{}, []string{}, fmt.Errorf("couldn't generate redirects: %v", err)
+	}
+	mainRoutes.Routes = append(mainRoutes.Routes, redirectRoutes.Routes...)
+
 	return primary, remainders, autogen, nil
 }
 
@@ -128,8 +135,17 @@ func generateAutogenRoutes(
 	// generate autogenerated routes for the services
 	// get the router pattern
 	lagoonRouterPattern, err := lagoon.GetLagoonVariable("LAGOON_SYSTEM_ROUTER_PATTERN", []string{"internal_system"}, envVars)
-	if err == nil {
-		// if the `LAGOON_SYSTEM_ROUTER_PATTERN` exists, generate the routes
+	// the router patterns can be used to define patterns for specific service types, pullrequests, or environment types
+	routerPatterns, pErr := getRouterPatterns(envVars)
+	if pErr != nil {
+		return pErr
+	}
+	if err == nil || routerPatterns != nil {
+		defaultPattern := ""
+		if lagoonRouterPattern != nil {
+			defaultPattern = lagoonRouterPattern.Value
+		}
+		// if the `LAGOON_SYSTEM_ROUTER_PATTERN` or `LAGOON_SYSTEM_ROUTER_PATTERNS` exists, generate the routes
 		for idx, service := range buildValues.Services {
 			// get the service type
 			// if autogenerated routes are enabled, generate them :)
@@ -140,7 +156,14 @@ func generateAutogenRoutes(
 					// but if a typename is provided by the service, use it instead
 					serviceOverrideName = service.OverrideName
 				}
-				domain, shortDomain := autogeneratedDomainFromPattern(lagoonRouterPattern.Value, serviceOverrideName, buildValues.Project, buildValues.Environment)
+				pattern := routerPatterns.routerPattern(defaultPattern, service.Type, *buildValues)
+				if pattern == "" {
+					return fmt.Errorf("no router pattern is defined for service %s, contact your Lagoon administrator", serviceOverrideName)
+				}
+				domain, shortDomain, err := autogeneratedDomainFromPattern(pattern, serviceOverrideName, *buildValues)
+				if err != nil {
+					return err
+				}
 				buildValues.Services[idx].AutogeneratedRouteDomain = domain
 				buildValues.Services[idx].ShortAutogeneratedRouteDomain = shortDomain
 
@@ -230,52 +253,10 @@ func generateAutogenRoutes(
 		}
 		return nil
 	}
-	// if there is no LAGOON_SYSTEM_ROUTER_PATTERN found, abort
+	// if there is no LAGOON_SYSTEM_ROUTER_PATTERN or LAGOON_SYSTEM_ROUTER_PATTERNS found, abort
 	return err
 }
 
-// autogeneratedDomainFromPattern generates the domain name and the shortened domain name for an autogenerated ingress
-func autogeneratedDomainFromPattern(pattern, service, projectName, environmentName string) (string, string) {
-	domain := pattern
-	shortDomain := pattern
-
-	// fallback check for ${service} in the router pattern
-	hasServicePattern := false
-	if strings.Contains(pattern, "${service}") {
-		hasServicePattern = true
-	}
-
-	// find and replace
-	domain = strings.Replace(domain, "${service}", service, 1)
-	domain = strings.Replace(domain, "${project}", projectName, 1)
-	domain = strings.Replace(domain, "${environment}", environmentName, 1)
-	// find and replace for the short domain
-	shortDomain = strings.Replace(shortDomain, "${service}", service, 1)
-	shortDomain = strings.Replace(shortDomain, "${project}", helpers.GetBase32EncodedLowercase(helpers.GetSha256Hash(projectName))[:8], 1)
-	shortDomain = strings.Replace(shortDomain, "${environment}", helpers.GetBase32EncodedLowercase(helpers.GetSha256Hash(environmentName))[:8], 1)
-
-	if !hasServicePattern {
-		domain = fmt.Sprintf("%s.%s", service, domain)
-		shortDomain = fmt.Sprintf("%s.%s", service, shortDomain)
-	}
-
-	domainParts := strings.Split(domain, ".")
-	domainHash := helpers.GetBase32EncodedLowercase(helpers.GetSha256Hash(domain))
-	finalDomain := ""
-	for count, part := range domainParts {
-		domainPart := part
-		if len(part) > 63 {
-			domainPart = fmt.Sprintf("%s-%s", part[:54], domainHash[:8])
-		}
-		if count == (len(domainParts) - 1) {
-			finalDomain = fmt.Sprintf("%s%s", finalDomain, domainPart)
-		} else {
-			finalDomain = fmt.Sprintf("%s%s.", finalDomain, domainPart)
-		}
-	}
-	return finalDomain, shortDomain
-}
-
 // create the activestandby routes from lagoon yaml
 func generateActiveStandbyRoutes(
 	envVars []lagoon.EnvironmentVariable,
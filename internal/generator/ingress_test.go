@@ -875,7 +875,11 @@ func Test_autogeneratedDomainFromPattern(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, got1 := autogeneratedDomainFromPattern(tt.args.pattern, tt.args.service, tt.args.projectName, tt.args.environmentName)
+			got, got1, err := autogeneratedDomainFromPattern(tt.args.pattern, tt.args.service, BuildValues{Project: tt.args.projectName, Environment: tt.args.environmentName})
+			if err != nil {
+				t.Errorf("autogeneratedDomainFromPattern() error = %v", err)
+				return
+			}
 			if got != tt.want {
 				t.Errorf("autogeneratedDomainFromPattern() got = %v, want %v", got, tt.want)
 			}
@@ -0,0 +1,131 @@
+package generator
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// generateRedirectRoutes generates the redirect routes defined in the `redirects` of the environment in the .lagoon.yml
+// the redirects are validated against the routes that the environment serves, so this must be run after all other routes are generated
+func generateRedirectRoutes(buildValues BuildValues, servedRoutes ...lagoon.RoutesV2) (lagoon.RoutesV2, error) {
+	redirectRoutes := lagoon.RoutesV2{}
+	redirects := buildValues.LagoonYAML.Environments[buildValues.Branch].Redirects
+	if len(redirects) == 0 {
+		return redirectRoutes, nil
+	}
+
+	// collect every domain the environment serves and the service that serves it
+	served := map[string]string{}
+	for _, routes := range servedRoutes {
+		for _, route := range routes.Routes {
+			served[route.Domain] = route.LagoonService
+			for _, alternativeName := range route.AlternativeNames {
+				served[alternativeName] = route.LagoonService
+			}
+			if route.Wildcard != nil && *route.Wildcard {
+				served[fmt.Sprintf("*.%s", route.Domain)] = route.LagoonService
+			}
+		}
+	}
+
+	// collect the target hosts of the redirects so that chains of redirects can be followed
+	targets := map[string]string{}
+	for _, redirect := range redirects {
+		domain := strings.ToLower(redirect.Domain)
+		if err := validation.IsDNS1123Subdomain(domain); err != nil {
+			return redirectRoutes, fmt.Errorf("the redirect domain %s is not valid: %v", redirect.Domain, strings.Join(err, ", "))
+		}
+		if _, ok := servedService(served, domain); ok {
+			return redirectRoutes, fmt.Errorf("the redirect domain %s is already a route of this environment", redirect.Domain)
+		}
+		if _, ok := targets[domain]; ok {
+			return redirectRoutes, fmt.Errorf("the redirect domain %s is defined more than once", redirect.Domain)
+		}
+		target, err := url.Parse(redirect.Target)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+			return redirectRoutes, fmt.Errorf("the redirect target %s for %s is not a valid http or https url", redirect.Target, redirect.Domain)
+		}
+		targets[domain] = strings.ToLower(target.Hostname())
+	}
+
+	for _, redirect := range redirects {
+		domain := strings.ToLower(redirect.Domain)
+		// follow the redirect through any other redirects to the domain that finally serves the request
+		chain := []string{domain}
+		host := targets[domain]
+		for {
+			for _, visited := range chain {
+				if visited == host {
+					return redirectRoutes, fmt.Errorf("the redirect for %s is a redirect loop: %s -> %s", redirect.Domain, strings.Join(chain, " -> "), host)
+				}
+			}
+			next, ok := targets[host]
+			if !ok {
+				break
+			}
+			chain = append(chain, host)
+			host = next
+		}
+		service, ok := servedService(served, host)
+		if !ok {
+			return redirectRoutes, fmt.Errorf("the redirect target %s for %s is not a domain served by this environment", redirect.Target, redirect.Domain)
+		}
+
+		statusCode := redirect.StatusCode
+		switch statusCode {
+		case 0:
+			statusCode = http.StatusMovedPermanently
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			return redirectRoutes, fmt.Errorf("the redirect status code %d for %s is not valid, it must be one of 301, 302, 307, or 308", statusCode, redirect.Domain)
+		}
+
+		// redirects have their own certificate, and the insecure traffic is redirected straight to the target
+		route := lagoon.RouteV2{
+			Domain:        domain,
+			LagoonService: service,
+			IngressName:   domain,
+			IngressClass:  buildValues.IngressClass,
+			TLSAcme:       helpers.BoolPtr(true),
+			Insecure:      helpers.StrPtr("Allow"),
+			Annotations:   map[string]string{},
+			Labels:        map[string]string{},
+			Redirect: &lagoon.RouteRedirect{
+				Target:        redirect.Target,
+				StatusCode:    statusCode,
+				PreservePath:  true,
+				PreserveQuery: true,
+			},
+		}
+		if redirect.TLSAcme != nil {
+			route.TLSAcme = redirect.TLSAcme
+		}
+		if redirect.PreservePath != nil {
+			route.Redirect.PreservePath = *redirect.PreservePath
+		}
+		if redirect.PreserveQuery != nil {
+			route.Redirect.PreserveQuery = *redirect.PreserveQuery
+		}
+		redirectRoutes.Routes = append(redirectRoutes.Routes, route)
+	}
+	return redirectRoutes, nil
+}
+
+// servedService returns the service that serves a domain, including any wildcard routes that match the domain
+func servedService(served map[string]string, domain string) (string, bool) {
+	if service, ok := served[domain]; ok {
+		return service, true
+	}
+	if _, parent, ok := strings.Cut(domain, "."); ok {
+		if service, ok := served[fmt.Sprintf("*.%s", parent)]; ok {
+			return service, true
+		}
+	}
+	return "", false
+}
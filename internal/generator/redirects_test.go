@@ -0,0 +1,186 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+)
+
+func Test_generateRedirectRoutes(t *testing.T) {
+	servedRoutes := lagoon.RoutesV2{
+		Routes: []lagoon.RouteV2{
+			{
+				Domain:           "www.example.com",
+				LagoonService:    "nginx",
+				AlternativeNames: []string{"example.com"},
+			},
+			{
+				Domain:        "example.org",
+				LagoonService: "node",
+				Wildcard:      helpers.BoolPtr(true),
+			},
+		},
+	}
+	tests := []struct {
+		name      string
+		redirects []lagoon.Redirect
+		want      lagoon.RoutesV2
+		wantErr   string
+	}{
+		{
+			name: "no redirects",
+			want: lagoon.RoutesV2{},
+		},
+		{
+			name: "redirect defaults",
+			redirects: []lagoon.Redirect{
+				{Domain: "Old.example.com", Target: "https://www.example.com/"},
+			},
+			want: lagoon.RoutesV2{
+				Routes: []lagoon.RouteV2{
+					{
+						Domain:        "old.example.com",
+						LagoonService: "nginx",
+						IngressName:   "old.example.com",
+						IngressClass:  "nginx",
+						TLSAcme:       helpers.BoolPtr(true),
+						Insecure:      helpers.StrPtr("Allow"),
+						Annotations:   map[string]string{},
+						Labels:        map[string]string{},
+						Redirect: &lagoon.RouteRedirect{
+							Target:        "https://www.example.com/",
+							StatusCode:    301,
+							PreservePath:  true,
+							PreserveQuery: true,
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "redirect chain to a wildcard route",
+			redirects: []lagoon.Redirect{
+				{Domain: "old.example.com", Target: "https://new.example.com/", StatusCode: 302, PreservePath: helpers.BoolPtr(false), TLSAcme: helpers.BoolPtr(false)},
+				{Domain: "new.example.com", Target: "https://shop.example.org/"},
+			},
+			want: lagoon.RoutesV2{
+				Routes: []lagoon.RouteV2{
+					{
+						Domain:        "old.example.com",
+						LagoonService: "node",
+						IngressName:   "old.example.com",
+						IngressClass:  "nginx",
+						TLSAcme:       helpers.BoolPtr(false),
+						Insecure:      helpers.StrPtr("Allow"),
+						Annotations:   map[string]string{},
+						Labels:        map[string]string{},
+						Redirect: &lagoon.RouteRedirect{
+							Target:        "https://new.example.com/",
+							StatusCode:    302,
+							PreservePath:  false,
+							PreserveQuery: true,
+						},
+					},
+					{
+						Domain:        "new.example.com",
+						LagoonService: "node",
+						IngressName:   "new.example.com",
+						IngressClass:  "nginx",
+						TLSAcme:       helpers.BoolPtr(true),
+						Insecure:      helpers.StrPtr("Allow"),
+						Annotations:   map[string]string{},
+						Labels:        map[string]string{},
+						Redirect: &lagoon.RouteRedirect{
+							Target:        "https://shop.example.org/",
+							StatusCode:    301,
+							PreservePath:  true,
+							PreserveQuery: true,
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "redirect loop",
+			redirects: []lagoon.Redirect{
+				{Domain: "a.example.com", Target: "https://b.example.com/"},
+				{Domain: "b.example.com", Target: "https://a.example.com/"},
+			},
+			wantErr: "the redirect for a.example.com is a redirect loop: a.example.com -> b.example.com -> a.example.com",
+		},
+		{
+			name: "redirect to itself",
+			redirects: []lagoon.Redirect{
+				{Domain: "a.example.com", Target: "https://a.example.com/other"},
+			},
+			wantErr: "the redirect for a.example.com is a redirect loop: a.example.com -> a.example.com",
+		},
+		{
+			name: "redirect to a domain not served by the environment",
+			redirects: []lagoon.Redirect{
+				{Domain: "old.example.com", Target: "https://www.example.net/"},
+			},
+			wantErr: "the redirect target https://www.example.net/ for old.example.com is not a domain served by this environment",
+		},
+		{
+			name: "redirect domain is a route",
+			redirects: []lagoon.Redirect{
+				{Domain: "example.com", Target: "https://www.example.com/"},
+			},
+			wantErr: "the redirect domain example.com is already a route of this environment",
+		},
+		{
+			name: "redirect domain defined twice",
+			redirects: []lagoon.Redirect{
+				{Domain: "old.example.com", Target: "https://www.example.com/"},
+				{Domain: "old.example.com", Target: "https://example.com/"},
+			},
+			wantErr: "the redirect domain old.example.com is defined more than once",
+		},
+		{
+			name: "invalid target",
+			redirects: []lagoon.Redirect{
+				{Domain: "old.example.com", Target: "www.example.com"},
+			},
+			wantErr: "the redirect target www.example.com for old.example.com is not a valid http or https url",
+		},
+		{
+			name: "invalid status code",
+			redirects: []lagoon.Redirect{
+				{Domain: "old.example.com", Target: "https://www.example.com/", StatusCode: 303},
+			},
+			wantErr: "the redirect status code 303 for old.example.com is not valid, it must be one of 301, 302, 307, or 308",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buildValues := BuildValues{
+				Branch:       "main",
+				IngressClass: "nginx",
+				LagoonYAML: lagoon.YAML{
+					Environments: lagoon.Environments{
+						"main": lagoon.Environment{
+							Redirects: tt.redirects,
+						},
+					},
+				},
+			}
+			got, err := generateRedirectRoutes(buildValues, servedRoutes)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("generateRedirectRoutes() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("generateRedirectRoutes() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("generateRedirectRoutes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
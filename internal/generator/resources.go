@@ -0,0 +1,146 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// generateServiceResources calculates the cpu and memory requests and limits of a service from the `overrides` of the environment
+// in the .lagoon.yml file. The requests and limits can't be more than the maximums set by the Lagoon administrator, if there is no
+// maximum memory set then the environment memory limit is used as the maximum
+func generateServiceResources(buildValues *BuildValues, composeService string) (*corev1.ResourceRequirements, error) {
+	override := buildValues.LagoonYAML.Environments[buildValues.Environment].Overrides[composeService].Resources
+	if override == nil {
+		return nil, nil
+	}
+	maximums := map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    buildValues.Resources.Maximum.CPU,
+		corev1.ResourceMemory: buildValues.Resources.Maximum.Memory,
+	}
+	if maximums[corev1.ResourceMemory] == "" {
+		maximums[corev1.ResourceMemory] = buildValues.Resources.Limits.Memory
+	}
+	resources := &corev1.ResourceRequirements{}
+	var err error
+	if resources.Requests, err = serviceResourceList(override.Requests, "request", composeService, maximums); err != nil {
+		return nil, err
+	}
+	if resources.Limits, err = serviceResourceList(override.Limits, "limit", composeService, maximums); err != nil {
+		return nil, err
+	}
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		request, ok := resources.Requests[name]
+		if !ok {
+			continue
+		}
+		limit, ok := resources.Limits[name]
+		if !ok && name == corev1.ResourceMemory && buildValues.Resources.Limits.Memory != "" {
+			// the environment memory limit is used if the service doesn't have its own
+			limit, ok = resource.MustParse(buildValues.Resources.Limits.Memory), true
+		}
+		if ok && request.Cmp(limit) > 0 {
+			return nil, fmt.Errorf("the %s request %s for service %s is more than the %s limit %s", name, request.String(), composeService, name, limit.String())
+		}
+	}
+	if resources.Requests == nil && resources.Limits == nil {
+		return nil, nil
+	}
+	return resources, nil
+}
+
+func serviceResourceList(list lagoon.ResourceList, kind, composeService string, maximums map[corev1.ResourceName]string) (corev1.ResourceList, error) {
+	var resources corev1.ResourceList
+	values := []struct {
+		name  corev1.ResourceName
+		value string
+	}{
+		{corev1.ResourceCPU, list.CPU},
+		{corev1.ResourceMemory, list.Memory},
+	}
+	for _, v := range values {
+		name, value := v.name, v.value
+		if value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("the %s %s %s for service %s is not a valid resource quantity: %v", name, kind, value, composeService, err)
+		}
+		if quantity.Sign() <= 0 {
+			return nil, fmt.Errorf("the %s %s %s for service %s must be greater than 0", name, kind, value, composeService)
+		}
+		if maximums[name] != "" {
+			maximum := resource.MustParse(maximums[name])
+			if quantity.Cmp(maximum) > 0 {
+				return nil, fmt.Errorf("the %s %s %s for service %s is more than the maximum of %s, contact your Lagoon administrator", name, kind, value, composeService, maximums[name])
+			}
+		}
+		if resources == nil {
+			resources = corev1.ResourceList{}
+		}
+		resources[name] = quantity
+	}
+	return resources, nil
+}
+
+// generateServiceProbes checks the probe overrides of a service in the `overrides` of the environment in the .lagoon.yml file,
+// the probes are merged with the default probes of the service type when the service is templated
+func generateServiceProbes(buildValues *BuildValues, composeService string) (*ServiceProbes, error) {
+	override := buildValues.LagoonYAML.Environments[buildValues.Environment].Overrides[composeService]
+	if override.ReadinessProbe == nil && override.LivenessProbe == nil && override.StartupProbe == nil {
+		return nil, nil
+	}
+	probes := &ServiceProbes{
+		Readiness: override.ReadinessProbe,
+		Liveness:  override.LivenessProbe,
+		Startup:   override.StartupProbe,
+	}
+	checks := []struct {
+		name  string
+		probe *lagoon.Probe
+	}{
+		{"readiness", probes.Readiness},
+		{"liveness", probes.Liveness},
+		{"startup", probes.Startup},
+	}
+	for _, check := range checks {
+		name, probe := check.name, check.probe
+		if probe == nil {
+			continue
+		}
+		handlers := 0
+		for _, set := range []bool{probe.Exec != nil, probe.HTTPGet != nil, probe.TCPSocket != nil, probe.GRPC != nil} {
+			if set {
+				handlers++
+			}
+		}
+		if handlers > 1 {
+			return nil, fmt.Errorf("the %s probe for service %s can only define one of exec, httpGet, tcpSocket or grpc", name, composeService)
+		}
+		if probe.InitialDelaySeconds != nil && *probe.InitialDelaySeconds < 0 {
+			return nil, fmt.Errorf("the %s probe initialDelaySeconds for service %s must not be negative", name, composeService)
+		}
+		timings := []struct {
+			field string
+			value *int32
+		}{
+			{"timeoutSeconds", probe.TimeoutSeconds},
+			{"periodSeconds", probe.PeriodSeconds},
+			{"successThreshold", probe.SuccessThreshold},
+			{"failureThreshold", probe.FailureThreshold},
+		}
+		for _, timing := range timings {
+			if timing.value != nil && *timing.value < 1 {
+				return nil, fmt.Errorf("the %s probe %s for service %s must be greater than 0", name, timing.field, composeService)
+			}
+		}
+		// kubernetes only allows liveness and startup probes to have a success threshold of 1
+		if name != "readiness" && probe.SuccessThreshold != nil && *probe.SuccessThreshold != 1 {
+			return nil, fmt.Errorf("the %s probe successThreshold for service %s must be 1", name, composeService)
+		}
+	}
+	return probes, nil
+}
@@ -0,0 +1,234 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func resourceBuildValues(resources Resources, override lagoon.Override) *BuildValues {
+	return &BuildValues{
+		Environment: "main",
+		Resources:   resources,
+		LagoonYAML: lagoon.YAML{
+			Environments: lagoon.Environments{
+				"main": {
+					Overrides: map[string]lagoon.Override{
+						"php": override,
+					},
+				},
+			},
+		},
+	}
+}
+
+func Test_generateServiceResources(t *testing.T) {
+	tests := []struct {
+		name        string
+		buildValues *BuildValues
+		want        *corev1.ResourceRequirements
+		wantErr     string
+	}{
+		{
+			name:        "no overrides",
+			buildValues: resourceBuildValues(Resources{}, lagoon.Override{}),
+		},
+		{
+			name: "requests and limits",
+			buildValues: resourceBuildValues(Resources{}, lagoon.Override{
+				Resources: &lagoon.Resources{
+					Requests: lagoon.ResourceList{CPU: "500m", Memory: "1Gi"},
+					Limits:   lagoon.ResourceList{CPU: "2", Memory: "2Gi"},
+				},
+			}),
+			want: &corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("500m"),
+					corev1.ResourceMemory: resource.MustParse("1Gi"),
+				},
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("2"),
+					corev1.ResourceMemory: resource.MustParse("2Gi"),
+				},
+			},
+		},
+		{
+			name: "memory limit above the environment limit with a maximum",
+			buildValues: resourceBuildValues(Resources{
+				Limits:  ResourceLimits{Memory: "1Gi"},
+				Maximum: ResourceMaximum{Memory: "4Gi"},
+			}, lagoon.Override{
+				Resources: &lagoon.Resources{
+					Limits: lagoon.ResourceList{Memory: "4Gi"},
+				},
+			}),
+			want: &corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("4Gi"),
+				},
+			},
+		},
+		{
+			name: "memory limit above the environment limit without a maximum",
+			buildValues: resourceBuildValues(Resources{
+				Limits: ResourceLimits{Memory: "1Gi"},
+			}, lagoon.Override{
+				Resources: &lagoon.Resources{
+					Limits: lagoon.ResourceList{Memory: "2Gi"},
+				},
+			}),
+			wantErr: "the memory limit 2Gi for service php is more than the maximum of 1Gi, contact your Lagoon administrator",
+		},
+		{
+			name: "cpu request above the maximum",
+			buildValues: resourceBuildValues(Resources{
+				Maximum: ResourceMaximum{CPU: "1"},
+			}, lagoon.Override{
+				Resources: &lagoon.Resources{
+					Requests: lagoon.ResourceList{CPU: "1500m"},
+				},
+			}),
+			wantErr: "the cpu request 1500m for service php is more than the maximum of 1, contact your Lagoon administrator",
+		},
+		{
+			name: "request above the limit",
+			buildValues: resourceBuildValues(Resources{}, lagoon.Override{
+				Resources: &lagoon.Resources{
+					Requests: lagoon.ResourceList{CPU: "2"},
+					Limits:   lagoon.ResourceList{CPU: "1"},
+				},
+			}),
+			wantErr: "the cpu request 2 for service php is more than the cpu limit 1",
+		},
+		{
+			name: "request above the environment memory limit",
+			buildValues: resourceBuildValues(Resources{
+				Limits:  ResourceLimits{Memory: "1Gi"},
+				Maximum: ResourceMaximum{Memory: "4Gi"},
+			}, lagoon.Override{
+				Resources: &lagoon.Resources{
+					Requests: lagoon.ResourceList{Memory: "2Gi"},
+				},
+			}),
+			wantErr: "the memory request 2Gi for service php is more than the memory limit 1Gi",
+		},
+		{
+			name: "invalid quantity",
+			buildValues: resourceBuildValues(Resources{}, lagoon.Override{
+				Resources: &lagoon.Resources{
+					Limits: lagoon.ResourceList{Memory: "2GB"},
+				},
+			}),
+			wantErr: "the memory limit 2GB for service php is not a valid resource quantity: quantities must match the regular expression '^([+-]?[0-9.]+)([eEinumkKMGTP]*[-+]?[0-9]*)$'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generateServiceResources(tt.buildValues, "php")
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("generateServiceResources() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("generateServiceResources() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("generateServiceResources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_generateServiceProbes(t *testing.T) {
+	tests := []struct {
+		name     string
+		override lagoon.Override
+		want     *ServiceProbes
+		wantErr  string
+	}{
+		{
+			name: "no overrides",
+		},
+		{
+			name: "probes",
+			override: lagoon.Override{
+				LivenessProbe: &lagoon.Probe{
+					InitialDelaySeconds: helpers.Int32Ptr(120),
+				},
+				StartupProbe: &lagoon.Probe{
+					ProbeHandler: corev1.ProbeHandler{
+						TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(9000)},
+					},
+					FailureThreshold: helpers.Int32Ptr(30),
+				},
+			},
+			want: &ServiceProbes{
+				Liveness: &lagoon.Probe{
+					InitialDelaySeconds: helpers.Int32Ptr(120),
+				},
+				Startup: &lagoon.Probe{
+					ProbeHandler: corev1.ProbeHandler{
+						TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(9000)},
+					},
+					FailureThreshold: helpers.Int32Ptr(30),
+				},
+			},
+		},
+		{
+			name: "multiple handlers",
+			override: lagoon.Override{
+				ReadinessProbe: &lagoon.Probe{
+					ProbeHandler: corev1.ProbeHandler{
+						TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(9000)},
+						HTTPGet:   &corev1.HTTPGetAction{Path: "/", Port: intstr.FromInt32(8080)},
+					},
+				},
+			},
+			wantErr: "the readiness probe for service php can only define one of exec, httpGet, tcpSocket or grpc",
+		},
+		{
+			name: "zero period",
+			override: lagoon.Override{
+				ReadinessProbe: &lagoon.Probe{
+					PeriodSeconds: helpers.Int32Ptr(0),
+				},
+			},
+			wantErr: "the readiness probe periodSeconds for service php must be greater than 0",
+		},
+		{
+			name: "liveness success threshold",
+			override: lagoon.Override{
+				LivenessProbe: &lagoon.Probe{
+					SuccessThreshold: helpers.Int32Ptr(2),
+				},
+			},
+			wantErr: "the liveness probe successThreshold for service php must be 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generateServiceProbes(resourceBuildValues(Resources{}, tt.override), "php")
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("generateServiceProbes() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("generateServiceProbes() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("generateServiceProbes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
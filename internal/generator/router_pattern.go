@@ -0,0 +1,181 @@
+package generator
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	machinerynamespace "github.com/uselagoon/machinery/utils/namespace"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// routerPatterns are the router patterns that can be used instead of the `LAGOON_SYSTEM_ROUTER_PATTERN`
+// for specific service types, pullrequest builds, or environment types
+type routerPatterns struct {
+	Production   string            `json:"production,omitempty"`
+	Development  string            `json:"development,omitempty"`
+	PullRequest  string            `json:"pullrequest,omitempty"`
+	ServiceTypes map[string]string `json:"serviceTypes,omitempty"`
+}
+
+var routerPatternExpression = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// getRouterPatterns collects the router patterns from the `LAGOON_SYSTEM_ROUTER_PATTERNS` variable if it is provided
+func getRouterPatterns(envVars []lagoon.EnvironmentVariable) (*routerPatterns, error) {
+	patterns := &routerPatterns{}
+	lagoonRouterPatterns, _ := lagoon.GetLagoonVariable("LAGOON_SYSTEM_ROUTER_PATTERNS", []string{"internal_system"}, envVars)
+	if lagoonRouterPatterns == nil {
+		return nil, nil
+	}
+	rawJSONStr, _ := base64.StdEncoding.DecodeString(lagoonRouterPatterns.Value)
+	if err := json.Unmarshal(rawJSONStr, patterns); err != nil {
+		return nil, fmt.Errorf("couldn't unmarshal router patterns from Lagoon API, is it actually JSON that has been base64 encoded?: %v", err)
+	}
+	return patterns, nil
+}
+
+// routerPattern returns the router pattern for a service, a service type pattern is preferred over a pullrequest pattern,
+// which is preferred over the environment type pattern. If none are defined, the default pattern is used
+func (p *routerPatterns) routerPattern(defaultPattern, serviceType string, buildValues BuildValues) string {
+	if p == nil {
+		return defaultPattern
+	}
+	if pattern, ok := p.ServiceTypes[serviceType]; ok && pattern != "" {
+		return pattern
+	}
+	if buildValues.BuildType == "pullrequest" && p.PullRequest != "" {
+		return p.PullRequest
+	}
+	if buildValues.EnvironmentType == "production" && p.Production != "" {
+		return p.Production
+	}
+	if buildValues.EnvironmentType == "development" && p.Development != "" {
+		return p.Development
+	}
+	return defaultPattern
+}
+
+// autogeneratedDomainFromPattern generates the domain name and the shortened domain name for an autogenerated ingress
+//
+// variables are defined as `${variable}` and can have functions applied to them in order `${variable|truncate:10|lowercase}`
+// the shortened domain uses hashes of the project, environment, and branch names to keep the domain as short as possible.
+// functions are always applied to the full value, so a variable with functions is the same in both domains
+func autogeneratedDomainFromPattern(pattern, service string, buildValues BuildValues) (string, string, error) {
+	shortProject := helpers.GetBase32EncodedLowercase(helpers.GetSha256Hash(buildValues.Project))[:8]
+	shortEnvironment := helpers.GetBase32EncodedLowercase(helpers.GetSha256Hash(buildValues.Environment))[:8]
+	variables := map[string][2]string{
+		"service":          {service, service},
+		"project":          {buildValues.Project, shortProject},
+		"environment":      {buildValues.Environment, shortEnvironment},
+		"branch":           {machinerynamespace.MakeSafe(buildValues.Branch), helpers.GetBase32EncodedLowercase(helpers.GetSha256Hash(buildValues.Branch))[:8]},
+		"prNumber":         {buildValues.PRNumber, buildValues.PRNumber},
+		"environmentType":  {buildValues.EnvironmentType, buildValues.EnvironmentType},
+		"shortProject":     {shortProject, shortProject},
+		"shortEnvironment": {shortEnvironment, shortEnvironment},
+	}
+
+	// fallback check for ${service} in the router pattern
+	hasServicePattern := false
+	var err error
+	replace := func(short int) func(string) string {
+		return func(match string) string {
+			expression := strings.Split(routerPatternExpression.FindStringSubmatch(match)[1], "|")
+			value, ok := variables[expression[0]]
+			if !ok {
+				if err == nil {
+					err = fmt.Errorf("router pattern %s has an unknown variable %s", pattern, expression[0])
+				}
+				return match
+			}
+			if expression[0] == "service" {
+				hasServicePattern = true
+			}
+			index := short
+			if len(expression) > 1 {
+				index = 0
+			}
+			result, fErr := routerPatternFunctions(value[index], expression[1:])
+			if fErr != nil && err == nil {
+				err = fmt.Errorf("router pattern %s is not valid: %v", pattern, fErr)
+			}
+			return result
+		}
+	}
+	domain := routerPatternExpression.ReplaceAllStringFunc(pattern, replace(0))
+	shortDomain := routerPatternExpression.ReplaceAllStringFunc(pattern, replace(1))
+	if err != nil {
+		return "", "", err
+	}
+
+	if !hasServicePattern {
+		domain = fmt.Sprintf("%s.%s", service, domain)
+		shortDomain = fmt.Sprintf("%s.%s", service, shortDomain)
+	}
+	domain = shortenDomainLabels(domain)
+	shortDomain = shortenDomainLabels(shortDomain)
+	// variables can be empty, like the prNumber of a branch build, or be truncated to end with a hyphen
+	for _, d := range []string{domain, shortDomain} {
+		if errs := validation.IsDNS1123Subdomain(d); errs != nil {
+			return "", "", fmt.Errorf("router pattern %s generated an invalid domain %s: %v", pattern, d, strings.Join(errs, ", "))
+		}
+	}
+	return domain, shortDomain, nil
+}
+
+// routerPatternFunctions applies the functions to a value from a router pattern in the order they are defined
+func routerPatternFunctions(value string, functions []string) (string, error) {
+	for _, function := range functions {
+		name, argument, hasArgument := strings.Cut(function, ":")
+		switch name {
+		case "lowercase":
+			value = strings.ToLower(value)
+		case "truncate":
+			length, err := strconv.Atoi(argument)
+			if !hasArgument || err != nil || length < 1 {
+				return "", fmt.Errorf("truncate requires a length greater than 0")
+			}
+			if len(value) > length {
+				// a truncated value can't end with a hyphen, it would make the label invalid
+				value = strings.TrimRight(value[:length], "-")
+			}
+		case "hash":
+			hash := strings.TrimRight(helpers.GetBase32EncodedLowercase(helpers.GetSha256Hash(value)), "=")
+			length := 8
+			if hasArgument {
+				var err error
+				length, err = strconv.Atoi(argument)
+				if err != nil || length < 1 || length > len(hash) {
+					return "", fmt.Errorf("hash requires a length between 1 and %d", len(hash))
+				}
+			}
+			value = hash[:length]
+		default:
+			return "", fmt.Errorf("unknown function %s", name)
+		}
+	}
+	return value, nil
+}
+
+// shortenDomainLabels shortens any label in a domain that is longer than 63 characters
+func shortenDomainLabels(domain string) string {
+	domainParts := strings.Split(domain, ".")
+	domainHash := helpers.GetBase32EncodedLowercase(helpers.GetSha256Hash(domain))
+	finalDomain := ""
+	for count, part := range domainParts {
+		domainPart := part
+		if len(part) > 63 {
+			domainPart = fmt.Sprintf("%s-%s", part[:54], domainHash[:8])
+		}
+		if count == (len(domainParts) - 1) {
+			finalDomain = fmt.Sprintf("%s%s", finalDomain, domainPart)
+		} else {
+			finalDomain = fmt.Sprintf("%s%s.", finalDomain, domainPart)
+		}
+	}
+	return finalDomain
+}
@@ -0,0 +1,251 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+)
+
+func Test_autogeneratedDomainFromPatternVariables(t *testing.T) {
+	tests := []struct {
+		name        string
+		pattern     string
+		service     string
+		buildValues BuildValues
+		want        string
+		want1       string
+		wantErr     string
+	}{
+		{
+			name:    "pullrequest variables",
+			pattern: "${service}.pr-${prNumber}.${project}.${environmentType}.example.com",
+			service: "nginx",
+			buildValues: BuildValues{
+				Project:         "example-com",
+				Environment:     "pr-123",
+				EnvironmentType: "development",
+				BuildType:       "pullrequest",
+				PRNumber:        "123",
+			},
+			want:  "nginx.pr-123.example-com.development.example.com",
+			want1: "nginx.pr-123.wjscrqcw.development.example.com",
+		},
+		{
+			name:    "branch and short project",
+			pattern: "${service}-${branch|lowercase}.${shortProject}.example.com",
+			service: "nginx",
+			buildValues: BuildValues{
+				Project:     "example-com",
+				Environment: "Feature-ABC",
+				Branch:      "Feature-ABC",
+			},
+			want:  "nginx-feature-abc.wjscrqcw.example.com",
+			want1: "nginx-feature-abc.wjscrqcw.example.com",
+		},
+		{
+			name:    "branch with a slash",
+			pattern: "${service}-${branch}.${project}.example.com",
+			service: "nginx",
+			buildValues: BuildValues{
+				Project:     "example-com",
+				Environment: "feature-foo",
+				Branch:      "feature/foo",
+			},
+			want:  "nginx-feature-foo.example-com.example.com",
+			want1: "nginx-7ezagjqh.wjscrqcw.example.com",
+		},
+		{
+			name:    "truncate and hash functions",
+			pattern: "${service|truncate:3}-${environment|truncate:8}-${project|hash}.example.com",
+			service: "nginx",
+			buildValues: BuildValues{
+				Project:     "example-com",
+				Environment: "a-very-long-environment-name",
+			},
+			want:  "ngi-a-very-l-wjscrqcw.example.com",
+			want1: "ngi-a-very-l-wjscrqcw.example.com",
+		},
+		{
+			name:    "no service in the pattern",
+			pattern: "${environment|hash:5}.${project}.example.com",
+			service: "nginx",
+			buildValues: BuildValues{
+				Project:     "example-com",
+				Environment: "main",
+			},
+			want:  "nginx.bvxea.example-com.example.com",
+			want1: "nginx.bvxea.wjscrqcw.example.com",
+		},
+		{
+			name:    "long label",
+			pattern: "${service}-${environment}-${project}.example.com",
+			service: "nginx",
+			buildValues: BuildValues{
+				Project:     "a-very-long-project-name-that-is-long",
+				Environment: "a-very-long-environment-name-that-is-long",
+			},
+			want:  "nginx-a-very-long-environment-name-that-is-long-a-very-d6c5bglf.example.com",
+			want1: "nginx-ilvzxxqn-37j4ks2f.example.com",
+		},
+		{
+			name:    "unknown variable",
+			pattern: "${service}-${namespace}.example.com",
+			service: "nginx",
+			wantErr: "router pattern ${service}-${namespace}.example.com has an unknown variable namespace",
+		},
+		{
+			name:    "unknown function",
+			pattern: "${service|uppercase}.example.com",
+			service: "nginx",
+			wantErr: "router pattern ${service|uppercase}.example.com is not valid: unknown function uppercase",
+		},
+		{
+			name:    "invalid truncate",
+			pattern: "${service|truncate}.example.com",
+			service: "nginx",
+			wantErr: "router pattern ${service|truncate}.example.com is not valid: truncate requires a length greater than 0",
+		},
+		{
+			name:    "empty prNumber on a branch build",
+			pattern: "${service}.${prNumber}.${project}.example.com",
+			service: "nginx",
+			buildValues: BuildValues{
+				Project:     "example-com",
+				Environment: "main",
+				Branch:      "main",
+				BuildType:   "branch",
+			},
+			wantErr: `router pattern ${service}.${prNumber}.${project}.example.com generated an invalid domain nginx..example-com.example.com: a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')`,
+		},
+		{
+			name:    "truncate trims a trailing hyphen",
+			pattern: "${environment|truncate:8}.${project}.example.com",
+			service: "nginx",
+			buildValues: BuildValues{
+				Project:     "example-com",
+				Environment: "feature-abc",
+			},
+			want:  "nginx.feature.example-com.example.com",
+			want1: "nginx.feature.wjscrqcw.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, got1, err := autogeneratedDomainFromPattern(tt.pattern, tt.service, tt.buildValues)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("autogeneratedDomainFromPattern() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("autogeneratedDomainFromPattern() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("autogeneratedDomainFromPattern() got = %v, want %v", got, tt.want)
+			}
+			if got1 != tt.want1 {
+				t.Errorf("autogeneratedDomainFromPattern() got1 = %v, want %v", got1, tt.want1)
+			}
+		})
+	}
+}
+
+func Test_routerPattern(t *testing.T) {
+	tests := []struct {
+		name        string
+		envVars     []lagoon.EnvironmentVariable
+		serviceType string
+		buildValues BuildValues
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        "no router patterns",
+			serviceType: "nginx",
+			buildValues: BuildValues{EnvironmentType: "production", BuildType: "branch"},
+			want:        "${service}-${project}-${environment}.example.com",
+		},
+		{
+			name: "production pattern",
+			envVars: []lagoon.EnvironmentVariable{
+				{
+					// {"production":"${service}.${project}.example.com","development":"${service}-${environment}.${project}.dev.example.com","pullrequest":"${service}-pr-${prNumber}.${project}.dev.example.com","serviceTypes":{"solr":"${service}-${environment}.${project}.search.example.com"}}
+					Name:  "LAGOON_SYSTEM_ROUTER_PATTERNS",
+					Value: "eyJwcm9kdWN0aW9uIjoiJHtzZXJ2aWNlfS4ke3Byb2plY3R9LmV4YW1wbGUuY29tIiwiZGV2ZWxvcG1lbnQiOiIke3NlcnZpY2V9LSR7ZW52aXJvbm1lbnR9LiR7cHJvamVjdH0uZGV2LmV4YW1wbGUuY29tIiwicHVsbHJlcXVlc3QiOiIke3NlcnZpY2V9LXByLSR7cHJOdW1iZXJ9LiR7cHJvamVjdH0uZGV2LmV4YW1wbGUuY29tIiwic2VydmljZVR5cGVzIjp7InNvbHIiOiIke3NlcnZpY2V9LSR7ZW52aXJvbm1lbnR9LiR7cHJvamVjdH0uc2VhcmNoLmV4YW1wbGUuY29tIn19",
+					Scope: "internal_system",
+				},
+			},
+			serviceType: "nginx",
+			buildValues: BuildValues{EnvironmentType: "production", BuildType: "branch"},
+			want:        "${service}.${project}.example.com",
+		},
+		{
+			name: "development pattern",
+			envVars: []lagoon.EnvironmentVariable{
+				{
+					Name:  "LAGOON_SYSTEM_ROUTER_PATTERNS",
+					Value: "eyJwcm9kdWN0aW9uIjoiJHtzZXJ2aWNlfS4ke3Byb2plY3R9LmV4YW1wbGUuY29tIiwiZGV2ZWxvcG1lbnQiOiIke3NlcnZpY2V9LSR7ZW52aXJvbm1lbnR9LiR7cHJvamVjdH0uZGV2LmV4YW1wbGUuY29tIiwicHVsbHJlcXVlc3QiOiIke3NlcnZpY2V9LXByLSR7cHJOdW1iZXJ9LiR7cHJvamVjdH0uZGV2LmV4YW1wbGUuY29tIiwic2VydmljZVR5cGVzIjp7InNvbHIiOiIke3NlcnZpY2V9LSR7ZW52aXJvbm1lbnR9LiR7cHJvamVjdH0uc2VhcmNoLmV4YW1wbGUuY29tIn19",
+					Scope: "internal_system",
+				},
+			},
+			serviceType: "nginx",
+			buildValues: BuildValues{EnvironmentType: "development", BuildType: "branch"},
+			want:        "${service}-${environment}.${project}.dev.example.com",
+		},
+		{
+			name: "pullrequest pattern",
+			envVars: []lagoon.EnvironmentVariable{
+				{
+					Name:  "LAGOON_SYSTEM_ROUTER_PATTERNS",
+					Value: "eyJwcm9kdWN0aW9uIjoiJHtzZXJ2aWNlfS4ke3Byb2plY3R9LmV4YW1wbGUuY29tIiwiZGV2ZWxvcG1lbnQiOiIke3NlcnZpY2V9LSR7ZW52aXJvbm1lbnR9LiR7cHJvamVjdH0uZGV2LmV4YW1wbGUuY29tIiwicHVsbHJlcXVlc3QiOiIke3NlcnZpY2V9LXByLSR7cHJOdW1iZXJ9LiR7cHJvamVjdH0uZGV2LmV4YW1wbGUuY29tIiwic2VydmljZVR5cGVzIjp7InNvbHIiOiIke3NlcnZpY2V9LSR7ZW52aXJvbm1lbnR9LiR7cHJvamVjdH0uc2VhcmNoLmV4YW1wbGUuY29tIn19",
+					Scope: "internal_system",
+				},
+			},
+			serviceType: "nginx",
+			buildValues: BuildValues{EnvironmentType: "development", BuildType: "pullrequest"},
+			want:        "${service}-pr-${prNumber}.${project}.dev.example.com",
+		},
+		{
+			name: "service type pattern",
+			envVars: []lagoon.EnvironmentVariable{
+				{
+					Name:  "LAGOON_SYSTEM_ROUTER_PATTERNS",
+					Value: "eyJwcm9kdWN0aW9uIjoiJHtzZXJ2aWNlfS4ke3Byb2plY3R9LmV4YW1wbGUuY29tIiwiZGV2ZWxvcG1lbnQiOiIke3NlcnZpY2V9LSR7ZW52aXJvbm1lbnR9LiR7cHJvamVjdH0uZGV2LmV4YW1wbGUuY29tIiwicHVsbHJlcXVlc3QiOiIke3NlcnZpY2V9LXByLSR7cHJOdW1iZXJ9LiR7cHJvamVjdH0uZGV2LmV4YW1wbGUuY29tIiwic2VydmljZVR5cGVzIjp7InNvbHIiOiIke3NlcnZpY2V9LSR7ZW52aXJvbm1lbnR9LiR7cHJvamVjdH0uc2VhcmNoLmV4YW1wbGUuY29tIn19",
+					Scope: "internal_system",
+				},
+			},
+			serviceType: "solr",
+			buildValues: BuildValues{EnvironmentType: "development", BuildType: "pullrequest"},
+			want:        "${service}-${environment}.${project}.search.example.com",
+		},
+		{
+			name: "invalid router patterns",
+			envVars: []lagoon.EnvironmentVariable{
+				{
+					Name:  "LAGOON_SYSTEM_ROUTER_PATTERNS",
+					Value: "bm90IGpzb24=",
+					Scope: "internal_system",
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patterns, err := getRouterPatterns(tt.envVars)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getRouterPatterns() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			got := patterns.routerPattern("${service}-${project}-${environment}.example.com", tt.serviceType, tt.buildValues)
+			if got != tt.want {
+				t.Errorf("routerPattern() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/secretref"
+)
+
+// SecretReference is a lagoon-env variable that is synced from a secret store by an ExternalSecret
+type SecretReference struct {
+	Name        string              `json:"name"`
+	Reference   secretref.Reference `json:"reference"`
+	SecretStore string              `json:"secretStore"`
+}
+
+// ResolveSecretReferences resolves the secret references in the lagoon-env variables. This is only done when the lagoon-env secret
+// is templated, so that other commands that use the generator never read secrets
+func ResolveSecretReferences(buildValues *BuildValues, debug bool) error {
+	resolved, references, err := resolveSecretReferences(buildValues, buildValues.LagoonEnvVariables, debug)
+	if err != nil {
+		return err
+	}
+	buildValues.LagoonEnvVariables = resolved
+	buildValues.LagoonEnvSecretReferences = references
+	return nil
+}
+
+// resolveSecretReferences resolves the variables that use the secret reference syntax `ref+<scheme>://<path>#<key>` into
+// the value of the secret. If external secrets are enabled, references to a secret store are returned so that an ExternalSecret
+// can sync them into the secret instead of the value being stored in the secret by the build
+func resolveSecretReferences(buildValues *BuildValues, variables map[string]string, debug bool) (map[string]string, []SecretReference, error) {
+	client := buildValues.SecretRefClient
+	if client == nil {
+		client = secretref.NewClient(secretref.Client{})
+	}
+	// the resolvers are configured by the remote cluster the build runs in, files can only be read from the directory the cluster defines
+	client.RegisterResolver(secretref.SchemeFile, &secretref.FileResolver{
+		Root: helpers.GetEnv("LAGOON_SECRET_RESOLVER_FILE_ROOT", "", debug),
+	})
+	// the vault token and secret store are shared by every project, so a project can only read secrets under its own path
+	client.RegisterResolver(secretref.SchemeVault, &secretref.HTTPResolver{
+		HTTPClient: client.HTTPClient,
+		Endpoint:   helpers.GetEnv("LAGOON_SECRET_RESOLVER_VAULT_HTTP", "http://vault.vault.svc:8200", debug),
+		Token:      helpers.GetEnv("LAGOON_SECRET_RESOLVER_VAULT_TOKEN", "", debug),
+		Store:      helpers.GetEnv("LAGOON_SECRET_RESOLVER_VAULT_STORE", "vault", debug),
+		PathPrefix: fmt.Sprintf("%s/%s", strings.Trim(helpers.GetEnv("LAGOON_SECRET_RESOLVER_VAULT_PATH_PREFIX", "secret/data", debug), "/"), buildValues.Project),
+	})
+	externalSecrets := CheckFeatureFlag("EXTERNAL_SECRETS", buildValues.EnvironmentVariables, debug) == "enabled"
+
+	// sort the variables so that the references are always in the same order
+	names := []string{}
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	resolved := map[string]string{}
+	var references []SecretReference
+	for _, name := range names {
+		value := variables[name]
+		if !secretref.IsReference(value) {
+			resolved[name] = value
+			continue
+		}
+		ref, err := secretref.ParseReference(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("variable %s has an invalid secret reference: %v", name, err)
+		}
+		if externalSecrets {
+			if store, ok := client.SecretStore(ref.Scheme); ok {
+				// the ExternalSecret reads from the secret store directly, so the reference is checked here
+				if err := client.Allowed(*ref); err != nil {
+					return nil, nil, fmt.Errorf("couldn't use variable %s: %v", name, err)
+				}
+				references = append(references, SecretReference{
+					Name:        name,
+					Reference:   *ref,
+					SecretStore: store,
+				})
+				continue
+			}
+		}
+		if debug {
+			fmt.Printf("Resolving secret reference for variable %s\n", name)
+		}
+		secret, err := client.Resolve(*ref)
+		if err != nil {
+			return nil, nil, fmt.Errorf("couldn't resolve variable %s: %v", name, err)
+		}
+		resolved[name] = secret
+	}
+	return resolved, references, nil
+}
@@ -0,0 +1,144 @@
+package generator
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"github.com/uselagoon/build-deploy-tool/internal/secretref"
+	"github.com/uselagoon/build-deploy-tool/internal/secretref/secretreftest"
+)
+
+func Test_resolveSecretReferences(t *testing.T) {
+	ts := secretreftest.NewServer()
+	defer ts.Close()
+	tests := []struct {
+		name           string
+		variables      map[string]string
+		vars           []helpers.EnvironmentVariable
+		buildVariables []lagoon.EnvironmentVariable
+		want           map[string]string
+		wantReferences []SecretReference
+		wantErr        string
+	}{
+		{
+			name: "no references",
+			variables: map[string]string{
+				"MY_SPECIAL_VARIABLE": "myspecialvariable",
+			},
+			want: map[string]string{
+				"MY_SPECIAL_VARIABLE": "myspecialvariable",
+			},
+		},
+		{
+			name: "resolved references",
+			variables: map[string]string{
+				"MY_SPECIAL_VARIABLE": "myspecialvariable",
+				"API_KEY":             "ref+vault://secret/data/example-project#api-key",
+				"DB_PASSWORD":         "ref+file://secrets.yml#password",
+			},
+			vars: []helpers.EnvironmentVariable{
+				{Name: "LAGOON_SECRET_RESOLVER_VAULT_TOKEN", Value: "lagoon-token"},
+			},
+			want: map[string]string{
+				"MY_SPECIAL_VARIABLE": "myspecialvariable",
+				"API_KEY":             "super-secret-api-key",
+				"DB_PASSWORD":         "file-password",
+			},
+		},
+		{
+			name: "external secrets",
+			variables: map[string]string{
+				"MY_SPECIAL_VARIABLE": "myspecialvariable",
+				"API_KEY":             "ref+vault://secret/data/example-project#api-key",
+				"DB_PASSWORD":         "ref+file://secrets.yml#password",
+			},
+			vars: []helpers.EnvironmentVariable{
+				{Name: "LAGOON_SECRET_RESOLVER_VAULT_STORE", Value: "lagoon-vault"},
+			},
+			buildVariables: []lagoon.EnvironmentVariable{
+				{Name: "LAGOON_FEATURE_FLAG_EXTERNAL_SECRETS", Value: "enabled", Scope: "build"},
+			},
+			want: map[string]string{
+				"MY_SPECIAL_VARIABLE": "myspecialvariable",
+				"DB_PASSWORD":         "file-password",
+			},
+			wantReferences: []SecretReference{
+				{
+					Name:        "API_KEY",
+					Reference:   secretref.Reference{Scheme: "vault", Path: "secret/data/example-project", Key: "api-key"},
+					SecretStore: "lagoon-vault",
+				},
+			},
+		},
+		{
+			name: "reference that can't be resolved",
+			variables: map[string]string{
+				"API_KEY": "ref+vault://secret/data/example-project#api-key",
+			},
+			wantErr: "couldn't resolve variable API_KEY: couldn't resolve secret reference ref+vault://secret/data/example-project#api-key: secret store responded with status 403: permission denied",
+		},
+		{
+			name: "external secret of another project",
+			variables: map[string]string{
+				"API_KEY": "ref+vault://secret/data/other-project#api-key",
+			},
+			buildVariables: []lagoon.EnvironmentVariable{
+				{Name: "LAGOON_FEATURE_FLAG_EXTERNAL_SECRETS", Value: "enabled", Scope: "build"},
+			},
+			wantErr: "couldn't use variable API_KEY: secret reference ref+vault://secret/data/other-project#api-key is not allowed: path secret/data/other-project is not under secret/data/example-project",
+		},
+		{
+			name: "invalid reference",
+			variables: map[string]string{
+				"API_KEY": "ref+vault:/secret/data/example-project#api-key",
+			},
+			wantErr: "variable API_KEY has an invalid secret reference: secret reference must be in the format ref+<scheme>://<path>#<key>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.vars = append(tt.vars,
+				helpers.EnvironmentVariable{Name: "LAGOON_SECRET_RESOLVER_VAULT_HTTP", Value: ts.URL},
+				helpers.EnvironmentVariable{Name: "LAGOON_SECRET_RESOLVER_FILE_ROOT", Value: "../secretref/test-resources"},
+			)
+			for _, envVar := range tt.vars {
+				if err := os.Setenv(envVar.Name, envVar.Value); err != nil {
+					t.Errorf("%v", err)
+				}
+			}
+			t.Cleanup(func() {
+				helpers.UnsetEnvVars(tt.vars)
+			})
+			buildValues := &BuildValues{
+				Project:              "example-project",
+				EnvironmentVariables: tt.buildVariables,
+				SecretRefClient: secretref.NewClient(secretref.Client{
+					RetryMax:     5,
+					RetryWaitMin: time.Duration(10) * time.Millisecond,
+					RetryWaitMax: time.Duration(50) * time.Millisecond,
+				}),
+			}
+			got, references, err := resolveSecretReferences(buildValues, tt.variables, false)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("resolveSecretReferences() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("resolveSecretReferences() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveSecretReferences() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(references, tt.wantReferences) {
+				t.Errorf("resolveSecretReferences() references = %v, want %v", references, tt.wantReferences)
+			}
+		})
+	}
+}
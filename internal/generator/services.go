@@ -79,6 +79,44 @@ func generateServicesFromDockerCompose(
 	return nil
 }
 
+// serviceTypeOverride returns the type of a service after applying any override for this environment in the lagoon yaml,
+// and then any override defined in the lagoon API `LAGOON_SERVICE_TYPES` for the override name of the service
+func serviceTypeOverride(buildValues *BuildValues, composeService, lagoonOverrideName, lagoonType string) string {
+	// check lagoon yaml for an override for this service
+	if value, ok := buildValues.LagoonYAML.Environments[buildValues.Environment].Types[composeService]; ok {
+		lagoonType = value
+	}
+	// if there are overrides defined in the lagoon API `LAGOON_SERVICE_TYPES`
+	// handle those here
+	if buildValues.ServiceTypeOverrides != nil {
+		serviceTypesSplit := strings.Split(buildValues.ServiceTypeOverrides.Value, ",")
+		for _, sType := range serviceTypesSplit {
+			sTypeSplit := strings.Split(sType, ":")
+			if sTypeSplit[0] == lagoonOverrideName {
+				lagoonType = sTypeSplit[1]
+			}
+		}
+	}
+	return lagoonType
+}
+
+// ServiceTypeOverrides returns the types of the services in the docker-compose file that are overridden for the environment,
+// keyed by the docker-compose service name. The overrides are resolved the same way as they are when the services are generated
+func ServiceTypeOverrides(buildValues *BuildValues, project *composetypes.Project) map[string]string {
+	overrides := map[string]string{}
+	for _, service := range project.Services {
+		lagoonType := lagoon.CheckDockerComposeLagoonLabel(service.Labels, "lagoon.type")
+		lagoonOverrideName := lagoon.CheckDockerComposeLagoonLabel(service.Labels, "lagoon.name")
+		if lagoonOverrideName == "" {
+			lagoonOverrideName = service.Name
+		}
+		if override := serviceTypeOverride(buildValues, service.Name, lagoonOverrideName, lagoonType); override != lagoonType {
+			overrides[service.Name] = override
+		}
+	}
+	return overrides
+}
+
 // composeToServiceValues is the primary function used to pre-seed how templates are created
 // it reads the docker-compose file and converts each service into a ServiceValues struct
 // this is the "known state" of that service, and all subsequent steps to create templates will use this data unmodified
@@ -140,10 +178,6 @@ func composeToServiceValues(
 				autogeRequestVerification = true
 			}
 		}
-		// check lagoon yaml for an override for this service
-		if value, ok := buildValues.LagoonYAML.Environments[buildValues.Environment].Types[composeService]; ok {
-			lagoonType = value
-		}
 		// check if the service has a specific override
 		serviceAutogenerated := lagoon.CheckDockerComposeLagoonLabel(composeServiceValues.Labels, "lagoon.autogeneratedroute")
 		if serviceAutogenerated != "" {
@@ -189,17 +223,8 @@ func composeToServiceValues(
 			lagoonOverrideName = composeService
 		}
 
-		// if there are overrides defined in the lagoon API `LAGOON_SERVICE_TYPES`
-		// handle those here
-		if buildValues.ServiceTypeOverrides != nil {
-			serviceTypesSplit := strings.Split(buildValues.ServiceTypeOverrides.Value, ",")
-			for _, sType := range serviceTypesSplit {
-				sTypeSplit := strings.Split(sType, ":")
-				if sTypeSplit[0] == lagoonOverrideName {
-					lagoonType = sTypeSplit[1]
-				}
-			}
-		}
+		// check for any overrides of the service type in the lagoon yaml or lagoon API
+		lagoonType = serviceTypeOverride(buildValues, composeService, lagoonOverrideName, lagoonType)
 
 		// convert old service types to new service types from the old service map
 		// this allows for adding additional values to the oldServiceMap that we can force to be anything else
@@ -534,6 +559,27 @@ func composeToServiceValues(
 			ExternalServiceName:                    externalName,
 		}
 
+		// calculate any resource or probe overrides for the container of this service
+		cService.Resources, err = generateServiceResources(buildValues, composeService)
+		if err != nil {
+			return nil, err
+		}
+		cService.Probes, err = generateServiceProbes(buildValues, composeService)
+		if err != nil {
+			return nil, err
+		}
+		cService.DeploymentStrategy, err = generateDeploymentStrategy(buildValues, *cService)
+		if err != nil {
+			return nil, err
+		}
+		// calculate any horizontalpodautoscaler or poddisruptionbudget for this service
+		if lagoonType != "external" && !svcIsDBaaS {
+			cService.Autoscaling, err = generateAutoscaling(buildValues, *cService, composeServiceValues.Labels)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		// work out the images here and the associated dockerfile and contexts
 		// if the type is in the ignored image types, then there is no image to build or pull for this service (eg, its a dbaas service)
 		if !servicetypes.IsIgnoredImageType(lagoonType) {
@@ -0,0 +1,72 @@
+package generator
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// the defaults of the zero downtime strategy start a full set of new pods, and only remove the old pods once the new pods
+// have been ready for the minimum ready seconds
+var (
+	defaultStrategyMaxSurge        = intstr.FromString("100%")
+	defaultStrategyMaxUnavailable  = intstr.FromInt32(0)
+	defaultStrategyMinReadySeconds = int32(10)
+)
+
+// DeploymentStrategy is the calculated zero downtime rolling update strategy for a service
+type DeploymentStrategy struct {
+	MaxSurge        intstr.IntOrString `json:"maxSurge"`
+	MaxUnavailable  intstr.IntOrString `json:"maxUnavailable"`
+	MinReadySeconds int32              `json:"minReadySeconds"`
+}
+
+// generateDeploymentStrategy calculates the zero downtime strategy of a service from the `deployment-strategy` in the `overrides`
+// of the environment in the .lagoon.yml file, services that don't opt in use the strategy of their service type
+func generateDeploymentStrategy(buildValues *BuildValues, service ServiceValues) (*DeploymentStrategy, error) {
+	composeService := service.Name
+	override := buildValues.LagoonYAML.Environments[buildValues.Environment].Overrides[composeService].DeploymentStrategy
+	if override == nil {
+		return nil, nil
+	}
+
+	// services that use a ReadWriteOnce volume can't run the new pods alongside the old pods
+	if usesRWOVolume(buildValues, service) {
+		return nil, fmt.Errorf("deployment-strategy is not supported for service %s, it uses a ReadWriteOnce volume", composeService)
+	}
+
+	strategy := &DeploymentStrategy{
+		MaxSurge:        defaultStrategyMaxSurge,
+		MaxUnavailable:  defaultStrategyMaxUnavailable,
+		MinReadySeconds: defaultStrategyMinReadySeconds,
+	}
+	if override.MaxSurge != nil {
+		strategy.MaxSurge = *override.MaxSurge
+	}
+	if override.MaxUnavailable != nil {
+		strategy.MaxUnavailable = *override.MaxUnavailable
+	}
+	if override.MinReadySeconds != nil {
+		strategy.MinReadySeconds = *override.MinReadySeconds
+	}
+	if err := validateDisruptionValue(&strategy.MaxSurge); err != nil {
+		return nil, fmt.Errorf("deployment-strategy maxSurge for service %s is not valid: %v", composeService, err)
+	}
+	if err := validateDisruptionValue(&strategy.MaxUnavailable); err != nil {
+		return nil, fmt.Errorf("deployment-strategy maxUnavailable for service %s is not valid: %v", composeService, err)
+	}
+	if isZeroStrategyValue(strategy.MaxSurge) && isZeroStrategyValue(strategy.MaxUnavailable) {
+		return nil, fmt.Errorf("deployment-strategy maxSurge and maxUnavailable for service %s can't both be 0", composeService)
+	}
+	if strategy.MinReadySeconds < 0 {
+		return nil, fmt.Errorf("deployment-strategy minReadySeconds for service %s must not be negative", composeService)
+	}
+	return strategy, nil
+}
+
+func isZeroStrategyValue(value intstr.IntOrString) bool {
+	if value.Type == intstr.Int {
+		return value.IntVal == 0
+	}
+	return value.StrVal == "0%"
+}
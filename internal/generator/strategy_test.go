@@ -0,0 +1,181 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func Test_generateDeploymentStrategy(t *testing.T) {
+	surge := intstr.FromInt32(1)
+	unavailable := intstr.FromString("25%")
+	zero := intstr.FromString("0%")
+	negative := intstr.FromInt32(-1)
+	invalid := intstr.FromString("one")
+	tests := []struct {
+		name        string
+		buildValues *BuildValues
+		lagoonType  string
+		volumes     []ServiceVolume
+		want        *DeploymentStrategy
+		wantErr     string
+	}{
+		{
+			name:        "no override",
+			buildValues: resourceBuildValues(Resources{}, lagoon.Override{}),
+			lagoonType:  "nginx-php",
+		},
+		{
+			name: "defaults",
+			buildValues: resourceBuildValues(Resources{}, lagoon.Override{
+				DeploymentStrategy: &lagoon.DeploymentStrategy{},
+			}),
+			lagoonType: "nginx-php",
+			want: &DeploymentStrategy{
+				MaxSurge:        intstr.FromString("100%"),
+				MaxUnavailable:  intstr.FromInt32(0),
+				MinReadySeconds: 10,
+			},
+		},
+		{
+			name: "tuned",
+			buildValues: resourceBuildValues(Resources{}, lagoon.Override{
+				DeploymentStrategy: &lagoon.DeploymentStrategy{
+					MaxSurge:        &surge,
+					MaxUnavailable:  &unavailable,
+					MinReadySeconds: helpers.Int32Ptr(30),
+				},
+			}),
+			lagoonType: "node",
+			want: &DeploymentStrategy{
+				MaxSurge:        intstr.FromInt32(1),
+				MaxUnavailable:  intstr.FromString("25%"),
+				MinReadySeconds: 30,
+			},
+		},
+		{
+			name: "readwritemany persistent type",
+			buildValues: resourceBuildValues(Resources{}, lagoon.Override{
+				DeploymentStrategy: &lagoon.DeploymentStrategy{},
+			}),
+			lagoonType: "nginx-php-persistent",
+			want: &DeploymentStrategy{
+				MaxSurge:        intstr.FromString("100%"),
+				MaxUnavailable:  intstr.FromInt32(0),
+				MinReadySeconds: 10,
+			},
+		},
+		{
+			name: "readwriteonce persistent type",
+			buildValues: resourceBuildValues(Resources{}, lagoon.Override{
+				DeploymentStrategy: &lagoon.DeploymentStrategy{},
+			}),
+			lagoonType: "mariadb-single",
+			wantErr:    "deployment-strategy is not supported for service php, it uses a ReadWriteOnce volume",
+		},
+		{
+			name: "readwritemany to readwriteonce",
+			buildValues: func() *BuildValues {
+				buildValues := resourceBuildValues(Resources{}, lagoon.Override{
+					DeploymentStrategy: &lagoon.DeploymentStrategy{},
+				})
+				buildValues.RWX2RWO = true
+				return buildValues
+			}(),
+			lagoonType: "nginx-php-persistent",
+			wantErr:    "deployment-strategy is not supported for service php, it uses a ReadWriteOnce volume",
+		},
+		{
+			name: "consumed volume in ci",
+			buildValues: func() *BuildValues {
+				buildValues := resourceBuildValues(Resources{}, lagoon.Override{
+					DeploymentStrategy: &lagoon.DeploymentStrategy{},
+				})
+				buildValues.IsCI = true
+				return buildValues
+			}(),
+			lagoonType: "cli-persistent",
+			wantErr:    "deployment-strategy is not supported for service php, it uses a ReadWriteOnce volume",
+		},
+		{
+			name: "additional volume converted to readwriteonce",
+			buildValues: func() *BuildValues {
+				buildValues := resourceBuildValues(Resources{}, lagoon.Override{
+					DeploymentStrategy: &lagoon.DeploymentStrategy{},
+				})
+				buildValues.RWX2RWO = true
+				return buildValues
+			}(),
+			lagoonType: "node",
+			volumes: []ServiceVolume{
+				{ComposeVolume: ComposeVolume{Name: "custom-files", Size: "5Gi"}, Path: "/app/files"},
+			},
+			wantErr: "deployment-strategy is not supported for service php, it uses a ReadWriteOnce volume",
+		},
+		{
+			name: "no surge or unavailable",
+			buildValues: resourceBuildValues(Resources{}, lagoon.Override{
+				DeploymentStrategy: &lagoon.DeploymentStrategy{
+					MaxSurge: &zero,
+				},
+			}),
+			lagoonType: "node",
+			wantErr:    "deployment-strategy maxSurge and maxUnavailable for service php can't both be 0",
+		},
+		{
+			name: "negative surge",
+			buildValues: resourceBuildValues(Resources{}, lagoon.Override{
+				DeploymentStrategy: &lagoon.DeploymentStrategy{
+					MaxSurge: &negative,
+				},
+			}),
+			lagoonType: "node",
+			wantErr:    "deployment-strategy maxSurge for service php is not valid: -1 must not be negative",
+		},
+		{
+			name: "invalid unavailable",
+			buildValues: resourceBuildValues(Resources{}, lagoon.Override{
+				DeploymentStrategy: &lagoon.DeploymentStrategy{
+					MaxUnavailable: &invalid,
+				},
+			}),
+			lagoonType: "node",
+			wantErr:    "deployment-strategy maxUnavailable for service php is not valid: one must be an integer or a percentage",
+		},
+		{
+			name: "negative min ready seconds",
+			buildValues: resourceBuildValues(Resources{}, lagoon.Override{
+				DeploymentStrategy: &lagoon.DeploymentStrategy{
+					MinReadySeconds: helpers.Int32Ptr(-5),
+				},
+			}),
+			lagoonType: "node",
+			wantErr:    "deployment-strategy minReadySeconds for service php must not be negative",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generateDeploymentStrategy(tt.buildValues, ServiceValues{
+				Name:              "php",
+				Type:              tt.lagoonType,
+				AdditionalVolumes: tt.volumes,
+			})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("generateDeploymentStrategy() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("generateDeploymentStrategy() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("generateDeploymentStrategy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
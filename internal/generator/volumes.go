@@ -6,6 +6,7 @@ import (
 	composetypes "github.com/compose-spec/compose-go/types"
 	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
 	"github.com/uselagoon/build-deploy-tool/internal/servicetypes"
+	corev1 "k8s.io/api/core/v1"
 )
 
 var (
@@ -146,3 +147,20 @@ func flagDefaultVolumeCreation(
 	}
 	return nil
 }
+
+// usesRWOVolume checks if any volume that a service mounts is a ReadWriteOnce volume, the pods of these services can't run
+// alongside each other. This covers the default volume the service type provides or consumes, and any additional volumes
+func usesRWOVolume(buildValues *BuildValues, service ServiceValues) bool {
+	// volumes are created as ReadWriteOnce in CI and if the rwx2rwo flag is enabled, see updatePVC in the templating
+	rwx2rwo := buildValues.RWX2RWO || buildValues.IsCI
+	if val, ok := servicetypes.ServiceTypes[service.Type]; ok {
+		if val.ProvidesPersistentVolume && val.Volumes.PersistentVolumeType == corev1.ReadWriteOnce {
+			return true
+		}
+		if (val.ProvidesPersistentVolume || val.ConsumesPersistentVolume) && rwx2rwo {
+			return true
+		}
+	}
+	// additional volumes are ReadWriteMany volumes unless they are converted to ReadWriteOnce
+	return len(service.AdditionalVolumes) > 0 && rwx2rwo
+}
@@ -0,0 +1,364 @@
+package gitcheckout
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+// the name and email used for the merge commit of a pullrequest build
+const (
+	mergeAuthorName  = "lagoon"
+	mergeAuthorEmail = "lagoon@lagoon.io"
+)
+
+// Options are the options used to check out the source of a build
+type Options struct {
+	// Directory is where the repository is checked out
+	Directory string
+	// Repository is the url of the source repository
+	Repository string
+	// BuildType is one of branch, pullrequest or promote
+	BuildType string
+	// Ref is the branch (as origin/<branch>), tag or sha to check out for branch and promote builds
+	Ref string
+	// PRHeadSHA and PRBaseSHA are the commits that are merged for pullrequest builds
+	PRHeadSHA string
+	PRBaseSHA string
+	// Depth limits the history fetched for branch and promote builds, 0 fetches the full history
+	Depth int
+	// Submodules will initialise and update any submodules after the checkout
+	Submodules bool
+	// SSHPrivateKey is used for any ssh repositories
+	SSHPrivateKey string
+	// Credentials are used for any http or https repositories that match the host of the credential
+	Credentials []url.URL
+	// Out receives the progress of the checkout
+	Out io.Writer
+}
+
+// Checkout fetches the source repository into the directory and checks out the requested ref.
+// For pullrequest builds the head is merged into the base, if this can't be done cleanly a MergeConflictError is returned.
+// The sha of the resulting commit is returned.
+func Checkout(ctx context.Context, opts Options) (string, error) {
+	if opts.Repository == "" {
+		return "", fmt.Errorf("no source repository provided")
+	}
+	if opts.Out == nil {
+		opts.Out = io.Discard
+	}
+	r, err := git.PlainInit(opts.Directory, false)
+	if err == git.ErrRepositoryAlreadyExists {
+		r, err = git.PlainOpen(opts.Directory)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to open repository in %s: %v", opts.Directory, err)
+	}
+	if _, err := r.Remote(git.DefaultRemoteName); err == git.ErrRemoteNotFound {
+		_, err = r.CreateRemote(&config.RemoteConfig{
+			Name: git.DefaultRemoteName,
+			URLs: []string{opts.Repository},
+		})
+		if err != nil {
+			return "", fmt.Errorf("unable to add remote %s: %v", opts.Repository, err)
+		}
+	} else if err != nil {
+		return "", fmt.Errorf("unable to read remote: %v", err)
+	}
+	auth, err := authMethod(opts.Repository, opts)
+	if err != nil {
+		return "", err
+	}
+
+	fetch := &git.FetchOptions{
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+		Tags:       git.AllTags,
+		Auth:       auth,
+		Progress:   opts.Out,
+		Force:      true,
+	}
+	var sha plumbing.Hash
+	switch opts.BuildType {
+	case "branch", "promote":
+		// pullrequests need the full history to find the merge base, but branches and promotions can be shallow
+		fetch.Depth = opts.Depth
+		if err := fetchRepository(ctx, r, fetch); err != nil {
+			return "", err
+		}
+		hash, err := r.ResolveRevision(plumbing.Revision(opts.Ref))
+		if err != nil {
+			return "", fmt.Errorf("unable to find %s in %s: %v", opts.Ref, opts.Repository, err)
+		}
+		if err := checkoutHash(r, *hash); err != nil {
+			return "", err
+		}
+		sha = *hash
+	case "pullrequest":
+		if err := fetchRepository(ctx, r, fetch); err != nil {
+			return "", err
+		}
+		sha, err = mergePullRequest(r, opts)
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unsupported build type %s", opts.BuildType)
+	}
+
+	if opts.Submodules {
+		if err := updateSubmodules(ctx, r, opts); err != nil {
+			return "", err
+		}
+	}
+	return sha.String(), nil
+}
+
+func fetchRepository(ctx context.Context, r *git.Repository, fetch *git.FetchOptions) error {
+	err := r.FetchContext(ctx, fetch)
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return fmt.Errorf("unable to fetch repository: %v", err)
+	}
+	return nil
+}
+
+func checkoutHash(r *git.Repository, hash plumbing.Hash) error {
+	w, err := r.Worktree()
+	if err != nil {
+		return fmt.Errorf("unable to open worktree: %v", err)
+	}
+	if err := w.Checkout(&git.CheckoutOptions{Hash: hash, Force: true}); err != nil {
+		return fmt.Errorf("unable to checkout %s: %v", hash, err)
+	}
+	return nil
+}
+
+// mergePullRequest checks out the base of the pullrequest and merges the head into it
+func mergePullRequest(r *git.Repository, opts Options) (plumbing.Hash, error) {
+	base, err := resolveCommit(r, opts.PRBaseSHA)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	head, err := resolveCommit(r, opts.PRHeadSHA)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := checkoutHash(r, base.Hash); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	// the head is already part of the base, there is nothing to merge
+	if headInBase, err := head.IsAncestor(base); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("unable to compare %s and %s: %v", head.Hash, base.Hash, err)
+	} else if headInBase || head.Hash == base.Hash {
+		return base.Hash, nil
+	}
+	// the base has not changed since the head branched from it, so the head can be fast-forwarded
+	if baseInHead, err := base.IsAncestor(head); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("unable to compare %s and %s: %v", base.Hash, head.Hash, err)
+	} else if baseInHead {
+		if err := checkoutHash(r, head.Hash); err != nil {
+			return plumbing.ZeroHash, err
+		}
+		return head.Hash, nil
+	}
+
+	mergeBases, err := base.MergeBase(head)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("unable to find the merge base of %s and %s: %v", base.Hash, head.Hash, err)
+	}
+	if len(mergeBases) == 0 {
+		return plumbing.ZeroHash, fmt.Errorf("%s and %s have no common history", base.Hash, head.Hash)
+	}
+	merged, err := mergeTrees(r, mergeBases, base, head)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := applyMerge(r, merged); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("unable to open worktree: %v", err)
+	}
+	sig := &object.Signature{Name: mergeAuthorName, Email: mergeAuthorEmail, When: time.Now()}
+	sha, err := w.Commit(fmt.Sprintf("Merge commit '%s'", head.Hash), &git.CommitOptions{
+		Author:            sig,
+		Committer:         sig,
+		Parents:           []plumbing.Hash{base.Hash, head.Hash},
+		AllowEmptyCommits: true,
+	})
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("unable to commit the merge of %s into %s: %v", head.Hash, base.Hash, err)
+	}
+	return sha, nil
+}
+
+func resolveCommit(r *git.Repository, revision string) (*object.Commit, error) {
+	if revision == "" {
+		return nil, fmt.Errorf("the pullrequest head and base sha are required for pullrequest builds")
+	}
+	hash, err := r.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, fmt.Errorf("unable to find %s: %v", revision, err)
+	}
+	commit, err := r.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find commit %s: %v", revision, err)
+	}
+	return commit, nil
+}
+
+// applyMerge writes the merged paths to the worktree and index, submodules are only updated in the index
+func applyMerge(r *git.Repository, merged []mergedEntry) error {
+	w, err := r.Worktree()
+	if err != nil {
+		return fmt.Errorf("unable to open worktree: %v", err)
+	}
+	var gitlinks []mergedEntry
+	for _, m := range merged {
+		switch {
+		case m.entry == nil:
+			if _, err := w.Remove(m.path); err != nil {
+				return fmt.Errorf("unable to remove %s: %v", m.path, err)
+			}
+			continue
+		case m.entry.Mode == filemode.Submodule:
+			gitlinks = append(gitlinks, m)
+			continue
+		}
+		content, err := blobContent(r, m.entry.Hash)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(w.Filesystem, m.path, m.entry.Mode, content); err != nil {
+			return fmt.Errorf("unable to write %s: %v", m.path, err)
+		}
+		if _, err := w.Add(m.path); err != nil {
+			return fmt.Errorf("unable to add %s: %v", m.path, err)
+		}
+	}
+	if len(gitlinks) == 0 {
+		return nil
+	}
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return fmt.Errorf("unable to read index: %v", err)
+	}
+	for _, m := range gitlinks {
+		entry, err := idx.Entry(m.path)
+		if err != nil {
+			entry = idx.Add(m.path)
+			if err := w.Filesystem.MkdirAll(m.path, 0755); err != nil {
+				return fmt.Errorf("unable to create submodule directory %s: %v", m.path, err)
+			}
+		}
+		entry.Hash = m.entry.Hash
+		entry.Mode = filemode.Submodule
+	}
+	if err := r.Storer.SetIndex(idx); err != nil {
+		return fmt.Errorf("unable to write index: %v", err)
+	}
+	return nil
+}
+
+func writeFile(fs billy.Filesystem, path string, mode filemode.FileMode, content []byte) error {
+	// remove anything that is already there, as the type of the file may have changed
+	if err := util.RemoveAll(fs, path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if mode == filemode.Symlink {
+		return fs.Symlink(string(content), path)
+	}
+	perm := os.FileMode(0644)
+	if mode == filemode.Executable {
+		perm = 0755
+	}
+	return util.WriteFile(fs, path, content, perm)
+}
+
+// updateSubmodules initialises and updates all the submodules of the repository, nested submodules use the auth of their parent
+func updateSubmodules(ctx context.Context, r *git.Repository, opts Options) error {
+	w, err := r.Worktree()
+	if err != nil {
+		return fmt.Errorf("unable to open worktree: %v", err)
+	}
+	submodules, err := w.Submodules()
+	if err != nil {
+		return fmt.Errorf("unable to read submodules: %v", err)
+	}
+	for _, s := range submodules {
+		name := s.Config().Name
+		fmt.Fprintf(opts.Out, "Updating submodule %s\n", name)
+		subURL := s.Config().URL
+		if strings.HasPrefix(subURL, "./") || strings.HasPrefix(subURL, "../") {
+			// relative submodules are resolved against the source repository
+			subURL = opts.Repository
+		}
+		auth, err := authMethod(subURL, opts)
+		if err != nil {
+			return err
+		}
+		err = s.UpdateContext(ctx, &git.SubmoduleUpdateOptions{
+			Init:              true,
+			Auth:              auth,
+			RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to update submodule %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// authMethod returns the auth to use for a repository url, ssh repositories use the private key
+// and http repositories use the credentials that match the host of the repository
+func authMethod(repository string, opts Options) (transport.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(repository)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse repository url %s: %v", repository, err)
+	}
+	switch endpoint.Protocol {
+	case "ssh":
+		if opts.SSHPrivateKey == "" {
+			return nil, nil
+		}
+		user := endpoint.User
+		if user == "" {
+			user = "git"
+		}
+		// the key may be provided with escaped newlines
+		key := strings.ReplaceAll(opts.SSHPrivateKey, "\\n", "\n")
+		auth, err := gitssh.NewPublicKeys(user, []byte(key), "")
+		if err != nil {
+			return nil, fmt.Errorf("unable to read ssh private key: %v", err)
+		}
+		// lagoon builds don't verify the host keys of source repositories
+		auth.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+		return auth, nil
+	case "http", "https":
+		for _, cred := range opts.Credentials {
+			if cred.Host != endpoint.Host || cred.User == nil {
+				continue
+			}
+			password, _ := cred.User.Password()
+			return &githttp.BasicAuth{Username: cred.User.Username(), Password: password}, nil
+		}
+	}
+	return nil, nil
+}
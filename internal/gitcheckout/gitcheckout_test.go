@@ -0,0 +1,431 @@
+package gitcheckout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// testRepo is a repository that is used as the source repository in the tests
+type testRepo struct {
+	t    *testing.T
+	path string
+	repo *git.Repository
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	path := filepath.Join(t.TempDir(), "source")
+	r, err := git.PlainInit(path, false)
+	if err != nil {
+		t.Fatalf("unable to create source repository: %v", err)
+	}
+	return &testRepo{t: t, path: path, repo: r}
+}
+
+// commit writes the files to the worktree and commits them, a file with empty content is removed
+func (tr *testRepo) commit(message string, files map[string]string) plumbing.Hash {
+	return tr.merge(message, files)
+}
+
+// merge writes the files to the worktree and commits them with the given parents, no parents uses HEAD as the parent
+func (tr *testRepo) merge(message string, files map[string]string, parents ...plumbing.Hash) plumbing.Hash {
+	w, err := tr.repo.Worktree()
+	if err != nil {
+		tr.t.Fatalf("unable to open worktree: %v", err)
+	}
+	for name, content := range files {
+		if content == "" {
+			if _, err := w.Remove(name); err != nil {
+				tr.t.Fatalf("unable to remove %s: %v", name, err)
+			}
+			continue
+		}
+		if err := util.WriteFile(w.Filesystem, name, []byte(content), 0644); err != nil {
+			tr.t.Fatalf("unable to write %s: %v", name, err)
+		}
+		if _, err := w.Add(name); err != nil {
+			tr.t.Fatalf("unable to add %s: %v", name, err)
+		}
+	}
+	sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	hash, err := w.Commit(message, &git.CommitOptions{Author: sig, Parents: parents, AllowEmptyCommits: true})
+	if err != nil {
+		tr.t.Fatalf("unable to commit: %v", err)
+	}
+	return hash
+}
+
+// branch creates and checks out a branch from the given commit
+func (tr *testRepo) branch(name string, from plumbing.Hash) {
+	w, err := tr.repo.Worktree()
+	if err != nil {
+		tr.t.Fatalf("unable to open worktree: %v", err)
+	}
+	err = w.Checkout(&git.CheckoutOptions{Hash: from, Branch: plumbing.NewBranchReferenceName(name), Create: true, Force: true})
+	if err != nil {
+		tr.t.Fatalf("unable to create branch %s: %v", name, err)
+	}
+}
+
+func (tr *testRepo) tag(name string, hash plumbing.Hash) {
+	if _, err := tr.repo.CreateTag(name, hash, nil); err != nil {
+		tr.t.Fatalf("unable to create tag %s: %v", name, err)
+	}
+}
+
+// submodule adds a gitlink to the index and a .gitmodules entry for it, then commits
+func (tr *testRepo) submodule(path, subURL string, hash plumbing.Hash) plumbing.Hash {
+	w, err := tr.repo.Worktree()
+	if err != nil {
+		tr.t.Fatalf("unable to open worktree: %v", err)
+	}
+	gitmodules := "[submodule \"" + path + "\"]\n\tpath = " + path + "\n\turl = " + subURL + "\n"
+	if err := util.WriteFile(w.Filesystem, ".gitmodules", []byte(gitmodules), 0644); err != nil {
+		tr.t.Fatalf("unable to write .gitmodules: %v", err)
+	}
+	if _, err := w.Add(".gitmodules"); err != nil {
+		tr.t.Fatalf("unable to add .gitmodules: %v", err)
+	}
+	idx, err := tr.repo.Storer.Index()
+	if err != nil {
+		tr.t.Fatalf("unable to read index: %v", err)
+	}
+	entry := idx.Add(path)
+	entry.Hash = hash
+	entry.Mode = filemode.Submodule
+	if err := tr.repo.Storer.SetIndex(idx); err != nil {
+		tr.t.Fatalf("unable to write index: %v", err)
+	}
+	return tr.commit("add submodule "+path, nil)
+}
+
+func readFile(t *testing.T, dir, name string) string {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("unable to read %s: %v", name, err)
+	}
+	return string(b)
+}
+
+func TestCheckout(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup creates the source repository and returns the checkout options and the expected sha, a zero sha is not checked
+		setup     func(t *testing.T, tr *testRepo) (Options, plumbing.Hash)
+		wantFiles map[string]string
+		// wantMissing are the files that must not be in the checkout
+		wantMissing []string
+		// wantParents is the number of parents of the checked out commit
+		wantParents int
+		wantErr     error
+	}{
+		{
+			name: "shallow branch",
+			setup: func(t *testing.T, tr *testRepo) (Options, plumbing.Hash) {
+				tr.commit("first", map[string]string{"a.txt": "one\n"})
+				tr.commit("second", map[string]string{"a.txt": "two\n"})
+				head := tr.commit("third", map[string]string{"a.txt": "three\n"})
+				return Options{BuildType: "branch", Ref: "origin/master", Depth: 1}, head
+			},
+			wantFiles:   map[string]string{"a.txt": "three\n"},
+			wantParents: 1,
+		},
+		{
+			name: "tag promote",
+			setup: func(t *testing.T, tr *testRepo) (Options, plumbing.Hash) {
+				first := tr.commit("first", map[string]string{"a.txt": "one\n"})
+				tr.tag("v1.0.0", first)
+				tr.commit("second", map[string]string{"a.txt": "two\n"})
+				return Options{BuildType: "promote", Ref: "v1.0.0"}, first
+			},
+			wantFiles: map[string]string{"a.txt": "one\n"},
+		},
+		{
+			name: "pullrequest merge",
+			setup: func(t *testing.T, tr *testRepo) (Options, plumbing.Hash) {
+				root := tr.commit("first", map[string]string{"a.txt": "1\n2\n3\n4\n5\n", "b.txt": "b\n", "c.txt": "c\n"})
+				base := tr.commit("base", map[string]string{"a.txt": "one\n2\n3\n4\n5\n", "c.txt": ""})
+				tr.branch("feature", root)
+				head := tr.commit("head", map[string]string{"a.txt": "1\n2\n3\n4\nfive\n", "b.txt": "bee\n", "d.txt": "d\n"})
+				return Options{BuildType: "pullrequest", PRBaseSHA: base.String(), PRHeadSHA: head.String()}, plumbing.ZeroHash
+			},
+			wantFiles: map[string]string{
+				"a.txt": "one\n2\n3\n4\nfive\n",
+				"b.txt": "bee\n",
+				"d.txt": "d\n",
+			},
+			wantParents: 2,
+		},
+		{
+			name: "pullrequest fast-forward",
+			setup: func(t *testing.T, tr *testRepo) (Options, plumbing.Hash) {
+				base := tr.commit("first", map[string]string{"a.txt": "one\n"})
+				tr.branch("feature", base)
+				head := tr.commit("head", map[string]string{"a.txt": "two\n"})
+				return Options{BuildType: "pullrequest", PRBaseSHA: base.String(), PRHeadSHA: head.String()}, head
+			},
+			wantFiles:   map[string]string{"a.txt": "two\n"},
+			wantParents: 1,
+		},
+		{
+			name: "pullrequest conflict",
+			setup: func(t *testing.T, tr *testRepo) (Options, plumbing.Hash) {
+				root := tr.commit("first", map[string]string{"a.txt": "1\n2\n3\n", "b.txt": "b\n"})
+				base := tr.commit("base", map[string]string{"a.txt": "1\ntwo\n3\n", "b.txt": ""})
+				tr.branch("feature", root)
+				head := tr.commit("head", map[string]string{"a.txt": "1\nTWO\n3\n", "b.txt": "bee\n"})
+				return Options{BuildType: "pullrequest", PRBaseSHA: base.String(), PRHeadSHA: head.String()}, plumbing.ZeroHash
+			},
+			wantErr: &MergeConflictError{
+				Conflicts: []MergeConflict{
+					{Path: "a.txt", Reason: "content"},
+					{Path: "b.txt", Reason: "modify/delete"},
+				},
+			},
+		},
+		{
+			name: "pullrequest rename",
+			setup: func(t *testing.T, tr *testRepo) (Options, plumbing.Hash) {
+				root := tr.commit("first", map[string]string{"a.txt": "1\n2\n3\n4\n5\n", "b.txt": "b\n"})
+				base := tr.commit("base", map[string]string{"a.txt": "", "moved/a.txt": "one\n2\n3\n4\n5\n"})
+				tr.branch("feature", root)
+				head := tr.commit("head", map[string]string{"a.txt": "1\n2\n3\n4\nfive\n", "b.txt": "", "c.txt": "b\n"})
+				return Options{BuildType: "pullrequest", PRBaseSHA: base.String(), PRHeadSHA: head.String()}, plumbing.ZeroHash
+			},
+			wantFiles: map[string]string{
+				"moved/a.txt": "one\n2\n3\n4\nfive\n",
+				"c.txt":       "b\n",
+			},
+			wantMissing: []string{"a.txt", "b.txt"},
+			wantParents: 2,
+		},
+		{
+			name: "pullrequest rename conflict",
+			setup: func(t *testing.T, tr *testRepo) (Options, plumbing.Hash) {
+				root := tr.commit("first", map[string]string{"a.txt": "1\n2\n3\n"})
+				base := tr.commit("base", map[string]string{"a.txt": "", "b.txt": "1\n2\n3\n"})
+				tr.branch("feature", root)
+				head := tr.commit("head", map[string]string{"a.txt": "", "c.txt": "1\n2\n3\n"})
+				return Options{BuildType: "pullrequest", PRBaseSHA: base.String(), PRHeadSHA: head.String()}, plumbing.ZeroHash
+			},
+			wantErr: &MergeConflictError{
+				Conflicts: []MergeConflict{
+					{Path: "a.txt", Reason: "rename"},
+				},
+			},
+		},
+		{
+			// the base and head both merged the other side, so they have two merge bases
+			name: "pullrequest criss-cross merge",
+			setup: func(t *testing.T, tr *testRepo) (Options, plumbing.Hash) {
+				root := tr.commit("first", map[string]string{"a.txt": "1\n2\n3\n"})
+				left := tr.commit("left", map[string]string{"a.txt": "one\n2\n3\n"})
+				tr.branch("right", root)
+				right := tr.commit("right", map[string]string{"a.txt": "1\n2\nthree\n"})
+				tr.branch("base", left)
+				base := tr.merge("merge right", map[string]string{"a.txt": "one\n2\nthree\n"}, left, right)
+				tr.branch("head", right)
+				tr.merge("merge left", map[string]string{"a.txt": "one\n2\nthree\n"}, right, left)
+				head := tr.commit("head", map[string]string{"a.txt": "one\ntwo\nthree\n"})
+				return Options{BuildType: "pullrequest", PRBaseSHA: base.String(), PRHeadSHA: head.String()}, plumbing.ZeroHash
+			},
+			wantFiles:   map[string]string{"a.txt": "one\ntwo\nthree\n"},
+			wantParents: 2,
+		},
+		{
+			name: "submodules",
+			setup: func(t *testing.T, tr *testRepo) (Options, plumbing.Hash) {
+				sub := newTestRepo(t)
+				subHead := sub.commit("sub", map[string]string{"sub.txt": "submodule\n"})
+				tr.commit("first", map[string]string{"a.txt": "one\n"})
+				head := tr.submodule("modules/sub", sub.path, subHead)
+				return Options{BuildType: "branch", Ref: "origin/master", Submodules: true}, head
+			},
+			wantFiles: map[string]string{
+				"a.txt":               "one\n",
+				"modules/sub/sub.txt": "submodule\n",
+			},
+			wantParents: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTestRepo(t)
+			opts, wantSHA := tt.setup(t, tr)
+			opts.Repository = tr.path
+			opts.Directory = filepath.Join(t.TempDir(), "checkout")
+			sha, err := Checkout(context.Background(), opts)
+			if tt.wantErr != nil {
+				var mergeErr *MergeConflictError
+				if !errors.As(err, &mergeErr) {
+					t.Fatalf("Checkout() error = %v, want a merge conflict", err)
+				}
+				want := tt.wantErr.(*MergeConflictError)
+				if !reflect.DeepEqual(mergeErr.Conflicts, want.Conflicts) {
+					t.Errorf("Checkout() conflicts = %v, want %v", mergeErr.Conflicts, want.Conflicts)
+				}
+				if mergeErr.BaseSHA != opts.PRBaseSHA || mergeErr.HeadSHA != opts.PRHeadSHA {
+					t.Errorf("Checkout() conflict base %s head %s, want base %s head %s", mergeErr.BaseSHA, mergeErr.HeadSHA, opts.PRBaseSHA, opts.PRHeadSHA)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Checkout() error = %v", err)
+			}
+			if !wantSHA.IsZero() && sha != wantSHA.String() {
+				t.Errorf("Checkout() sha = %v, want %v", sha, wantSHA)
+			}
+			for name, content := range tt.wantFiles {
+				if got := readFile(t, opts.Directory, name); got != content {
+					t.Errorf("%s = %q, want %q", name, got, content)
+				}
+			}
+			for _, name := range tt.wantMissing {
+				if _, err := os.Stat(filepath.Join(opts.Directory, name)); !os.IsNotExist(err) {
+					t.Errorf("%s exists, want it removed", name)
+				}
+			}
+			r, err := git.PlainOpen(opts.Directory)
+			if err != nil {
+				t.Fatalf("unable to open checkout: %v", err)
+			}
+			head, err := r.Head()
+			if err != nil {
+				t.Fatalf("unable to read HEAD: %v", err)
+			}
+			if head.Hash().String() != sha {
+				t.Errorf("HEAD = %v, want %v", head.Hash(), sha)
+			}
+			if tt.wantParents > 0 {
+				commit, err := r.CommitObject(head.Hash())
+				if err != nil {
+					t.Fatalf("unable to read HEAD commit: %v", err)
+				}
+				if commit.NumParents() != tt.wantParents {
+					t.Errorf("HEAD has %d parents, want %d", commit.NumParents(), tt.wantParents)
+				}
+			}
+			if opts.Depth > 0 {
+				shallow, err := r.Storer.Shallow()
+				if err != nil {
+					t.Fatalf("unable to read shallow commits: %v", err)
+				}
+				if len(shallow) == 0 {
+					t.Errorf("checkout is not shallow")
+				}
+			}
+			w, err := r.Worktree()
+			if err != nil {
+				t.Fatalf("unable to open worktree: %v", err)
+			}
+			status, err := w.Status()
+			if err != nil {
+				t.Fatalf("unable to read status: %v", err)
+			}
+			if !status.IsClean() {
+				t.Errorf("worktree is not clean:\n%v", status)
+			}
+		})
+	}
+}
+
+func Test_merge3(t *testing.T) {
+	tests := []struct {
+		name   string
+		base   string
+		ours   string
+		theirs string
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "separate changes",
+			base:   "1\n2\n3\n4\n5\n",
+			ours:   "one\n2\n3\n4\n5\n",
+			theirs: "1\n2\n3\n4\nfive\n",
+			want:   "one\n2\n3\n4\nfive\n",
+			wantOK: true,
+		},
+		{
+			name:   "insertions and deletions",
+			base:   "1\n2\n3\n4\n5\n6\n",
+			ours:   "1\n3\n4\n5\n6\n",
+			theirs: "1\n2\n3\n4\n5\n6\n7\n",
+			want:   "1\n3\n4\n5\n6\n7\n",
+			wantOK: true,
+		},
+		{
+			name:   "same change on both sides",
+			base:   "1\n2\n3\n",
+			ours:   "1\ntwo\n3\n",
+			theirs: "1\ntwo\n3\n",
+			want:   "1\ntwo\n3\n",
+			wantOK: true,
+		},
+		{
+			name:   "conflicting change",
+			base:   "1\n2\n3\n",
+			ours:   "1\ntwo\n3\n",
+			theirs: "1\nTWO\n3\n",
+			wantOK: false,
+		},
+		{
+			name:   "adjacent changes conflict",
+			base:   "1\n2\n3\n",
+			ours:   "1\ntwo\n3\n",
+			theirs: "1\n2\nthree\n",
+			wantOK: false,
+		},
+		{
+			// more unique lines than there are runes before the surrogate range
+			name:   "many unique lines",
+			base:   numberedLines("line", 70000),
+			ours:   "first\n" + strings.TrimPrefix(numberedLines("line", 70000), "line0\n"),
+			theirs: numberedLines("line", 60000) + "changed\n" + strings.TrimPrefix(numberedLines("line", 70000), numberedLines("line", 60001)),
+			want:   "first\n" + strings.TrimPrefix(numberedLines("line", 60000), "line0\n") + "changed\n" + strings.TrimPrefix(numberedLines("line", 70000), numberedLines("line", 60001)),
+			wantOK: true,
+		},
+		{
+			name:   "add/add with different content",
+			base:   "",
+			ours:   "a\n",
+			theirs: "b\n",
+			wantOK: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := merge3(tt.base, tt.ours, tt.theirs)
+			if err != nil {
+				t.Fatalf("merge3() error = %v", err)
+			}
+			if ok != tt.wantOK {
+				t.Fatalf("merge3() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("merge3() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// numberedLines returns count lines of the prefix followed by the line number
+func numberedLines(prefix string, count int) string {
+	var sb strings.Builder
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(&sb, "%s%d\n", prefix, i)
+	}
+	return sb.String()
+}
@@ -0,0 +1,664 @@
+package gitcheckout
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// MergeConflict is a path that couldn't be merged
+type MergeConflict struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// MergeConflictError is returned when the head of a pullrequest can't be merged into the base without conflicts
+type MergeConflictError struct {
+	HeadSHA   string          `json:"headSHA"`
+	BaseSHA   string          `json:"baseSHA"`
+	Conflicts []MergeConflict `json:"conflicts"`
+}
+
+func (e *MergeConflictError) Error() string {
+	var paths []string
+	for _, c := range e.Conflicts {
+		paths = append(paths, fmt.Sprintf("%s (%s)", c.Path, c.Reason))
+	}
+	return fmt.Sprintf("unable to merge %s into %s, there are conflicts in: %s", e.HeadSHA, e.BaseSHA, strings.Join(paths, ", "))
+}
+
+// the reasons a path can conflict
+const (
+	conflictContent      = "content"
+	conflictModifyDelete = "modify/delete"
+	conflictAddAdd       = "add/add"
+	conflictMode         = "mode"
+	conflictBinary       = "binary"
+	conflictRename       = "rename"
+)
+
+// files are considered renamed if at least half of their lines are the same, the same threshold git uses.
+// to keep the rename detection quick, similar files are only compared when there are not too many added and removed files
+const (
+	renameSimilarity = 0.5
+	renameLimit      = 100
+)
+
+// treeEntry is a file, symlink or submodule in a tree
+type treeEntry struct {
+	Hash plumbing.Hash
+	Mode filemode.FileMode
+}
+
+// mergedEntry is a path that differs from ours after the merge, a nil entry is a removed path
+type mergedEntry struct {
+	path  string
+	entry *treeEntry
+}
+
+// treeEntries returns all the non directory entries of a tree, keyed by their path
+func treeEntries(tree *object.Tree) (map[string]treeEntry, error) {
+	entries := map[string]treeEntry{}
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entry.Mode == filemode.Dir {
+			continue
+		}
+		entries[name] = treeEntry{Hash: entry.Hash, Mode: entry.Mode}
+	}
+	return entries, nil
+}
+
+func commitEntries(c *object.Commit) (map[string]treeEntry, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read tree of commit %s: %v", c.Hash, err)
+	}
+	entries, err := treeEntries(tree)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read tree of commit %s: %v", c.Hash, err)
+	}
+	return entries, nil
+}
+
+// mergeTrees performs a three way merge of the ours and theirs commits using their merge bases, returning the paths that differ from ours.
+// A path that was changed on both sides is merged line by line, any conflicts are returned as a MergeConflictError
+func mergeTrees(r *git.Repository, mergeBases []*object.Commit, ours, theirs *object.Commit) ([]mergedEntry, error) {
+	base, err := mergeBaseEntries(r, mergeBases)
+	if err != nil {
+		return nil, err
+	}
+	o, err := commitEntries(ours)
+	if err != nil {
+		return nil, err
+	}
+	t, err := commitEntries(theirs)
+	if err != nil {
+		return nil, err
+	}
+	result, conflicts, err := mergeEntries(r, base, o, t)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		return nil, &MergeConflictError{
+			HeadSHA:   theirs.Hash.String(),
+			BaseSHA:   ours.Hash.String(),
+			Conflicts: conflicts,
+		}
+	}
+	var merged []mergedEntry
+	for _, path := range sortedPaths(o, result) {
+		oe, oOK := o[path]
+		re, rOK := result[path]
+		switch {
+		case !rOK:
+			merged = append(merged, mergedEntry{path: path})
+		case !oOK || oe != re:
+			merged = append(merged, mergedEntry{path: path, entry: &re})
+		}
+	}
+	return merged, nil
+}
+
+// mergeBaseEntries returns the entries to use as the base of the merge. Like the recursive strategy of git,
+// when there is more than one merge base (a criss-cross merge) the merge bases are merged together into a virtual merge base
+func mergeBaseEntries(r *git.Repository, mergeBases []*object.Commit) (map[string]treeEntry, error) {
+	entries, err := commitEntries(mergeBases[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, next := range mergeBases[1:] {
+		ancestors, err := mergeBases[0].MergeBase(next)
+		if err != nil {
+			return nil, fmt.Errorf("unable to find the merge base of %s and %s: %v", mergeBases[0].Hash, next.Hash, err)
+		}
+		ancestor := map[string]treeEntry{}
+		if len(ancestors) > 0 {
+			if ancestor, err = mergeBaseEntries(r, ancestors); err != nil {
+				return nil, err
+			}
+		}
+		nextEntries, err := commitEntries(next)
+		if err != nil {
+			return nil, err
+		}
+		merged, conflicts, err := mergeEntries(r, ancestor, entries, nextEntries)
+		if err != nil {
+			return nil, err
+		}
+		if len(conflicts) > 0 {
+			var paths []string
+			for _, c := range conflicts {
+				paths = append(paths, fmt.Sprintf("%s (%s)", c.Path, c.Reason))
+			}
+			return nil, fmt.Errorf("unable to merge the merge bases %s and %s, there are conflicts in: %s", mergeBases[0].Hash, next.Hash, strings.Join(paths, ", "))
+		}
+		entries = merged
+	}
+	return entries, nil
+}
+
+// sortedPaths returns the paths of all the entries in sorted order
+func sortedPaths(entries ...map[string]treeEntry) []string {
+	paths := map[string]bool{}
+	for _, e := range entries {
+		for path := range e {
+			paths[path] = true
+		}
+	}
+	sorted := []string{}
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// mergeEntries performs a three way merge of the ours and theirs entries, returning all the entries of the result.
+// Files renamed on one side are merged with the changes made to the file on the other side. The contents of
+// files that were merged line by line are written to the repository as new blobs
+func mergeEntries(r *git.Repository, base, ours, theirs map[string]treeEntry) (map[string]treeEntry, []MergeConflict, error) {
+	oursRenames, err := renames(r, base, ours)
+	if err != nil {
+		return nil, nil, err
+	}
+	theirsRenames, err := renames(r, base, theirs)
+	if err != nil {
+		return nil, nil, err
+	}
+	// move the renamed files in the base and the side that didn't rename them to the new path, so they are merged as the same path
+	b, o, t := copyEntries(base), copyEntries(ours), copyEntries(theirs)
+	var conflicts []MergeConflict
+	for _, path := range sortedPaths(base) {
+		oursPath, oursRenamed := oursRenames[path]
+		theirsPath, theirsRenamed := theirsRenames[path]
+		switch {
+		case oursRenamed && theirsRenamed && oursPath != theirsPath:
+			conflicts = append(conflicts, MergeConflict{Path: path, Reason: conflictRename})
+			delete(b, path)
+			delete(o, oursPath)
+			delete(t, theirsPath)
+		case oursRenamed && theirsRenamed:
+			b[oursPath] = b[path]
+			delete(b, path)
+		case oursRenamed:
+			if moved := moveEntry(b, t, path, oursPath); !moved {
+				conflicts = append(conflicts, MergeConflict{Path: oursPath, Reason: conflictRename})
+				delete(o, oursPath)
+				delete(t, oursPath)
+			}
+		case theirsRenamed:
+			if moved := moveEntry(b, o, path, theirsPath); !moved {
+				conflicts = append(conflicts, MergeConflict{Path: theirsPath, Reason: conflictRename})
+				delete(o, theirsPath)
+				delete(t, theirsPath)
+			}
+		}
+	}
+
+	result := map[string]treeEntry{}
+	for _, path := range sortedPaths(b, o, t) {
+		be, bOK := b[path]
+		oe, oOK := o[path]
+		te, tOK := t[path]
+		switch {
+		case oOK == tOK && oe == te:
+			// both sides are the same
+			if oOK {
+				result[path] = oe
+			}
+			continue
+		case oOK == bOK && oe == be:
+			// only theirs changed the path
+			if tOK {
+				result[path] = te
+			}
+			continue
+		case tOK == bOK && te == be:
+			// only ours changed the path
+			if oOK {
+				result[path] = oe
+			}
+			continue
+		case !oOK || !tOK:
+			conflicts = append(conflicts, MergeConflict{Path: path, Reason: conflictModifyDelete})
+			continue
+		case oe.Mode != te.Mode:
+			conflicts = append(conflicts, MergeConflict{Path: path, Reason: conflictMode})
+			continue
+		case oe.Mode == filemode.Submodule || oe.Mode == filemode.Symlink:
+			conflicts = append(conflicts, MergeConflict{Path: path, Reason: conflictContent})
+			continue
+		}
+		// both sides changed the contents of the file
+		var baseContent []byte
+		if bOK {
+			if baseContent, err = blobContent(r, be.Hash); err != nil {
+				return nil, nil, err
+			}
+		}
+		ourContent, err := blobContent(r, oe.Hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		theirContent, err := blobContent(r, te.Hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		if isBinary(baseContent) || isBinary(ourContent) || isBinary(theirContent) {
+			conflicts = append(conflicts, MergeConflict{Path: path, Reason: conflictBinary})
+			continue
+		}
+		content, ok, err := merge3(string(baseContent), string(ourContent), string(theirContent))
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to merge %s: %v", path, err)
+		}
+		if !ok {
+			reason := conflictContent
+			if !bOK {
+				reason = conflictAddAdd
+			}
+			conflicts = append(conflicts, MergeConflict{Path: path, Reason: reason})
+			continue
+		}
+		hash, err := writeBlob(r, []byte(content))
+		if err != nil {
+			return nil, nil, err
+		}
+		result[path] = treeEntry{Hash: hash, Mode: oe.Mode}
+	}
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		return conflicts[i].Path < conflicts[j].Path
+	})
+	return result, conflicts, nil
+}
+
+func copyEntries(entries map[string]treeEntry) map[string]treeEntry {
+	c := make(map[string]treeEntry, len(entries))
+	for path, entry := range entries {
+		c[path] = entry
+	}
+	return c
+}
+
+// moveEntry moves the path of the base and the other side to the path it was renamed to, this can't be done if the other side added its own file at the new path
+func moveEntry(base, other map[string]treeEntry, path, renamed string) bool {
+	if _, ok := other[renamed]; ok {
+		return false
+	}
+	base[renamed] = base[path]
+	delete(base, path)
+	if entry, ok := other[path]; ok {
+		other[renamed] = entry
+		delete(other, path)
+	}
+	return true
+}
+
+// renames finds the paths of the base that were renamed in changed, returning the new path keyed by the base path.
+// Files with the same content are always matched, other files are matched if they are similar enough
+func renames(r *git.Repository, base, changed map[string]treeEntry) (map[string]string, error) {
+	var removed, added []string
+	for _, path := range sortedPaths(base, changed) {
+		_, bOK := base[path]
+		_, cOK := changed[path]
+		switch {
+		case bOK && !cOK:
+			removed = append(removed, path)
+		case cOK && !bOK:
+			added = append(added, path)
+		}
+	}
+	renamed := map[string]string{}
+	if len(removed) == 0 || len(added) == 0 {
+		return renamed, nil
+	}
+	matched := map[string]bool{}
+	for _, from := range removed {
+		for _, to := range added {
+			if !matched[to] && base[from] == changed[to] {
+				renamed[from] = to
+				matched[to] = true
+				break
+			}
+		}
+	}
+	if len(removed) > renameLimit || len(added) > renameLimit {
+		return renamed, nil
+	}
+
+	// score the remaining regular files against each other, and match the most similar first
+	type candidate struct {
+		from, to string
+		score    float64
+	}
+	var candidates []candidate
+	contents := map[plumbing.Hash][]string{}
+	lines := func(entry treeEntry) ([]string, bool, error) {
+		if l, ok := contents[entry.Hash]; ok {
+			return l, l != nil, nil
+		}
+		content, err := blobContent(r, entry.Hash)
+		if err != nil {
+			return nil, false, err
+		}
+		if isBinary(content) {
+			contents[entry.Hash] = nil
+			return nil, false, nil
+		}
+		contents[entry.Hash] = splitLines(string(content))
+		return contents[entry.Hash], true, nil
+	}
+	for _, from := range removed {
+		if _, ok := renamed[from]; ok || !base[from].Mode.IsFile() || base[from].Mode == filemode.Symlink {
+			continue
+		}
+		fromLines, ok, err := lines(base[from])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		for _, to := range added {
+			if matched[to] || changed[to].Mode != base[from].Mode {
+				continue
+			}
+			toLines, ok, err := lines(changed[to])
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			score, err := similarity(fromLines, toLines)
+			if err != nil {
+				return nil, err
+			}
+			if score >= renameSimilarity {
+				candidates = append(candidates, candidate{from: from, to: to, score: score})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	for _, c := range candidates {
+		if _, ok := renamed[c.from]; ok || matched[c.to] {
+			continue
+		}
+		renamed[c.from] = c.to
+		matched[c.to] = true
+	}
+	return renamed, nil
+}
+
+// similarity returns the fraction of lines that are the same in both files
+func similarity(a, b []string) (float64, error) {
+	if len(a)+len(b) == 0 {
+		return 1, nil
+	}
+	hunks, err := lineHunks(a, b)
+	if err != nil {
+		return 0, err
+	}
+	same := len(a)
+	for _, h := range hunks {
+		same -= h.end - h.start
+	}
+	return float64(2*same) / float64(len(a)+len(b)), nil
+}
+
+func blobContent(r *git.Repository, hash plumbing.Hash) ([]byte, error) {
+	blob, err := r.BlobObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read blob %s: %v", hash, err)
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read blob %s: %v", hash, err)
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func writeBlob(r *git.Repository, content []byte) (plumbing.Hash, error) {
+	obj := r.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("unable to write blob: %v", err)
+	}
+	defer w.Close()
+	if _, err := w.Write(content); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("unable to write blob: %v", err)
+	}
+	hash, err := r.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("unable to write blob: %v", err)
+	}
+	return hash, nil
+}
+
+// isBinary uses the same check as git, a file is binary if there is a NUL byte in the first 8000 bytes
+func isBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) != -1
+}
+
+// hunk is a range of base lines [start, end) that one side replaced with lines
+type hunk struct {
+	start int
+	end   int
+	lines []string
+}
+
+// the surrogate range can't be encoded in a string, so these runes are skipped when lines are mapped to runes
+const (
+	surrogateMin = 0xD800
+	surrogateMax = 0xDFFF
+	maxLines     = unicode.MaxRune - (surrogateMax - surrogateMin + 1)
+)
+
+// lineRune returns the rune that represents the line with the given id
+func lineRune(id int) (rune, error) {
+	if id > maxLines {
+		return 0, fmt.Errorf("the files have more than %d unique lines", maxLines)
+	}
+	r := rune(id)
+	if r >= surrogateMin {
+		r += surrogateMax - surrogateMin + 1
+	}
+	return r, nil
+}
+
+// lineHunks returns the changes made to the base lines to produce the changed lines
+func lineHunks(base, changed []string) ([]hunk, error) {
+	dmp := diffmatchpatch.New()
+	dmp.DiffTimeout = 0
+	// map each line to a rune so the diff is line based
+	lineIndex := map[string]rune{}
+	encode := func(lines []string) (string, error) {
+		var sb strings.Builder
+		for _, line := range lines {
+			r, ok := lineIndex[line]
+			if !ok {
+				var err error
+				if r, err = lineRune(len(lineIndex) + 1); err != nil {
+					return "", err
+				}
+				lineIndex[line] = r
+			}
+			sb.WriteRune(r)
+		}
+		return sb.String(), nil
+	}
+	encodedBase, err := encode(base)
+	if err != nil {
+		return nil, err
+	}
+	encodedChanged, err := encode(changed)
+	if err != nil {
+		return nil, err
+	}
+	diffs := dmp.DiffMain(encodedBase, encodedChanged, false)
+	var hunks []hunk
+	var current *hunk
+	pos := 0
+	changedPos := 0
+	for _, d := range diffs {
+		n := len([]rune(d.Text))
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			if current != nil {
+				hunks = append(hunks, *current)
+				current = nil
+			}
+			pos += n
+			changedPos += n
+		case diffmatchpatch.DiffDelete:
+			if current == nil {
+				current = &hunk{start: pos, end: pos}
+			}
+			pos += n
+			current.end = pos
+		case diffmatchpatch.DiffInsert:
+			if current == nil {
+				current = &hunk{start: pos, end: pos}
+			}
+			current.lines = append(current.lines, changed[changedPos:changedPos+n]...)
+			changedPos += n
+		}
+	}
+	if current != nil {
+		hunks = append(hunks, *current)
+	}
+	return hunks, nil
+}
+
+// splitLines splits content into lines, keeping the line endings so the content can be joined back together
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// applyHunks returns the lines of base[start:end] with the hunks applied, the hunks must be within the range
+func applyHunks(base []string, start, end int, hunks []hunk) []string {
+	var lines []string
+	pos := start
+	for _, h := range hunks {
+		lines = append(lines, base[pos:h.start]...)
+		lines = append(lines, h.lines...)
+		pos = h.end
+	}
+	return append(lines, base[pos:end]...)
+}
+
+// merge3 merges the changes made in ours and theirs to base, changes that overlap are a conflict unless both sides made the same change
+func merge3(base, ours, theirs string) (string, bool, error) {
+	baseLines := splitLines(base)
+	oursHunks, err := lineHunks(baseLines, splitLines(ours))
+	if err != nil {
+		return "", false, err
+	}
+	theirsHunks, err := lineHunks(baseLines, splitLines(theirs))
+	if err != nil {
+		return "", false, err
+	}
+	var result []string
+	pos := 0
+	i, j := 0, 0
+	for i < len(oursHunks) || j < len(theirsHunks) {
+		// start a region with the hunk that starts first
+		var start, end int
+		if j >= len(theirsHunks) || (i < len(oursHunks) && oursHunks[i].start <= theirsHunks[j].start) {
+			start, end = oursHunks[i].start, oursHunks[i].end
+		} else {
+			start, end = theirsHunks[j].start, theirsHunks[j].end
+		}
+		// extend the region with any hunks from either side that overlap or touch it
+		var oursRegion, theirsRegion []hunk
+		for {
+			if i < len(oursHunks) && oursHunks[i].start <= end {
+				if oursHunks[i].end > end {
+					end = oursHunks[i].end
+				}
+				oursRegion = append(oursRegion, oursHunks[i])
+				i++
+				continue
+			}
+			if j < len(theirsHunks) && theirsHunks[j].start <= end {
+				if theirsHunks[j].end > end {
+					end = theirsHunks[j].end
+				}
+				theirsRegion = append(theirsRegion, theirsHunks[j])
+				j++
+				continue
+			}
+			break
+		}
+		result = append(result, baseLines[pos:start]...)
+		oursLines := applyHunks(baseLines, start, end, oursRegion)
+		theirsLines := applyHunks(baseLines, start, end, theirsRegion)
+		switch {
+		case len(theirsRegion) == 0:
+			result = append(result, oursLines...)
+		case len(oursRegion) == 0:
+			result = append(result, theirsLines...)
+		case strings.Join(oursLines, "") == strings.Join(theirsLines, ""):
+			result = append(result, oursLines...)
+		default:
+			return "", false, nil
+		}
+		pos = end
+	}
+	result = append(result, baseLines[pos:]...)
+	return strings.Join(result, ""), true, nil
+}
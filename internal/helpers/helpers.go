@@ -205,6 +205,7 @@ func GetNamespace(namespace, filename string) (string, error) {
 func UnsetEnvVars(localVars []EnvironmentVariable) {
 	varNames := []string{
 		"MONITORING_ALERTCONTACT",
+		"LAGOON_BUILD_EVENT_LOG",
 		"MONITORING_STATUSPAGEID",
 		"PROJECT",
 		"ENVIRONMENT",
@@ -225,13 +226,18 @@ func UnsetEnvVars(localVars []EnvironmentVariable) {
 		"LAGOON_FEATURE_BACKUP_DEV_SCHEDULE",
 		"LAGOON_FEATURE_BACKUP_PR_SCHEDULE",
 		"LAGOON_GIT_BRANCH",
+		"GIT_REF",
 		"DEFAULT_BACKUP_SCHEDULE",
 		"LAGOON_FEATURE_BACKUP_DEV_SCHEDULE",
 		"LAGOON_FEATURE_BACKUP_PR_SCHEDULE",
 		"LAGOON_FEATURE_FLAG_DEFAULT_INGRESS_CLASS",
 		"LAGOON_FEATURE_FLAG_ROOTLESS_WORKLOAD",
 		"DBAAS_OPERATOR_HTTP",
+		"LAGOON_SECRET_RESOLVER_VAULT_HTTP",
+		"LAGOON_SECRET_RESOLVER_VAULT_TOKEN",
+		"LAGOON_SECRET_RESOLVER_VAULT_STORE",
 		"CONFIG_MAP_SHA",
+		"LAGOON_SERVICE_TYPES_DIR",
 		"LAGOON_FEATURE_FLAG_IMAGECACHE_REGISTRY",
 		"CI",
 	}
@@ -7,7 +7,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"time"
+
+	"github.com/uselagoon/build-deploy-tool/internal/buildlog"
 )
 
 func RunHooks(hookName, hookDir string) error {
@@ -32,8 +33,7 @@ func RunHooks(hookName, hookDir string) error {
 		hookCount++
 
 		// print the step header for build log parser
-		fmt.Printf("##############################################\nBEGIN %s - %d\n##############################################\n", hookName, hookCount)
-		st := time.Now()
+		step := buildlog.StartStep(fmt.Sprintf("%s - %d", hookName, hookCount))
 		binaryPath := filepath.Join(hookDir, file.Name())
 
 		// execute the binary and capture its error
@@ -46,30 +46,22 @@ func RunHooks(hookName, hookDir string) error {
 		err := cmd.Run()
 		exitCode := cmd.ProcessState.ExitCode()
 
-		var warning string
 		if exitCode == 1 {
 			// for 1 return the error and fail the build
-			return fmt.Errorf("hook %s %d failed with exit code %d: %v", hookName, hookCount, exitCode, err)
+			err = fmt.Errorf("hook %s %d failed with exit code %d: %v", hookName, hookCount, exitCode, err)
+			buildlog.Err(step.Name(), err)
+			return err
 		} else if exitCode > 1 {
 			// if the exit code is greater than 1, we will consider as a warning
-			// set the warning flag for the step footer
-			warning = " WithWarnings"
-			// add to the warnings counter to add to the end of the build to flag build as warning
-			f, err := os.OpenFile("/tmp/warnings", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-			if err != nil {
-				panic(err)
-			}
-			defer f.Close()
-			if _, err = f.WriteString(fmt.Sprintf("%s:%d\n", hookName, hookCount)); err != nil {
-				panic(err)
+			// this sets the warning flag for the step footer, and adds to the warnings counter
+			// to add to the end of the build to flag build as warning
+			if err := step.Warning(fmt.Sprintf("%s:%d", hookName, hookCount)); err != nil {
+				return err
 			}
 		}
 
-		et := time.Now()
-		diff := time.Time{}.Add(et.Sub(st))
-		tz, _ := et.Zone()
 		// print the step footer for build log parser
-		fmt.Printf("##############################################\nSTEP %s - %d: Completed at %s (%s) Duration %s Elapsed %s%s\n##############################################\n", hookName, hookCount, et.Format("2006-01-02 15:04:05"), tz, diff.Format("15:04:05"), diff.Format("15:04:05"), warning)
+		step.End()
 	}
 
 	return nil
@@ -13,11 +13,16 @@ func IdentifyDBaaSConsumers(g generator.GeneratorInput) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	return DBaaSConsumers(*lagoonBuild.BuildValues), nil
+}
+
+// DBaaSConsumers returns the dbaas or single services in the build values in the format `name:type`
+func DBaaSConsumers(buildValues generator.BuildValues) []string {
 	ret := []string{}
-	for _, svc := range lagoonBuild.BuildValues.Services {
+	for _, svc := range buildValues.Services {
 		if svc.IsDBaaS || svc.IsSingle {
 			ret = append(ret, fmt.Sprintf("%s:%s", svc.Name, svc.Type))
 		}
 	}
-	return ret, nil
+	return ret
 }
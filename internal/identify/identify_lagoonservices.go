@@ -5,12 +5,16 @@ import (
 
 	"github.com/uselagoon/build-deploy-tool/internal/generator"
 	servicestemplates "github.com/uselagoon/build-deploy-tool/internal/templating"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 type IdentifyServices struct {
 	Deployments []string `json:"deployments,omitempty"`
 	Volumes     []string `json:"volumes,omitempty"`
 	Services    []string `json:"services,omitempty"`
+	HPAs        []string `json:"hpas,omitempty"`
+	PDBs        []string `json:"pdbs,omitempty"`
 }
 
 // eventually replace with https://github.com/uselagoon/machinery/pull/99
@@ -28,10 +32,19 @@ type EnvironmentService struct {
 	Type       string             `json:"type,omitempty"`
 	Updated    string             `json:"updated,omitempty"`
 	Containers []ServiceContainer `json:"containers,omitempty"`
+	Strategy   *ServiceStrategy   `json:"strategy,omitempty"`
 	Created    string             `json:"created,omitempty"`
 	Abandoned  bool               `json:"abandoned,omitempty"` // no longer tracked in the docker-compose file
 }
 
+// ServiceStrategy is the deployment strategy the service is rolled out with
+type ServiceStrategy struct {
+	Type            string              `json:"type,omitempty"`
+	MaxSurge        *intstr.IntOrString `json:"maxSurge,omitempty"`
+	MaxUnavailable  *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+	MinReadySeconds int32               `json:"minReadySeconds,omitempty"`
+}
+
 // eventually replace with https://github.com/uselagoon/machinery/pull/99
 type ServiceContainer struct {
 	Name    string          `json:"name,omitempty"`
@@ -60,6 +73,22 @@ type ContainerPort struct {
 	Port int    `json:"port,omitempty"`
 }
 
+// deploymentStrategy returns the strategy of a deployment, deployments that don't define a strategy use the kubernetes default
+func deploymentStrategy(d appsv1.Deployment) *ServiceStrategy {
+	if d.Spec.Strategy.Type == "" {
+		return nil
+	}
+	strategy := &ServiceStrategy{
+		Type:            string(d.Spec.Strategy.Type),
+		MinReadySeconds: d.Spec.MinReadySeconds,
+	}
+	if d.Spec.Strategy.RollingUpdate != nil {
+		strategy.MaxSurge = d.Spec.Strategy.RollingUpdate.MaxSurge
+		strategy.MaxUnavailable = d.Spec.Strategy.RollingUpdate.MaxUnavailable
+	}
+	return strategy
+}
+
 // LagoonServiceTemplateIdentification takes the output of the generator and returns a JSON payload that contains information
 // about the services that lagoon will be deploying (this will be kubernetes `kind: deployment`, but lagoon calls them services ¯\_(ツ)_/¯)
 // this command can be used to identify services that are deployed by the build, so that services that may remain in the environment can be identified
@@ -133,6 +162,7 @@ func LagoonServiceTemplateIdentification(g generator.GeneratorInput) (*IdentifyS
 			Type:       d.Labels["lagoon.sh/service-type"],
 			Containers: containers,
 		}
+		service.Strategy = deploymentStrategy(d)
 		lagoonServices.Services = append(lagoonServices.Services, service)
 	}
 	services, err := servicestemplates.GenerateServiceTemplate(*lagoonBuild.BuildValues)
@@ -142,5 +172,19 @@ func LagoonServiceTemplateIdentification(g generator.GeneratorInput) (*IdentifyS
 	for _, service := range services {
 		servicesData.Services = append(servicesData.Services, service.Name)
 	}
+	hpas, err := servicestemplates.GenerateHorizontalPodAutoscalerTemplate(*lagoonBuild.BuildValues)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't identify horizontalpodautoscalers: %v", err)
+	}
+	for _, hpa := range hpas {
+		servicesData.HPAs = append(servicesData.HPAs, hpa.Name)
+	}
+	pdbs, err := servicestemplates.GeneratePodDisruptionBudgetTemplate(*lagoonBuild.BuildValues)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't identify poddisruptionbudgets: %v", err)
+	}
+	for _, pdb := range pdbs {
+		servicesData.PDBs = append(servicesData.PDBs, pdb.Name)
+	}
 	return &servicesData, lagoonServices, nil
 }
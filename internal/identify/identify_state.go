@@ -2,6 +2,7 @@ package identify
 
 import (
 	"context"
+	"slices"
 	"strings"
 
 	mariadbv1 "github.com/amazeeio/dbaas-operator/apis/mariadb/v1"
@@ -10,7 +11,9 @@ import (
 	"github.com/uselagoon/build-deploy-tool/internal/collector"
 	"github.com/uselagoon/build-deploy-tool/internal/generator"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 )
 
 func GetCurrentState(c *collector.Collector, gen generator.GeneratorInput) (
@@ -21,6 +24,8 @@ func GetCurrentState(c *collector.Collector, gen generator.GeneratorInput) (
 	[]appsv1.Deployment,
 	[]corev1.PersistentVolumeClaim,
 	[]corev1.Service,
+	[]autoscalingv2.HorizontalPodAutoscaler,
+	[]policyv1.PodDisruptionBudget,
 	*collector.LagoonEnvState,
 	error,
 ) {
@@ -30,17 +35,17 @@ func GetCurrentState(c *collector.Collector, gen generator.GeneratorInput) (
 	}
 	out, currentServices, err := LagoonServiceTemplateIdentification(gen)
 	if err != nil {
-		return lagoonServices, nil, nil, nil, nil, nil, nil, nil, err
+		return lagoonServices, nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	dbaas, err := IdentifyDBaaSConsumers(gen)
 	if err != nil {
-		return lagoonServices, nil, nil, nil, nil, nil, nil, nil, err
+		return lagoonServices, nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	state, err := c.Collect(context.Background(), gen.Namespace)
 	if err != nil {
-		return lagoonServices, nil, nil, nil, nil, nil, nil, nil, err
+		return lagoonServices, nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	// add any dbaas that should exist to the current services
@@ -203,6 +208,7 @@ func GetCurrentState(c *collector.Collector, gen generator.GeneratorInput) (
 			Name:       exist.Name,
 			Type:       exist.Labels["lagoon.sh/service-type"],
 			Containers: containers,
+			Strategy:   deploymentStrategy(exist),
 		}
 		for _, prov := range out.Deployments {
 			if exist.Name == prov {
@@ -213,6 +219,13 @@ func GetCurrentState(c *collector.Collector, gen generator.GeneratorInput) (
 		if !depMatch {
 			service.Abandoned = true
 			depDelete = append(depDelete, exist)
+		} else {
+			// services that are still deployed will be rolled out with the strategy from this build
+			for _, svc := range currentServices.Services {
+				if svc.Name == exist.Name {
+					service.Strategy = svc.Strategy
+				}
+			}
 		}
 		depMatch = false
 		lagoonServices.Services = append(lagoonServices.Services, service)
@@ -230,7 +243,9 @@ func GetCurrentState(c *collector.Collector, gen generator.GeneratorInput) (
 		}
 	}
 
-	return lagoonServices, mariadbDelete, mongodbDelete, postgresqlDelete, depDelete, volDelete, servDelete, state, nil
+	hpaDelete, pdbDelete := orphanedAutoscaling(out, state)
+
+	return lagoonServices, mariadbDelete, mongodbDelete, postgresqlDelete, depDelete, volDelete, servDelete, hpaDelete, pdbDelete, state, nil
 }
 
 func serviceExists(services []EnvironmentService, serviceName string) bool {
@@ -250,3 +265,28 @@ func volumeExists(volumes []EnvironmentVolume, volumeName string) bool {
 	}
 	return false
 }
+
+// orphanedAutoscaling returns the horizontalpodautoscalers and poddisruptionbudgets in the environment state
+// that are no longer generated by the build
+func orphanedAutoscaling(out *IdentifyServices, state *collector.LagoonEnvState) (
+	[]autoscalingv2.HorizontalPodAutoscaler,
+	[]policyv1.PodDisruptionBudget,
+) {
+	var hpaDelete []autoscalingv2.HorizontalPodAutoscaler
+	if state.HPAs != nil {
+		for _, exist := range state.HPAs.Items {
+			if !slices.Contains(out.HPAs, exist.Name) {
+				hpaDelete = append(hpaDelete, exist)
+			}
+		}
+	}
+	var pdbDelete []policyv1.PodDisruptionBudget
+	if state.PDBs != nil {
+		for _, exist := range state.PDBs.Items {
+			if !slices.Contains(out.PDBs, exist.Name) {
+				pdbDelete = append(pdbDelete, exist)
+			}
+		}
+	}
+	return hpaDelete, pdbDelete
+}
@@ -212,6 +212,9 @@ func TestGetCurrentState(t *testing.T) {
 								},
 							},
 						},
+						Strategy: &ServiceStrategy{
+							Type: "Recreate",
+						},
 					},
 					{
 						Name: "opensearch-2",
@@ -233,6 +236,9 @@ func TestGetCurrentState(t *testing.T) {
 								},
 							},
 						},
+						Strategy: &ServiceStrategy{
+							Type: "Recreate",
+						},
 					},
 					{
 						Name: "postgres-11",
@@ -254,6 +260,9 @@ func TestGetCurrentState(t *testing.T) {
 								},
 							},
 						},
+						Strategy: &ServiceStrategy{
+							Type: "Recreate",
+						},
 					},
 					{
 						Name: "redis-6",
@@ -305,6 +314,9 @@ func TestGetCurrentState(t *testing.T) {
 								},
 							},
 						},
+						Strategy: &ServiceStrategy{
+							Type: "Recreate",
+						},
 					},
 					{
 						Name: "web",
@@ -529,7 +541,7 @@ func TestGetCurrentState(t *testing.T) {
 				t.Errorf("error seeding fake data: %v", err)
 			}
 			col := collector.NewCollector(client)
-			lagoonServices, _, _, _, _, _, _, _, err := GetCurrentState(col, generator)
+			lagoonServices, _, _, _, _, _, _, _, _, _, err := GetCurrentState(col, generator)
 			if err != nil {
 				t.Errorf("GetCurrentState() %v ", err)
 			}
@@ -0,0 +1,150 @@
+package imagebuild
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+)
+
+// BuildOptions are the options for a single image build
+type BuildOptions struct {
+	Image          string
+	Context        string
+	DockerFile     string
+	Target         string
+	BuildArguments map[string]string
+	BuildKit       bool
+	NoCache        bool
+}
+
+// Builder is used to interact with the container engine that builds and pushes images
+type Builder interface {
+	// Login will log in to a container registry
+	Login(ctx context.Context, registry generator.ContainerRegistry) error
+	// Pull will pull an image
+	Pull(ctx context.Context, image string) error
+	// Build will build an image, the output of the build must be written to out
+	Build(ctx context.Context, opts BuildOptions, out io.Writer) error
+	// Push will tag the source image as the target image and push it, returning the image reference with the digest
+	Push(ctx context.Context, source, target string) (string, error)
+	// Copy will copy an image from a registry to another, returning the image reference with the digest
+	Copy(ctx context.Context, source, target string) (string, error)
+}
+
+// DockerBuilder uses the docker CLI to build and push images
+type DockerBuilder struct {
+	Binary string
+}
+
+// NewDockerBuilder returns a builder that uses the docker CLI found in the path
+func NewDockerBuilder() *DockerBuilder {
+	return &DockerBuilder{Binary: "docker"}
+}
+
+func (d *DockerBuilder) run(ctx context.Context, env []string, out io.Writer, stdin io.Reader, args ...string) error {
+	cmd := exec.CommandContext(ctx, d.Binary, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.Stdin = stdin
+	return cmd.Run()
+}
+
+// Login will log in to a container registry, the password is provided over stdin so it doesn't show in the process list
+func (d *DockerBuilder) Login(ctx context.Context, registry generator.ContainerRegistry) error {
+	args := []string{"login", "--username", registry.Username, "--password-stdin"}
+	if registry.IsDockerHub == nil || !*registry.IsDockerHub {
+		args = append(args, registry.URL)
+	}
+	var out bytes.Buffer
+	if err := d.run(ctx, nil, &out, strings.NewReader(registry.Password), args...); err != nil {
+		return fmt.Errorf("unable to log in to %s: %v: %s", registry.URL, err, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+// Pull will pull an image
+func (d *DockerBuilder) Pull(ctx context.Context, image string) error {
+	var out bytes.Buffer
+	if err := d.run(ctx, nil, &out, nil, "pull", image); err != nil {
+		return fmt.Errorf("unable to pull %s: %v: %s", image, err, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+// Build will build an image using docker build
+func (d *DockerBuilder) Build(ctx context.Context, opts BuildOptions, out io.Writer) error {
+	args := []string{"build", "--network=host"}
+	if opts.NoCache {
+		args = append(args, "--no-cache")
+	}
+	for _, key := range sortedKeys(opts.BuildArguments) {
+		args = append(args, "--build-arg", fmt.Sprintf("%s=%s", key, opts.BuildArguments[key]))
+	}
+	args = append(args, "-t", opts.Image, "-f", fmt.Sprintf("%s/%s", opts.Context, opts.DockerFile))
+	if opts.Target != "" {
+		args = append(args, "--target", opts.Target)
+	}
+	args = append(args, opts.Context)
+	env := []string{"DOCKER_BUILDKIT=0"}
+	if opts.BuildKit {
+		env = []string{"DOCKER_BUILDKIT=1"}
+	}
+	return d.run(ctx, env, out, nil, args...)
+}
+
+// Push will tag the source image as the target image and push it
+func (d *DockerBuilder) Push(ctx context.Context, source, target string) (string, error) {
+	var out bytes.Buffer
+	if err := d.run(ctx, nil, &out, nil, "tag", source, target); err != nil {
+		return "", fmt.Errorf("unable to tag %s as %s: %v: %s", source, target, err, strings.TrimSpace(out.String()))
+	}
+	out.Reset()
+	if err := d.run(ctx, nil, &out, nil, "push", target); err != nil {
+		return "", fmt.Errorf("unable to push %s: %v: %s", target, err, strings.TrimSpace(out.String()))
+	}
+	return d.digest(ctx, target)
+}
+
+// Copy will pull the source image and push it as the target image
+func (d *DockerBuilder) Copy(ctx context.Context, source, target string) (string, error) {
+	if err := d.Pull(ctx, source); err != nil {
+		return "", err
+	}
+	return d.Push(ctx, source, target)
+}
+
+// digest returns the repository digest of an image that has been pushed
+func (d *DockerBuilder) digest(ctx context.Context, image string) (string, error) {
+	var out bytes.Buffer
+	if err := d.run(ctx, nil, &out, nil, "inspect", image, "--format", "{{json .RepoDigests}}"); err != nil {
+		return "", fmt.Errorf("unable to inspect %s: %v: %s", image, err, strings.TrimSpace(out.String()))
+	}
+	var digests []string
+	if err := json.Unmarshal(out.Bytes(), &digests); err != nil {
+		return "", fmt.Errorf("unable to read digests for %s: %v", image, err)
+	}
+	return repoDigest(image, digests)
+}
+
+// repoDigest returns the digest that matches the repository of the image
+func repoDigest(image string, digests []string) (string, error) {
+	repository := image
+	// strip the tag, taking care not to strip a registry port
+	if idx := strings.LastIndex(repository, ":"); idx > strings.LastIndex(repository, "/") {
+		repository = repository[:idx]
+	}
+	for _, d := range digests {
+		if strings.HasPrefix(d, repository+"@") {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("no digest found for %s", image)
+}
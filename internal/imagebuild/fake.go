@@ -0,0 +1,88 @@
+package imagebuild
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+)
+
+// FakeBuilder is a builder that doesn't build anything, it records the calls made to it and can be configured to
+// fail builds with specific output
+type FakeBuilder struct {
+	// BuildOutput is the output to write and the error to return for each attempt to build an image, keyed by image name
+	// once all the attempts for an image are used any further builds succeed
+	BuildOutput map[string][]FakeBuildResult
+	// Calls are the calls made to the builder in the order they were made
+	Calls []string
+	mu    sync.Mutex
+}
+
+// FakeBuildResult is the output and error to return for a single fake build
+type FakeBuildResult struct {
+	Output string
+	Err    error
+}
+
+func (f *FakeBuilder) record(call string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Calls = append(f.Calls, call)
+}
+
+// Login records the login
+func (f *FakeBuilder) Login(ctx context.Context, registry generator.ContainerRegistry) error {
+	f.record(fmt.Sprintf("login %s", registry.URL))
+	return nil
+}
+
+// Pull records the pull
+func (f *FakeBuilder) Pull(ctx context.Context, image string) error {
+	f.record(fmt.Sprintf("pull %s", image))
+	return nil
+}
+
+// Build records the build and returns the next configured result for the image
+func (f *FakeBuilder) Build(ctx context.Context, opts BuildOptions, out io.Writer) error {
+	call := fmt.Sprintf("build %s", opts.Image)
+	if opts.NoCache {
+		call = fmt.Sprintf("%s --no-cache", call)
+	}
+	f.record(call)
+	f.mu.Lock()
+	results := f.BuildOutput[opts.Image]
+	var result FakeBuildResult
+	if len(results) > 0 {
+		result = results[0]
+		f.BuildOutput[opts.Image] = results[1:]
+	}
+	f.mu.Unlock()
+	if result.Output != "" {
+		fmt.Fprintln(out, result.Output)
+	}
+	return result.Err
+}
+
+// Push records the push and returns a digest generated from the target image name
+func (f *FakeBuilder) Push(ctx context.Context, source, target string) (string, error) {
+	f.record(fmt.Sprintf("push %s %s", source, target))
+	return fakeDigest(target), nil
+}
+
+// Copy records the copy and returns a digest generated from the target image name
+func (f *FakeBuilder) Copy(ctx context.Context, source, target string) (string, error) {
+	f.record(fmt.Sprintf("copy %s %s", source, target))
+	return fakeDigest(target), nil
+}
+
+func fakeDigest(image string) string {
+	repository := image
+	if idx := strings.LastIndex(repository, ":"); idx > strings.LastIndex(repository, "/") {
+		repository = repository[:idx]
+	}
+	return fmt.Sprintf("%s@sha256:%x", repository, sha256.Sum256([]byte(image)))
+}
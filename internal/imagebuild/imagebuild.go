@@ -0,0 +1,376 @@
+package imagebuild
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+)
+
+// the errors from a buildkit build that indicate a problem with the layer cache, a build that fails with one of these
+// is retried once without the cache. why this happens is still to be determined, there isn't enough information in the
+// error to know which layers are the problem or what the actual cause is
+var layerErrors = []string{
+	": failed to solve: layer does not exist",
+	": failed to solve: failed to prepare",
+	": failed to solve: failed to get layer",
+}
+
+// defaultPushRetryDelay is the delay before the first retry of a failed push
+const defaultPushRetryDelay = 5 * time.Second
+
+// Image is a service image from the `identify image-builds` payload
+type Image struct {
+	Name       string               `json:"name"`
+	ImageBuild generator.ImageBuild `json:"imageBuild"`
+}
+
+// Config is the configuration for building and pushing all the images for a build
+type Config struct {
+	BuildType           string
+	BuildKit            *bool
+	Images              []Image
+	BuildArguments      map[string]string
+	ContainerRegistries []generator.ContainerRegistry
+	ForcePullImages     []string
+	// Parallel is the number of builds or pushes that can run at the same time
+	Parallel int
+	// PushRetries is the number of times a push is attempted before failing
+	PushRetries int
+	// PushRetryDelay is the delay before the first retry of a push, the delay doubles for each further retry
+	PushRetryDelay time.Duration
+	Out            io.Writer
+}
+
+// Run will build and push all the images in the config, returning the image references with digests for each service.
+// Images that depend on the image of another service in the build (using the `SERVICE_IMAGE` build argument)
+// will wait for that image to be built, all other images are built in parallel.
+func Run(ctx context.Context, b Builder, c Config) (map[string]string, error) {
+	if c.Parallel < 1 {
+		c.Parallel = 1
+	}
+	if c.PushRetries < 1 {
+		c.PushRetries = 1
+	}
+	if c.PushRetryDelay <= 0 {
+		c.PushRetryDelay = defaultPushRetryDelay
+	}
+	// builds and pushes run in parallel and share the same output
+	c.Out = &lockedWriter{w: c.Out}
+	for _, registry := range c.ContainerRegistries {
+		fmt.Fprintf(c.Out, "Attempting to log in to %s with user %s from %s\n", registry.URL, registry.Username, registry.UsernameSource)
+		if err := b.Login(ctx, registry); err != nil {
+			return nil, err
+		}
+	}
+	if c.BuildType == "promote" {
+		return copyImages(ctx, b, c, func(i Image) string { return i.ImageBuild.PromoteImage })
+	}
+	for _, image := range c.ForcePullImages {
+		fmt.Fprintf(c.Out, "Pulling Image: %s\n", image)
+		if err := b.Pull(ctx, image); err != nil {
+			return nil, err
+		}
+	}
+	var builds, pulls []Image
+	for _, image := range c.Images {
+		switch {
+		case image.ImageBuild.DockerFile != "":
+			builds = append(builds, image)
+		case image.ImageBuild.PullImage != "":
+			pulls = append(pulls, image)
+		}
+	}
+	if err := buildImages(ctx, b, c, builds); err != nil {
+		return nil, err
+	}
+	digests, err := pushImages(ctx, b, c, builds)
+	if err != nil {
+		return nil, err
+	}
+	pulled, err := copyImages(ctx, b, Config{Images: pulls, Parallel: c.Parallel, PushRetries: c.PushRetries, PushRetryDelay: c.PushRetryDelay, Out: c.Out}, func(i Image) string { return i.ImageBuild.PullImage })
+	if err != nil {
+		return nil, err
+	}
+	for name, digest := range pulled {
+		digests[name] = digest
+	}
+	return digests, nil
+}
+
+// Dependencies returns the services that each image build depends on, an image depends on another service if its Dockerfile
+// references that services `SERVICE_IMAGE` build argument or temporary image name
+func Dependencies(images []Image) (map[string][]string, error) {
+	deps := map[string][]string{}
+	for _, image := range images {
+		if image.ImageBuild.DockerFile == "" {
+			continue
+		}
+		dockerfile, err := os.ReadFile(filepath.Join(image.ImageBuild.Context, image.ImageBuild.DockerFile))
+		if err != nil {
+			// the build will report the missing dockerfile
+			continue
+		}
+		for _, other := range images {
+			if other.Name == image.Name || other.ImageBuild.DockerFile == "" {
+				continue
+			}
+			arg := regexp.MustCompile(fmt.Sprintf(`\$\{?%s\b`, regexp.QuoteMeta(fmt.Sprintf("%s_IMAGE", strings.ToUpper(other.Name)))))
+			if arg.Match(dockerfile) || (other.ImageBuild.TemporaryImage != "" && bytes.Contains(dockerfile, []byte(other.ImageBuild.TemporaryImage))) {
+				deps[image.Name] = append(deps[image.Name], other.Name)
+			}
+		}
+	}
+	// check that the dependencies can be resolved before starting any builds
+	visited := map[string]int{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch visited[name] {
+		case 1:
+			return fmt.Errorf("image builds have a circular dependency: %s", strings.Join(append(path, name), " -> "))
+		case 2:
+			return nil
+		}
+		visited[name] = 1
+		for _, dep := range deps[name] {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		visited[name] = 2
+		return nil
+	}
+	for _, image := range images {
+		if err := visit(image.Name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return deps, nil
+}
+
+// buildImages builds the images, waiting for any dependencies of an image to be built first
+func buildImages(ctx context.Context, b Builder, c Config, images []Image) error {
+	deps, err := Dependencies(images)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	built := map[string]chan struct{}{}
+	for _, image := range images {
+		built[image.Name] = make(chan struct{})
+	}
+	sem := make(chan struct{}, c.Parallel)
+	var wg sync.WaitGroup
+	var errs []error
+	var mu sync.Mutex
+	for _, image := range images {
+		wg.Add(1)
+		go func(image Image) {
+			defer wg.Done()
+			for _, dep := range deps[image.Name] {
+				select {
+				case <-built[dep]:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+			if err := buildImage(ctx, b, c, image); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				// stop any other builds, the build has failed
+				cancel()
+				return
+			}
+			close(built[image.Name])
+		}(image)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// buildImage builds a single image, retrying without the cache if the build fails with a known buildkit layer error
+func buildImage(ctx context.Context, b Builder, c Config, image Image) error {
+	opts := BuildOptions{
+		Image:          image.ImageBuild.TemporaryImage,
+		Context:        image.ImageBuild.Context,
+		DockerFile:     image.ImageBuild.DockerFile,
+		Target:         image.ImageBuild.Target,
+		BuildArguments: c.BuildArguments,
+		BuildKit:       c.BuildKit == nil || *c.BuildKit,
+	}
+	if opts.Target != "" {
+		fmt.Fprintf(c.Out, "[%s] Building target %s for %s/%s\n", image.Name, opts.Target, opts.Context, opts.DockerFile)
+	} else {
+		fmt.Fprintf(c.Out, "[%s] Building %s/%s\n", image.Name, opts.Context, opts.DockerFile)
+	}
+	var log bytes.Buffer
+	pw := &prefixWriter{prefix: fmt.Sprintf("[%s] ", image.Name), out: c.Out}
+	err := b.Build(ctx, opts, io.MultiWriter(&log, pw))
+	pw.Flush()
+	if err == nil {
+		return nil
+	}
+	if !isLayerError(log.String()) {
+		return fmt.Errorf("unable to build image for %s: %v", image.Name, err)
+	}
+	fmt.Fprintf(c.Out, "##############################################\nThe first attempt to build %s/%s failed due to a layer error\nRetrying build for %s/%s without cache\n##############################################\n",
+		opts.Context, opts.DockerFile, opts.Context, opts.DockerFile)
+	opts.NoCache = true
+	err = b.Build(ctx, opts, pw)
+	pw.Flush()
+	if err != nil {
+		return fmt.Errorf("unable to build image for %s: %v", image.Name, err)
+	}
+	return nil
+}
+
+func isLayerError(log string) bool {
+	for _, e := range layerErrors {
+		if strings.Contains(log, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// pushImages pushes the built images to their build image names
+func pushImages(ctx context.Context, b Builder, c Config, images []Image) (map[string]string, error) {
+	return parallel(ctx, c, images, func(ctx context.Context, image Image) (string, error) {
+		fmt.Fprintf(c.Out, "Pushing %s\n", image.ImageBuild.BuildImage)
+		return b.Push(ctx, image.ImageBuild.TemporaryImage, image.ImageBuild.BuildImage)
+	})
+}
+
+// copyImages copies the images from the source image to their build image names
+func copyImages(ctx context.Context, b Builder, c Config, source func(Image) string) (map[string]string, error) {
+	var images []Image
+	for _, image := range c.Images {
+		if source(image) != "" {
+			images = append(images, image)
+		}
+	}
+	return parallel(ctx, c, images, func(ctx context.Context, image Image) (string, error) {
+		fmt.Fprintf(c.Out, "Copying %s to %s\n", source(image), image.ImageBuild.BuildImage)
+		return b.Copy(ctx, source(image), image.ImageBuild.BuildImage)
+	})
+}
+
+// parallel runs the function for all the images, retrying each up to the number of push retries with a backoff between
+// attempts, retries stop when the context is cancelled
+func parallel(ctx context.Context, c Config, images []Image, fn func(context.Context, Image) (string, error)) (map[string]string, error) {
+	digests := map[string]string{}
+	sem := make(chan struct{}, c.Parallel)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for _, image := range images {
+		wg.Add(1)
+		go func(image Image) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			var digest string
+			var err error
+			delay := c.PushRetryDelay
+		retry:
+			for attempt := 0; attempt < c.PushRetries; attempt++ {
+				if digest, err = fn(ctx, image); err == nil {
+					break
+				}
+				if attempt == c.PushRetries-1 {
+					break
+				}
+				select {
+				case <-ctx.Done():
+					err = fmt.Errorf("%v: %v", err, ctx.Err())
+					break retry
+				case <-time.After(delay):
+				}
+				delay *= 2
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			digests[image.Name] = digest
+		}(image)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return digests, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lockedWriter allows multiple builds to write to the same output
+type lockedWriter struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// prefixWriter prefixes every line of build output with the service name so parallel builds can be told apart
+type prefixWriter struct {
+	prefix string
+	out    io.Writer
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		idx := bytes.IndexByte(p.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		if _, err := p.out.Write(append([]byte(p.prefix), p.buf[:idx+1]...)); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[idx+1:]
+	}
+	return len(b), nil
+}
+
+// Flush writes any partial line left in the buffer
+func (p *prefixWriter) Flush() {
+	if len(p.buf) > 0 {
+		p.out.Write(append([]byte(p.prefix), append(p.buf, '\n')...))
+		p.buf = nil
+	}
+}
@@ -0,0 +1,323 @@
+package imagebuild
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+)
+
+// writeDockerfiles writes the dockerfiles into a temporary build context
+func writeDockerfiles(t *testing.T, dockerfiles map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range dockerfiles {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("couldn't write dockerfile: %v", err)
+		}
+	}
+	return dir
+}
+
+func testImage(context, name string) Image {
+	return Image{
+		Name: name,
+		ImageBuild: generator.ImageBuild{
+			Context:        context,
+			DockerFile:     name + ".dockerfile",
+			TemporaryImage: "example-project-main-" + name,
+			BuildImage:     "harbor.example/example-project/main/" + name + ":latest",
+		},
+	}
+}
+
+func indexOf(calls []string, call string) int {
+	for idx, c := range calls {
+		if c == call {
+			return idx
+		}
+	}
+	return -1
+}
+
+func TestRun(t *testing.T) {
+	dir := writeDockerfiles(t, map[string]string{
+		"cli.dockerfile":   "FROM uselagoon/php-8.3-cli-drupal:latest\n",
+		"nginx.dockerfile": "ARG CLI_IMAGE\nFROM ${CLI_IMAGE} as cli\nFROM uselagoon/nginx-drupal:latest\n",
+		"php.dockerfile":   "ARG CLI_IMAGE\nFROM $CLI_IMAGE as cli\nFROM uselagoon/php-8.3-fpm:latest\n",
+	})
+	tests := []struct {
+		name        string
+		config      Config
+		buildOutput map[string][]FakeBuildResult
+		want        map[string]string
+		wantCalls   []string
+		wantOrder   [][]string
+		wantOutput  []string
+		wantErr     string
+	}{
+		{
+			name: "parallel builds with dependencies",
+			config: Config{
+				BuildType: "branch",
+				BuildKit:  helpers.BoolPtr(true),
+				Images: []Image{
+					testImage(dir, "cli"),
+					testImage(dir, "nginx"),
+					testImage(dir, "php"),
+					{
+						Name: "redis",
+						ImageBuild: generator.ImageBuild{
+							PullImage:  "uselagoon/redis-7:latest",
+							BuildImage: "harbor.example/example-project/main/redis:latest",
+						},
+					},
+				},
+				ContainerRegistries: []generator.ContainerRegistry{
+					{Name: "my-registry", URL: "registry1.example.com", Username: "reguser"},
+				},
+				ForcePullImages: []string{"uselagoon/php-8.3-cli-drupal:latest"},
+				Parallel:        4,
+			},
+			want: map[string]string{
+				"cli":   fakeDigest("harbor.example/example-project/main/cli:latest"),
+				"nginx": fakeDigest("harbor.example/example-project/main/nginx:latest"),
+				"php":   fakeDigest("harbor.example/example-project/main/php:latest"),
+				"redis": fakeDigest("harbor.example/example-project/main/redis:latest"),
+			},
+			wantCalls: []string{
+				"build example-project-main-cli",
+				"build example-project-main-nginx",
+				"build example-project-main-php",
+				"copy uselagoon/redis-7:latest harbor.example/example-project/main/redis:latest",
+				"login registry1.example.com",
+				"pull uselagoon/php-8.3-cli-drupal:latest",
+				"push example-project-main-cli harbor.example/example-project/main/cli:latest",
+				"push example-project-main-nginx harbor.example/example-project/main/nginx:latest",
+				"push example-project-main-php harbor.example/example-project/main/php:latest",
+			},
+			wantOrder: [][]string{
+				{"login registry1.example.com", "pull uselagoon/php-8.3-cli-drupal:latest"},
+				{"pull uselagoon/php-8.3-cli-drupal:latest", "build example-project-main-cli"},
+				{"build example-project-main-cli", "build example-project-main-nginx"},
+				{"build example-project-main-cli", "build example-project-main-php"},
+				{"build example-project-main-nginx", "push example-project-main-cli harbor.example/example-project/main/cli:latest"},
+				{"build example-project-main-php", "push example-project-main-cli harbor.example/example-project/main/cli:latest"},
+			},
+		},
+		{
+			name: "buildkit layer error retried without cache",
+			config: Config{
+				BuildType: "branch",
+				Images: []Image{
+					testImage(dir, "cli"),
+				},
+			},
+			buildOutput: map[string][]FakeBuildResult{
+				"example-project-main-cli": {
+					{Output: "ERROR: failed to solve: layer does not exist", Err: errors.New("exit status 1")},
+				},
+			},
+			want: map[string]string{
+				"cli": fakeDigest("harbor.example/example-project/main/cli:latest"),
+			},
+			wantCalls: []string{
+				"build example-project-main-cli",
+				"build example-project-main-cli --no-cache",
+				"push example-project-main-cli harbor.example/example-project/main/cli:latest",
+			},
+			wantOutput: []string{
+				"[cli] ERROR: failed to solve: layer does not exist",
+				"Retrying build for " + dir + "/cli.dockerfile without cache",
+			},
+		},
+		{
+			name: "build failure",
+			config: Config{
+				BuildType: "branch",
+				Images: []Image{
+					testImage(dir, "cli"),
+					testImage(dir, "nginx"),
+				},
+			},
+			buildOutput: map[string][]FakeBuildResult{
+				"example-project-main-cli": {
+					{Output: "ERROR: failed to solve: process \"/bin/sh -c composer install\" did not complete successfully", Err: errors.New("exit status 1")},
+				},
+			},
+			wantCalls: []string{
+				"build example-project-main-cli",
+			},
+			wantErr: "unable to build image for cli: exit status 1",
+		},
+		{
+			name: "promote",
+			config: Config{
+				BuildType: "promote",
+				Images: []Image{
+					{
+						Name: "cli",
+						ImageBuild: generator.ImageBuild{
+							PromoteImage: "harbor.example/example-project/dev/cli:latest",
+							BuildImage:   "harbor.example/example-project/main/cli:latest",
+						},
+					},
+				},
+			},
+			want: map[string]string{
+				"cli": fakeDigest("harbor.example/example-project/main/cli:latest"),
+			},
+			wantCalls: []string{
+				"copy harbor.example/example-project/dev/cli:latest harbor.example/example-project/main/cli:latest",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			tt.config.Out = out
+			b := &FakeBuilder{BuildOutput: tt.buildOutput}
+			got, err := Run(context.Background(), b, tt.config)
+			if err != nil {
+				if tt.wantErr == "" || err.Error() != tt.wantErr {
+					t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			} else if tt.wantErr != "" {
+				t.Errorf("Run() error = nil, wantErr %v", tt.wantErr)
+			}
+			if tt.wantErr == "" && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Run() = %v, want %v", got, tt.want)
+			}
+			calls := append([]string{}, b.Calls...)
+			sort.Strings(calls)
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("Run() calls = %v, want %v", calls, tt.wantCalls)
+			}
+			for _, order := range tt.wantOrder {
+				if indexOf(b.Calls, order[0]) > indexOf(b.Calls, order[1]) {
+					t.Errorf("Run() %q should be called before %q, got %v", order[0], order[1], b.Calls)
+				}
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("Run() output missing %q, got:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	tests := []struct {
+		name        string
+		dockerfiles map[string]string
+		images      []string
+		want        map[string][]string
+		wantErr     bool
+	}{
+		{
+			name: "build argument and temporary image",
+			dockerfiles: map[string]string{
+				"cli.dockerfile":   "FROM uselagoon/php-8.3-cli-drupal:latest\n",
+				"nginx.dockerfile": "ARG CLI_IMAGE\nFROM ${CLI_IMAGE} as cli\n",
+				"php.dockerfile":   "FROM example-project-main-cli as cli\n",
+			},
+			images: []string{"cli", "nginx", "php"},
+			want: map[string][]string{
+				"nginx": {"cli"},
+				"php":   {"cli"},
+			},
+		},
+		{
+			name: "similar build argument names",
+			dockerfiles: map[string]string{
+				"cli.dockerfile":   "FROM uselagoon/php-8.3-cli-drupal:latest\n",
+				"nginx.dockerfile": "ARG CLI_IMAGE_VERSION\nFROM uselagoon/nginx:${CLI_IMAGE_VERSION}\n",
+			},
+			images: []string{"cli", "nginx"},
+			want:   map[string][]string{},
+		},
+		{
+			name: "circular dependency",
+			dockerfiles: map[string]string{
+				"cli.dockerfile":   "FROM ${NGINX_IMAGE}\n",
+				"nginx.dockerfile": "FROM ${CLI_IMAGE}\n",
+			},
+			images:  []string{"cli", "nginx"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeDockerfiles(t, tt.dockerfiles)
+			var images []Image
+			for _, name := range tt.images {
+				images = append(images, testImage(dir, name))
+			}
+			got, err := Dependencies(images)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Dependencies() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Dependencies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepoDigest(t *testing.T) {
+	got, err := repoDigest("registry.example.com:5000/example-project/main/cli:latest", []string{
+		"uselagoon/php-8.3-cli-drupal@sha256:1111",
+		"registry.example.com:5000/example-project/main/cli@sha256:2222",
+	})
+	if err != nil {
+		t.Errorf("repoDigest() error = %v", err)
+	}
+	if got != "registry.example.com:5000/example-project/main/cli@sha256:2222" {
+		t.Errorf("repoDigest() = %v", got)
+	}
+}
+
+func TestParallelRetries(t *testing.T) {
+	images := []Image{{Name: "cli"}}
+	t.Run("retries until the push succeeds", func(t *testing.T) {
+		attempts := 0
+		got, err := parallel(context.Background(), Config{Parallel: 1, PushRetries: 3, PushRetryDelay: time.Millisecond}, images, func(ctx context.Context, image Image) (string, error) {
+			attempts++
+			if attempts < 3 {
+				return "", errors.New("push failed")
+			}
+			return "cli@sha256:1111", nil
+		})
+		if err != nil {
+			t.Errorf("parallel() error = %v", err)
+		}
+		if attempts != 3 || got["cli"] != "cli@sha256:1111" {
+			t.Errorf("parallel() = %v after %d attempts", got, attempts)
+		}
+	})
+	t.Run("stops retrying when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		attempts := 0
+		_, err := parallel(ctx, Config{Parallel: 1, PushRetries: 3, PushRetryDelay: time.Hour}, images, func(ctx context.Context, image Image) (string, error) {
+			attempts++
+			cancel()
+			return "", errors.New("push failed")
+		})
+		if err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+			t.Errorf("parallel() error = %v, want a cancelled error", err)
+		}
+		if attempts != 1 {
+			t.Errorf("parallel() attempts = %d, want 1", attempts)
+		}
+	})
+}
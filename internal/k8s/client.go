@@ -11,15 +11,20 @@ import (
 	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
 	k8upv1alpha1 "github.com/vshn/k8up/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	client "sigs.k8s.io/controller-runtime/pkg/client"
 	ctrlfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 	"sigs.k8s.io/yaml"
 )
 
@@ -53,10 +58,22 @@ func setScheme() (*runtime.Scheme, error) {
 	if err := corev1.AddToScheme(k8sScheme); err != nil {
 		return nil, err
 	}
+	if err := autoscalingv2.AddToScheme(k8sScheme); err != nil {
+		return nil, err
+	}
+	if err := policyv1.AddToScheme(k8sScheme); err != nil {
+		return nil, err
+	}
+	if err := gatewayv1.AddToScheme(k8sScheme); err != nil {
+		return nil, err
+	}
+	if err := gatewayv1beta1.AddToScheme(k8sScheme); err != nil {
+		return nil, err
+	}
 	return k8sScheme, nil
 }
 
-func NewClient() (client.Client, error) {
+func restConfig() (*rest.Config, error) {
 	// read the serviceaccount deployer token first.
 	token, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/token")
 	if err != nil {
@@ -66,18 +83,24 @@ func NewClient() (client.Client, error) {
 			return nil, err
 		}
 	}
-	k8sScheme, err := setScheme()
-	if err != nil {
-		return nil, err
-	}
-
 	// generate the rest config for the client.
-	config := &rest.Config{
+	return &rest.Config{
 		BearerToken: string(token),
 		Host:        "https://kubernetes.default.svc",
 		TLSClientConfig: rest.TLSClientConfig{
 			Insecure: true,
 		},
+	}, nil
+}
+
+func NewClient() (client.Client, error) {
+	config, err := restConfig()
+	if err != nil {
+		return nil, err
+	}
+	k8sScheme, err := setScheme()
+	if err != nil {
+		return nil, err
 	}
 	// create the client using the rest config.
 	return client.New(config, client.Options{
@@ -85,6 +108,15 @@ func NewClient() (client.Client, error) {
 	})
 }
 
+// NewClientset returns a kubernetes clientset for the api requests that the controller-runtime client doesn't support, like reading pod logs
+func NewClientset() (*kubernetes.Clientset, error) {
+	config, err := restConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
 func NewFakeClient(namespace string) (client.Client, error) {
 	k8sScheme, err := setScheme()
 	if err != nil {
@@ -92,6 +124,10 @@ func NewFakeClient(namespace string) (client.Client, error) {
 	}
 	clientBuilder := ctrlfake.NewClientBuilder()
 	clientBuilder = clientBuilder.WithScheme(k8sScheme)
+	// the api server supports listing events by the involved object, the fake client needs an index to do the same
+	clientBuilder = clientBuilder.WithIndex(&corev1.Event{}, "involvedObject.name", func(obj client.Object) []string {
+		return []string{obj.(*corev1.Event).InvolvedObject.Name}
+	})
 
 	fakeClient := clientBuilder.Build()
 	ns := corev1.Namespace{
@@ -130,3 +166,16 @@ func SeedFakeData(fakeClient client.Client, namespace string, seedDir string) er
 	}
 	return nil
 }
+
+// SeedFakeObjects creates the objects in the namespace, any server populated fields are removed so they can be created
+func SeedFakeObjects(fakeClient client.Client, namespace string, objects []client.Object) error {
+	for _, obj := range objects {
+		obj.SetNamespace(namespace)
+		obj.SetResourceVersion("")
+		obj.SetUID("")
+		if err := fakeClient.Create(context.Background(), obj); err != nil {
+			return fmt.Errorf("couldn't create %s: %v", obj.GetName(), err)
+		}
+	}
+	return nil
+}
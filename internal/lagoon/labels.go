@@ -0,0 +1,526 @@
+package lagoon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	composetypes "github.com/compose-spec/compose-go/types"
+	"github.com/uselagoon/build-deploy-tool/internal/servicetypes"
+	yamlv3 "gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// LabelIssue is a problem found when linting the lagoon labels of a docker-compose file
+type LabelIssue struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Column     int    `json:"column"`
+	Service    string `json:"service,omitempty"`
+	Volume     string `json:"volume,omitempty"`
+	Label      string `json:"label"`
+	Severity   string `json:"severity"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+func (i LabelIssue) String() string {
+	target := fmt.Sprintf("service %s", i.Service)
+	if i.Volume != "" {
+		target = fmt.Sprintf("volume %s", i.Volume)
+	}
+	message := i.Message
+	if i.Suggestion != "" {
+		message = fmt.Sprintf("%s, did you mean %s?", message, i.Suggestion)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s: %s: %s", i.File, i.Line, i.Column, i.Severity, target, i.Label, message)
+}
+
+// LabelIssues is the result of linting the labels of a docker-compose file
+type LabelIssues []LabelIssue
+
+// Errors returns the issues that will cause a build to fail
+func (l LabelIssues) Errors() LabelIssues {
+	return l.filter(SchemaError)
+}
+
+// Warnings returns the issues that don't prevent a build, like unknown labels or labels that have no effect
+func (l LabelIssues) Warnings() LabelIssues {
+	return l.filter(SchemaWarning)
+}
+
+func (l LabelIssues) filter(severity string) LabelIssues {
+	issues := LabelIssues{}
+	for _, i := range l {
+		if i.Severity == severity {
+			issues = append(issues, i)
+		}
+	}
+	return issues
+}
+
+// labelRule defines how the build uses a `lagoon.*` label
+type labelRule struct {
+	// effect returns an empty string if the label is used for the service type, otherwise the reason it isn't,
+	// and if a build with the label will fail
+	effect func(lagoonType string, serviceType servicetypes.ServiceType, service composetypes.ServiceConfig) (string, bool)
+	// validate checks the value of the label is one the build can use
+	validate func(value string) error
+	// the reason the label is deprecated
+	deprecated string
+}
+
+func anyType(string, servicetypes.ServiceType, composetypes.ServiceConfig) (string, bool) {
+	return "", false
+}
+
+func notExternal(lagoonType string, _ servicetypes.ServiceType, _ composetypes.ServiceConfig) (string, bool) {
+	if lagoonType == "external" {
+		return "external services don't run any containers", false
+	}
+	return "", false
+}
+
+func hasDeployment(lagoonType string, serviceType servicetypes.ServiceType, service composetypes.ServiceConfig) (string, bool) {
+	if servicetypes.IsIgnoredImageType(lagoonType) {
+		return "dbaas services don't run any containers", false
+	}
+	return notExternal(lagoonType, serviceType, service)
+}
+
+func usesPersistentVolume(lagoonType string, serviceType servicetypes.ServiceType, service composetypes.ServiceConfig) (string, bool) {
+	if reason, _ := hasDeployment(lagoonType, serviceType, service); reason != "" {
+		return reason, false
+	}
+	if !serviceType.ProvidesPersistentVolume && !serviceType.ConsumesPersistentVolume {
+		return "the service type doesn't provide or consume a persistent volume", false
+	}
+	return "", false
+}
+
+func providesPersistentVolume(lagoonType string, serviceType servicetypes.ServiceType, service composetypes.ServiceConfig) (string, bool) {
+	if reason, _ := hasDeployment(lagoonType, serviceType, service); reason != "" {
+		return reason, false
+	}
+	if !serviceType.ProvidesPersistentVolume {
+		return "the service type doesn't provide a persistent volume", false
+	}
+	return "", false
+}
+
+func autogeneratedRoutes(lagoonType string, _ servicetypes.ServiceType, _ composetypes.ServiceConfig) (string, bool) {
+	if !servicetypes.IsAutogeneratedSupported(lagoonType) {
+		return "the service type doesn't support autogenerated routes", false
+	}
+	return "", false
+}
+
+func autoscaling(lagoonType string, serviceType servicetypes.ServiceType, service composetypes.ServiceConfig) (string, bool) {
+	if reason, _ := hasDeployment(lagoonType, serviceType, service); reason != "" {
+		return reason, false
+	}
+	if serviceType.ProvidesPersistentVolume && serviceType.Volumes.PersistentVolumeType == corev1.ReadWriteOnce {
+		return "autoscaling is not supported for service types that use a ReadWriteOnce volume", true
+	}
+	return "", false
+}
+
+func dbaasEnvironment(dbType string) func(string, servicetypes.ServiceType, composetypes.ServiceConfig) (string, bool) {
+	return func(lagoonType string, _ servicetypes.ServiceType, _ composetypes.ServiceConfig) (string, bool) {
+		if lagoonType != fmt.Sprintf("%s-dbaas", dbType) {
+			return fmt.Sprintf("the label is only used by the %s-dbaas service type", dbType), false
+		}
+		return "", false
+	}
+}
+
+func validateBool(value string) error {
+	if _, err := strconv.ParseBool(value); err != nil {
+		return fmt.Errorf("must be true or false")
+	}
+	return nil
+}
+
+func validateInt32(value string) error {
+	if _, err := strconv.ParseInt(value, 10, 32); err != nil {
+		return fmt.Errorf("must be an integer")
+	}
+	return nil
+}
+
+func validateSize(value string) error {
+	_, err := resource.ParseQuantity(value)
+	return err
+}
+
+// serviceLabels are the labels a service in a docker-compose file can use, the `lagoon.volumes.<volume>.path` labels
+// depend on the volumes in the file so they are added when a file is linted
+var serviceLabels = map[string]labelRule{
+	"lagoon.type": {effect: anyType},
+	"lagoon.name": {effect: anyType},
+	"lagoon.autogeneratedroute": {
+		effect:   autogeneratedRoutes,
+		validate: validateBool,
+	},
+	"lagoon.autogeneratedroute.tls-acme": {
+		effect:   autogeneratedRoutes,
+		validate: validateBool,
+	},
+	"lagoon.deployment.servicetype": {
+		effect:     anyType,
+		deprecated: "the label is no longer used by builds and can be removed",
+	},
+	"lagoon.external.service": {
+		effect: func(lagoonType string, _ servicetypes.ServiceType, _ composetypes.ServiceConfig) (string, bool) {
+			if lagoonType != "external" {
+				return "the label is only used by the external service type", false
+			}
+			return "", false
+		},
+		validate: func(value string) error {
+			var externalService map[string]interface{}
+			if err := json.Unmarshal([]byte(value), &externalService); err != nil {
+				return fmt.Errorf("must be a JSON object")
+			}
+			return nil
+		},
+	},
+	"lagoon.mariadb-dbaas.environment":  {effect: dbaasEnvironment("mariadb")},
+	"lagoon.mongodb-dbaas.environment":  {effect: dbaasEnvironment("mongodb")},
+	"lagoon.postgres-dbaas.environment": {effect: dbaasEnvironment("postgres")},
+	"lagoon.persistent":                 {effect: usesPersistentVolume},
+	"lagoon.persistent.name":            {effect: usesPersistentVolume},
+	"lagoon.persistent.size": {
+		effect:   providesPersistentVolume,
+		validate: validateSize,
+	},
+	"lagoon.base.image": {effect: hasDeployment},
+	"lagoon.image": {
+		effect: func(lagoonType string, serviceType servicetypes.ServiceType, service composetypes.ServiceConfig) (string, bool) {
+			if reason, _ := hasDeployment(lagoonType, serviceType, service); reason != "" {
+				return reason, false
+			}
+			if service.Build != nil {
+				return "the service is built from a Dockerfile, the label only overrides pulled images", false
+			}
+			return "", false
+		},
+	},
+	"lagoon.service.port": {
+		effect: func(lagoonType string, serviceType servicetypes.ServiceType, _ composetypes.ServiceConfig) (string, bool) {
+			if !serviceType.Ports.CanChangePort {
+				return "the service type doesn't allow the port to be changed", false
+			}
+			return "", false
+		},
+		validate: validateInt32,
+	},
+	"lagoon.service.usecomposeports": {effect: hasDeployment},
+	"lagoon.autoscaling.minreplicas": {
+		effect:   autoscaling,
+		validate: validateInt32,
+	},
+	"lagoon.autoscaling.maxreplicas": {
+		effect:   autoscaling,
+		validate: validateInt32,
+	},
+	"lagoon.autoscaling.targetcpu": {
+		effect:   autoscaling,
+		validate: validateInt32,
+	},
+	"lagoon.autoscaling.targetmemory": {
+		effect:   autoscaling,
+		validate: validateInt32,
+	},
+	"lagoon.autoscaling.minavailable":   {effect: autoscaling},
+	"lagoon.autoscaling.maxunavailable": {effect: autoscaling},
+}
+
+// volumeLabels are the labels a volume in a docker-compose file can use
+var volumeLabels = map[string]labelRule{
+	"lagoon.type": {
+		validate: func(value string) error {
+			if value != "persistent" && value != "none" {
+				return fmt.Errorf("must be persistent or none")
+			}
+			return nil
+		},
+	},
+	"lagoon.persistent.size": {validate: validateSize},
+	"lagoon.backup":          {validate: validateBool},
+}
+
+// LintDockerComposeLabels checks the `lagoon.*` labels of the services and volumes in a docker-compose file.
+// Unknown labels and labels that have no effect for the type of the service are warnings, labels that will fail a build are errors.
+// The file is only used to find the line and column of an issue. Any service types that are overridden for the environment,
+// keyed by the docker-compose service name, are used instead of the lagoon.type label of the service
+func LintDockerComposeLabels(file string, project *composetypes.Project, typeOverrides map[string]string) (LabelIssues, error) {
+	rawYAML, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read %v: %v", file, err)
+	}
+	root := &yamlv3.Node{}
+	if err := yamlv3.Unmarshal(rawYAML, root); err != nil {
+		return nil, fmt.Errorf("couldn't parse %v: %v", file, err)
+	}
+	issues := LabelIssues{}
+	add := func(path []string, issue LabelIssue) {
+		issue.File = file
+		issue.Line, issue.Column = labelPosition(root, path, issue.Label)
+		issues = append(issues, issue)
+	}
+
+	persistentVolumes := []string{}
+	volumeNames := []string{}
+	for name := range project.Volumes {
+		volumeNames = append(volumeNames, name)
+	}
+	sort.Strings(volumeNames)
+	for _, name := range volumeNames {
+		volume := project.Volumes[name]
+		volumeType := volume.Labels["lagoon.type"]
+		if volumeType == "persistent" {
+			persistentVolumes = append(persistentVolumes, name)
+		}
+		for _, label := range sortedLagoonLabels(volume.Labels) {
+			issue := LabelIssue{Volume: name, Label: label, Severity: SchemaWarning}
+			rule, ok := volumeLabels[label]
+			switch {
+			case !ok:
+				issue.Message = "unknown label"
+				issue.Suggestion = suggestLabel(label, volumeLabels)
+			case rule.validate != nil && rule.validate(volume.Labels[label]) != nil:
+				issue.Severity = SchemaError
+				issue.Message = fmt.Sprintf("invalid value %q: %v", volume.Labels[label], rule.validate(volume.Labels[label]))
+			case label != "lagoon.type" && volumeType != "persistent":
+				issue.Message = "the label has no effect, only volumes with the lagoon.type persistent are created"
+			default:
+				continue
+			}
+			add([]string{"volumes", name, "labels"}, issue)
+		}
+	}
+
+	// the volume path labels are only known once the volumes have been read
+	rules := map[string]labelRule{}
+	for label, rule := range serviceLabels {
+		rules[label] = rule
+	}
+	for _, name := range persistentVolumes {
+		rules[fmt.Sprintf("lagoon.volumes.%s.path", name)] = labelRule{
+			effect: func(lagoonType string, serviceType servicetypes.ServiceType, service composetypes.ServiceConfig) (string, bool) {
+				if reason, _ := hasDeployment(lagoonType, serviceType, service); reason != "" {
+					return reason, false
+				}
+				if !serviceType.AllowAdditionalVolumes {
+					return "the service type is not permitted to have additional volumes attached", true
+				}
+				return "", false
+			},
+		}
+	}
+
+	for _, service := range project.Services {
+		path := []string{"services", service.Name, "labels"}
+		labels := sortedLagoonLabels(service.Labels)
+		lagoonType := service.Labels["lagoon.type"]
+		if lagoonType == "" {
+			add(path, LabelIssue{
+				Service:  service.Name,
+				Label:    "lagoon.type",
+				Severity: SchemaError,
+				Message:  "no lagoon.type has been set, if a Lagoon service is not required set the lagoon.type to none",
+			})
+			continue
+		}
+		if override, ok := typeOverrides[service.Name]; ok {
+			lagoonType = override
+		}
+		switch {
+		case lagoonType == "none":
+			// services that aren't deployed can have any labels
+			continue
+		case servicetypes.ConvertOldServiceType(lagoonType) != lagoonType:
+			add(path, LabelIssue{
+				Service:    service.Name,
+				Label:      "lagoon.type",
+				Severity:   SchemaWarning,
+				Message:    fmt.Sprintf("the service type %s is deprecated", lagoonType),
+				Suggestion: servicetypes.ConvertOldServiceType(lagoonType),
+			})
+			lagoonType = servicetypes.ConvertOldServiceType(lagoonType)
+		}
+		candidates := buildServiceTypes(lagoonType)
+		if len(candidates) == 0 {
+			types := map[string]labelRule{}
+			for name := range servicetypes.ServiceTypes {
+				types[name] = labelRule{}
+			}
+			add(path, LabelIssue{
+				Service:    service.Name,
+				Label:      "lagoon.type",
+				Severity:   SchemaError,
+				Message:    fmt.Sprintf("%s is not a valid service type", lagoonType),
+				Suggestion: suggestLabel(lagoonType, types),
+			})
+			continue
+		}
+		if lagoonType == "external" && service.Labels["lagoon.external.service"] == "" {
+			add(path, LabelIssue{
+				Service:  service.Name,
+				Label:    "lagoon.external.service",
+				Severity: SchemaError,
+				Message:  "the label is required for the external service type",
+			})
+		}
+		for _, label := range labels {
+			issue := LabelIssue{Service: service.Name, Label: label, Severity: SchemaWarning}
+			rule, ok := rules[label]
+			if !ok {
+				issue.Message = "unknown label"
+				issue.Suggestion = suggestLabel(label, rules)
+				if strings.HasPrefix(label, "lagoon.volumes.") && strings.HasSuffix(label, ".path") && issue.Suggestion == "" {
+					issue.Message = "unknown label, there is no volume with the lagoon.type persistent of this name"
+				}
+				add(path, issue)
+				continue
+			}
+			if rule.deprecated != "" {
+				issue.Message = fmt.Sprintf("deprecated: %s", rule.deprecated)
+				add(path, issue)
+				continue
+			}
+			if rule.validate != nil {
+				if err := rule.validate(service.Labels[label]); err != nil {
+					issue.Severity = SchemaError
+					issue.Message = fmt.Sprintf("invalid value %q: %v", service.Labels[label], err)
+					add(path, issue)
+					continue
+				}
+			}
+			// the label is used if it has an effect for any of the types the service could be built as
+			reason, fails := "", true
+			for _, candidate := range candidates {
+				r, f := rule.effect(candidate, servicetypes.ServiceTypes[candidate], service)
+				if r == "" {
+					reason, fails = "", false
+					break
+				}
+				if reason == "" || !f {
+					reason, fails = r, f
+				}
+			}
+			if reason == "" {
+				continue
+			}
+			issue.Message = fmt.Sprintf("the label has no effect for the service type %s, %s", lagoonType, reason)
+			if fails {
+				issue.Severity = SchemaError
+				issue.Message = fmt.Sprintf("the label can't be used with the service type %s, %s", lagoonType, reason)
+			}
+			add(path, issue)
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Column < issues[j].Column
+	})
+	return issues, nil
+}
+
+// buildServiceTypes returns the types a service with the lagoon.type could be built as,
+// database types become either the dbaas or single type depending on the dbaas providers of the environment
+func buildServiceTypes(lagoonType string) []string {
+	if servicetypes.IsSupportedDBType(lagoonType) {
+		dbType := strings.TrimSuffix(lagoonType, "-dbaas")
+		return []string{fmt.Sprintf("%s-dbaas", dbType), fmt.Sprintf("%s-single", dbType)}
+	}
+	if _, ok := servicetypes.ServiceTypes[lagoonType]; ok {
+		return []string{lagoonType}
+	}
+	return nil
+}
+
+// sortedLagoonLabels returns the names of the `lagoon.*` labels in order, any other labels are ignored
+func sortedLagoonLabels(labels composetypes.Labels) []string {
+	names := []string{}
+	for label := range labels {
+		if strings.HasPrefix(label, "lagoon.") {
+			names = append(names, label)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// suggestLabel returns the known name closest to the unknown name, if there is one that is close enough to be a typo
+func suggestLabel(name string, known map[string]labelRule) string {
+	suggestion := ""
+	best := 4
+	for candidate := range known {
+		distance := levenshtein(strings.ToLower(name), strings.ToLower(candidate))
+		if distance < best || (distance == best && candidate < suggestion) {
+			suggestion, best = candidate, distance
+		}
+	}
+	return suggestion
+}
+
+// levenshtein returns the number of single character edits needed to change a into b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+// labelPosition returns the line and column of a label, labels can be defined as a mapping or as a list of `key=value` strings.
+// If the label can't be found, the position of the closest parent is returned
+func labelPosition(root *yamlv3.Node, path []string, label string) (int, int) {
+	line, column := nodePosition(root, append(append([]string{}, path...), label), true)
+	labelsLine, labelsColumn := nodePosition(root, path, false)
+	if line != labelsLine || column != labelsColumn {
+		return line, column
+	}
+	node := root
+	if node.Kind == yamlv3.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, segment := range path {
+		_, node = childNode(node, segment)
+		if node == nil {
+			return line, column
+		}
+	}
+	for node.Kind == yamlv3.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	if node.Kind == yamlv3.SequenceNode {
+		for _, item := range node.Content {
+			if item.Value == label || strings.HasPrefix(item.Value, label+"=") {
+				return item.Line, item.Column
+			}
+		}
+	}
+	return line, column
+}
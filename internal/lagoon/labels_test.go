@@ -0,0 +1,257 @@
+package lagoon
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestLintDockerComposeLabels(t *testing.T) {
+	tests := []struct {
+		name          string
+		file          string
+		typeOverrides map[string]string
+		want          LabelIssues
+	}{
+		{
+			name: "valid labels",
+			file: "internal/testdata/basic/lagoon.yml",
+			want: LabelIssues{},
+		},
+		{
+			name: "unknown and unused labels",
+			file: "internal/lagoon/test-resources/docker-compose-labels/lagoon.yml",
+			want: LabelIssues{
+				{
+					Line:       18,
+					Column:     7,
+					Service:    "cli",
+					Label:      "lagoon.persistant",
+					Severity:   SchemaWarning,
+					Message:    "unknown label",
+					Suggestion: "lagoon.persistent",
+				},
+				{
+					Line:     28,
+					Column:   9,
+					Service:  "nginx",
+					Label:    "lagoon.persistent",
+					Severity: SchemaWarning,
+					Message:  "the label has no effect for the service type nginx, the service type doesn't provide or consume a persistent volume",
+				},
+				{
+					Line:     35,
+					Column:   7,
+					Service:  "node",
+					Label:    "lagoon.service.port",
+					Severity: SchemaWarning,
+					Message:  "the label has no effect for the service type node, the service type doesn't allow the port to be changed",
+				},
+				{
+					Line:     42,
+					Column:   7,
+					Service:  "basic",
+					Label:    "lagoon.service.port",
+					Severity: SchemaError,
+					Message:  "invalid value \"http\": must be an integer",
+				},
+				{
+					Line:     43,
+					Column:   7,
+					Service:  "basic",
+					Label:    "lagoon.volumes.files.path",
+					Severity: SchemaError,
+					Message:  "the label can't be used with the service type basic-single, the service type is not permitted to have additional volumes attached",
+				},
+				{
+					Line:     44,
+					Column:   7,
+					Service:  "basic",
+					Label:    "lagoon.volumes.scratch.path",
+					Severity: SchemaWarning,
+					Message:  "unknown label, there is no volume with the lagoon.type persistent of this name",
+				},
+				{
+					Line:     51,
+					Column:   7,
+					Service:  "mariadb",
+					Label:    "lagoon.postgres-dbaas.environment",
+					Severity: SchemaWarning,
+					Message:  "the label has no effect for the service type mariadb, the label is only used by the postgres-dbaas service type",
+				},
+				{
+					Line:       56,
+					Column:     7,
+					Service:    "redis",
+					Label:      "lagoon.type",
+					Severity:   SchemaError,
+					Message:    "redis-persisent is not a valid service type",
+					Suggestion: "redis-persistent",
+				},
+				{
+					Line:     61,
+					Column:   7,
+					Service:  "external",
+					Label:    "lagoon.external.service",
+					Severity: SchemaError,
+					Message:  "the label is required for the external service type",
+				},
+				{
+					Line:       73,
+					Column:     7,
+					Volume:     "files",
+					Label:      "lagoon.backups",
+					Severity:   SchemaWarning,
+					Message:    "unknown label",
+					Suggestion: "lagoon.backup",
+				},
+				{
+					Line:     76,
+					Column:   7,
+					Volume:   "scratch",
+					Label:    "lagoon.persistent.size",
+					Severity: SchemaWarning,
+					Message:  "the label has no effect, only volumes with the lagoon.type persistent are created",
+				},
+			},
+		},
+		{
+			name: "overridden service types",
+			file: "internal/lagoon/test-resources/docker-compose-labels/lagoon.yml",
+			typeOverrides: map[string]string{
+				"node":  "none",
+				"redis": "redis-persistent",
+			},
+			want: LabelIssues{
+				{
+					Line:       18,
+					Column:     7,
+					Service:    "cli",
+					Label:      "lagoon.persistant",
+					Severity:   SchemaWarning,
+					Message:    "unknown label",
+					Suggestion: "lagoon.persistent",
+				},
+				{
+					Line:     28,
+					Column:   9,
+					Service:  "nginx",
+					Label:    "lagoon.persistent",
+					Severity: SchemaWarning,
+					Message:  "the label has no effect for the service type nginx, the service type doesn't provide or consume a persistent volume",
+				},
+				{
+					Line:     42,
+					Column:   7,
+					Service:  "basic",
+					Label:    "lagoon.service.port",
+					Severity: SchemaError,
+					Message:  "invalid value \"http\": must be an integer",
+				},
+				{
+					Line:     43,
+					Column:   7,
+					Service:  "basic",
+					Label:    "lagoon.volumes.files.path",
+					Severity: SchemaError,
+					Message:  "the label can't be used with the service type basic-single, the service type is not permitted to have additional volumes attached",
+				},
+				{
+					Line:     44,
+					Column:   7,
+					Service:  "basic",
+					Label:    "lagoon.volumes.scratch.path",
+					Severity: SchemaWarning,
+					Message:  "unknown label, there is no volume with the lagoon.type persistent of this name",
+				},
+				{
+					Line:     51,
+					Column:   7,
+					Service:  "mariadb",
+					Label:    "lagoon.postgres-dbaas.environment",
+					Severity: SchemaWarning,
+					Message:  "the label has no effect for the service type mariadb, the label is only used by the postgres-dbaas service type",
+				},
+				{
+					Line:     61,
+					Column:   7,
+					Service:  "external",
+					Label:    "lagoon.external.service",
+					Severity: SchemaError,
+					Message:  "the label is required for the external service type",
+				},
+				{
+					Line:       73,
+					Column:     7,
+					Volume:     "files",
+					Label:      "lagoon.backups",
+					Severity:   SchemaWarning,
+					Message:    "unknown label",
+					Suggestion: "lagoon.backup",
+				},
+				{
+					Line:     76,
+					Column:   7,
+					Volume:   "scratch",
+					Label:    "lagoon.persistent.size",
+					Severity: SchemaWarning,
+					Message:  "the label has no effect, only volumes with the lagoon.type persistent are created",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project, _, _, err := UnmarshaDockerComposeYAML(tt.file, true, true, map[string]string{})
+			if err != nil {
+				t.Fatalf("UnmarshaDockerComposeYAML() error = %v", err)
+			}
+			lYAML := &YAML{}
+			if err := UnmarshalLagoonYAML(tt.file, lYAML, ""); err != nil {
+				t.Fatalf("UnmarshalLagoonYAML() error = %v", err)
+			}
+			got, err := LintDockerComposeLabels(lYAML.DockerComposeYAML, project, tt.typeOverrides)
+			if err != nil {
+				t.Fatalf("LintDockerComposeLabels() error = %v", err)
+			}
+			for idx := range tt.want {
+				tt.want[idx].File = lYAML.DockerComposeYAML
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				gotJSON, _ := json.MarshalIndent(got, "", "  ")
+				t.Errorf("LintDockerComposeLabels() = %s", gotJSON)
+			}
+		})
+	}
+}
+
+func Test_suggestLabel(t *testing.T) {
+	tests := []struct {
+		name  string
+		label string
+		want  string
+	}{
+		{
+			name:  "typo",
+			label: "lagoon.autogenerateroute",
+			want:  "lagoon.autogeneratedroute",
+		},
+		{
+			name:  "case",
+			label: "lagoon.Persistent.Size",
+			want:  "lagoon.persistent.size",
+		},
+		{
+			name:  "no close match",
+			label: "lagoon.persistent.class",
+			want:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := suggestLabel(tt.label, serviceLabels); got != tt.want {
+				t.Errorf("suggestLabel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
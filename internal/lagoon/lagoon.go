@@ -10,6 +10,8 @@ import (
 
 	"dario.cat/mergo"
 	"github.com/uselagoon/build-deploy-tool/internal/cron"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/yaml"
 )
 
@@ -30,11 +32,68 @@ type Environment struct {
 	Overrides              map[string]Override     `json:"overrides,omitempty"`
 	AutogeneratePathRoutes []AutogeneratePathRoute `json:"autogeneratePathRoutes,omitempty"`
 	NetworkPolicies        []NetworkPolicy         `json:"network-policies,omitempty"`
+	Autoscaling            map[string]Autoscaling  `json:"autoscaling,omitempty"`
+	Redirects              []Redirect              `json:"redirects,omitempty"`
+}
+
+// Redirect is a domain served by the environment that only redirects to a target url
+type Redirect struct {
+	Domain        string `json:"domain"`
+	Target        string `json:"target"`
+	StatusCode    int    `json:"statusCode,omitempty"`
+	PreservePath  *bool  `json:"preservePath,omitempty"`
+	PreserveQuery *bool  `json:"preserveQuery,omitempty"`
+	TLSAcme       *bool  `json:"tls-acme,omitempty"`
+}
+
+// Autoscaling is the autoscaling and disruption budget configuration for a service
+type Autoscaling struct {
+	MinReplicas             *int32              `json:"minReplicas,omitempty"`
+	MaxReplicas             *int32              `json:"maxReplicas,omitempty"`
+	TargetCPUUtilization    *int32              `json:"targetCPUUtilization,omitempty"`
+	TargetMemoryUtilization *int32              `json:"targetMemoryUtilization,omitempty"`
+	MinAvailable            *intstr.IntOrString `json:"minAvailable,omitempty"`
+	MaxUnavailable          *intstr.IntOrString `json:"maxUnavailable,omitempty"`
 }
 
 type Override struct {
-	Build Build  `json:"build,omitempty"`
-	Image string `json:"image,omitempty"`
+	Build              Build               `json:"build,omitempty"`
+	Image              string              `json:"image,omitempty"`
+	Resources          *Resources          `json:"resources,omitempty"`
+	ReadinessProbe     *Probe              `json:"readinessProbe,omitempty"`
+	LivenessProbe      *Probe              `json:"livenessProbe,omitempty"`
+	StartupProbe       *Probe              `json:"startupProbe,omitempty"`
+	DeploymentStrategy *DeploymentStrategy `json:"deployment-strategy,omitempty"`
+}
+
+// DeploymentStrategy opts a service into a zero downtime rolling update, new pods are started alongside the old pods
+// and the old pods are only removed once the new pods are ready
+type DeploymentStrategy struct {
+	MaxSurge        *intstr.IntOrString `json:"maxSurge,omitempty"`
+	MaxUnavailable  *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+	MinReadySeconds *int32              `json:"minReadySeconds,omitempty"`
+}
+
+// Resources are the cpu and memory requests and limits for the container of a service
+type Resources struct {
+	Requests ResourceList `json:"requests,omitempty"`
+	Limits   ResourceList `json:"limits,omitempty"`
+}
+
+type ResourceList struct {
+	CPU    string `json:"cpu,omitempty"`
+	Memory string `json:"memory,omitempty"`
+}
+
+// Probe overrides the default probe of the container of a service. If no handler is defined the handler
+// of the default probe is kept, and any timings that aren't defined keep the value of the default probe
+type Probe struct {
+	corev1.ProbeHandler `json:",inline"`
+	InitialDelaySeconds *int32 `json:"initialDelaySeconds,omitempty"`
+	TimeoutSeconds      *int32 `json:"timeoutSeconds,omitempty"`
+	PeriodSeconds       *int32 `json:"periodSeconds,omitempty"`
+	SuccessThreshold    *int32 `json:"successThreshold,omitempty"`
+	FailureThreshold    *int32 `json:"failureThreshold,omitempty"`
 }
 
 type Build struct {
@@ -72,9 +131,11 @@ type YAML struct {
 }
 
 type ContainerRegistry struct {
+	Type     string `json:"type,omitempty"`
 	Username string `json:"username"`
 	Password string `json:"password"`
 	URL      string `json:"url"`
+	TokenURL string `json:"token-url,omitempty"`
 }
 
 type GitCredential struct {
@@ -182,8 +243,7 @@ func (a *Routes) UnmarshalJSON(data []byte) error {
 			if reflect.TypeOf(value.(map[string]interface{})["tls-acme"]).Kind() == reflect.String {
 				vBool, err := strconv.ParseBool(value.(map[string]interface{})["tls-acme"].(string))
 				if err == nil {
-					// @TODO: add warning functionality here to inform that users should fix their yaml to be boolean not string
-					// this could warn in a yaml validation step at the start of builds
+					// the schema validation in `validate lagoon-yml` warns that these should be boolean not string
 					value.(map[string]interface{})["tls-acme"] = vBool
 				}
 			}
@@ -192,8 +252,7 @@ func (a *Routes) UnmarshalJSON(data []byte) error {
 			if reflect.TypeOf(value.(map[string]interface{})["enabled"]).Kind() == reflect.String {
 				vBool, err := strconv.ParseBool(value.(map[string]interface{})["enabled"].(string))
 				if err == nil {
-					// @TODO: add warning functionality here to inform that users should fix their yaml to be boolean not string
-					// this could warn in a yaml validation step at the start of builds
+					// the schema validation in `validate lagoon-yml` warns that these should be boolean not string
 					value.(map[string]interface{})["enabled"] = vBool
 				}
 			}
@@ -202,8 +261,7 @@ func (a *Routes) UnmarshalJSON(data []byte) error {
 			if reflect.TypeOf(value.(map[string]interface{})["allowPullRequests"]).Kind() == reflect.String {
 				vBool, err := strconv.ParseBool(value.(map[string]interface{})["allowPullRequests"].(string))
 				if err == nil {
-					// @TODO: add warning functionality here to inform that users should fix their yaml to be boolean not string
-					// this could warn in a yaml validation step at the start of builds
+					// the schema validation in `validate lagoon-yml` warns that these should be boolean not string
 					value.(map[string]interface{})["allowPullRequests"] = vBool
 				}
 			}
@@ -225,8 +283,7 @@ func (a *EnvironmentVariables) UnmarshalJSON(data []byte) error {
 		if reflect.TypeOf(value).Kind() == reflect.String {
 			vBool, err := strconv.ParseBool(value.(string))
 			if err == nil {
-				// @TODO: add warning functionality here to inform that users should fix their yaml to be boolean not string
-				// this could warn in a yaml validation step at the start of builds
+				// the schema validation in `validate lagoon-yml` warns that these should be boolean not string
 				value = vBool
 			}
 		}
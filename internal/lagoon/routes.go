@@ -43,6 +43,15 @@ type RouteV2 struct {
 	Source                string            `json:"source,omitempty"`
 	Primary               *bool             `json:"primary,omitempty"`
 	Type                  RouteType         `json:"type,omitempty"`
+	Redirect              *RouteRedirect    `json:"redirect,omitempty"`
+}
+
+// RouteRedirect is the redirect a route performs instead of sending traffic to a service
+type RouteRedirect struct {
+	Target        string `json:"target"`
+	StatusCode    int    `json:"statusCode"`
+	PreservePath  bool   `json:"preservePath"`
+	PreserveQuery bool   `json:"preserveQuery"`
 }
 
 // @TODO: Update machinery schema at some point
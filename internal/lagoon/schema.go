@@ -0,0 +1,353 @@
+package lagoon
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+	yamlv3 "gopkg.in/yaml.v3"
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed lagoon.schema.json
+var lagoonYAMLSchema []byte
+
+// Schema returns the JSON schema for the .lagoon.yml file
+func Schema() []byte {
+	return lagoonYAMLSchema
+}
+
+// the severity of a schema issue
+const (
+	SchemaError   = "error"
+	SchemaWarning = "warning"
+)
+
+// SchemaIssue is a problem found when validating a .lagoon.yml file against the schema
+type SchemaIssue struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Path     string `json:"path"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (i SchemaIssue) String() string {
+	path := i.Path
+	if path == "" {
+		path = "(root)"
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s: %s", i.File, i.Line, i.Column, i.Severity, path, i.Message)
+}
+
+// SchemaIssues is the result of validating a .lagoon.yml file
+type SchemaIssues []SchemaIssue
+
+// Errors returns the issues that will cause a build to fail
+func (s SchemaIssues) Errors() SchemaIssues {
+	return s.filter(SchemaError)
+}
+
+// Warnings returns the issues that don't prevent a build, like unknown keys or deprecated values
+func (s SchemaIssues) Warnings() SchemaIssues {
+	return s.filter(SchemaWarning)
+}
+
+func (s SchemaIssues) filter(severity string) SchemaIssues {
+	issues := SchemaIssues{}
+	for _, i := range s {
+		if i.Severity == severity {
+			issues = append(issues, i)
+		}
+	}
+	return issues
+}
+
+// ValidateLagoonYAMLSchema validates a .lagoon.yml file against the schema.
+// The top level key with the name of the project is validated as a polysite project block, other unknown top level keys are warnings.
+func ValidateLagoonYAMLSchema(file, project string) (SchemaIssues, error) {
+	rawYAML, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read %v: %v", file, err)
+	}
+	return ValidateLagoonYAMLSchemaBytes(file, rawYAML, project)
+}
+
+// ValidateLagoonYAMLSchemaBytes validates the contents of a .lagoon.yml file against the schema, the name is used in the issues
+func ValidateLagoonYAMLSchemaBytes(name string, rawYAML []byte, project string) (SchemaIssues, error) {
+	// the yaml is converted to json the same way it is when it is unmarshalled for a build
+	// so that the types are the same as the build would see them
+	rawJSON, err := yaml.YAMLToJSON(rawYAML)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse %v: %v", name, err)
+	}
+	// the yaml nodes are only used to find the line and column of an issue
+	root := &yamlv3.Node{}
+	if err := yamlv3.Unmarshal(rawYAML, root); err != nil {
+		return nil, fmt.Errorf("couldn't parse %v: %v", name, err)
+	}
+	schema := map[string]interface{}{}
+	if err := json.Unmarshal(lagoonYAMLSchema, &schema); err != nil {
+		return nil, fmt.Errorf("couldn't read lagoon.yml schema: %v", err)
+	}
+	var document interface{}
+	if err := json.Unmarshal(rawJSON, &document); err != nil {
+		return nil, fmt.Errorf("couldn't parse %v: %v", name, err)
+	}
+	if document == nil {
+		// an empty file is valid
+		return SchemaIssues{}, nil
+	}
+	rootProperties, _ := schema["properties"].(map[string]interface{})
+	if project != "" && rootProperties[project] == nil {
+		// only the block with the name of the project is a polysite block, any other unknown top level keys are warned about
+		rootProperties[project] = map[string]interface{}{"$ref": "#/definitions/polysite"}
+	}
+	result, err := gojsonschema.Validate(gojsonschema.NewGoLoader(schema), gojsonschema.NewGoLoader(document))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't validate %v: %v", name, err)
+	}
+
+	issues := SchemaIssues{}
+	for _, resultErr := range result.Errors() {
+		// the context is split on a character that can't be in a key, as keys like domains contain dots
+		path := strings.Split(resultErr.Context().String("\x00"), "\x00")[1:]
+		issue := SchemaIssue{
+			File:     name,
+			Severity: SchemaError,
+			// the descriptions of some errors start with the field, which is already in the path
+			Message: strings.TrimPrefix(resultErr.Description(), resultErr.Field()+" "),
+		}
+		switch resultErr.Type() {
+		case "invalid_type":
+			if resultErr.Details()["given"] == "null" {
+				// empty values are the same as not setting the key
+				continue
+			}
+		case "number_one_of", "number_any_of":
+			if hasNestedError(result.Errors(), path) {
+				// the errors from the closest matching schema are more useful
+				continue
+			}
+		case "additional_property_not_allowed":
+			// unknown keys are ignored by builds, so they are only a warning
+			property := fmt.Sprintf("%v", resultErr.Details()["property"])
+			path = append(path, property)
+			issue.Severity = SchemaWarning
+			issue.Message = fmt.Sprintf("unknown key %s", property)
+		}
+		issue.Path = strings.Join(path, ".")
+		issue.Line, issue.Column = nodePosition(root, path, issue.Severity == SchemaWarning)
+		issues = append(issues, issue)
+	}
+	// deprecated values are valid, so they have to be found separately
+	definitions, _ := schema["definitions"].(map[string]interface{})
+	findDeprecated(document, schema, definitions, nil, func(path []string, message string) {
+		line, column := nodePosition(root, path, false)
+		issues = append(issues, SchemaIssue{
+			File:     name,
+			Line:     line,
+			Column:   column,
+			Path:     strings.Join(path, "."),
+			Severity: SchemaWarning,
+			Message:  fmt.Sprintf("deprecated: %s", message),
+		})
+	})
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Column < issues[j].Column
+	})
+	return issues, nil
+}
+
+// hasNestedError checks if there are any errors below the path
+func hasNestedError(resultErrs []gojsonschema.ResultError, path []string) bool {
+	prefix := strings.Join(append([]string{"(root)"}, path...), "\x00") + "\x00"
+	for _, resultErr := range resultErrs {
+		if strings.HasPrefix(resultErr.Context().String("\x00"), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// findDeprecated walks the document with the schema and calls deprecated for any value that matches a deprecated schema
+func findDeprecated(value interface{}, schema, definitions map[string]interface{}, path []string, deprecated func([]string, string)) {
+	schema = resolveRef(schema, definitions)
+	if schema == nil {
+		return
+	}
+	if d, _ := schema["deprecated"].(bool); d {
+		message, _ := schema["deprecationMessage"].(string)
+		deprecated(path, message)
+		return
+	}
+	for _, keyword := range []string{"oneOf", "anyOf"} {
+		branches, ok := schema[keyword].([]interface{})
+		if !ok {
+			continue
+		}
+		// descend into the first branch that has the type of the value
+		for _, branch := range branches {
+			b := resolveRef(branch.(map[string]interface{}), definitions)
+			if matchesType(value, b["type"]) {
+				findDeprecated(value, b, definitions, path, deprecated)
+				break
+			}
+		}
+		return
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		keys := []string{}
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			keyPath := append(append([]string{}, path...), key)
+			if property, ok := properties[key].(map[string]interface{}); ok {
+				findDeprecated(v[key], property, definitions, keyPath, deprecated)
+			} else if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+				findDeprecated(v[key], additional, definitions, keyPath, deprecated)
+			}
+		}
+	case []interface{}:
+		items, ok := schema["items"].(map[string]interface{})
+		if !ok {
+			return
+		}
+		for idx, item := range v {
+			findDeprecated(item, items, definitions, append(append([]string{}, path...), strconv.Itoa(idx)), deprecated)
+		}
+	}
+}
+
+// resolveRef returns the definition a schema refers to, only local definitions are supported
+func resolveRef(schema, definitions map[string]interface{}) map[string]interface{} {
+	for {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			return schema
+		}
+		schema, _ = definitions[strings.TrimPrefix(ref, "#/definitions/")].(map[string]interface{})
+		if schema == nil {
+			return nil
+		}
+	}
+}
+
+// matchesType checks if a value is one of the types of a schema, a schema without a type matches any value
+func matchesType(value interface{}, schemaType interface{}) bool {
+	types := []string{}
+	switch t := schemaType.(type) {
+	case nil:
+		return true
+	case string:
+		types = append(types, t)
+	case []interface{}:
+		for _, s := range t {
+			types = append(types, fmt.Sprintf("%v", s))
+		}
+	}
+	for _, t := range types {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case nil:
+			if t == "null" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nodePosition returns the line and column of the value at the path, or of its key if key is true.
+// If the path can't be found, the position of the closest parent is returned
+func nodePosition(root *yamlv3.Node, path []string, key bool) (int, int) {
+	node := root
+	if node.Kind == yamlv3.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	line, column := node.Line, node.Column
+	for idx, segment := range path {
+		keyNode, valueNode := childNode(node, segment)
+		if valueNode == nil {
+			break
+		}
+		node = valueNode
+		line, column = valueNode.Line, valueNode.Column
+		if key && keyNode != nil && idx == len(path)-1 {
+			line, column = keyNode.Line, keyNode.Column
+		}
+	}
+	return line, column
+}
+
+// childNode returns the key and value nodes of a mapping key or sequence index
+func childNode(node *yamlv3.Node, segment string) (*yamlv3.Node, *yamlv3.Node) {
+	for node.Kind == yamlv3.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		var merged []*yamlv3.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == segment {
+				return node.Content[i], node.Content[i+1]
+			}
+			if node.Content[i].Value == "<<" {
+				merged = append(merged, node.Content[i+1])
+			}
+		}
+		// keys can also come from a merged anchor
+		for _, m := range merged {
+			if m.Kind == yamlv3.SequenceNode {
+				for _, s := range m.Content {
+					if k, v := childNode(s, segment); v != nil {
+						return k, v
+					}
+				}
+				continue
+			}
+			if k, v := childNode(m, segment); v != nil {
+				return k, v
+			}
+		}
+	case yamlv3.SequenceNode:
+		idx, err := strconv.Atoi(segment)
+		if err == nil && idx >= 0 && idx < len(node.Content) {
+			return nil, node.Content[idx]
+		}
+	}
+	return nil, nil
+}
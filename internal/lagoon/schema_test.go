@@ -0,0 +1,189 @@
+package lagoon
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestValidateLagoonYAMLSchema(t *testing.T) {
+	type args struct {
+		file    string
+		project string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    SchemaIssues
+		wantErr bool
+	}{
+		{
+			name: "valid with anchors",
+			args: args{
+				file: "internal/lagoon/test-resources/lagoon-yaml-schema/valid.yml",
+			},
+			want: SchemaIssues{},
+		},
+		{
+			name: "errors and warnings",
+			args: args{
+				file: "internal/lagoon/test-resources/lagoon-yaml-schema/invalid.yml",
+			},
+			want: SchemaIssues{
+				{
+					Line:     4,
+					Column:   12,
+					Path:     "environment_variables.git_sha",
+					Severity: SchemaWarning,
+					Message:  "deprecated: boolean values should not be quoted",
+				},
+				{
+					Line:     9,
+					Column:   14,
+					Path:     "routes.autogenerate.tlsAcme",
+					Severity: SchemaWarning,
+					Message:  "deprecated: use tls-acme instead",
+				},
+				{
+					Line:     10,
+					Column:   15,
+					Path:     "routes.autogenerate.insecure",
+					Severity: SchemaError,
+					Message:  `must be one of the following: "Allow", "Redirect", "None"`,
+				},
+				{
+					Line:     18,
+					Column:   17,
+					Path:     "tasks.post-rollout.0.run.weight",
+					Severity: SchemaError,
+					Message:  "Invalid type. Expected: integer, given: string",
+				},
+				{
+					Line:     25,
+					Column:   23,
+					Path:     "environments.main.routes.0.nginx.0.example.com.tls-acme",
+					Severity: SchemaWarning,
+					Message:  "deprecated: boolean values should not be quoted",
+				},
+				{
+					Line:     26,
+					Column:   13,
+					Path:     "environments.main.routes.0.nginx.0.example.com.hsts",
+					Severity: SchemaWarning,
+					Message:  "unknown key hsts",
+				},
+				{
+					Line:     33,
+					Column:   16,
+					Path:     "environments.main.cronjobs.0.inPod",
+					Severity: SchemaError,
+					Message:  "Invalid type. Expected: boolean, given: string",
+				},
+			},
+		},
+		{
+			name: "polysite",
+			args: args{
+				file:    "internal/lagoon/test-resources/lagoon-yaml-schema/polysite.yml",
+				project: "example-project",
+			},
+			want: SchemaIssues{
+				{
+					Line:     7,
+					Column:   1,
+					Path:     "other-project",
+					Severity: SchemaWarning,
+					Message:  "unknown key other-project",
+				},
+				{
+					Line:     16,
+					Column:   1,
+					Path:     "docker-compose-yml",
+					Severity: SchemaWarning,
+					Message:  "unknown key docker-compose-yml",
+				},
+			},
+		},
+		{
+			name: "polysite without a project",
+			args: args{
+				file: "internal/lagoon/test-resources/lagoon-yaml-schema/polysite.yml",
+			},
+			want: SchemaIssues{
+				{
+					Line:     1,
+					Column:   1,
+					Path:     "example-project",
+					Severity: SchemaWarning,
+					Message:  "unknown key example-project",
+				},
+				{
+					Line:     7,
+					Column:   1,
+					Path:     "other-project",
+					Severity: SchemaWarning,
+					Message:  "unknown key other-project",
+				},
+				{
+					Line:     16,
+					Column:   1,
+					Path:     "docker-compose-yml",
+					Severity: SchemaWarning,
+					Message:  "unknown key docker-compose-yml",
+				},
+			},
+		},
+		{
+			name: "invalid yaml",
+			args: args{
+				file: "internal/testdata/validate-lagoon-yml/test6/lagoon-override.yml",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateLagoonYAMLSchema(tt.args.file, tt.args.project)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLagoonYAMLSchema() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			for idx := range tt.want {
+				tt.want[idx].File = tt.args.file
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				gotJSON, _ := json.MarshalIndent(got, "", "  ")
+				t.Errorf("ValidateLagoonYAMLSchema() = %s", gotJSON)
+			}
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	schema := map[string]interface{}{}
+	if err := json.Unmarshal(Schema(), &schema); err != nil {
+		t.Fatalf("schema is not valid json: %v", err)
+	}
+	definitions := schema["definitions"].(map[string]interface{})
+	// every reference in the schema must point to a definition
+	var check func(v interface{})
+	check = func(v interface{}) {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := value["$ref"].(string); ok && resolveRef(value, definitions) == nil {
+				t.Errorf("schema reference %s has no definition", ref)
+			}
+			for _, child := range value {
+				check(child)
+			}
+		case []interface{}:
+			for _, child := range value {
+				check(child)
+			}
+		}
+	}
+	check(schema)
+}
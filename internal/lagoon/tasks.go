@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/uselagoon/build-deploy-tool/internal/buildlog"
 	"github.com/uselagoon/build-deploy-tool/internal/helpers"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -17,6 +18,7 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
 )
 
 var debug bool
@@ -34,6 +36,73 @@ type Task struct {
 	ScaleWaitTime       int    `json:"scaleWaitTime"`
 	ScaleMaxIterations  int    `json:"scaleMaxIterations"`
 	RequiresEnvironment bool   `json:"requiresEnvironment"`
+	Timeout             string `json:"timeout,omitempty"`
+	Retries             int    `json:"retries,omitempty"`
+	RetryDelay          string `json:"retryDelay,omitempty"`
+	OnFailure           string `json:"onFailure,omitempty"`
+}
+
+// what happens to the build when a task fails after all of its retries
+const (
+	TaskOnFailureFail     = "fail"
+	TaskOnFailureContinue = "continue"
+	TaskOnFailureWarn     = "warn"
+)
+
+// the exit code reported for a task that was cancelled because it ran longer than its timeout, the same as coreutils timeout
+const TaskTimeoutExitCode = 124
+
+// the delay between attempts of a task that defines retries but no retryDelay
+const defaultTaskRetryDelay = 10 * time.Second
+
+// TaskPolicy is the parsed timeout, retry and failure policy of a task
+type TaskPolicy struct {
+	Timeout    time.Duration
+	Retries    int
+	RetryDelay time.Duration
+	OnFailure  string
+}
+
+// Policy parses and validates the timeout, retries, retryDelay and onFailure fields of the task
+func (t Task) Policy() (TaskPolicy, error) {
+	policy := TaskPolicy{
+		Retries:   t.Retries,
+		OnFailure: TaskOnFailureFail,
+	}
+	if t.Timeout != "" {
+		timeout, err := time.ParseDuration(t.Timeout)
+		if err != nil {
+			return policy, fmt.Errorf("task %s has an invalid timeout %s: %v", t.Name, t.Timeout, err)
+		}
+		if timeout <= 0 {
+			return policy, fmt.Errorf("task %s has an invalid timeout %s: must be greater than 0", t.Name, t.Timeout)
+		}
+		policy.Timeout = timeout
+	}
+	if t.Retries < 0 {
+		return policy, fmt.Errorf("task %s has an invalid number of retries %d: must not be negative", t.Name, t.Retries)
+	}
+	if t.Retries > 0 {
+		policy.RetryDelay = defaultTaskRetryDelay
+	}
+	if t.RetryDelay != "" {
+		retryDelay, err := time.ParseDuration(t.RetryDelay)
+		if err != nil {
+			return policy, fmt.Errorf("task %s has an invalid retryDelay %s: %v", t.Name, t.RetryDelay, err)
+		}
+		if retryDelay < 0 {
+			return policy, fmt.Errorf("task %s has an invalid retryDelay %s: must not be negative", t.Name, t.RetryDelay)
+		}
+		policy.RetryDelay = retryDelay
+	}
+	switch t.OnFailure {
+	case "":
+	case TaskOnFailureFail, TaskOnFailureContinue, TaskOnFailureWarn:
+		policy.OnFailure = t.OnFailure
+	default:
+		return policy, fmt.Errorf("task %s has an invalid onFailure %s: must be one of %s, %s or %s", t.Name, t.OnFailure, TaskOnFailureFail, TaskOnFailureContinue, TaskOnFailureWarn)
+	}
+	return policy, nil
 }
 
 // NewTask .
@@ -62,6 +131,16 @@ func (e *PodScalingError) Error() string {
 	return e.ErrorText
 }
 
+// TaskExitError is returned when the command of a task exits with a non-zero exit code, or is cancelled by its timeout
+type TaskExitError struct {
+	ExitCode  int
+	ErrorText string
+}
+
+func (e *TaskExitError) Error() string {
+	return e.ErrorText
+}
+
 func (t Task) String() string {
 	return fmt.Sprintf("{command: '%v', ns: '%v', service: '%v', shell:'%v'}", t.Command, t.Namespace, t.Service, t.Shell)
 }
@@ -119,25 +198,80 @@ func ExecuteTaskInEnvironment(task Task, prePost string) error {
 	command = append(command, "-c")
 	command = append(command, task.Command)
 
-	fmt.Printf("##############################################\nBEGIN %s %s\n##############################################\n", prePost, task.Name)
-	st := time.Now()
+	policy, err := task.Policy()
+	if err != nil {
+		return err
+	}
+
+	step := buildlog.StartStep(fmt.Sprintf("%s %s", prePost, task.Name))
 
-	err := ExecTaskInPod(task, command, false)
+	exitCode, attempts, err := runTaskWithPolicy(context.Background(), task, policy, func(ctx context.Context) error {
+		return ExecTaskInPod(ctx, task, command, false)
+	})
+	step.Field("exitCode", strconv.Itoa(exitCode))
+	step.Field("attempts", strconv.Itoa(attempts))
 
 	if err != nil {
 		fmt.Printf("Failed to execute task `%v` due to reason `%v`\n", task.Name, err.Error())
+		step.Fail(err)
+	} else {
+		step.End()
 	}
 
-	et := time.Now()
-	diff := time.Time{}.Add(et.Sub(st))
-	tz, _ := et.Zone()
-	fmt.Printf("##############################################\nSTEP %s %s: Completed at %s (%s) Duration %s Elapsed %s\n##############################################\n", prePost, task.Name, et.Format("2006-01-02 15:04:05"), tz, diff.Format("15:04:05"), diff.Format("15:04:05"))
-
 	return err
 }
 
-// ExecTaskInPod .
+// runTaskWithPolicy runs the task until it succeeds or runs out of retries, each attempt is cancelled if it runs longer than the timeout.
+// the exit code of the last attempt and the number of attempts are returned
+func runTaskWithPolicy(ctx context.Context, task Task, policy TaskPolicy, execFn func(context.Context) error) (int, int, error) {
+	var err error
+	exitCode := 0
+	attempt := 0
+	for attempt < policy.Retries+1 {
+		attempt++
+		if attempt > 1 {
+			fmt.Printf("Retrying task `%v` in %v, attempt %d/%d\n", task.Name, policy.RetryDelay, attempt, policy.Retries+1)
+			select {
+			case <-ctx.Done():
+				// the result of the last attempt is returned if the task is cancelled while waiting to retry
+				return exitCode, attempt - 1, err
+			case <-time.After(policy.RetryDelay):
+			}
+		}
+		attemptCtx := ctx
+		cancel := func() {}
+		if policy.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, policy.Timeout)
+		}
+		err = execFn(attemptCtx)
+		timedOut := errors.Is(attemptCtx.Err(), context.DeadlineExceeded)
+		cancel()
+		if err == nil {
+			return 0, attempt, nil
+		}
+		if timedOut {
+			err = &TaskExitError{
+				ExitCode:  TaskTimeoutExitCode,
+				ErrorText: fmt.Sprintf("task %s was cancelled after reaching the timeout of %v", task.Name, policy.Timeout),
+			}
+		}
+		exitCode = 1
+		var exitErr *TaskExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode
+		}
+		// a missing deployment won't appear by retrying the task
+		var missingErr *DeploymentMissingError
+		if errors.As(err, &missingErr) {
+			break
+		}
+	}
+	return exitCode, attempt, err
+}
+
+// ExecTaskInPod executes the command in a pod of the service of the task, cancelling the context stops the command
 func ExecTaskInPod(
+	ctx context.Context,
 	task Task,
 	command []string,
 	tty bool,
@@ -157,7 +291,7 @@ func ExecTaskInPod(
 
 	lagoonServiceLabel := "lagoon.sh/service=" + task.Service
 
-	deployments, err := depClient.List(context.TODO(), v1.ListOptions{
+	deployments, err := depClient.List(ctx, v1.ListOptions{
 		LabelSelector: lagoonServiceLabel,
 	})
 	if err != nil {
@@ -180,17 +314,21 @@ func ExecTaskInPod(
 		if deployment.Status.ReadyReplicas == 0 {
 			fmt.Printf("No ready replicas found, scaling up. Attempt %d/%d\n", numIterations, task.ScaleMaxIterations)
 
-			scale, err := clientset.AppsV1().Deployments(task.Namespace).GetScale(context.TODO(), deployment.Name, v1.GetOptions{})
+			scale, err := clientset.AppsV1().Deployments(task.Namespace).GetScale(ctx, deployment.Name, v1.GetOptions{})
 			if err != nil {
 				return err
 			}
 
 			if scale.Spec.Replicas == 0 {
 				scale.Spec.Replicas = 1
-				depClient.UpdateScale(context.TODO(), deployment.Name, scale, v1.UpdateOptions{})
+				depClient.UpdateScale(ctx, deployment.Name, scale, v1.UpdateOptions{})
 			}
-			time.Sleep(time.Second * time.Duration(task.ScaleWaitTime))
-			deployment, err = depClient.Get(context.TODO(), deployment.Name, v1.GetOptions{})
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("cancelled while waiting for pods to scale for %s: %v", deployment.Name, ctx.Err())
+			case <-time.After(time.Second * time.Duration(task.ScaleWaitTime)):
+			}
+			deployment, err = depClient.Get(ctx, deployment.Name, v1.GetOptions{})
 			if err != nil {
 				return err
 			}
@@ -202,7 +340,7 @@ func ExecTaskInPod(
 	//grab pod - for now we'll copy precisely what the build script does and use the labels
 
 	podClient := clientset.CoreV1().Pods(task.Namespace)
-	clientList, err := podClient.List(context.TODO(), v1.ListOptions{
+	clientList, err := podClient.List(ctx, v1.ListOptions{
 		LabelSelector: lagoonServiceLabel,
 	})
 
@@ -269,15 +407,21 @@ func ExecTaskInPod(
 		return fmt.Errorf("error while creating Executor: %v", err)
 	}
 
-	// After replacing exec.Stream with exec.StreamWithContext, we can now pass a context to the stream
-	// this will allow us, eventually, to set limits on how long the exec can run, among other things.
-	err = exec.StreamWithContext(context.TODO(), remotecommand.StreamOptions{
+	// the stream is cancelled with the context, this is how the timeout of a task stops the command
+	err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{
 		Stdout: os.Stdout,
 		Stderr: os.Stderr,
 		Tty:    tty,
 	})
 
 	if err != nil {
+		var exitErr utilexec.ExitError
+		if errors.As(err, &exitErr) {
+			return &TaskExitError{
+				ExitCode:  exitErr.ExitStatus(),
+				ErrorText: fmt.Sprintf("Error returned: %v", err),
+			}
+		}
 		return fmt.Errorf("Error returned: %v", err)
 	}
 
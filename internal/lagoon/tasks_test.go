@@ -1,8 +1,11 @@
 package lagoon
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestNewTask(t *testing.T) {
@@ -28,3 +31,161 @@ func TestNewTask(t *testing.T) {
 		})
 	}
 }
+
+func TestTask_Policy(t *testing.T) {
+	tests := []struct {
+		name    string
+		task    Task
+		want    TaskPolicy
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			task: Task{Name: "drush cr"},
+			want: TaskPolicy{OnFailure: TaskOnFailureFail},
+		},
+		{
+			name: "retries use the default retry delay",
+			task: Task{Name: "drush cr", Retries: 2, Timeout: "5m", OnFailure: "warn"},
+			want: TaskPolicy{Timeout: 5 * time.Minute, Retries: 2, RetryDelay: 10 * time.Second, OnFailure: TaskOnFailureWarn},
+		},
+		{
+			name: "retry delay",
+			task: Task{Name: "drush cr", Retries: 1, RetryDelay: "30s", OnFailure: "continue"},
+			want: TaskPolicy{Retries: 1, RetryDelay: 30 * time.Second, OnFailure: TaskOnFailureContinue},
+		},
+		{
+			name:    "invalid timeout",
+			task:    Task{Name: "drush cr", Timeout: "5"},
+			wantErr: `task drush cr has an invalid timeout 5: time: missing unit in duration "5"`,
+		},
+		{
+			name:    "zero timeout",
+			task:    Task{Name: "drush cr", Timeout: "0s"},
+			wantErr: "task drush cr has an invalid timeout 0s: must be greater than 0",
+		},
+		{
+			name:    "negative retries",
+			task:    Task{Name: "drush cr", Retries: -1},
+			wantErr: "task drush cr has an invalid number of retries -1: must not be negative",
+		},
+		{
+			name:    "invalid on failure",
+			task:    Task{Name: "drush cr", OnFailure: "ignore"},
+			wantErr: "task drush cr has an invalid onFailure ignore: must be one of fail, continue or warn",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.task.Policy()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("Policy() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Policy() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Policy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_runTaskWithPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       TaskPolicy
+		results      []error
+		cancel       bool
+		wantExitCode int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{
+			name:         "succeeds first time",
+			policy:       TaskPolicy{Retries: 2},
+			results:      []error{nil},
+			wantExitCode: 0,
+			wantAttempts: 1,
+		},
+		{
+			name:   "succeeds after a retry",
+			policy: TaskPolicy{Retries: 2},
+			results: []error{
+				&TaskExitError{ExitCode: 1, ErrorText: "command terminated with exit code 1"},
+				nil,
+			},
+			wantExitCode: 0,
+			wantAttempts: 2,
+		},
+		{
+			name:   "fails after all retries",
+			policy: TaskPolicy{Retries: 1},
+			results: []error{
+				&TaskExitError{ExitCode: 1, ErrorText: "command terminated with exit code 1"},
+				&TaskExitError{ExitCode: 3, ErrorText: "command terminated with exit code 3"},
+			},
+			wantExitCode: 3,
+			wantAttempts: 2,
+			wantErr:      true,
+		},
+		{
+			name:         "missing deployment is not retried",
+			policy:       TaskPolicy{Retries: 3},
+			results:      []error{&DeploymentMissingError{ErrorText: "No deployments found"}},
+			wantExitCode: 1,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "timeout cancels the task",
+			policy:       TaskPolicy{Timeout: 10 * time.Millisecond},
+			results:      []error{context.DeadlineExceeded},
+			wantExitCode: TaskTimeoutExitCode,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "cancelled while waiting to retry",
+			policy:       TaskPolicy{Retries: 2, RetryDelay: time.Hour},
+			results:      []error{&TaskExitError{ExitCode: 1, ErrorText: "command terminated with exit code 1"}},
+			cancel:       true,
+			wantExitCode: 1,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			exitCode, attempts, err := runTaskWithPolicy(ctx, Task{Name: "drush cr"}, tt.policy, func(ctx context.Context) error {
+				result := tt.results[calls]
+				calls++
+				if tt.cancel {
+					cancel()
+				}
+				if errors.Is(result, context.DeadlineExceeded) {
+					// wait for the timeout to cancel the stream
+					<-ctx.Done()
+					return ctx.Err()
+				}
+				return result
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runTaskWithPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if exitCode != tt.wantExitCode {
+				t.Errorf("runTaskWithPolicy() exitCode = %v, want %v", exitCode, tt.wantExitCode)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("runTaskWithPolicy() attempts = %v, want %v", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
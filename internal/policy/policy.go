@@ -0,0 +1,301 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Severity is how a violation of a rule is treated, errors will stop the build and warnings are only reported
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Policy is the cluster policy a Lagoon administrator can mount into the build pod, every object generated by the build
+// is evaluated against the rules in the policy before any templates are written
+type Policy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule is a single named check in the policy, a rule can only define one check. If environmentTypes are defined
+// the rule is only evaluated for those environment types
+type Rule struct {
+	Name             string   `json:"name"`
+	Description      string   `json:"description,omitempty"`
+	Severity         Severity `json:"severity,omitempty"`
+	EnvironmentTypes []string `json:"environmentTypes,omitempty"`
+
+	// the maximum replicas of a deployment, or maximum replicas of a horizontalpodautoscaler
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+	// the ingress classes that ingress can use, ingress that doesn't set a class uses the cluster default and is allowed
+	AllowedIngressClasses []string `json:"allowedIngressClasses,omitempty"`
+	// hostPath volumes that are, or are below, any of these paths are not allowed
+	ForbiddenHostPaths []string `json:"forbiddenHostPaths,omitempty"`
+	// labels that every object must have, an empty value allows any value
+	RequiredLabels map[string]string `json:"requiredLabels,omitempty"`
+	// the maximum storage request of a persistentvolumeclaim
+	MaxPVCSize string `json:"maxPVCSize,omitempty"`
+
+	maxPVCSize resource.Quantity
+}
+
+// Violation is a rule that an object doesn't satisfy
+type Violation struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Kind     string   `json:"kind"`
+	Name     string   `json:"name"`
+	Message  string   `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s %s violates policy rule %s: %s", v.Severity, v.Kind, v.Name, v.Rule, v.Message)
+}
+
+// Violations is the result of evaluating a policy
+type Violations []Violation
+
+// Errors returns the violations that should stop the build
+func (v Violations) Errors() Violations {
+	return v.filter(SeverityError)
+}
+
+// Warnings returns the violations that are only reported
+func (v Violations) Warnings() Violations {
+	return v.filter(SeverityWarning)
+}
+
+func (v Violations) filter(severity Severity) Violations {
+	var result Violations
+	for _, violation := range v {
+		if violation.Severity == severity {
+			result = append(result, violation)
+		}
+	}
+	return result
+}
+
+// LoadPolicy reads and validates the policy file, if no file is provided then there is no policy to evaluate
+func LoadPolicy(file string) (*Policy, error) {
+	if file == "" {
+		return nil, nil
+	}
+	policyYAML, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read policy file %v: %v", file, err)
+	}
+	policy := &Policy{}
+	if err := yaml.UnmarshalStrict(policyYAML, policy); err != nil {
+		return nil, fmt.Errorf("couldn't unmarshal policy file %v: %v", file, err)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("policy file %v is not valid: %v", file, err)
+	}
+	return policy, nil
+}
+
+func (p *Policy) validate() error {
+	names := map[string]bool{}
+	for idx := range p.Rules {
+		rule := &p.Rules[idx]
+		if rule.Name == "" {
+			return fmt.Errorf("rule %d doesn't have a name", idx)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("rule %s is defined more than once", rule.Name)
+		}
+		names[rule.Name] = true
+		switch rule.Severity {
+		case "":
+			rule.Severity = SeverityError
+		case SeverityError, SeverityWarning:
+		default:
+			return fmt.Errorf("rule %s has severity %s, it must be one of %s or %s", rule.Name, rule.Severity, SeverityError, SeverityWarning)
+		}
+		checks := 0
+		for _, set := range []bool{
+			rule.MaxReplicas != nil,
+			len(rule.AllowedIngressClasses) > 0,
+			len(rule.ForbiddenHostPaths) > 0,
+			len(rule.RequiredLabels) > 0,
+			rule.MaxPVCSize != "",
+		} {
+			if set {
+				checks++
+			}
+		}
+		if checks != 1 {
+			return fmt.Errorf("rule %s must define exactly one of maxReplicas, allowedIngressClasses, forbiddenHostPaths, requiredLabels or maxPVCSize", rule.Name)
+		}
+		if rule.MaxReplicas != nil && *rule.MaxReplicas < 1 {
+			return fmt.Errorf("rule %s maxReplicas must be greater than 0", rule.Name)
+		}
+		for _, hostPath := range rule.ForbiddenHostPaths {
+			if !path.IsAbs(hostPath) {
+				return fmt.Errorf("rule %s forbiddenHostPaths %s must be an absolute path", rule.Name, hostPath)
+			}
+		}
+		if rule.MaxPVCSize != "" {
+			size, err := resource.ParseQuantity(rule.MaxPVCSize)
+			if err != nil {
+				return fmt.Errorf("rule %s maxPVCSize %s is not a valid resource quantity: %v", rule.Name, rule.MaxPVCSize, err)
+			}
+			rule.maxPVCSize = size
+		}
+	}
+	return nil
+}
+
+// Evaluate checks the objects against the rules of the policy that apply to the environment type
+func (p *Policy) Evaluate(environmentType string, objects ...client.Object) Violations {
+	if p == nil {
+		return nil
+	}
+	var violations Violations
+	for _, object := range objects {
+		kind := object.GetObjectKind().GroupVersionKind().Kind
+		if kind == "" {
+			kind = reflect.Indirect(reflect.ValueOf(object)).Type().Name()
+		}
+		for _, rule := range p.Rules {
+			if len(rule.EnvironmentTypes) > 0 && !contains(rule.EnvironmentTypes, environmentType) {
+				continue
+			}
+			for _, message := range rule.evaluate(object) {
+				violations = append(violations, Violation{
+					Rule:     rule.Name,
+					Severity: rule.Severity,
+					Kind:     kind,
+					Name:     object.GetName(),
+					Message:  message,
+				})
+			}
+		}
+	}
+	return violations
+}
+
+func (r Rule) evaluate(object client.Object) []string {
+	var messages []string
+	switch {
+	case r.MaxReplicas != nil:
+		replicas := int32(-1)
+		switch o := object.(type) {
+		case *appsv1.Deployment:
+			replicas = 1
+			if o.Spec.Replicas != nil {
+				replicas = *o.Spec.Replicas
+			}
+		case *autoscalingv2.HorizontalPodAutoscaler:
+			replicas = o.Spec.MaxReplicas
+		}
+		if replicas > *r.MaxReplicas {
+			messages = append(messages, fmt.Sprintf("replicas %d is more than the maximum of %d", replicas, *r.MaxReplicas))
+		}
+	case len(r.AllowedIngressClasses) > 0:
+		if ingress, ok := object.(*networkv1.Ingress); ok {
+			class := ingress.Annotations["kubernetes.io/ingress.class"]
+			if ingress.Spec.IngressClassName != nil {
+				class = *ingress.Spec.IngressClassName
+			}
+			if class != "" && !contains(r.AllowedIngressClasses, class) {
+				messages = append(messages, fmt.Sprintf("ingress class %s is not one of the allowed classes %s", class, strings.Join(r.AllowedIngressClasses, ", ")))
+			}
+		}
+	case len(r.ForbiddenHostPaths) > 0:
+		if spec := podSpec(object); spec != nil {
+			for _, volume := range spec.Volumes {
+				if volume.HostPath == nil {
+					continue
+				}
+				for _, forbidden := range r.ForbiddenHostPaths {
+					if isBelow(volume.HostPath.Path, forbidden) {
+						messages = append(messages, fmt.Sprintf("volume %s uses hostPath %s which is not allowed", volume.Name, volume.HostPath.Path))
+						break
+					}
+				}
+			}
+		}
+	case len(r.RequiredLabels) > 0:
+		labels := object.GetLabels()
+		for _, key := range sortedKeys(r.RequiredLabels) {
+			value, ok := labels[key]
+			switch {
+			case !ok:
+				messages = append(messages, fmt.Sprintf("label %s is required", key))
+			case r.RequiredLabels[key] != "" && value != r.RequiredLabels[key]:
+				messages = append(messages, fmt.Sprintf("label %s is %s, it must be %s", key, value, r.RequiredLabels[key]))
+			}
+		}
+	case r.MaxPVCSize != "":
+		if pvc, ok := object.(*corev1.PersistentVolumeClaim); ok {
+			if size, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok && size.Cmp(r.maxPVCSize) > 0 {
+				messages = append(messages, fmt.Sprintf("storage request %s is more than the maximum of %s", size.String(), r.MaxPVCSize))
+			}
+		}
+	}
+	return messages
+}
+
+// podSpec returns the pod spec of any object that creates pods
+func podSpec(object client.Object) *corev1.PodSpec {
+	switch o := object.(type) {
+	case *corev1.Pod:
+		return &o.Spec
+	case *appsv1.Deployment:
+		return &o.Spec.Template.Spec
+	case *appsv1.StatefulSet:
+		return &o.Spec.Template.Spec
+	case *appsv1.DaemonSet:
+		return &o.Spec.Template.Spec
+	case *batchv1.Job:
+		return &o.Spec.Template.Spec
+	case *batchv1.CronJob:
+		return &o.Spec.JobTemplate.Spec.Template.Spec
+	case *k8upv1.PreBackupPod:
+		if o.Spec.Pod != nil {
+			return &o.Spec.Pod.Spec
+		}
+	}
+	return nil
+}
+
+// isBelow checks if the path is the same as, or is below, the parent path
+func isBelow(p, parent string) bool {
+	p, parent = path.Clean(p), path.Clean(parent)
+	return p == parent || parent == "/" || strings.HasPrefix(p, parent+"/")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,213 @@
+package policy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	// changes the testing to source from root so paths to test resources must be defined from repo root
+	_ "github.com/uselagoon/build-deploy-tool/internal/testing"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    int
+		wantErr string
+	}{
+		{
+			name: "no-policy",
+			file: "",
+			want: 0,
+		},
+		{
+			name: "policy",
+			file: "internal/policy/test-resources/policy.yml",
+			want: 5,
+		},
+		{
+			name:    "multiple-checks",
+			file:    "internal/policy/test-resources/policy-multiple-checks.yml",
+			wantErr: "rule too-many must define exactly one of maxReplicas, allowedIngressClasses, forbiddenHostPaths, requiredLabels or maxPVCSize",
+		},
+		{
+			name:    "bad-severity",
+			file:    "internal/policy/test-resources/policy-bad-severity.yml",
+			wantErr: "rule replicas has severity fatal, it must be one of error or warning",
+		},
+		{
+			name:    "unknown-field",
+			file:    "internal/policy/test-resources/policy-unknown-field.yml",
+			wantErr: `unknown field "maxReplica"`,
+		},
+		{
+			name:    "bad-size",
+			file:    "internal/policy/test-resources/policy-bad-size.yml",
+			wantErr: "rule pvc-size maxPVCSize ten gigabytes is not a valid resource quantity",
+		},
+		{
+			name:    "missing-file",
+			file:    "internal/policy/test-resources/policy-missing.yml",
+			wantErr: "couldn't read policy file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadPolicy(tt.file)
+			if (err != nil) != (tt.wantErr != "") {
+				t.Fatalf("LoadPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadPolicy() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			rules := 0
+			if got != nil {
+				rules = len(got.Rules)
+			}
+			if rules != tt.want {
+				t.Errorf("LoadPolicy() rules = %v, want %v", rules, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_Evaluate(t *testing.T) {
+	labels := map[string]string{
+		"lagoon.sh/project":   "example-project",
+		"lagoon.sh/buildType": "branch",
+	}
+	deployment := &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx", Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: helpers.Int32Ptr(3),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{
+						{
+							Name: "docker-socket",
+							VolumeSource: corev1.VolumeSource{
+								HostPath: &corev1.HostPathVolumeSource{Path: "/var/run/docker.sock"},
+							},
+						},
+						{
+							Name: "varrun",
+							VolumeSource: corev1.VolumeSource{
+								HostPath: &corev1.HostPathVolumeSource{Path: "/varrun"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx", Labels: labels},
+		Spec:       autoscalingv2.HorizontalPodAutoscalerSpec{MaxReplicas: 2},
+	}
+	cronjob := &batchv1.CronJob{
+		TypeMeta:   metav1.TypeMeta{Kind: "CronJob", APIVersion: "batch/v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: "cronjob-cli-drush-cron", Labels: map[string]string{"lagoon.sh/buildType": "pullrequest"}},
+		Spec: batchv1.CronJobSpec{
+			JobTemplate: batchv1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Volumes: []corev1.Volume{
+								{
+									Name: "etc",
+									VolumeSource: corev1.VolumeSource{
+										HostPath: &corev1.HostPathVolumeSource{Path: "/etc/"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	ingress := &networkv1.Ingress{
+		TypeMeta:   metav1.TypeMeta{Kind: "Ingress", APIVersion: "networking.k8s.io/v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: "example.com", Labels: labels},
+		Spec:       networkv1.IngressSpec{IngressClassName: helpers.StrPtr("traefik")},
+	}
+	defaultIngress := &networkv1.Ingress{
+		TypeMeta:   metav1.TypeMeta{Kind: "Ingress", APIVersion: "networking.k8s.io/v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: "default.example.com", Labels: labels},
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		TypeMeta:   metav1.TypeMeta{Kind: "PersistentVolumeClaim", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx", Labels: labels},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse("20Gi"),
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name            string
+		environmentType string
+		objects         []client.Object
+		want            Violations
+	}{
+		{
+			name:            "development",
+			environmentType: "development",
+			objects:         []client.Object{deployment, hpa, cronjob, ingress, defaultIngress, pvc},
+			want: Violations{
+				{Rule: "development-replicas", Severity: SeverityError, Kind: "Deployment", Name: "nginx", Message: "replicas 3 is more than the maximum of 2"},
+				{Rule: "no-host-paths", Severity: SeverityError, Kind: "Deployment", Name: "nginx", Message: "volume docker-socket uses hostPath /var/run/docker.sock which is not allowed"},
+				{Rule: "no-host-paths", Severity: SeverityError, Kind: "CronJob", Name: "cronjob-cli-drush-cron", Message: "volume etc uses hostPath /etc/ which is not allowed"},
+				{Rule: "project-label", Severity: SeverityWarning, Kind: "CronJob", Name: "cronjob-cli-drush-cron", Message: "label lagoon.sh/buildType is pullrequest, it must be branch"},
+				{Rule: "project-label", Severity: SeverityWarning, Kind: "CronJob", Name: "cronjob-cli-drush-cron", Message: "label lagoon.sh/project is required"},
+				{Rule: "ingress-classes", Severity: SeverityError, Kind: "Ingress", Name: "example.com", Message: "ingress class traefik is not one of the allowed classes nginx"},
+				{Rule: "development-pvc-size", Severity: SeverityError, Kind: "PersistentVolumeClaim", Name: "nginx", Message: "storage request 20Gi is more than the maximum of 10Gi"},
+			},
+		},
+		{
+			name:            "production",
+			environmentType: "production",
+			objects:         []client.Object{deployment, hpa, ingress, pvc},
+			want: Violations{
+				{Rule: "no-host-paths", Severity: SeverityError, Kind: "Deployment", Name: "nginx", Message: "volume docker-socket uses hostPath /var/run/docker.sock which is not allowed"},
+				{Rule: "ingress-classes", Severity: SeverityError, Kind: "Ingress", Name: "example.com", Message: "ingress class traefik is not one of the allowed classes nginx"},
+			},
+		},
+	}
+	policy, err := LoadPolicy("internal/policy/test-resources/policy.yml")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := policy.Evaluate(tt.environmentType, tt.objects...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_EvaluateNoPolicy(t *testing.T) {
+	var policy *Policy
+	if got := policy.Evaluate("production", &appsv1.Deployment{}); got != nil {
+		t.Errorf("Evaluate() = %v, want nil", got)
+	}
+}
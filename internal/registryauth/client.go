@@ -0,0 +1,250 @@
+package registryauth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"time"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+)
+
+const (
+	// TypeStatic uses the username and password as they are provided
+	TypeStatic = "static"
+	// TypeTokenExchange exchanges the username and password for a short lived token at a token endpoint
+	TypeTokenExchange = "token-exchange"
+)
+
+// Registry is the configuration of a container registry that a provider uses to get the credentials
+type Registry struct {
+	Name     string
+	URL      string
+	Username string
+	Password string
+	TokenURL string
+}
+
+// Credentials are used to log in to a container registry, providers that issue short lived tokens will set when the credentials expire
+type Credentials struct {
+	Username  string
+	Password  string
+	ExpiresAt *time.Time
+}
+
+// Provider gets the credentials used to log in to a container registry
+type Provider interface {
+	Credentials(registry Registry) (*Credentials, error)
+}
+
+type Client struct {
+	HTTPClient   *retryablehttp.Client
+	RetryMax     int
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+	Timeout      time.Duration
+	providers    map[string]Provider
+}
+
+func NewClient(c Client) *Client {
+	httpClient := retryablehttp.NewClient()
+	// set up the default retries
+	httpClient.RetryMax = 5
+	if c.RetryMax > 0 {
+		httpClient.RetryMax = c.RetryMax
+	}
+	// set the default retry wait minimum to 1s
+	httpClient.RetryWaitMin = time.Duration(1000) * time.Millisecond
+	if c.RetryWaitMin > 0 {
+		httpClient.RetryWaitMin = c.RetryWaitMin
+	}
+	// set the default retry wait maximum to 5s
+	httpClient.RetryWaitMax = time.Duration(5000) * time.Millisecond
+	if c.RetryWaitMax > 0 {
+		httpClient.RetryWaitMax = c.RetryWaitMax
+	}
+	// set the http client timeout to 10s
+	httpClient.HTTPClient.Timeout = time.Duration(10000) * time.Millisecond
+	if c.Timeout > 0 {
+		httpClient.HTTPClient.Timeout = c.Timeout
+	}
+	// disable the retryablehttp client logger
+	httpClient.Logger = nil
+	c.HTTPClient = httpClient
+	c.providers = map[string]Provider{
+		TypeStatic:        &StaticProvider{},
+		TypeTokenExchange: &TokenExchangeProvider{HTTPClient: httpClient},
+	}
+	return &c
+}
+
+// RegisterProvider adds a provider for a registry type, or replaces the provider of an existing type
+func (c *Client) RegisterProvider(registryType string, provider Provider) {
+	c.providers[registryType] = provider
+}
+
+// Types returns the registry types that have a provider
+func (c *Client) Types() []string {
+	types := []string{}
+	for registryType := range c.providers {
+		types = append(types, registryType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Supports returns if the registry type has a provider
+func (c *Client) Supports(registryType string) bool {
+	_, ok := c.providers[registryType]
+	return ok
+}
+
+// Credentials gets the credentials of a registry from the provider of the registry type
+func (c *Client) Credentials(registryType string, registry Registry) (*Credentials, error) {
+	provider, ok := c.providers[registryType]
+	if !ok {
+		return nil, fmt.Errorf("container registry %s type %s is not supported, must be one of %s", registry.Name, registryType, strings.Join(c.Types(), ", "))
+	}
+	credentials, err := provider.Credentials(registry)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get credentials for container registry %s: %v", registry.Name, err)
+	}
+	return credentials, nil
+}
+
+// StaticProvider returns the username and password of the registry as the credentials
+type StaticProvider struct{}
+
+func (p *StaticProvider) Credentials(registry Registry) (*Credentials, error) {
+	return &Credentials{
+		Username: registry.Username,
+		Password: registry.Password,
+	}, nil
+}
+
+// TokenExchangeProvider exchanges the username and password of the registry for a short lived token
+// by sending them as basic auth to the token url of the registry
+type TokenExchangeProvider struct {
+	HTTPClient *retryablehttp.Client
+}
+
+type tokenRequest struct {
+	Registry string `json:"registry"`
+}
+
+// the token endpoint can respond with a token and an optional username, or an ecr style authorization token
+// that is the base64 encoded `username:password`. The expiry is either a timestamp or the seconds the token is valid for
+type tokenResponse struct {
+	Username           string `json:"username"`
+	Token              string `json:"token"`
+	AuthorizationToken string `json:"authorizationToken"`
+	ExpiresAt          string `json:"expiresAt"`
+	ExpiresIn          int64  `json:"expiresIn"`
+	Error              string `json:"error"`
+}
+
+func (p *TokenExchangeProvider) Credentials(registry Registry) (*Credentials, error) {
+	if registry.TokenURL == "" {
+		return nil, fmt.Errorf("no token-url defined")
+	}
+	body, err := json.Marshal(tokenRequest{Registry: registry.URL})
+	if err != nil {
+		return nil, err
+	}
+	req, err := retryablehttp.NewRequest(http.MethodPost, registry.TokenURL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(registry.Username, registry.Password)
+	requested := time.Now().UTC()
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	response := new(tokenResponse)
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return nil, fmt.Errorf("token endpoint responded with status %d, but response is not a valid JSON payload", resp.StatusCode)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("token endpoint responded with status %d: %s", resp.StatusCode, response.Error)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint responded with status %d", resp.StatusCode)
+	}
+
+	credentials := &Credentials{
+		Username: registry.Username,
+		Password: response.Token,
+	}
+	if response.Username != "" {
+		credentials.Username = response.Username
+	}
+	if response.AuthorizationToken != "" {
+		decoded, err := base64.StdEncoding.DecodeString(response.AuthorizationToken)
+		if err != nil {
+			return nil, fmt.Errorf("token endpoint responded with an authorizationToken that is not valid base64")
+		}
+		username, password, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return nil, fmt.Errorf("token endpoint responded with an authorizationToken that is not a username:password pair")
+		}
+		credentials.Username, credentials.Password = username, password
+	}
+	if credentials.Password == "" {
+		return nil, fmt.Errorf("token endpoint responded without a token")
+	}
+	if credentials.Username == "" {
+		return nil, fmt.Errorf("token endpoint responded without a username, and no username is defined")
+	}
+	switch {
+	case response.ExpiresAt != "":
+		expiresAt, err := time.Parse(time.RFC3339, response.ExpiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("token endpoint responded with an expiresAt that is not an RFC3339 timestamp: %v", err)
+		}
+		expiresAt = expiresAt.UTC()
+		credentials.ExpiresAt = &expiresAt
+	case response.ExpiresIn > 0:
+		expiresAt := requested.Add(time.Duration(response.ExpiresIn) * time.Second).Truncate(time.Second)
+		credentials.ExpiresAt = &expiresAt
+	}
+	return credentials, nil
+}
+
+// TestTokenExchangeHTTPServer is a test server used to test token exchange responses, it only issues tokens for the
+// `registry_user` username and `REGISTRY_PASSWORD` password
+func TestTokenExchangeHTTPServer() *httptest.Server {
+	authorized := func(res http.ResponseWriter, req *http.Request) bool {
+		username, password, ok := req.BasicAuth()
+		if req.Method != http.MethodPost || !ok || username != "registry_user" || password != "REGISTRY_PASSWORD" {
+			res.WriteHeader(http.StatusUnauthorized)
+			res.Write([]byte(`{"error":"invalid credentials"}`))
+			return false
+		}
+		return true
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(res http.ResponseWriter, req *http.Request) {
+		if authorized(res, req) {
+			res.Write([]byte(`{"username":"oauth2accesstoken","token":"short-lived-token","expiresAt":"2030-01-01T12:00:00Z"}`))
+		}
+	})
+	mux.HandleFunc("/authorization-token", func(res http.ResponseWriter, req *http.Request) {
+		if authorized(res, req) {
+			res.Write([]byte(`{"authorizationToken":"QVdTOnNob3J0LWxpdmVkLXRva2Vu","expiresAt":"2030-01-01T12:00:00Z"}`))
+		}
+	})
+	mux.HandleFunc("/expires-in", func(res http.ResponseWriter, req *http.Request) {
+		if authorized(res, req) {
+			res.Write([]byte(`{"token":"short-lived-token","expiresIn":3600}`))
+		}
+	})
+	ts := httptest.NewServer(mux)
+	return ts
+}
@@ -0,0 +1,153 @@
+package registryauth
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClientCredentials(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	type args struct {
+		registryType string
+		username     string
+		password     string
+		tokenPath    string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *Credentials
+		wantErr string
+	}{
+		{
+			name: "test1 - static credentials",
+			args: args{
+				registryType: TypeStatic,
+				username:     "registry_user",
+				password:     "REGISTRY_PASSWORD",
+			},
+			want: &Credentials{
+				Username: "registry_user",
+				Password: "REGISTRY_PASSWORD",
+			},
+		},
+		{
+			name: "test2 - token exchange with a username and token",
+			args: args{
+				registryType: TypeTokenExchange,
+				username:     "registry_user",
+				password:     "REGISTRY_PASSWORD",
+				tokenPath:    "/token",
+			},
+			want: &Credentials{
+				Username:  "oauth2accesstoken",
+				Password:  "short-lived-token",
+				ExpiresAt: &expiresAt,
+			},
+		},
+		{
+			name: "test3 - token exchange with an authorization token",
+			args: args{
+				registryType: TypeTokenExchange,
+				username:     "registry_user",
+				password:     "REGISTRY_PASSWORD",
+				tokenPath:    "/authorization-token",
+			},
+			want: &Credentials{
+				Username:  "AWS",
+				Password:  "short-lived-token",
+				ExpiresAt: &expiresAt,
+			},
+		},
+		{
+			name: "test4 - token exchange with invalid credentials",
+			args: args{
+				registryType: TypeTokenExchange,
+				username:     "registry_user",
+				password:     "WRONG_PASSWORD",
+				tokenPath:    "/token",
+			},
+			wantErr: "couldn't get credentials for container registry my-registry: token endpoint responded with status 401: invalid credentials",
+		},
+		{
+			name: "test5 - token exchange without a token url",
+			args: args{
+				registryType: TypeTokenExchange,
+				username:     "registry_user",
+				password:     "REGISTRY_PASSWORD",
+			},
+			wantErr: "couldn't get credentials for container registry my-registry: no token-url defined",
+		},
+		{
+			name: "test6 - type that doesn't exist",
+			args: args{
+				registryType: "oidc",
+				username:     "registry_user",
+				password:     "REGISTRY_PASSWORD",
+			},
+			wantErr: "container registry my-registry type oidc is not supported, must be one of static, token-exchange",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := TestTokenExchangeHTTPServer()
+			defer ts.Close()
+			tokenURL := ""
+			if tt.args.tokenPath != "" {
+				tokenURL = ts.URL + tt.args.tokenPath
+			}
+			c := NewClient(Client{
+				RetryMax:     5,
+				RetryWaitMin: time.Duration(10) * time.Millisecond,
+				RetryWaitMax: time.Duration(50) * time.Millisecond,
+			})
+			got, err := c.Credentials(tt.args.registryType, Registry{
+				Name:     "my-registry",
+				URL:      "registry.example.com",
+				Username: tt.args.username,
+				Password: tt.args.password,
+				TokenURL: tokenURL,
+			})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("Credentials() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Credentials() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Credentials() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientCredentialsExpiresIn(t *testing.T) {
+	ts := TestTokenExchangeHTTPServer()
+	defer ts.Close()
+	c := NewClient(Client{})
+	before := time.Now().UTC().Truncate(time.Second)
+	got, err := c.Credentials(TypeTokenExchange, Registry{
+		Name:     "my-registry",
+		URL:      "registry.example.com",
+		Username: "registry_user",
+		Password: "REGISTRY_PASSWORD",
+		TokenURL: ts.URL + "/expires-in",
+	})
+	if err != nil {
+		t.Fatalf("Credentials() error = %v", err)
+	}
+	if got.Username != "registry_user" || got.Password != "short-lived-token" {
+		t.Errorf("Credentials() = %v:%v, want registry_user:short-lived-token", got.Username, got.Password)
+	}
+	if got.ExpiresAt == nil {
+		t.Fatalf("Credentials() ExpiresAt is not set")
+	}
+	if got.ExpiresAt.Before(before.Add(time.Hour)) || got.ExpiresAt.After(time.Now().UTC().Add(time.Hour)) {
+		t.Errorf("Credentials() ExpiresAt = %v, want an hour from now", got.ExpiresAt)
+	}
+}
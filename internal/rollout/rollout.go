@@ -0,0 +1,314 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	// ErrRolloutTimeout is returned when the rollouts haven't completed before the monitor timeout
+	ErrRolloutTimeout = errors.New("timed out waiting for rollouts to complete")
+	// ErrRolloutFailed is returned when a deployment reports that it has exceeded its progress deadline
+	ErrRolloutFailed = errors.New("rollout failed")
+)
+
+// the waiting reasons that are reported as problems with a container
+var containerWaitingReasons = []string{
+	"ImagePullBackOff",
+	"ErrImagePull",
+	"CrashLoopBackOff",
+	"CreateContainerConfigError",
+	"CreateContainerError",
+	"InvalidImageName",
+}
+
+// the warning event reasons that are reported as problems with a pod
+var podEventReasons = []string{
+	"Unhealthy",
+	"FailedScheduling",
+	"FailedMount",
+	"FailedAttachVolume",
+}
+
+// LogReader is used to retrieve the last lines of a containers logs
+type LogReader interface {
+	ContainerLogs(ctx context.Context, namespace, pod, container string, tailLines int64) (string, error)
+}
+
+// ClientsetLogs reads container logs using a kubernetes clientset
+type ClientsetLogs struct {
+	Clientset kubernetes.Interface
+}
+
+// ContainerLogs returns the last lines of the logs of a container
+func (c ClientsetLogs) ContainerLogs(ctx context.Context, namespace, pod, container string, tailLines int64) (string, error) {
+	logs, err := c.Clientset.CoreV1().Pods(namespace).GetLogs(pod, &corev1.PodLogOptions{
+		Container: container,
+		TailLines: &tailLines,
+	}).DoRaw(ctx)
+	if err != nil {
+		return "", err
+	}
+	return string(logs), nil
+}
+
+// Monitor watches the deployments for a set of services until they have rolled out
+type Monitor struct {
+	Client    client.Client
+	Logs      LogReader
+	Namespace string
+	Timeout   time.Duration
+	Interval  time.Duration
+	LogLines  int64
+	Out       io.Writer
+}
+
+// PodIssue is a problem detected on a pod belonging to a service
+type PodIssue struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container,omitempty"`
+	Reason    string `json:"reason"`
+	Message   string `json:"message,omitempty"`
+}
+
+func (p PodIssue) String() string {
+	name := p.Pod
+	if p.Container != "" {
+		name = fmt.Sprintf("%s/%s", p.Pod, p.Container)
+	}
+	if p.Message != "" {
+		return fmt.Sprintf("%s %s: %s", name, p.Reason, p.Message)
+	}
+	return fmt.Sprintf("%s %s", name, p.Reason)
+}
+
+// ServiceStatus is the current state of the rollout of a service
+type ServiceStatus struct {
+	Service  string     `json:"service"`
+	Complete bool       `json:"complete"`
+	Failed   bool       `json:"failed"`
+	Message  string     `json:"message"`
+	Issues   []PodIssue `json:"issues,omitempty"`
+	pods     []corev1.Pod
+}
+
+// Run will watch the deployments for the provided services until they have all rolled out, a deployment fails, or the timeout is reached.
+// Progress and any pod issues are written to the monitor output as they are detected.
+func (m *Monitor) Run(ctx context.Context, services []string) ([]ServiceStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+	statuses := make([]ServiceStatus, len(services))
+	for idx, service := range services {
+		statuses[idx] = ServiceStatus{Service: service}
+	}
+	// issues are only reported the first time they are seen
+	seen := map[string]bool{}
+	for {
+		complete := true
+		failed := false
+		for idx := range statuses {
+			if statuses[idx].Complete {
+				continue
+			}
+			previous := statuses[idx].Message
+			status, err := m.check(ctx, statuses[idx].Service)
+			if err != nil {
+				if ctx.Err() != nil {
+					break
+				}
+				return statuses, err
+			}
+			statuses[idx] = status
+			if status.Message != previous {
+				fmt.Fprintf(m.Out, "%s: %s\n", status.Service, status.Message)
+			}
+			for _, issue := range status.Issues {
+				if !seen[issue.String()] {
+					seen[issue.String()] = true
+					fmt.Fprintf(m.Out, "%s: %s\n", status.Service, issue.String())
+				}
+			}
+			if !status.Complete {
+				complete = false
+			}
+			if status.Failed {
+				failed = true
+			}
+		}
+		if complete && ctx.Err() == nil {
+			return statuses, nil
+		}
+		if failed {
+			m.report(statuses)
+			return statuses, ErrRolloutFailed
+		}
+		select {
+		case <-ctx.Done():
+			m.report(statuses)
+			return statuses, ErrRolloutTimeout
+		case <-time.After(m.Interval):
+		}
+	}
+}
+
+// check returns the current rollout status of a service
+func (m *Monitor) check(ctx context.Context, service string) (ServiceStatus, error) {
+	status := ServiceStatus{Service: service}
+	deployments := &appsv1.DeploymentList{}
+	if err := m.Client.List(ctx, deployments, client.InNamespace(m.Namespace), client.MatchingLabels{"lagoon.sh/service": service}); err != nil {
+		return status, fmt.Errorf("unable to list deployments for service %s: %v", service, err)
+	}
+	if len(deployments.Items) == 0 {
+		status.Message = "waiting for deployment to be created"
+		return status, nil
+	}
+	status.Complete = true
+	var messages []string
+	for _, deployment := range deployments.Items {
+		done, failed, message := deploymentStatus(deployment)
+		if !done {
+			status.Complete = false
+		}
+		if failed {
+			status.Failed = true
+		}
+		messages = append(messages, message)
+		if done {
+			continue
+		}
+		pods, issues, err := m.podIssues(ctx, deployment)
+		if err != nil {
+			return status, err
+		}
+		status.pods = append(status.pods, pods...)
+		status.Issues = append(status.Issues, issues...)
+	}
+	status.Message = strings.Join(messages, ", ")
+	return status, nil
+}
+
+// deploymentStatus uses the same checks as `kubectl rollout status` to determine if a deployment has rolled out
+func deploymentStatus(deployment appsv1.Deployment) (bool, bool, string) {
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		return false, false, fmt.Sprintf("waiting for deployment %q spec update to be observed", deployment.Name)
+	}
+	for _, c := range deployment.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			return false, true, fmt.Sprintf("deployment %q exceeded its progress deadline", deployment.Name)
+		}
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	if deployment.Status.UpdatedReplicas < replicas {
+		return false, false, fmt.Sprintf("waiting for deployment %q rollout to finish: %d out of %d new replicas have been updated", deployment.Name, deployment.Status.UpdatedReplicas, replicas)
+	}
+	if deployment.Status.Replicas > deployment.Status.UpdatedReplicas {
+		return false, false, fmt.Sprintf("waiting for deployment %q rollout to finish: %d old replicas are pending termination", deployment.Name, deployment.Status.Replicas-deployment.Status.UpdatedReplicas)
+	}
+	if deployment.Status.AvailableReplicas < deployment.Status.UpdatedReplicas {
+		return false, false, fmt.Sprintf("waiting for deployment %q rollout to finish: %d of %d updated replicas are available", deployment.Name, deployment.Status.AvailableReplicas, deployment.Status.UpdatedReplicas)
+	}
+	return true, false, fmt.Sprintf("deployment %q successfully rolled out", deployment.Name)
+}
+
+// podIssues returns the pods of a deployment and any problems detected from the container statuses or warning events of those pods
+func (m *Monitor) podIssues(ctx context.Context, deployment appsv1.Deployment) ([]corev1.Pod, []PodIssue, error) {
+	var issues []PodIssue
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse selector for deployment %s: %v", deployment.Name, err)
+	}
+	pods := &corev1.PodList{}
+	if err := m.Client.List(ctx, pods, client.InNamespace(m.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, nil, fmt.Errorf("unable to list pods for deployment %s: %v", deployment.Name, err)
+	}
+	podNames := map[string]bool{}
+	for _, pod := range pods.Items {
+		podNames[pod.Name] = true
+		statuses := append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...)
+		statuses = append(statuses, pod.Status.ContainerStatuses...)
+		for _, cs := range statuses {
+			if cs.State.Waiting != nil && helpers.Contains(containerWaitingReasons, cs.State.Waiting.Reason) {
+				issues = append(issues, PodIssue{
+					Pod:       pod.Name,
+					Container: cs.Name,
+					Reason:    cs.State.Waiting.Reason,
+					Message:   cs.State.Waiting.Message,
+				})
+			}
+		}
+	}
+	// only the events of the pods of the deployment are listed, the namespace can have a lot of events
+	events := &corev1.EventList{}
+	for _, pod := range pods.Items {
+		podEvents := &corev1.EventList{}
+		if err := m.Client.List(ctx, podEvents, client.InNamespace(m.Namespace), client.MatchingFields{"involvedObject.name": pod.Name}); err != nil {
+			return nil, nil, fmt.Errorf("unable to list events for pod %s: %v", pod.Name, err)
+		}
+		events.Items = append(events.Items, podEvents.Items...)
+	}
+	sort.SliceStable(events.Items, func(i, j int) bool {
+		return events.Items[i].LastTimestamp.Before(&events.Items[j].LastTimestamp)
+	})
+	for _, event := range events.Items {
+		if event.Type != corev1.EventTypeWarning || event.InvolvedObject.Kind != "Pod" || !podNames[event.InvolvedObject.Name] {
+			continue
+		}
+		if helpers.Contains(podEventReasons, event.Reason) {
+			issues = append(issues, PodIssue{
+				Pod:     event.InvolvedObject.Name,
+				Reason:  event.Reason,
+				Message: event.Message,
+			})
+		}
+	}
+	return pods.Items, issues, nil
+}
+
+// report writes the last log lines of the containers and the pod conditions of any services that didn't complete
+func (m *Monitor) report(statuses []ServiceStatus) {
+	for _, status := range statuses {
+		if status.Complete {
+			continue
+		}
+		fmt.Fprintf(m.Out, "##############################################\n")
+		fmt.Fprintf(m.Out, "Rollout for %s failed: %s\n", status.Service, status.Message)
+		if m.Logs != nil && m.LogLines > 0 {
+			// use a new context as the monitor context may have already timed out
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			for _, pod := range status.pods {
+				for _, container := range pod.Spec.Containers {
+					logs, err := m.Logs.ContainerLogs(ctx, m.Namespace, pod.Name, container.Name, m.LogLines)
+					if err != nil || logs == "" {
+						continue
+					}
+					fmt.Fprintf(m.Out, "======== %s/%s =========\n%s\n", pod.Name, container.Name, strings.TrimRight(logs, "\n"))
+				}
+			}
+			cancel()
+		}
+		for _, pod := range status.pods {
+			for _, c := range pod.Status.Conditions {
+				if c.Status == corev1.ConditionTrue {
+					continue
+				}
+				fmt.Fprintf(m.Out, "%s\t%s\t%s\t%s\n", pod.Name, pod.Status.Phase, c.Type, c.Message)
+			}
+		}
+	}
+	fmt.Fprintf(m.Out, "##############################################\n")
+}
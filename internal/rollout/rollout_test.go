@@ -0,0 +1,247 @@
+package rollout
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/k8s"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type fakeLogs struct{}
+
+func (f fakeLogs) ContainerLogs(ctx context.Context, namespace, pod, container string, tailLines int64) (string, error) {
+	return fmt.Sprintf("last %d lines of %s/%s", tailLines, pod, container), nil
+}
+
+func deployment(name string, status appsv1.DeploymentStatus) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       name,
+			Generation: 1,
+			Labels: map[string]string{
+				"lagoon.sh/service": name,
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: helpers.Int32Ptr(1),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app.kubernetes.io/instance": name},
+			},
+		},
+		Status: status,
+	}
+}
+
+func pod(name, service string, waiting *corev1.ContainerStateWaiting) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"app.kubernetes.io/instance": service},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: service, Image: "example"}},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.ContainersReady, Status: corev1.ConditionFalse, Message: "containers with unready status: [" + service + "]"},
+			},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: service, State: corev1.ContainerState{Waiting: waiting}},
+			},
+		},
+	}
+}
+
+var complete = appsv1.DeploymentStatus{
+	ObservedGeneration: 1,
+	Replicas:           1,
+	UpdatedReplicas:    1,
+	AvailableReplicas:  1,
+}
+
+var progressing = appsv1.DeploymentStatus{
+	ObservedGeneration: 1,
+	Replicas:           1,
+	UpdatedReplicas:    1,
+}
+
+func TestMonitorRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		services   []string
+		objects    []client.Object
+		wantErr    error
+		wantOutput []string
+	}{
+		{
+			name:     "rollout complete",
+			services: []string{"node", "nginx"},
+			objects: []client.Object{
+				deployment("node", complete),
+				deployment("nginx", complete),
+			},
+			wantOutput: []string{
+				"node: deployment \"node\" successfully rolled out",
+				"nginx: deployment \"nginx\" successfully rolled out",
+			},
+		},
+		{
+			name:     "image pull backoff times out",
+			services: []string{"node"},
+			objects: []client.Object{
+				deployment("node", progressing),
+				pod("node-abcd", "node", &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image \"example\""}),
+			},
+			wantErr: ErrRolloutTimeout,
+			wantOutput: []string{
+				"node: waiting for deployment \"node\" rollout to finish: 0 of 1 updated replicas are available",
+				"node: node-abcd/node ImagePullBackOff: Back-off pulling image \"example\"",
+				"Rollout for node failed",
+				"======== node-abcd/node =========\nlast 20 lines of node-abcd/node",
+				"node-abcd\tPending\tContainersReady\tcontainers with unready status: [node]",
+			},
+		},
+		{
+			name:     "crashloop exceeds progress deadline",
+			services: []string{"node"},
+			objects: []client.Object{
+				deployment("node", appsv1.DeploymentStatus{
+					ObservedGeneration: 1,
+					Replicas:           1,
+					UpdatedReplicas:    1,
+					Conditions: []appsv1.DeploymentCondition{
+						{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: "ProgressDeadlineExceeded"},
+					},
+				}),
+				pod("node-abcd", "node", &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}),
+			},
+			wantErr: ErrRolloutFailed,
+			wantOutput: []string{
+				"node: deployment \"node\" exceeded its progress deadline",
+				"node: node-abcd/node CrashLoopBackOff",
+				"last 20 lines of node-abcd/node",
+			},
+		},
+		{
+			name:     "failed probes",
+			services: []string{"node"},
+			objects: []client.Object{
+				deployment("node", progressing),
+				pod("node-abcd", "node", nil),
+				&corev1.Event{
+					ObjectMeta:     metav1.ObjectMeta{Name: "node-abcd.1"},
+					InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "node-abcd"},
+					Type:           corev1.EventTypeWarning,
+					Reason:         "Unhealthy",
+					Message:        "Readiness probe failed: dial tcp 10.0.0.1:3000: connect: connection refused",
+				},
+				&corev1.Event{
+					ObjectMeta:     metav1.ObjectMeta{Name: "other.1"},
+					InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "other"},
+					Type:           corev1.EventTypeWarning,
+					Reason:         "Unhealthy",
+					Message:        "Liveness probe failed",
+				},
+			},
+			wantErr: ErrRolloutTimeout,
+			wantOutput: []string{
+				"node: node-abcd Unhealthy: Readiness probe failed: dial tcp 10.0.0.1:3000: connect: connection refused",
+			},
+		},
+		{
+			name:     "missing deployment",
+			services: []string{"node"},
+			wantErr:  ErrRolloutTimeout,
+			wantOutput: []string{
+				"node: waiting for deployment to be created",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace := "example-project-main"
+			client, err := k8s.NewFakeClient(namespace)
+			if err != nil {
+				t.Errorf("error creating fake client")
+			}
+			for _, obj := range tt.objects {
+				obj.SetNamespace(namespace)
+				if err := client.Create(context.Background(), obj); err != nil {
+					t.Errorf("error seeding fake data: %v", err)
+				}
+			}
+			out := &bytes.Buffer{}
+			m := Monitor{
+				Client:    client,
+				Logs:      fakeLogs{},
+				Namespace: namespace,
+				Timeout:   100 * time.Millisecond,
+				Interval:  10 * time.Millisecond,
+				LogLines:  20,
+				Out:       out,
+			}
+			_, err = m.Run(context.Background(), tt.services)
+			if err != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("Run() output missing %q, got:\n%s", want, out.String())
+				}
+			}
+			if strings.Contains(out.String(), "Liveness probe failed") {
+				t.Errorf("Run() output contains events for pods that don't belong to the service:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestMonitorRunProgress(t *testing.T) {
+	namespace := "example-project-main"
+	client, err := k8s.NewFakeClient(namespace)
+	if err != nil {
+		t.Errorf("error creating fake client")
+	}
+	dep := deployment("node", progressing)
+	dep.SetNamespace(namespace)
+	if err := client.Create(context.Background(), dep); err != nil {
+		t.Errorf("error seeding fake data: %v", err)
+	}
+	// mark the deployment available part way through the watch
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		dep.Status = complete
+		if err := client.Status().Update(context.Background(), dep); err != nil {
+			t.Errorf("error updating deployment: %v", err)
+		}
+	}()
+	out := &bytes.Buffer{}
+	m := Monitor{
+		Client:    client,
+		Namespace: namespace,
+		Timeout:   5 * time.Second,
+		Interval:  10 * time.Millisecond,
+		Out:       out,
+	}
+	statuses, err := m.Run(context.Background(), []string{"node"})
+	if err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+	if len(statuses) != 1 || !statuses[0].Complete {
+		t.Errorf("Run() = %v, want complete", statuses)
+	}
+	want := "node: waiting for deployment \"node\" rollout to finish: 0 of 1 updated replicas are available\nnode: deployment \"node\" successfully rolled out\n"
+	if out.String() != want {
+		t.Errorf("Run() output = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,60 @@
+package secretref
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// these are the parts of the external secrets operator ExternalSecret (external-secrets.io/v1) that the build uses
+// to sync secrets from a secret store into an existing secret
+
+// ExternalSecret is the ExternalSecret custom resource
+type ExternalSecret struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              ExternalSecretSpec `json:"spec"`
+}
+
+type ExternalSecretSpec struct {
+	RefreshInterval string               `json:"refreshInterval,omitempty"`
+	Target          ExternalSecretTarget `json:"target"`
+	Data            []ExternalSecretData `json:"data"`
+}
+
+type ExternalSecretTarget struct {
+	Name           string `json:"name"`
+	CreationPolicy string `json:"creationPolicy,omitempty"`
+}
+
+type ExternalSecretData struct {
+	SecretKey string                  `json:"secretKey"`
+	SourceRef ExternalSecretSourceRef `json:"sourceRef"`
+	RemoteRef ExternalSecretRemoteRef `json:"remoteRef"`
+}
+
+type ExternalSecretSourceRef struct {
+	StoreRef SecretStoreRef `json:"storeRef"`
+}
+
+type SecretStoreRef struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+type ExternalSecretRemoteRef struct {
+	Key      string `json:"key"`
+	Property string `json:"property,omitempty"`
+}
+
+// DeepCopyObject implements runtime.Object so the ExternalSecret can be evaluated like any other generated object
+func (in *ExternalSecret) DeepCopyObject() runtime.Object {
+	out := new(ExternalSecret)
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	if in.Spec.Data != nil {
+		out.Spec.Data = make([]ExternalSecretData, len(in.Spec.Data))
+		copy(out.Spec.Data, in.Spec.Data)
+	}
+	return out
+}
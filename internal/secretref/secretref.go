@@ -0,0 +1,332 @@
+package secretref
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// Prefix is used by variable values that are a reference to a secret in a secret store
+	Prefix = "ref+"
+	// SchemeFile references a file, or a key in a yaml or json file, that is available to the build
+	SchemeFile = "file"
+	// SchemeVault references a key of a secret in a vault kv (version 2) secrets engine
+	SchemeVault = "vault"
+)
+
+// Reference is a parsed secret reference in the format `ref+<scheme>://<path>#<key>`, the key is optional for some schemes
+type Reference struct {
+	Scheme string `json:"scheme"`
+	Path   string `json:"path"`
+	Key    string `json:"key,omitempty"`
+}
+
+func (r Reference) String() string {
+	if r.Key != "" {
+		return fmt.Sprintf("%s%s://%s#%s", Prefix, r.Scheme, r.Path, r.Key)
+	}
+	return fmt.Sprintf("%s%s://%s", Prefix, r.Scheme, r.Path)
+}
+
+// IsReference checks if a variable value uses the secret reference syntax
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// ParseReference parses a variable value that uses the secret reference syntax
+func ParseReference(value string) (*Reference, error) {
+	if !IsReference(value) {
+		return nil, fmt.Errorf("%s is not a secret reference", value)
+	}
+	scheme, path, ok := strings.Cut(strings.TrimPrefix(value, Prefix), "://")
+	if !ok || scheme == "" {
+		return nil, fmt.Errorf("secret reference must be in the format %s<scheme>://<path>#<key>", Prefix)
+	}
+	ref := &Reference{Scheme: scheme, Path: path}
+	if i := strings.LastIndex(path, "#"); i >= 0 {
+		ref.Path, ref.Key = path[:i], path[i+1:]
+	}
+	if ref.Path == "" {
+		return nil, fmt.Errorf("secret reference must define a path")
+	}
+	return ref, nil
+}
+
+// Resolver resolves a secret reference to the value of the secret
+type Resolver interface {
+	// Allowed checks that the reference is one the build is allowed to read, this is checked before a reference is resolved
+	// or synced by an ExternalSecret
+	Allowed(ref Reference) error
+	Resolve(ref Reference) (string, error)
+}
+
+// StoreResolver is a resolver that reads from a secret store that an ExternalSecret can also read from, this allows
+// the secret to be synced into the environment by the external secrets operator instead of the build
+type StoreResolver interface {
+	Resolver
+	SecretStore() string
+}
+
+type Client struct {
+	HTTPClient   *retryablehttp.Client
+	RetryMax     int
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+	Timeout      time.Duration
+	resolvers    map[string]Resolver
+}
+
+func NewClient(c Client) *Client {
+	httpClient := retryablehttp.NewClient()
+	// set up the default retries
+	httpClient.RetryMax = 5
+	if c.RetryMax > 0 {
+		httpClient.RetryMax = c.RetryMax
+	}
+	// set the default retry wait minimum to 1s
+	httpClient.RetryWaitMin = time.Duration(1000) * time.Millisecond
+	if c.RetryWaitMin > 0 {
+		httpClient.RetryWaitMin = c.RetryWaitMin
+	}
+	// set the default retry wait maximum to 5s
+	httpClient.RetryWaitMax = time.Duration(5000) * time.Millisecond
+	if c.RetryWaitMax > 0 {
+		httpClient.RetryWaitMax = c.RetryWaitMax
+	}
+	// set the http client timeout to 10s
+	httpClient.HTTPClient.Timeout = time.Duration(10000) * time.Millisecond
+	if c.Timeout > 0 {
+		httpClient.HTTPClient.Timeout = c.Timeout
+	}
+	// disable the retryablehttp client logger
+	httpClient.Logger = nil
+	c.HTTPClient = httpClient
+	c.resolvers = map[string]Resolver{
+		SchemeFile: &FileResolver{},
+	}
+	return &c
+}
+
+// RegisterResolver adds a resolver for a scheme, or replaces the resolver of an existing scheme
+func (c *Client) RegisterResolver(scheme string, resolver Resolver) {
+	c.resolvers[scheme] = resolver
+}
+
+// Schemes returns the schemes that have a resolver
+func (c *Client) Schemes() []string {
+	schemes := []string{}
+	for scheme := range c.resolvers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// SecretStore returns the secret store of the resolver of a scheme, if the resolver reads from a secret store
+func (c *Client) SecretStore(scheme string) (string, bool) {
+	if resolver, ok := c.resolvers[scheme].(StoreResolver); ok {
+		return resolver.SecretStore(), true
+	}
+	return "", false
+}
+
+// Allowed checks that the reference scheme is supported, and that the resolver of the scheme allows the reference to be read
+func (c *Client) Allowed(ref Reference) error {
+	resolver, ok := c.resolvers[ref.Scheme]
+	if !ok {
+		return fmt.Errorf("secret reference scheme %s is not supported, must be one of %s", ref.Scheme, strings.Join(c.Schemes(), ", "))
+	}
+	if err := resolver.Allowed(ref); err != nil {
+		return fmt.Errorf("secret reference %s is not allowed: %v", ref, err)
+	}
+	return nil
+}
+
+// Resolve resolves a secret reference using the resolver of the reference scheme
+func (c *Client) Resolve(ref Reference) (string, error) {
+	if err := c.Allowed(ref); err != nil {
+		return "", err
+	}
+	value, err := c.resolvers[ref.Scheme].Resolve(ref)
+	if err != nil {
+		return "", fmt.Errorf("couldn't resolve secret reference %s: %v", ref, err)
+	}
+	return value, nil
+}
+
+// FileResolver reads the secret from a file, if a key is defined the file is read as yaml or json and the value of the key is used.
+// Only files in the root directory can be read, relative paths are relative to the root directory. If there is no root directory
+// file references are not allowed
+type FileResolver struct {
+	Root string
+}
+
+func (r *FileResolver) Allowed(ref Reference) error {
+	_, err := r.path(ref)
+	return err
+}
+
+// path returns the path of the file the reference reads, after any symlinks are followed the file must still be in the root directory
+func (r *FileResolver) path(ref Reference) (string, error) {
+	if r.Root == "" {
+		return "", fmt.Errorf("file references are not enabled")
+	}
+	if hasParentSegment(ref.Path) {
+		return "", fmt.Errorf("path %s must not contain '..'", ref.Path)
+	}
+	root, err := filepath.Abs(r.Root)
+	if err != nil {
+		return "", err
+	}
+	path := ref.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	if !pathInDirectory(filepath.Clean(path), root) {
+		return "", fmt.Errorf("path %s is not in %s", ref.Path, r.Root)
+	}
+	// follow any symlinks in both the root and the path, so that a symlink can't point out of the root directory
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if !pathInDirectory(realPath, realRoot) {
+		return "", fmt.Errorf("path %s is not in %s", ref.Path, r.Root)
+	}
+	return realPath, nil
+}
+
+// hasParentSegment checks if a path has a `..` segment
+func hasParentSegment(path string) bool {
+	for _, segment := range strings.Split(filepath.ToSlash(path), "/") {
+		if segment == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// pathInDirectory checks if a cleaned absolute path is in a directory
+func pathInDirectory(path, directory string) bool {
+	rel, err := filepath.Rel(directory, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+func (r *FileResolver) Resolve(ref Reference) (string, error) {
+	path, err := r.path(ref)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if ref.Key == "" {
+		return strings.TrimSuffix(string(data), "\n"), nil
+	}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return "", fmt.Errorf("couldn't read key %s, file is not valid yaml or json: %v", ref.Key, err)
+	}
+	value, ok := values[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found", ref.Key)
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case map[string]interface{}, []interface{}:
+		return "", fmt.Errorf("key %s is not a scalar value", ref.Key)
+	default:
+		return fmt.Sprintf("%v", v), nil
+	}
+}
+
+// HTTPResolver reads the secret from a vault kv (version 2) secrets engine over http, the path of the reference
+// is the path of the secret including the mount, for example `ref+vault://secret/data/example-project#api-key`.
+// The token and the secret store are shared by all projects, so only paths under the path prefix can be read
+type HTTPResolver struct {
+	HTTPClient *retryablehttp.Client
+	Endpoint   string
+	Token      string
+	Store      string
+	PathPrefix string
+}
+
+func (r *HTTPResolver) Allowed(ref Reference) error {
+	prefix := strings.Trim(r.PathPrefix, "/")
+	if prefix == "" {
+		return fmt.Errorf("no path prefix is defined")
+	}
+	path := strings.TrimPrefix(ref.Path, "/")
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("path %s must not contain empty, '.', or '..' segments", ref.Path)
+		}
+	}
+	if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+		return fmt.Errorf("path %s is not under %s", ref.Path, prefix)
+	}
+	return nil
+}
+
+type vaultResponse struct {
+	Data struct {
+		Data map[string]interface{} `json:"data"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+func (r *HTTPResolver) Resolve(ref Reference) (string, error) {
+	if ref.Key == "" {
+		return "", fmt.Errorf("secret reference must define a key")
+	}
+	req, err := retryablehttp.NewRequest(http.MethodGet, fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(r.Endpoint, "/"), strings.TrimPrefix(ref.Path, "/")), nil)
+	if err != nil {
+		return "", err
+	}
+	if r.Token != "" {
+		req.Header.Set("X-Vault-Token", r.Token)
+	}
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	response := new(vaultResponse)
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return "", fmt.Errorf("secret store responded with status %d, but response is not a valid JSON payload", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if len(response.Errors) > 0 {
+			return "", fmt.Errorf("secret store responded with status %d: %s", resp.StatusCode, strings.Join(response.Errors, ", "))
+		}
+		return "", fmt.Errorf("secret store responded with status %d", resp.StatusCode)
+	}
+	value, ok := response.Data.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found", ref.Key)
+	}
+	if v, ok := value.(string); ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("key %s is not a string value", ref.Key)
+}
+
+// SecretStore is the name of the ClusterSecretStore that reads from the same vault
+func (r *HTTPResolver) SecretStore() string {
+	return r.Store
+}
@@ -0,0 +1,215 @@
+package secretref
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/uselagoon/build-deploy-tool/internal/secretref/secretreftest"
+	// changes the testing to source from root so paths to test resources must be defined from repo root
+	_ "github.com/uselagoon/build-deploy-tool/internal/testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    *Reference
+		wantErr string
+	}{
+		{
+			name:  "file",
+			value: "ref+file://internal/secretref/test-resources/api-key.txt",
+			want:  &Reference{Scheme: "file", Path: "internal/secretref/test-resources/api-key.txt"},
+		},
+		{
+			name:  "absolute file with key",
+			value: "ref+file:///var/run/secrets/lagoon/secrets.yml#password",
+			want:  &Reference{Scheme: "file", Path: "/var/run/secrets/lagoon/secrets.yml", Key: "password"},
+		},
+		{
+			name:  "vault",
+			value: "ref+vault://secret/data/example-project#api-key",
+			want:  &Reference{Scheme: "vault", Path: "secret/data/example-project", Key: "api-key"},
+		},
+		{
+			name:    "not a reference",
+			value:   "vault://secret/data/example-project#api-key",
+			wantErr: "vault://secret/data/example-project#api-key is not a secret reference",
+		},
+		{
+			name:    "no scheme",
+			value:   "ref+secret/data/example-project#api-key",
+			wantErr: "secret reference must be in the format ref+<scheme>://<path>#<key>",
+		},
+		{
+			name:    "no path",
+			value:   "ref+vault://#api-key",
+			wantErr: "secret reference must define a path",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseReference(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("ParseReference() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("ParseReference() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseReference() = %v, want %v", got, tt.want)
+			}
+			if got.String() != tt.value {
+				t.Errorf("String() = %v, want %v", got.String(), tt.value)
+			}
+		})
+	}
+}
+
+func TestClientResolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		token   string
+		noRoot  bool
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "test1 - whole file",
+			value: "ref+file://api-key.txt",
+			want:  "file-api-key",
+		},
+		{
+			name:  "test2 - key in a yaml file",
+			value: "ref+file://secrets.yml#password",
+			want:  "file-password",
+		},
+		{
+			name:  "test3 - number key in a yaml file",
+			value: "ref+file://secrets.yml#port",
+			want:  "5432",
+		},
+		{
+			name:    "test4 - key that isn't a scalar",
+			value:   "ref+file://secrets.yml#nested",
+			wantErr: "couldn't resolve secret reference ref+file://secrets.yml#nested: key nested is not a scalar value",
+		},
+		{
+			name:    "test5 - key that doesn't exist in a file",
+			value:   "ref+file://secrets.yml#username",
+			wantErr: "couldn't resolve secret reference ref+file://secrets.yml#username: key username not found",
+		},
+		{
+			name:  "test6 - vault",
+			value: "ref+vault://secret/data/example-project#api-key",
+			token: "lagoon-token",
+			want:  "super-secret-api-key",
+		},
+		{
+			name:    "test7 - vault without a token",
+			value:   "ref+vault://secret/data/example-project#api-key",
+			wantErr: "couldn't resolve secret reference ref+vault://secret/data/example-project#api-key: secret store responded with status 403: permission denied",
+		},
+		{
+			name:    "test8 - vault secret that doesn't exist",
+			value:   "ref+vault://secret/data/example-project/other#api-key",
+			token:   "lagoon-token",
+			wantErr: "couldn't resolve secret reference ref+vault://secret/data/example-project/other#api-key: secret store responded with status 404",
+		},
+		{
+			name:    "test9 - vault without a key",
+			value:   "ref+vault://secret/data/example-project",
+			token:   "lagoon-token",
+			wantErr: "couldn't resolve secret reference ref+vault://secret/data/example-project: secret reference must define a key",
+		},
+		{
+			name:    "test10 - scheme that doesn't exist",
+			value:   "ref+awssecrets://example-project#api-key",
+			wantErr: "secret reference scheme awssecrets is not supported, must be one of file, vault",
+		},
+		{
+			name:    "test11 - vault secret of another project",
+			value:   "ref+vault://secret/data/other-project#api-key",
+			token:   "lagoon-token",
+			wantErr: "secret reference ref+vault://secret/data/other-project#api-key is not allowed: path secret/data/other-project is not under secret/data/example-project",
+		},
+		{
+			name:    "test12 - vault secret of another project with a parent segment",
+			value:   "ref+vault://secret/data/example-project/../other-project#api-key",
+			token:   "lagoon-token",
+			wantErr: "secret reference ref+vault://secret/data/example-project/../other-project#api-key is not allowed: path secret/data/example-project/../other-project must not contain empty, '.', or '..' segments",
+		},
+		{
+			name:    "test13 - vault secret with a similar prefix",
+			value:   "ref+vault://secret/data/example-project-2#api-key",
+			token:   "lagoon-token",
+			wantErr: "secret reference ref+vault://secret/data/example-project-2#api-key is not allowed: path secret/data/example-project-2 is not under secret/data/example-project",
+		},
+		{
+			name:    "test14 - file outside of the root",
+			value:   "ref+file:///var/run/secrets/kubernetes.io/serviceaccount/token",
+			wantErr: "secret reference ref+file:///var/run/secrets/kubernetes.io/serviceaccount/token is not allowed: path /var/run/secrets/kubernetes.io/serviceaccount/token is not in internal/secretref/test-resources",
+		},
+		{
+			name:    "test15 - file with a parent segment",
+			value:   "ref+file://../secretref.go",
+			wantErr: "secret reference ref+file://../secretref.go is not allowed: path ../secretref.go must not contain '..'",
+		},
+		{
+			name:    "test16 - file that is a symlink out of the root",
+			value:   "ref+file://escape.txt",
+			wantErr: "secret reference ref+file://escape.txt is not allowed: path escape.txt is not in internal/secretref/test-resources",
+		},
+		{
+			name:    "test17 - file references not enabled",
+			value:   "ref+file://api-key.txt",
+			noRoot:  true,
+			wantErr: "secret reference ref+file://api-key.txt is not allowed: file references are not enabled",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := secretreftest.NewServer()
+			defer ts.Close()
+			c := NewClient(Client{
+				RetryMax:     5,
+				RetryWaitMin: time.Duration(10) * time.Millisecond,
+				RetryWaitMax: time.Duration(50) * time.Millisecond,
+			})
+			if !tt.noRoot {
+				c.RegisterResolver(SchemeFile, &FileResolver{Root: "internal/secretref/test-resources"})
+			}
+			c.RegisterResolver(SchemeVault, &HTTPResolver{
+				HTTPClient: c.HTTPClient,
+				Endpoint:   ts.URL,
+				Token:      tt.token,
+				Store:      "vault",
+				PathPrefix: "secret/data/example-project",
+			})
+			ref, err := ParseReference(tt.value)
+			if err != nil {
+				t.Fatalf("ParseReference() error = %v", err)
+			}
+			got, err := c.Resolve(*ref)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Resolve() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Resolve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
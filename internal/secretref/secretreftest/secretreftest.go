@@ -0,0 +1,29 @@
+// Package secretreftest provides a secret store server for testing secret references
+package secretreftest
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// NewServer returns a test server used to test secret store responses, it only responds to requests that provide a token
+// and has a single secret at `secret/data/example-project`
+func NewServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/", func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Vault-Token") == "" {
+			res.WriteHeader(http.StatusForbidden)
+			res.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch req.URL.Path {
+		case "/v1/secret/data/example-project":
+			res.Write([]byte(`{"data":{"data":{"api-key":"super-secret-api-key","db-password":"hunter2"},"metadata":{"version":1}}}`))
+		default:
+			res.WriteHeader(http.StatusNotFound)
+			res.Write([]byte(`{"errors":[]}`))
+		}
+	})
+	ts := httptest.NewServer(mux)
+	return ts
+}
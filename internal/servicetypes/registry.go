@@ -0,0 +1,182 @@
+package servicetypes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// ServiceTypeDefinition is a service type loaded from a file. As well as the values for the service type
+// it defines how the build treats services of this type
+type ServiceTypeDefinition struct {
+	ServiceType `json:",inline"`
+	// AutogeneratedRoutes allows services of this type to have autogenerated routes, the first port must be named `http`
+	AutogeneratedRoutes bool `json:"autogeneratedRoutes"`
+	// Backups enables the backup schedule for services of this type
+	Backups bool `json:"backups"`
+}
+
+// the names of the built-in service types, these can't be redefined by files
+var builtinServiceTypes = func() map[string]bool {
+	names := map[string]bool{}
+	for name := range ServiceTypes {
+		names[name] = true
+	}
+	return names
+}()
+
+// the loaded service types that support autogenerated routes or backups
+var (
+	loadedAutogeneratedTypes []string
+	loadedTypesWithBackups   []string
+)
+
+var serviceTypeNameRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+var persistentVolumeTypes = []corev1.PersistentVolumeAccessMode{
+	corev1.ReadWriteOnce,
+	corev1.ReadWriteMany,
+	corev1.ReadOnlyMany,
+	corev1.ReadWriteOncePod,
+}
+
+// LoadServiceTypes resets the service types to the built-in types, then adds the service types defined in the `.yml` or `.yaml`
+// files in the directory. Every file must contain a single valid service type, and the name of the service type can't be the
+// same as a built-in type or a type in another file. If the directory is empty only the built-in types are available.
+func LoadServiceTypes(dir string) error {
+	resetServiceTypes()
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("couldn't read service types directory %v: %v", dir, err)
+	}
+	definitions := map[string]ServiceTypeDefinition{}
+	sources := map[string]string{}
+	var names []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yml" && ext != ".yaml") {
+			continue
+		}
+		file := filepath.Join(dir, entry.Name())
+		definition, err := readServiceTypeDefinition(file)
+		if err != nil {
+			return err
+		}
+		name := definition.Name
+		if builtinServiceTypes[name] {
+			return fmt.Errorf("service type %s in %s conflicts with the built-in service type of the same name", name, file)
+		}
+		if _, ok := oldServiceMap[name]; ok {
+			return fmt.Errorf("service type %s in %s conflicts with the deprecated service type of the same name", name, file)
+		}
+		if source, ok := sources[name]; ok {
+			return fmt.Errorf("service type %s in %s is already defined in %s", name, file, source)
+		}
+		definitions[name] = definition
+		sources[name] = file
+		names = append(names, name)
+	}
+	// only add the service types once they have all been validated
+	sort.Strings(names)
+	for _, name := range names {
+		definition := definitions[name]
+		ServiceTypes[name] = definition.ServiceType
+		if definition.AutogeneratedRoutes {
+			loadedAutogeneratedTypes = append(loadedAutogeneratedTypes, name)
+		}
+		if definition.Backups {
+			loadedTypesWithBackups = append(loadedTypesWithBackups, name)
+		}
+	}
+	return nil
+}
+
+// resetServiceTypes removes any service types that were loaded from files
+func resetServiceTypes() {
+	for name := range ServiceTypes {
+		if !builtinServiceTypes[name] {
+			delete(ServiceTypes, name)
+		}
+	}
+	loadedAutogeneratedTypes = nil
+	loadedTypesWithBackups = nil
+}
+
+func readServiceTypeDefinition(file string) (ServiceTypeDefinition, error) {
+	definition := ServiceTypeDefinition{}
+	definitionBytes, err := os.ReadFile(file)
+	if err != nil {
+		return definition, fmt.Errorf("couldn't read file %v: %v", file, err)
+	}
+	// unknown fields are an error so that mistakes in the definition aren't silently ignored
+	if err := yaml.UnmarshalStrict(definitionBytes, &definition); err != nil {
+		return definition, fmt.Errorf("unable to unmarshal service type file %v: %v", file, err)
+	}
+	if err := definition.validate(); err != nil {
+		return definition, fmt.Errorf("service type file %v is not valid: %v", file, err)
+	}
+	return definition, nil
+}
+
+func (d ServiceTypeDefinition) validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !serviceTypeNameRegex.MatchString(d.Name) {
+		return fmt.Errorf("name %s must consist of lower case alphanumeric characters or '-'", d.Name)
+	}
+	if d.PrimaryContainer.Name == "" {
+		return fmt.Errorf("primaryContainer.name is required")
+	}
+	portNames := map[string]bool{}
+	for idx, port := range d.Ports.Ports {
+		if port.Port < 1 || port.Port > 65535 {
+			return fmt.Errorf("ports.ports[%d].port %d must be between 1 and 65535", idx, port.Port)
+		}
+		if port.Name == "" {
+			return fmt.Errorf("ports.ports[%d].name is required", idx)
+		}
+		if portNames[port.Name] {
+			return fmt.Errorf("ports.ports[%d].name %s is used by another port", idx, port.Name)
+		}
+		portNames[port.Name] = true
+	}
+	if d.AutogeneratedRoutes && (len(d.Ports.Ports) == 0 || d.Ports.Ports[0].Name != "http") {
+		return fmt.Errorf("autogeneratedRoutes requires the first port to be named http")
+	}
+	if d.Volumes.PersistentVolumeType != "" {
+		valid := false
+		for _, t := range persistentVolumeTypes {
+			if d.Volumes.PersistentVolumeType == t {
+				valid = true
+			}
+		}
+		if !valid {
+			return fmt.Errorf("volumes.persistentVolumeType %s is not a valid access mode", d.Volumes.PersistentVolumeType)
+		}
+	}
+	if d.ProvidesPersistentVolume {
+		if d.Volumes.PersistentVolumePath == "" {
+			return fmt.Errorf("volumes.persistentVolumePath is required when providesPersistentVolume is true")
+		}
+		if _, err := resource.ParseQuantity(d.Volumes.PersistentVolumeSize); err != nil {
+			return fmt.Errorf("volumes.persistentVolumeSize %s is not a valid size: %v", d.Volumes.PersistentVolumeSize, err)
+		}
+	}
+	if d.Backups && !d.ProvidesPersistentVolume && d.Volumes.BackupConfiguration.Command == "" {
+		return fmt.Errorf("backups requires the service type to provide a persistent volume or a volumes.backupConfiguration.command")
+	}
+	if d.Volumes.BackupConfiguration.Command != "" && d.Volumes.BackupConfiguration.FileExtension == "" {
+		return fmt.Errorf("volumes.backupConfiguration.fileExtension is required when a backup command is defined")
+	}
+	return nil
+}
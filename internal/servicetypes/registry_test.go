@@ -0,0 +1,140 @@
+package servicetypes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const memcachedType = `name: memcached
+ports:
+  ports:
+  - name: 11211-tcp
+    port: 11211
+primaryContainer:
+  name: memcached
+`
+
+func TestLoadServiceTypes(t *testing.T) {
+	tests := []struct {
+		name           string
+		files          map[string]string
+		wantTypes      []string
+		wantAutogen    []string
+		wantBackups    []string
+		wantErr        string
+		wantNotDefined []string
+	}{
+		{
+			name: "additional types",
+			files: map[string]string{
+				"memcached.yml": memcachedType,
+				"clickhouse.yaml": `name: clickhouse
+autogeneratedRoutes: true
+backups: true
+providesPersistentVolume: true
+ports:
+  ports:
+  - name: http
+    port: 8123
+volumes:
+  persistentVolumeSize: 5Gi
+  persistentVolumePath: /var/lib/clickhouse
+  persistentVolumeType: ReadWriteOnce
+  backup: true
+primaryContainer:
+  name: clickhouse
+`,
+				"README.md": "not a service type",
+			},
+			wantTypes:   []string{"memcached", "clickhouse", "basic"},
+			wantAutogen: []string{"clickhouse"},
+			wantBackups: []string{"clickhouse"},
+		},
+		{
+			name: "conflicts with built-in type",
+			files: map[string]string{
+				"redis.yml": strings.Replace(memcachedType, "name: memcached", "name: redis", 1),
+			},
+			wantErr: "service type redis in " + "%s/redis.yml conflicts with the built-in service type of the same name",
+		},
+		{
+			name: "conflicts with another file",
+			files: map[string]string{
+				"a.yml": memcachedType,
+				"b.yml": memcachedType,
+			},
+			wantErr:        "service type memcached in %s/b.yml is already defined in %s/a.yml",
+			wantNotDefined: []string{"memcached"},
+		},
+		{
+			name: "unknown field",
+			files: map[string]string{
+				"memcached.yml": memcachedType + "primaryContianer:\n  name: memcached\n",
+			},
+			wantErr: `unable to unmarshal service type file %s/memcached.yml: error unmarshaling JSON: while decoding JSON: json: unknown field "primaryContianer"`,
+		},
+		{
+			name: "autogenerated routes without http port",
+			files: map[string]string{
+				"memcached.yml": memcachedType + "autogeneratedRoutes: true\n",
+			},
+			wantErr: "service type file %s/memcached.yml is not valid: autogeneratedRoutes requires the first port to be named http",
+		},
+		{
+			name: "persistent volume without a path",
+			files: map[string]string{
+				"memcached.yml": memcachedType + "providesPersistentVolume: true\nvolumes:\n  persistentVolumeSize: 5Gi\n",
+			},
+			wantErr: "service type file %s/memcached.yml is not valid: volumes.persistentVolumePath is required when providesPersistentVolume is true",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("couldn't write file: %v", err)
+				}
+			}
+			defer LoadServiceTypes("")
+			err := LoadServiceTypes(dir)
+			if tt.wantErr != "" {
+				wantErr := strings.ReplaceAll(tt.wantErr, "%s", dir)
+				if err == nil || err.Error() != wantErr {
+					t.Errorf("LoadServiceTypes() error = %v, wantErr %v", err, wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("LoadServiceTypes() error = %v", err)
+			}
+			for _, name := range tt.wantTypes {
+				if _, ok := ServiceTypes[name]; !ok {
+					t.Errorf("LoadServiceTypes() service type %s not defined", name)
+				}
+			}
+			for _, name := range tt.wantNotDefined {
+				if _, ok := ServiceTypes[name]; ok {
+					t.Errorf("LoadServiceTypes() service type %s should not be defined", name)
+				}
+			}
+			for _, name := range tt.wantAutogen {
+				if !IsAutogeneratedSupported(name) {
+					t.Errorf("IsAutogeneratedSupported(%s) = false", name)
+				}
+			}
+			for _, name := range tt.wantBackups {
+				if !IsTypeWithBackups(name) {
+					t.Errorf("IsTypeWithBackups(%s) = false", name)
+				}
+			}
+		})
+	}
+	// loading without a directory removes the loaded types
+	if err := LoadServiceTypes(""); err != nil {
+		t.Errorf("LoadServiceTypes() error = %v", err)
+	}
+	if _, ok := ServiceTypes["memcached"]; ok || IsAutogeneratedSupported("clickhouse") {
+		t.Errorf("LoadServiceTypes() loaded service types were not removed")
+	}
+}
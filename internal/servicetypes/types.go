@@ -7,57 +7,57 @@ import (
 )
 
 type ServiceType struct {
-	Name                     string
-	Ports                    ServicePorts
-	Volumes                  ServiceVolume
-	Strategy                 appsv1.DeploymentStrategy
-	PrimaryContainer         ServiceContainer
-	InitContainer            ServiceContainer
-	SecondaryContainer       ServiceContainer
-	PodSecurityContext       ServicePodSecurityContext
-	EnableServiceLinks       bool
-	ProvidesPersistentVolume bool
-	ConsumesPersistentVolume bool
-	AllowAdditionalVolumes   bool
+	Name                     string                    `json:"name"`
+	Ports                    ServicePorts              `json:"ports"`
+	Volumes                  ServiceVolume             `json:"volumes"`
+	Strategy                 appsv1.DeploymentStrategy `json:"strategy"`
+	PrimaryContainer         ServiceContainer          `json:"primaryContainer"`
+	InitContainer            ServiceContainer          `json:"initContainer"`
+	SecondaryContainer       ServiceContainer          `json:"secondaryContainer"`
+	PodSecurityContext       ServicePodSecurityContext `json:"podSecurityContext"`
+	EnableServiceLinks       bool                      `json:"enableServiceLinks"`
+	ProvidesPersistentVolume bool                      `json:"providesPersistentVolume"`
+	ConsumesPersistentVolume bool                      `json:"consumesPersistentVolume"`
+	AllowAdditionalVolumes   bool                      `json:"allowAdditionalVolumes"`
 }
 
 type ServicePodSecurityContext struct {
-	HasDefault bool
-	FSGroup    int64
+	HasDefault bool  `json:"hasDefault"`
+	FSGroup    int64 `json:"fsGroup"`
 }
 
 type ServiceContainer struct {
-	Name            string
-	ImagePullPolicy corev1.PullPolicy
-	Container       corev1.Container
+	Name            string            `json:"name"`
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy"`
+	Container       corev1.Container  `json:"container"`
 	// define additional volumes here, can leverage 'go template' with generator.ServiceValues
-	Volumes      []corev1.Volume
-	VolumeMounts []corev1.VolumeMount
-	Command      []string
-	FeatureFlags map[string]bool
+	Volumes      []corev1.Volume      `json:"volumes"`
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts"`
+	Command      []string             `json:"command"`
+	FeatureFlags map[string]bool      `json:"featureFlags"`
 	// define additional variables here, this can be used by types that inherit from another type
-	EnvVars []corev1.EnvVar
+	EnvVars []corev1.EnvVar `json:"envVars"`
 }
 
 type ServiceVolume struct {
-	PersistentVolumeSize   string
-	PersistentVolumePath   string
-	PersistentVolumeType   corev1.PersistentVolumeAccessMode
-	SourceFromOtherService string
-	Backup                 bool
-	BackupConfiguration    BackupConfiguration
+	PersistentVolumeSize   string                            `json:"persistentVolumeSize"`
+	PersistentVolumePath   string                            `json:"persistentVolumePath"`
+	PersistentVolumeType   corev1.PersistentVolumeAccessMode `json:"persistentVolumeType"`
+	SourceFromOtherService string                            `json:"sourceFromOtherService"`
+	Backup                 bool                              `json:"backup"`
+	BackupConfiguration    BackupConfiguration               `json:"backupConfiguration"`
 }
 
 type BackupConfiguration struct {
-	Command       string
-	FileExtension string
+	Command       string `json:"command"`
+	FileExtension string `json:"fileExtension"`
 }
 
 // when defining default ServicePorts for a service, the first port in the list should be the port that could be associated to an ingress
 // the name of this port must be `http`
 type ServicePorts struct {
-	CanChangePort bool
-	Ports         []corev1.ServicePort
+	CanChangePort bool                 `json:"canChangePort"`
+	Ports         []corev1.ServicePort `json:"ports"`
 }
 
 // this is a map that maps all the lagoon service-type that can be provided in the `lagoon.type` label to the default values for that service
@@ -148,7 +148,7 @@ var supportedAutogeneratedTypes = []string{
 }
 
 func IsAutogeneratedSupported(lagoonType string) bool {
-	return helpers.Contains(supportedAutogeneratedTypes, lagoonType)
+	return helpers.Contains(supportedAutogeneratedTypes, lagoonType) || helpers.Contains(loadedAutogeneratedTypes, lagoonType)
 }
 
 // these are lagoon types that come with resources requiring backups
@@ -173,7 +173,7 @@ var typesWithBackups = []string{
 }
 
 func IsTypeWithBackups(lagoonType string) bool {
-	return helpers.Contains(typesWithBackups, lagoonType)
+	return helpers.Contains(typesWithBackups, lagoonType) || helpers.Contains(loadedTypesWithBackups, lagoonType)
 }
 
 // this is a map that maps old service types to their new service types
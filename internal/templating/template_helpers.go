@@ -5,6 +5,7 @@ import (
 
 	"github.com/uselagoon/build-deploy-tool/internal/generator"
 	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/secretref"
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/yaml"
 )
@@ -63,3 +64,13 @@ func TemplateSecret(item corev1.Secret) ([]byte, error) {
 	templateYAML := append(separator[:], iBytes[:]...)
 	return templateYAML, nil
 }
+
+func TemplateExternalSecret(item secretref.ExternalSecret) ([]byte, error) {
+	separator := []byte("---\n")
+	iBytes, err := yaml.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	templateYAML := append(separator[:], iBytes[:]...)
+	return templateYAML, nil
+}
@@ -0,0 +1,184 @@
+package templating
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	corev1 "k8s.io/api/core/v1"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metavalidation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// the maximum size of the compressed insights data in a configmap, this is the threshold the legacy insights scripts used
+// which leaves room under the 1MiB kubernetes limit for the key, labels and annotations of the configmap
+const insightsConfigMapMaxSize = 950000
+
+// ImageInsights is the image inspect and sbom output for the image of a service
+type ImageInsights struct {
+	Inspect json.RawMessage `json:"inspect,omitempty"`
+	SBOM    json.RawMessage `json:"sbom,omitempty"`
+}
+
+// the types of insights that are stored in configmaps
+var insightsTypes = []struct {
+	name         string
+	insightsType string
+	suffix       string
+	data         func(ImageInsights) json.RawMessage
+}{
+	{
+		name:         "image",
+		insightsType: "inspect",
+		suffix:       "image-inspect.json.gz",
+		data:         func(i ImageInsights) json.RawMessage { return i.Inspect },
+	},
+	{
+		name:         "sbom",
+		insightsType: "sbom",
+		suffix:       "cyclonedx.json.gz",
+		data:         func(i ImageInsights) json.RawMessage { return i.SBOM },
+	},
+}
+
+// InsightsSizeError is returned when the compressed insights data for a service is too large to store in a configmap
+type InsightsSizeError struct {
+	ConfigMap string
+	Size      int
+}
+
+func (e *InsightsSizeError) Error() string {
+	return fmt.Sprintf("the compressed insights data for configmap %s is %d bytes, which is larger than the configmap limit of %d bytes", e.ConfigMap, e.Size, insightsConfigMapMaxSize)
+}
+
+// GenerateInsightsConfigMaps generates the insights configmaps for the image inspect and sbom data of each service.
+// The data is stored gzip compressed, any configmaps that would exceed the configmap size limit are not generated
+// and are returned as errors so the other configmaps can still be applied.
+// If a custom dependency track endpoint is provided, the sbom configmaps are annotated so the sbom is sent to it
+func GenerateInsightsConfigMaps(
+	buildValues generator.BuildValues,
+	insights map[string]ImageInsights,
+	coreEnabled bool,
+	dependencyTrackEndpoint string,
+) ([]corev1.ConfigMap, []error, error) {
+	var configMaps []corev1.ConfigMap
+	var sizeErrors []error
+
+	// add the default annotations
+	annotations := map[string]string{}
+	switch buildValues.BuildType {
+	case "branch":
+		annotations["lagoon.sh/branch"] = buildValues.Branch
+	case "pullrequest":
+		// the insights handler links pullrequest environments by the pullrequest number in the branch annotation
+		annotations["lagoon.sh/branch"] = buildValues.PRNumber
+		annotations["lagoon.sh/prHeadBranch"] = buildValues.PRHeadBranch
+		annotations["lagoon.sh/prBaseBranch"] = buildValues.PRBaseBranch
+	}
+	if coreEnabled {
+		annotations["core.insights.lagoon.sh/enabled"] = "true"
+	}
+
+	services := []string{}
+	for service := range insights {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	for _, service := range services {
+		for _, iType := range insightsTypes {
+			data := iType.data(insights[service])
+			if len(data) == 0 {
+				continue
+			}
+			name := fmt.Sprintf("lagoon-insights-%s-%s", iType.name, service)
+			compressed, err := gzipInsights(data)
+			if err != nil {
+				return nil, nil, fmt.Errorf("couldn't compress insights data for %s: %v", name, err)
+			}
+			key := fmt.Sprintf("%s.%s", service, iType.suffix)
+			if size := len(compressed); size > insightsConfigMapMaxSize {
+				sizeErrors = append(sizeErrors, &InsightsSizeError{ConfigMap: name, Size: size})
+				continue
+			}
+			configMap := corev1.ConfigMap{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "ConfigMap",
+					APIVersion: corev1.SchemeGroupVersion.Version,
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: name,
+					Labels: map[string]string{
+						"app.kubernetes.io/managed-by": "build-deploy-tool",
+						"app.kubernetes.io/instance":   name,
+						"app.kubernetes.io/name":       name,
+						"lagoon.sh/project":            buildValues.Project,
+						"lagoon.sh/environment":        buildValues.Environment,
+						"lagoon.sh/environmentType":    buildValues.EnvironmentType,
+						"lagoon.sh/buildType":          buildValues.BuildType,
+						"lagoon.sh/buildName":          buildValues.BuildName,
+						"lagoon.sh/service":            service,
+						"lagoon.sh/insightsType":       fmt.Sprintf("%s-gz", iType.name),
+						"insights.lagoon.sh/type":      iType.insightsType,
+					},
+					Annotations: map[string]string{},
+				},
+				BinaryData: map[string][]byte{
+					key: compressed,
+				},
+			}
+			for key, value := range annotations {
+				configMap.ObjectMeta.Annotations[key] = value
+			}
+			if iType.insightsType == "sbom" && dependencyTrackEndpoint != "" {
+				configMap.ObjectMeta.Annotations["dependencytrack.insights.lagoon.sh/custom-endpoint"] = dependencyTrackEndpoint
+			}
+			// validate any annotations
+			if err := apivalidation.ValidateAnnotations(configMap.ObjectMeta.Annotations, nil); err != nil {
+				if len(err) != 0 {
+					return nil, nil, fmt.Errorf("the annotations for %s are not valid: %v", name, err)
+				}
+			}
+			// validate any labels
+			if err := metavalidation.ValidateLabels(configMap.ObjectMeta.Labels, nil); err != nil {
+				if len(err) != 0 {
+					return nil, nil, fmt.Errorf("the labels for %s are not valid: %v", name, err)
+				}
+			}
+			// check length of labels
+			if err := helpers.CheckLabelLength(configMap.ObjectMeta.Labels); err != nil {
+				return nil, nil, err
+			}
+			configMaps = append(configMaps, configMap)
+		}
+	}
+	return configMaps, sizeErrors, nil
+}
+
+func gzipInsights(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func TemplateConfigMap(item corev1.ConfigMap) ([]byte, error) {
+	separator := []byte("---\n")
+	iBytes, err := yaml.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	templateYAML := append(separator[:], iBytes[:]...)
+	return templateYAML, nil
+}
@@ -0,0 +1,143 @@
+package templating
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/andreyvit/diff"
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+)
+
+func TestGenerateInsightsConfigMaps(t *testing.T) {
+	// random data doesn't compress, so this sbom is too large to store in a configmap
+	random := make([]byte, insightsConfigMapMaxSize)
+	rand.New(rand.NewSource(1)).Read(random)
+	largeSBOM, _ := json.Marshal(map[string]string{"bomFormat": "CycloneDX", "data": base64.StdEncoding.EncodeToString(random)})
+	type args struct {
+		buildValues             generator.BuildValues
+		insights                map[string]ImageInsights
+		coreEnabled             bool
+		dependencyTrackEndpoint string
+	}
+	tests := []struct {
+		name       string
+		args       args
+		want       string
+		wantErrors []string
+	}{
+		{
+			name: "test1 - image inspect and sbom",
+			args: args{
+				buildValues: generator.BuildValues{
+					Project:         "example-project",
+					Environment:     "environment-name",
+					EnvironmentType: "production",
+					BuildType:       "branch",
+					BuildName:       "lagoon-build-abcdef",
+					Branch:          "environment-name",
+				},
+				insights: map[string]ImageInsights{
+					"node": {
+						Inspect: json.RawMessage(`{"Name":"harbor.example/example-project/environment-name/node","Digest":"sha256:b2001bef1fd6a3e5ff0a16c4b5b1a1a6e3e3c9d1e8b6d4f1c4a2b9f0e1d2c3b4"}`),
+						SBOM:    json.RawMessage(`{"bomFormat":"CycloneDX","specVersion":"1.5","components":[]}`),
+					},
+				},
+			},
+			want: "test-resources/insights/result-insights-1.yaml",
+		},
+		{
+			name: "test3 - custom dependency track endpoint",
+			args: args{
+				buildValues: generator.BuildValues{
+					Project:         "example-project",
+					Environment:     "environment-name",
+					EnvironmentType: "production",
+					BuildType:       "branch",
+					BuildName:       "lagoon-build-abcdef",
+					Branch:          "environment-name",
+				},
+				insights: map[string]ImageInsights{
+					"node": {
+						Inspect: json.RawMessage(`{"Name":"harbor.example/example-project/environment-name/node","Digest":"sha256:b2001bef1fd6a3e5ff0a16c4b5b1a1a6e3e3c9d1e8b6d4f1c4a2b9f0e1d2c3b4"}`),
+						SBOM:    json.RawMessage(`{"bomFormat":"CycloneDX","specVersion":"1.5","components":[]}`),
+					},
+				},
+				dependencyTrackEndpoint: "https://dependencytrack.example.com",
+			},
+			want: "test-resources/insights/result-insights-3.yaml",
+		},
+		{
+			name: "test2 - pullrequest with core insights and an oversized sbom",
+			args: args{
+				buildValues: generator.BuildValues{
+					Project:         "example-project",
+					Environment:     "pr-123",
+					EnvironmentType: "development",
+					BuildType:       "pullrequest",
+					BuildName:       "lagoon-build-abcdef",
+					PRNumber:        "123",
+					PRHeadBranch:    "feature",
+					PRBaseBranch:    "main",
+				},
+				insights: map[string]ImageInsights{
+					"nginx": {
+						Inspect: json.RawMessage(`{"Name":"harbor.example/example-project/pr-123/nginx"}`),
+						SBOM:    json.RawMessage(largeSBOM),
+					},
+					"cli": {
+						Inspect: json.RawMessage(`{"Name":"harbor.example/example-project/pr-123/cli"}`),
+					},
+				},
+				coreEnabled: true,
+			},
+			want: "test-resources/insights/result-insights-2.yaml",
+			wantErrors: []string{
+				"the compressed insights data for configmap lagoon-insights-sbom-nginx is ",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, sizeErrors, err := GenerateInsightsConfigMaps(tt.args.buildValues, tt.args.insights, tt.args.coreEnabled, tt.args.dependencyTrackEndpoint)
+			if err != nil {
+				t.Errorf("GenerateInsightsConfigMaps() error = %v", err)
+				return
+			}
+			if len(sizeErrors) != len(tt.wantErrors) {
+				t.Errorf("GenerateInsightsConfigMaps() size errors = %v, want %v", sizeErrors, tt.wantErrors)
+			}
+			for idx, want := range tt.wantErrors {
+				if idx >= len(sizeErrors) {
+					break
+				}
+				var sizeErr *InsightsSizeError
+				if !errors.As(sizeErrors[idx], &sizeErr) || sizeErr.Size <= insightsConfigMapMaxSize {
+					t.Errorf("GenerateInsightsConfigMaps() size error = %v, want an InsightsSizeError", sizeErrors[idx])
+				}
+				if got := sizeErrors[idx].Error(); len(got) < len(want) || got[:len(want)] != want {
+					t.Errorf("GenerateInsightsConfigMaps() size error = %v, want %v", got, want)
+				}
+			}
+			r1, err := os.ReadFile(tt.want)
+			if err != nil {
+				t.Errorf("couldn't read file %v: %v", tt.want, err)
+			}
+			var result []byte
+			for _, d := range got {
+				templateBytes, err := TemplateConfigMap(d)
+				if err != nil {
+					t.Errorf("couldn't generate template  %v", err)
+				}
+				result = append(result, templateBytes[:]...)
+			}
+			if !reflect.DeepEqual(string(result), string(r1)) {
+				t.Errorf("GenerateInsightsConfigMaps() = \n%v", diff.LineDiff(string(r1), string(result)))
+			}
+		})
+	}
+}
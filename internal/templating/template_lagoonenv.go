@@ -2,6 +2,7 @@ package templating
 
 import (
 	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/secretref"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -12,12 +13,86 @@ func GenerateLagoonEnvSecret(
 	buildValues generator.BuildValues,
 ) (corev1.Secret, error) {
 
+	labels, annotations := lagoonEnvMetadata(name, "lagoon-env-0.1.0", buildValues)
+
+	lagoonEnv := corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: corev1.SchemeGroupVersion.Version,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+	}
+	// pick which values to save into the secret based on the name
+	switch name {
+	case "lagoon-platform-env":
+		lagoonEnv.StringData = buildValues.LagoonPlatformEnvVariables
+	default:
+		lagoonEnv.StringData = buildValues.LagoonEnvVariables
+	}
+
+	return lagoonEnv, nil
+}
+
+// GenerateLagoonEnvExternalSecret generates the ExternalSecret that syncs the secret references of the lagoon-env variables
+// from their secret store into the lagoon-env secret, there is nothing to generate if there are no secret references
+func GenerateLagoonEnvExternalSecret(
+	name string,
+	buildValues generator.BuildValues,
+) (*secretref.ExternalSecret, error) {
+	// only the lagoon-env variables can use secret references
+	if name == "lagoon-platform-env" || len(buildValues.LagoonEnvSecretReferences) == 0 {
+		return nil, nil
+	}
+	labels, annotations := lagoonEnvMetadata(name, "lagoon-env-externalsecret-0.1.0", buildValues)
+
+	externalSecret := &secretref.ExternalSecret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ExternalSecret",
+			APIVersion: "external-secrets.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: secretref.ExternalSecretSpec{
+			RefreshInterval: "1h",
+			// the build creates the secret with the rest of the variables, the secret references are merged into it
+			Target: secretref.ExternalSecretTarget{
+				Name:           name,
+				CreationPolicy: "Merge",
+			},
+		},
+	}
+	for _, ref := range buildValues.LagoonEnvSecretReferences {
+		externalSecret.Spec.Data = append(externalSecret.Spec.Data, secretref.ExternalSecretData{
+			SecretKey: ref.Name,
+			SourceRef: secretref.ExternalSecretSourceRef{
+				StoreRef: secretref.SecretStoreRef{
+					Name: ref.SecretStore,
+					Kind: "ClusterSecretStore",
+				},
+			},
+			RemoteRef: secretref.ExternalSecretRemoteRef{
+				Key:      ref.Reference.Path,
+				Property: ref.Reference.Key,
+			},
+		})
+	}
+	return externalSecret, nil
+}
+
+func lagoonEnvMetadata(name, template string, buildValues generator.BuildValues) (map[string]string, map[string]string) {
 	// add the default labels
 	labels := map[string]string{
 		"app.kubernetes.io/managed-by": "build-deploy-tool",
 		"app.kubernetes.io/instance":   name,
 		"app.kubernetes.io/name":       name,
-		"lagoon.sh/template":           "lagoon-env-0.1.0",
+		"lagoon.sh/template":           template,
 		"lagoon.sh/project":            buildValues.Project,
 		"lagoon.sh/environment":        buildValues.Environment,
 		"lagoon.sh/environmentType":    buildValues.EnvironmentType,
@@ -36,25 +111,5 @@ func GenerateLagoonEnvSecret(
 		annotations["lagoon.sh/prHeadBranch"] = buildValues.PRHeadBranch
 		annotations["lagoon.sh/prBaseBranch"] = buildValues.PRBaseBranch
 	}
-
-	lagoonEnv := corev1.Secret{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "Secret",
-			APIVersion: corev1.SchemeGroupVersion.Version,
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:        name,
-			Labels:      labels,
-			Annotations: annotations,
-		},
-	}
-	// pick which values to save into the secret based on the name
-	switch name {
-	case "lagoon-platform-env":
-		lagoonEnv.StringData = buildValues.LagoonPlatformEnvVariables
-	default:
-		lagoonEnv.StringData = buildValues.LagoonEnvVariables
-	}
-
-	return lagoonEnv, nil
+	return labels, annotations
 }
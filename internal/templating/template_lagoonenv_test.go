@@ -8,6 +8,7 @@ import (
 	"github.com/andreyvit/diff"
 	"github.com/uselagoon/build-deploy-tool/internal/generator"
 	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"github.com/uselagoon/build-deploy-tool/internal/secretref"
 )
 
 func TestGenerateLagoonEnvSecret(t *testing.T) {
@@ -118,3 +119,86 @@ func TestGenerateLagoonEnvSecret(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateLagoonEnvExternalSecret(t *testing.T) {
+	buildValues := generator.BuildValues{
+		Project:         "example-project",
+		Environment:     "environment-name",
+		EnvironmentType: "production",
+		Namespace:       "myexample-project-environment-name",
+		BuildType:       "branch",
+		LagoonVersion:   "v2.x.x",
+		Kubernetes:      "generator.local",
+		Branch:          "environment-name",
+		LagoonEnvVariables: map[string]string{
+			"MY_SPECIAL_VARIABLE": "myspecialvariable",
+		},
+		LagoonEnvSecretReferences: []generator.SecretReference{
+			{
+				Name:        "API_KEY",
+				Reference:   secretref.Reference{Scheme: "vault", Path: "secret/data/example-project", Key: "api-key"},
+				SecretStore: "vault",
+			},
+			{
+				Name:        "DB_PASSWORD",
+				Reference:   secretref.Reference{Scheme: "vault", Path: "secret/data/example-project", Key: "db-password"},
+				SecretStore: "vault",
+			},
+		},
+	}
+	tests := []struct {
+		name        string
+		secretName  string
+		buildValues generator.BuildValues
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        "test1",
+			secretName:  "lagoon-env",
+			buildValues: buildValues,
+			want:        "test-resources/lagoonenv/lagoon-env-externalsecret-1.yaml",
+		},
+		{
+			name:        "test2 - platform env doesn't use secret references",
+			secretName:  "lagoon-platform-env",
+			buildValues: buildValues,
+		},
+		{
+			name:       "test3 - no secret references",
+			secretName: "lagoon-env",
+			buildValues: generator.BuildValues{
+				Project:     "example-project",
+				Environment: "environment-name",
+				BuildType:   "branch",
+				Branch:      "environment-name",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateLagoonEnvExternalSecret(tt.secretName, tt.buildValues)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GenerateLagoonEnvExternalSecret() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("GenerateLagoonEnvExternalSecret() = %v, want nil", got)
+				}
+				return
+			}
+			r1, err := os.ReadFile(tt.want)
+			if err != nil {
+				t.Errorf("couldn't read file %v: %v", tt.want, err)
+			}
+			templateBytes, err := TemplateExternalSecret(*got)
+			if err != nil {
+				t.Errorf("couldn't generate template: %v", err)
+			}
+			if !reflect.DeepEqual(string(templateBytes), string(r1)) {
+				t.Errorf("GenerateLagoonEnvExternalSecret() = \n%v", diff.LineDiff(string(r1), string(templateBytes)))
+			}
+		})
+	}
+}
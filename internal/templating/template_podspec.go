@@ -6,6 +6,7 @@ import (
 
 	"github.com/uselagoon/build-deploy-tool/internal/generator"
 	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
 	"github.com/uselagoon/build-deploy-tool/internal/servicetypes"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -480,6 +481,11 @@ func generatePodTemplateSpec(
 		}
 	}
 
+	// set any resource or probe overrides from the .lagoon.yml, these take precedence over the defaults above
+	if err := applyContainerOverrides(&container.Container, serviceValues, cronjobCommand == ""); err != nil {
+		return nil, err
+	}
+
 	// append the final defined container to the spec
 	podTemplateSpec.Spec.Containers = append(podTemplateSpec.Spec.Containers, container.Container)
 
@@ -592,7 +598,81 @@ func generatePodTemplateSpec(
 			linkedContainer.Container.Resources.Requests[corev1.ResourceEphemeralStorage] = resource.MustParse(buildValues.Resources.Requests.EphemeralStorage)
 		}
 
+		if err := applyContainerOverrides(&linkedContainer.Container, *serviceValues.LinkedService, cronjobCommand == ""); err != nil {
+			return nil, err
+		}
+
 		podTemplateSpec.Spec.Containers = append(podTemplateSpec.Spec.Containers, linkedContainer.Container)
 	}
 	return &podTemplateSpec, nil
 }
+
+// applyContainerOverrides sets the resource and probe overrides of a service on the container of the service,
+// the probes are only overridden if the container has probes (cronjob containers don't)
+func applyContainerOverrides(container *corev1.Container, serviceValues generator.ServiceValues, probes bool) error {
+	if serviceValues.Resources != nil {
+		// copy the resources so that the defaults of the service type aren't changed
+		resources := container.Resources.DeepCopy()
+		for name, quantity := range serviceValues.Resources.Requests {
+			if resources.Requests == nil {
+				resources.Requests = corev1.ResourceList{}
+			}
+			resources.Requests[name] = quantity
+		}
+		for name, quantity := range serviceValues.Resources.Limits {
+			if resources.Limits == nil {
+				resources.Limits = corev1.ResourceList{}
+			}
+			resources.Limits[name] = quantity
+		}
+		container.Resources = *resources
+	}
+	if !probes || serviceValues.Probes == nil {
+		return nil
+	}
+	var err error
+	if container.ReadinessProbe, err = mergeProbe(container.ReadinessProbe, serviceValues.Probes.Readiness, "readiness", serviceValues.Name); err != nil {
+		return err
+	}
+	if container.LivenessProbe, err = mergeProbe(container.LivenessProbe, serviceValues.Probes.Liveness, "liveness", serviceValues.Name); err != nil {
+		return err
+	}
+	if container.StartupProbe, err = mergeProbe(container.StartupProbe, serviceValues.Probes.Startup, "startup", serviceValues.Name); err != nil {
+		return err
+	}
+	return nil
+}
+
+// mergeProbe merges a probe override into the default probe of a container, a handler in the override replaces the default handler
+// and any timings in the override replace the default timings
+func mergeProbe(defaultProbe *corev1.Probe, override *lagoon.Probe, name, service string) (*corev1.Probe, error) {
+	if override == nil {
+		return defaultProbe, nil
+	}
+	probe := &corev1.Probe{}
+	if defaultProbe != nil {
+		probe = defaultProbe.DeepCopy()
+	}
+	if override.Exec != nil || override.HTTPGet != nil || override.TCPSocket != nil || override.GRPC != nil {
+		probe.ProbeHandler = *override.ProbeHandler.DeepCopy()
+	}
+	if probe.Exec == nil && probe.HTTPGet == nil && probe.TCPSocket == nil && probe.GRPC == nil {
+		return nil, fmt.Errorf("the %s probe for service %s must define a handler, the service type has no default %s probe", name, service, name)
+	}
+	if override.InitialDelaySeconds != nil {
+		probe.InitialDelaySeconds = *override.InitialDelaySeconds
+	}
+	if override.TimeoutSeconds != nil {
+		probe.TimeoutSeconds = *override.TimeoutSeconds
+	}
+	if override.PeriodSeconds != nil {
+		probe.PeriodSeconds = *override.PeriodSeconds
+	}
+	if override.SuccessThreshold != nil {
+		probe.SuccessThreshold = *override.SuccessThreshold
+	}
+	if override.FailureThreshold != nil {
+		probe.FailureThreshold = *override.FailureThreshold
+	}
+	return probe, nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	dockerconfig "github.com/docker/cli/cli/config/configfile"
 	dockertypes "github.com/docker/cli/cli/config/types"
@@ -52,6 +53,10 @@ func GenerateRegistrySecretTemplate(
 		additionalLabels["app.kubernetes.io/name"] = containerRegistry.Name
 		additionalLabels["app.kubernetes.io/instance"] = "internal-registry-secret"
 		additionalLabels["lagoon.sh/template"] = fmt.Sprintf("internal-registry-secret-%s", "0.1.0")
+		if containerRegistry.ExpiresAt != nil {
+			// record when the short lived token expires so that it can be refreshed
+			additionalAnnotations["lagoon.sh/registry-token-expiry"] = containerRegistry.ExpiresAt.UTC().Format(time.RFC3339)
+		}
 
 		// generate the auths config for the secret
 		auths := dockerconfig.ConfigFile{
@@ -4,12 +4,14 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/andreyvit/diff"
 	"github.com/uselagoon/build-deploy-tool/internal/generator"
 )
 
 func TestGenerateRegistrySecretTemplate(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
 	type args struct {
 		buildValues generator.BuildValues
 	}
@@ -99,6 +101,33 @@ func TestGenerateRegistrySecretTemplate(t *testing.T) {
 				},
 			},
 			want: "test-resources/regsecret/registry-secret2.yaml",
+		}, {
+			name:        "test3",
+			description: "test a token exchange registry records when the token expires",
+			args: args{
+				buildValues: generator.BuildValues{
+					Project:         "example-project",
+					Environment:     "environment-name",
+					EnvironmentType: "production",
+					Namespace:       "myexample-project-environment-name",
+					BuildType:       "branch",
+					LagoonVersion:   "v2.x.x",
+					Kubernetes:      "generator.local",
+					Branch:          "environment-name",
+					ContainerRegistry: []generator.ContainerRegistry{
+						{
+							Name:       "secret3",
+							Type:       "token-exchange",
+							SecretName: "internal-registry-secret-secret3",
+							Username:   "oauth2accesstoken",
+							Password:   "short-lived-token",
+							URL:        "my.registry.example.com",
+							ExpiresAt:  &expiresAt,
+						},
+					},
+				},
+			},
+			want: "test-resources/regsecret/registry-secret3.yaml",
 		},
 	}
 	for _, tt := range tests {
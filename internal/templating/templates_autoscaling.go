@@ -0,0 +1,211 @@
+package templating
+
+import (
+	"fmt"
+
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/servicetypes"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metavalidation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// GenerateHorizontalPodAutoscalerTemplate generates the lagoon template to apply.
+func GenerateHorizontalPodAutoscalerTemplate(
+	buildValues generator.BuildValues,
+) ([]autoscalingv2.HorizontalPodAutoscaler, error) {
+	var hpas []autoscalingv2.HorizontalPodAutoscaler
+
+	// check linked services
+	checkedServices := LinkedServiceCalculator(buildValues.Services)
+
+	// for all the services that the build values generated
+	// iterate over them and generate any kubernetes horizontalpodautoscalers
+	for _, serviceValues := range checkedServices {
+		autoscaling := serviceAutoscaling(serviceValues)
+		if !autoscaling.HasHorizontalPodAutoscaler() {
+			continue
+		}
+		serviceType, ok := autoscalingServiceType(serviceValues)
+		if !ok {
+			continue
+		}
+		labels, annotations := autoscalingMeta(buildValues, serviceValues, serviceType)
+		hpa := &autoscalingv2.HorizontalPodAutoscaler{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "HorizontalPodAutoscaler",
+				APIVersion: autoscalingv2.SchemeGroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        serviceValues.OverrideName,
+				Labels:      labels,
+				Annotations: annotations,
+			},
+			Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+					Kind:       "Deployment",
+					Name:       serviceValues.OverrideName,
+					APIVersion: appsv1.SchemeGroupVersion.String(),
+				},
+				MinReplicas: helpers.Int32Ptr(autoscaling.MinReplicas),
+				MaxReplicas: autoscaling.MaxReplicas,
+			},
+		}
+		if autoscaling.TargetCPUUtilization != nil {
+			hpa.Spec.Metrics = append(hpa.Spec.Metrics, resourceUtilizationMetric(corev1.ResourceCPU, *autoscaling.TargetCPUUtilization))
+		}
+		if autoscaling.TargetMemoryUtilization != nil {
+			hpa.Spec.Metrics = append(hpa.Spec.Metrics, resourceUtilizationMetric(corev1.ResourceMemory, *autoscaling.TargetMemoryUtilization))
+		}
+		if err := validateAutoscalingMeta(hpa.ObjectMeta, serviceValues.OverrideName); err != nil {
+			return nil, err
+		}
+		hpas = append(hpas, *hpa)
+	}
+	return hpas, nil
+}
+
+// GeneratePodDisruptionBudgetTemplate generates the lagoon template to apply.
+func GeneratePodDisruptionBudgetTemplate(
+	buildValues generator.BuildValues,
+) ([]policyv1.PodDisruptionBudget, error) {
+	var pdbs []policyv1.PodDisruptionBudget
+
+	// check linked services
+	checkedServices := LinkedServiceCalculator(buildValues.Services)
+
+	// for all the services that the build values generated
+	// iterate over them and generate any kubernetes poddisruptionbudgets
+	for _, serviceValues := range checkedServices {
+		autoscaling := serviceAutoscaling(serviceValues)
+		if !autoscaling.HasPodDisruptionBudget() {
+			continue
+		}
+		serviceType, ok := autoscalingServiceType(serviceValues)
+		if !ok {
+			continue
+		}
+		labels, annotations := autoscalingMeta(buildValues, serviceValues, serviceType)
+		pdb := &policyv1.PodDisruptionBudget{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "PodDisruptionBudget",
+				APIVersion: policyv1.SchemeGroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        serviceValues.OverrideName,
+				Labels:      labels,
+				Annotations: annotations,
+			},
+			Spec: policyv1.PodDisruptionBudgetSpec{
+				// the selector is the same as the selector of the deployment
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"app.kubernetes.io/name":     serviceType.Name,
+						"app.kubernetes.io/instance": serviceValues.OverrideName,
+					},
+				},
+				MinAvailable:   autoscaling.MinAvailable,
+				MaxUnavailable: autoscaling.MaxUnavailable,
+			},
+		}
+		if err := validateAutoscalingMeta(pdb.ObjectMeta, serviceValues.OverrideName); err != nil {
+			return nil, err
+		}
+		pdbs = append(pdbs, *pdb)
+	}
+	return pdbs, nil
+}
+
+// serviceAutoscaling returns the autoscaling configuration of a service, or of its linked service
+// if only the linked service defines it
+func serviceAutoscaling(serviceValues generator.ServiceValues) *generator.Autoscaling {
+	if serviceValues.Autoscaling == nil && serviceValues.LinkedService != nil {
+		return serviceValues.LinkedService.Autoscaling
+	}
+	return serviceValues.Autoscaling
+}
+
+// autoscalingServiceType returns the service type of a service that generates a deployment
+func autoscalingServiceType(serviceValues generator.ServiceValues) (servicetypes.ServiceType, bool) {
+	val, ok := servicetypes.ServiceTypes[serviceValues.Type]
+	if !ok || serviceValues.Type == "external" || serviceValues.IsDBaaS {
+		return servicetypes.ServiceType{}, false
+	}
+	return val, true
+}
+
+func autoscalingMeta(buildValues generator.BuildValues, serviceValues generator.ServiceValues, serviceType servicetypes.ServiceType) (map[string]string, map[string]string) {
+	labels := map[string]string{
+		"app.kubernetes.io/managed-by": "build-deploy-tool",
+		"app.kubernetes.io/name":       serviceType.Name,
+		"app.kubernetes.io/instance":   serviceValues.OverrideName,
+		"lagoon.sh/project":            buildValues.Project,
+		"lagoon.sh/environment":        buildValues.Environment,
+		"lagoon.sh/environmentType":    buildValues.EnvironmentType,
+		"lagoon.sh/buildType":          buildValues.BuildType,
+		"lagoon.sh/template":           fmt.Sprintf("%s-%s", serviceType.Name, "0.1.0"),
+		"lagoon.sh/service":            serviceValues.OverrideName,
+		"lagoon.sh/service-type":       serviceType.Name,
+	}
+	annotations := map[string]string{
+		"lagoon.sh/version": buildValues.LagoonVersion,
+	}
+	switch buildValues.BuildType {
+	case "branch":
+		annotations["lagoon.sh/branch"] = buildValues.Branch
+	case "pullrequest":
+		annotations["lagoon.sh/prNumber"] = buildValues.PRNumber
+		annotations["lagoon.sh/prHeadBranch"] = buildValues.PRHeadBranch
+		annotations["lagoon.sh/prBaseBranch"] = buildValues.PRBaseBranch
+	}
+	return labels, annotations
+}
+
+func validateAutoscalingMeta(objectMeta metav1.ObjectMeta, name string) error {
+	// validate any labels
+	if err := metavalidation.ValidateLabels(objectMeta.Labels, nil); err != nil {
+		if len(err) != 0 {
+			return fmt.Errorf("the labels for %s are not valid: %v", name, err)
+		}
+	}
+	// check length of labels
+	return helpers.CheckLabelLength(objectMeta.Labels)
+}
+
+func resourceUtilizationMetric(resource corev1.ResourceName, target int32) autoscalingv2.MetricSpec {
+	return autoscalingv2.MetricSpec{
+		Type: autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{
+			Name: resource,
+			Target: autoscalingv2.MetricTarget{
+				Type:               autoscalingv2.UtilizationMetricType,
+				AverageUtilization: helpers.Int32Ptr(target),
+			},
+		},
+	}
+}
+
+func TemplateHorizontalPodAutoscaler(item autoscalingv2.HorizontalPodAutoscaler) ([]byte, error) {
+	separator := []byte("---\n")
+	iBytes, err := yaml.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	templateYAML := append(separator[:], iBytes[:]...)
+	return templateYAML, nil
+}
+
+func TemplatePodDisruptionBudget(item policyv1.PodDisruptionBudget) ([]byte, error) {
+	separator := []byte("---\n")
+	iBytes, err := yaml.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate template: %v", err)
+	}
+	templateYAML := append(separator[:], iBytes[:]...)
+	return templateYAML, nil
+}
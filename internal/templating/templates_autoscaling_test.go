@@ -0,0 +1,205 @@
+package templating
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/andreyvit/diff"
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func autoscalingBuildValues(buildType string, services []generator.ServiceValues) generator.BuildValues {
+	return generator.BuildValues{
+		Project:         "example-project",
+		Environment:     "environment-name",
+		EnvironmentType: "production",
+		Namespace:       "myexample-project-environment-name",
+		BuildType:       buildType,
+		LagoonVersion:   "v2.x.x",
+		Kubernetes:      "generator.local",
+		Branch:          "environment-name",
+		PRNumber:        "123",
+		PRHeadBranch:    "feature",
+		PRBaseBranch:    "main",
+		Services:        services,
+	}
+}
+
+func TestGenerateHorizontalPodAutoscalerTemplate(t *testing.T) {
+	minAvailable := intstr.FromInt32(1)
+	maxUnavailable := intstr.FromString("50%")
+	type args struct {
+		buildValues generator.BuildValues
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "test1 - nginx-php with cpu and memory targets",
+			args: args{
+				buildValues: autoscalingBuildValues("branch", []generator.ServiceValues{
+					{
+						Name:         "nginx",
+						OverrideName: "nginx-php",
+						Type:         "nginx-php-persistent",
+						Autoscaling: &generator.Autoscaling{
+							MinReplicas:             2,
+							MaxReplicas:             5,
+							TargetCPUUtilization:    helpers.Int32Ptr(70),
+							TargetMemoryUtilization: helpers.Int32Ptr(80),
+							MinAvailable:            &minAvailable,
+						},
+					},
+					{
+						Name:         "php",
+						OverrideName: "nginx-php",
+						Type:         "nginx-php-persistent",
+					},
+					{
+						Name:         "redis",
+						OverrideName: "redis",
+						Type:         "redis",
+					},
+				}),
+			},
+			want: "test-resources/autoscaling/result-hpa-nginx-php-1.yaml",
+		},
+		{
+			name: "test2 - pullrequest with only a disruption budget",
+			args: args{
+				buildValues: autoscalingBuildValues("pullrequest", []generator.ServiceValues{
+					{
+						Name:         "node",
+						OverrideName: "node",
+						Type:         "node",
+						Autoscaling: &generator.Autoscaling{
+							MaxUnavailable: &maxUnavailable,
+						},
+					},
+					{
+						Name:         "worker",
+						OverrideName: "worker",
+						Type:         "worker",
+						Autoscaling: &generator.Autoscaling{
+							MinReplicas:          1,
+							MaxReplicas:          3,
+							TargetCPUUtilization: helpers.Int32Ptr(80),
+						},
+					},
+				}),
+			},
+			want: "test-resources/autoscaling/result-hpa-worker-1.yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateHorizontalPodAutoscalerTemplate(tt.args.buildValues)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GenerateHorizontalPodAutoscalerTemplate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			r1, err := os.ReadFile(tt.want)
+			if err != nil {
+				t.Errorf("couldn't read file %v: %v", tt.want, err)
+			}
+			var result []byte
+			for _, d := range got {
+				templateBytes, err := TemplateHorizontalPodAutoscaler(d)
+				if err != nil {
+					t.Errorf("couldn't generate template  %v", err)
+				}
+				result = append(result, templateBytes[:]...)
+			}
+			if !reflect.DeepEqual(string(result), string(r1)) {
+				t.Errorf("GenerateHorizontalPodAutoscalerTemplate() = \n%v", diff.LineDiff(string(r1), string(result)))
+			}
+		})
+	}
+}
+
+func TestGeneratePodDisruptionBudgetTemplate(t *testing.T) {
+	minAvailable := intstr.FromInt32(1)
+	maxUnavailable := intstr.FromString("50%")
+	type args struct {
+		buildValues generator.BuildValues
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "test1 - disruption budgets",
+			args: args{
+				buildValues: autoscalingBuildValues("branch", []generator.ServiceValues{
+					{
+						Name:         "nginx",
+						OverrideName: "nginx-php",
+						Type:         "nginx-php-persistent",
+						Autoscaling: &generator.Autoscaling{
+							MinReplicas:          2,
+							MaxReplicas:          5,
+							TargetCPUUtilization: helpers.Int32Ptr(70),
+							MinAvailable:         &minAvailable,
+						},
+					},
+					{
+						Name:         "php",
+						OverrideName: "nginx-php",
+						Type:         "nginx-php-persistent",
+					},
+					{
+						Name:         "node",
+						OverrideName: "node",
+						Type:         "node",
+						Autoscaling: &generator.Autoscaling{
+							MaxUnavailable: &maxUnavailable,
+						},
+					},
+					{
+						Name:         "worker",
+						OverrideName: "worker",
+						Type:         "worker",
+						Autoscaling: &generator.Autoscaling{
+							MinReplicas:          1,
+							MaxReplicas:          3,
+							TargetCPUUtilization: helpers.Int32Ptr(80),
+						},
+					},
+				}),
+			},
+			want: "test-resources/autoscaling/result-pdb-1.yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GeneratePodDisruptionBudgetTemplate(tt.args.buildValues)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GeneratePodDisruptionBudgetTemplate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			r1, err := os.ReadFile(tt.want)
+			if err != nil {
+				t.Errorf("couldn't read file %v: %v", tt.want, err)
+			}
+			var result []byte
+			for _, d := range got {
+				templateBytes, err := TemplatePodDisruptionBudget(d)
+				if err != nil {
+					t.Errorf("couldn't generate template  %v", err)
+				}
+				result = append(result, templateBytes[:]...)
+			}
+			if !reflect.DeepEqual(string(result), string(r1)) {
+				t.Errorf("GeneratePodDisruptionBudgetTemplate() = \n%v", diff.LineDiff(string(r1), string(result)))
+			}
+		})
+	}
+}
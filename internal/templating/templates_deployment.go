@@ -133,6 +133,10 @@ func GenerateDeploymentTemplate(
 			if serviceValues.Replicas != 0 {
 				deployment.Spec.Replicas = helpers.Int32Ptr(serviceValues.Replicas)
 			}
+			if serviceAutoscaling(serviceValues).HasHorizontalPodAutoscaler() {
+				// the horizontalpodautoscaler owns the replicas, setting them here would reset the scaled replicas on every deployment
+				deployment.Spec.Replicas = nil
+			}
 			deployment.Spec.Selector = &metav1.LabelSelector{
 				MatchLabels: map[string]string{
 					"app.kubernetes.io/name":     serviceTypeValues.Name,
@@ -140,6 +144,19 @@ func GenerateDeploymentTemplate(
 				},
 			}
 			deployment.Spec.Strategy = serviceTypeValues.Strategy
+			if serviceValues.DeploymentStrategy != nil {
+				// the service has opted in to a zero downtime rolling update instead of the strategy of the service type
+				maxSurge := serviceValues.DeploymentStrategy.MaxSurge
+				maxUnavailable := serviceValues.DeploymentStrategy.MaxUnavailable
+				deployment.Spec.Strategy = appsv1.DeploymentStrategy{
+					Type: appsv1.RollingUpdateDeploymentStrategyType,
+					RollingUpdate: &appsv1.RollingUpdateDeployment{
+						MaxSurge:       &maxSurge,
+						MaxUnavailable: &maxUnavailable,
+					},
+				}
+				deployment.Spec.MinReadySeconds = serviceValues.DeploymentStrategy.MinReadySeconds
+			}
 
 			podTemplateSpec, err := generatePodTemplateSpec(buildValues, serviceValues, serviceTypeValues, deployment.ObjectMeta, templateAnnotations, serviceTypeValues.PrimaryContainer.Name, "")
 			if err != nil {
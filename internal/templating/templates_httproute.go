@@ -0,0 +1,435 @@
+package templating
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"github.com/uselagoon/build-deploy-tool/internal/servicetypes"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	metavalidation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+	"sigs.k8s.io/yaml"
+)
+
+// the listeners of the parent gateway that insecure traffic is split across
+const (
+	gatewayHTTPListener  = "http"
+	gatewayHTTPSListener = "https"
+)
+
+// GenerateHTTPRouteTemplate generates the gateway api templates for a route to apply. The route is attached to the parent gateway,
+// and if insecure traffic should be redirected an additional httproute is attached to the http listener of the gateway to redirect to https
+func GenerateHTTPRouteTemplate(
+	route lagoon.RouteV2,
+	lValues generator.BuildValues,
+) ([]gatewayv1.HTTPRoute, error) {
+	if lValues.Gateway == nil {
+		return nil, fmt.Errorf("no parent gateway is defined for %s", route.Domain)
+	}
+	_, labels, annotations := generateRouteMetadata(&route, lValues, "custom-httproute-0.1.0")
+
+	httpRoute := gatewayv1.HTTPRoute{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "HTTPRoute",
+			APIVersion: gatewayv1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        route.IngressName,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+	}
+	// add any annotations that the route had to overwrite any previous annotations
+	for key, value := range route.Annotations {
+		httpRoute.ObjectMeta.Annotations[key] = value
+	}
+	// add any labels that the route had to overwrite any previous labels
+	for key, value := range route.Labels {
+		httpRoute.ObjectMeta.Labels[key] = value
+	}
+	// the template label of the route refers to the ingress template, so it is replaced with the httproute template
+	if route.Autogenerated {
+		httpRoute.ObjectMeta.Labels["lagoon.sh/template"] = "autogenerated-httproute-0.1.0"
+	}
+	// validate any annotations
+	if err := apivalidation.ValidateAnnotations(httpRoute.ObjectMeta.Annotations, nil); err != nil {
+		if len(err) != 0 {
+			return nil, fmt.Errorf("the annotations for %s are not valid: %v", route.Domain, err)
+		}
+	}
+	// validate any labels
+	if err := metavalidation.ValidateLabels(httpRoute.ObjectMeta.Labels, nil); err != nil {
+		if len(err) != 0 {
+			return nil, fmt.Errorf("the labels for %s are not valid: %v", route.Domain, err)
+		}
+	}
+
+	// the main domain is the first hostname, followed by any alternative names
+	httpRoute.Spec.Hostnames = []gatewayv1.Hostname{gatewayv1.Hostname(route.Domain)}
+	for _, alternativeName := range route.AlternativeNames {
+		httpRoute.Spec.Hostnames = append(httpRoute.Spec.Hostnames, gatewayv1.Hostname(alternativeName))
+	}
+
+	// insecure traffic is allowed on all the listeners of the gateway, otherwise the route is only attached to the https listener
+	switch *route.Insecure {
+	case "Allow":
+		httpRoute.Spec.ParentRefs = []gatewayv1.ParentReference{gatewayParentRef(lValues.Gateway, "")}
+	default:
+		httpRoute.Spec.ParentRefs = []gatewayv1.ParentReference{gatewayParentRef(lValues.Gateway, gatewayHTTPSListener)}
+	}
+
+	// response headers that nginx would add using snippets are added by a filter on every rule
+	var filters []gatewayv1.HTTPRouteFilter
+	headers := []gatewayv1.HTTPHeader{}
+	if lValues.EnvironmentType == "development" || route.Autogenerated {
+		headers = append(headers, gatewayv1.HTTPHeader{Name: "X-Robots-Tag", Value: "noindex, nofollow"})
+	}
+	if route.HSTSEnabled != nil && *route.HSTSEnabled {
+		hstsHeader := fmt.Sprintf("max-age=%d", route.HSTSMaxAge)
+		if route.HSTSIncludeSubdomains != nil && *route.HSTSIncludeSubdomains {
+			hstsHeader = fmt.Sprintf("%s%s", hstsHeader, "; includeSubDomains")
+		}
+		if route.HSTSPreload != nil && *route.HSTSPreload {
+			hstsHeader = fmt.Sprintf("%s%s", hstsHeader, "; preload")
+		}
+		headers = append(headers, gatewayv1.HTTPHeader{Name: "Strict-Transport-Security", Value: hstsHeader})
+	}
+	if len(headers) > 0 {
+		filters = append(filters, gatewayv1.HTTPRouteFilter{
+			Type: gatewayv1.HTTPRouteFilterResponseHeaderModifier,
+			ResponseHeaderModifier: &gatewayv1.HTTPHeaderFilter{
+				Set: headers,
+			},
+		})
+	}
+
+	// redirect routes only have the redirect rule, there is no backend service
+	if route.Redirect != nil {
+		httpRoute.ObjectMeta.Labels["lagoon.sh/template"] = "redirect-httproute-0.1.0"
+		redirectRule, err := generateRedirectRule(route.Domain, *route.Redirect)
+		if err != nil {
+			return nil, err
+		}
+		httpRoute.Spec.Rules = []gatewayv1.HTTPRouteRule{redirectRule}
+		return []gatewayv1.HTTPRoute{httpRoute}, nil
+	}
+
+	// set up the default rule to point to the backend service as required
+	backendRef, err := generateRouteBackendRef(route.LagoonService, lValues, true)
+	if err != nil {
+		return nil, err
+	}
+	httpRoute.Spec.Rules = []gatewayv1.HTTPRouteRule{
+		generateHTTPRouteRule("/", backendRef, filters),
+	}
+	// check for any path based routes defined against this route
+	for _, pr := range route.PathRoutes {
+		backendRef, err := generateRouteBackendRef(pr.ToService, lValues, false)
+		if err != nil {
+			return nil, err
+		}
+		httpRoute.Spec.Rules = append(httpRoute.Spec.Rules, generateHTTPRouteRule(pr.Path, backendRef, filters))
+	}
+	httpRoutes := []gatewayv1.HTTPRoute{httpRoute}
+
+	if *route.Insecure == "Redirect" {
+		// the redirect route uses the same metadata as the route it redirects for
+		redirect := gatewayv1.HTTPRoute{
+			TypeMeta: httpRoute.TypeMeta,
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        fmt.Sprintf("%s-redirect", route.IngressName),
+				Labels:      map[string]string{},
+				Annotations: map[string]string{},
+			},
+		}
+		for key, value := range httpRoute.ObjectMeta.Labels {
+			redirect.ObjectMeta.Labels[key] = value
+		}
+		// the redirect is removed with the route it redirects for, so it is labelled to be excluded from route cleanup
+		redirect.ObjectMeta.Labels["route.lagoon.sh/redirect"] = "true"
+		for key, value := range httpRoute.ObjectMeta.Annotations {
+			redirect.ObjectMeta.Annotations[key] = value
+		}
+		redirect.Spec.Hostnames = httpRoute.Spec.Hostnames
+		redirect.Spec.ParentRefs = []gatewayv1.ParentReference{gatewayParentRef(lValues.Gateway, gatewayHTTPListener)}
+		redirect.Spec.Rules = []gatewayv1.HTTPRouteRule{
+			{
+				Filters: []gatewayv1.HTTPRouteFilter{
+					{
+						Type: gatewayv1.HTTPRouteFilterRequestRedirect,
+						RequestRedirect: &gatewayv1.HTTPRequestRedirectFilter{
+							Scheme:     helpers.StrPtr("https"),
+							StatusCode: helpers.IntPtr(http.StatusMovedPermanently),
+						},
+					},
+				},
+			},
+		}
+		httpRoutes = append(httpRoutes, redirect)
+	}
+	return httpRoutes, nil
+}
+
+// generateRedirectRule generates the rule for a redirect route, the gateway api always preserves the query of a redirect
+// and only supports the 301 and 302 status codes, so any other redirect can't be templated as an httproute
+func generateRedirectRule(domain string, redirect lagoon.RouteRedirect) (gatewayv1.HTTPRouteRule, error) {
+	if !redirect.PreserveQuery {
+		return gatewayv1.HTTPRouteRule{}, fmt.Errorf("the redirect for %s doesn't preserve the query, this is not supported by httproutes", domain)
+	}
+	// the gateway api only supports the 301 and 302 redirect status codes
+	if redirect.StatusCode != http.StatusMovedPermanently && redirect.StatusCode != http.StatusFound {
+		return gatewayv1.HTTPRouteRule{}, fmt.Errorf("the redirect status code %d for %s is not supported by httproutes, it must be one of 301 or 302", redirect.StatusCode, domain)
+	}
+	target, err := url.Parse(redirect.Target)
+	if err != nil {
+		return gatewayv1.HTTPRouteRule{}, fmt.Errorf("the redirect target %s for %s is not valid: %v", redirect.Target, domain, err)
+	}
+	hostname := gatewayv1.PreciseHostname(target.Hostname())
+	requestRedirect := &gatewayv1.HTTPRequestRedirectFilter{
+		Scheme:     helpers.StrPtr(target.Scheme),
+		Hostname:   &hostname,
+		StatusCode: helpers.IntPtr(redirect.StatusCode),
+	}
+	if target.Port() != "" {
+		port, err := strconv.Atoi(target.Port())
+		if err != nil {
+			return gatewayv1.HTTPRouteRule{}, fmt.Errorf("the redirect target %s for %s has an invalid port: %v", redirect.Target, domain, err)
+		}
+		portNumber := gatewayv1.PortNumber(port)
+		requestRedirect.Port = &portNumber
+	}
+	targetPath := target.Path
+	if targetPath == "" {
+		targetPath = "/"
+	}
+	if !redirect.PreservePath {
+		requestRedirect.Path = &gatewayv1.HTTPPathModifier{
+			Type:            gatewayv1.FullPathHTTPPathModifier,
+			ReplaceFullPath: helpers.StrPtr(targetPath),
+		}
+	} else if targetPath != "/" {
+		// the prefix match of the rule is replaced with the path of the target
+		requestRedirect.Path = &gatewayv1.HTTPPathModifier{
+			Type:               gatewayv1.PrefixMatchHTTPPathModifier,
+			ReplacePrefixMatch: helpers.StrPtr(targetPath),
+		}
+	}
+	pathType := gatewayv1.PathMatchPathPrefix
+	return gatewayv1.HTTPRouteRule{
+		Matches: []gatewayv1.HTTPRouteMatch{
+			{
+				Path: &gatewayv1.HTTPPathMatch{
+					Type:  &pathType,
+					Value: helpers.StrPtr("/"),
+				},
+			},
+		},
+		Filters: []gatewayv1.HTTPRouteFilter{
+			{
+				Type:            gatewayv1.HTTPRouteFilterRequestRedirect,
+				RequestRedirect: requestRedirect,
+			},
+		},
+	}, nil
+}
+
+// GenerateHTTPRouteCertificate generates the cert-manager certificate for a route that uses tls-acme. cert-manager only requests certificates
+// for ingress with the tls-acme annotation, so the certificate for the hostnames of the httproute is requested directly from the issuer of the gateway.
+// If the parent gateway is in another namespace, a referencegrant allows the https listener of the gateway to use the certificate secret
+func GenerateHTTPRouteCertificate(
+	route lagoon.RouteV2,
+	lValues generator.BuildValues,
+) ([]client.Object, error) {
+	if lValues.Gateway == nil {
+		return nil, fmt.Errorf("no parent gateway is defined for %s", route.Domain)
+	}
+	if route.TLSAcme == nil || !*route.TLSAcme {
+		return nil, nil
+	}
+	if lValues.Gateway.CertificateIssuer == "" {
+		return nil, fmt.Errorf("the route %s uses tls-acme but no certificate issuer is defined for the gateway api, contact your Lagoon administrator", route.Domain)
+	}
+	truncatedRouteDomain, labels, _ := generateRouteMetadata(&route, lValues, "certificate-0.1.0")
+	for key, value := range route.Labels {
+		labels[key] = value
+	}
+	labels["lagoon.sh/template"] = "certificate-0.1.0"
+	secretName := tlsSecretName(route, truncatedRouteDomain)
+	dnsNames := []interface{}{}
+	for _, host := range append(tlsHosts(route, lValues), route.AlternativeNames...) {
+		dnsNames = append(dnsNames, host)
+	}
+	certificateLabels := map[string]interface{}{}
+	for key, value := range labels {
+		certificateLabels[key] = value
+	}
+	certificate := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "cert-manager.io/v1",
+			"kind":       "Certificate",
+			"metadata": map[string]interface{}{
+				"name":   secretName,
+				"labels": certificateLabels,
+			},
+			"spec": map[string]interface{}{
+				"secretName": secretName,
+				"dnsNames":   dnsNames,
+				"issuerRef": map[string]interface{}{
+					"group": "cert-manager.io",
+					"kind":  "ClusterIssuer",
+					"name":  lValues.Gateway.CertificateIssuer,
+				},
+			},
+		},
+	}
+	objects := []client.Object{certificate}
+	if lValues.Gateway.Namespace == "" || lValues.Gateway.Namespace == lValues.Namespace {
+		return objects, nil
+	}
+	secretObjectName := gatewayv1.ObjectName(secretName)
+	objects = append(objects, &gatewayv1beta1.ReferenceGrant{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ReferenceGrant",
+			APIVersion: gatewayv1beta1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   secretName,
+			Labels: labels,
+		},
+		Spec: gatewayv1beta1.ReferenceGrantSpec{
+			From: []gatewayv1beta1.ReferenceGrantFrom{
+				{
+					Group:     gatewayv1.GroupName,
+					Kind:      "Gateway",
+					Namespace: gatewayv1.Namespace(lValues.Gateway.Namespace),
+				},
+			},
+			To: []gatewayv1beta1.ReferenceGrantTo{
+				{
+					Group: "",
+					Kind:  "Secret",
+					Name:  &secretObjectName,
+				},
+			},
+		},
+	})
+	return objects, nil
+}
+
+func gatewayParentRef(gateway *generator.Gateway, sectionName string) gatewayv1.ParentReference {
+	parentRef := gatewayv1.ParentReference{
+		Name: gatewayv1.ObjectName(gateway.Name),
+	}
+	if gateway.Namespace != "" {
+		namespace := gatewayv1.Namespace(gateway.Namespace)
+		parentRef.Namespace = &namespace
+	}
+	if sectionName != "" {
+		section := gatewayv1.SectionName(sectionName)
+		parentRef.SectionName = &section
+	}
+	return parentRef
+}
+
+func generateHTTPRouteRule(path string, backendRef gatewayv1.BackendObjectReference, filters []gatewayv1.HTTPRouteFilter) gatewayv1.HTTPRouteRule {
+	pathType := gatewayv1.PathMatchPathPrefix
+	return gatewayv1.HTTPRouteRule{
+		Matches: []gatewayv1.HTTPRouteMatch{
+			{
+				Path: &gatewayv1.HTTPPathMatch{
+					Type:  &pathType,
+					Value: helpers.StrPtr(path),
+				},
+			},
+		},
+		Filters: filters,
+		BackendRefs: []gatewayv1.HTTPBackendRef{
+			{
+				BackendRef: gatewayv1.BackendRef{
+					BackendObjectReference: backendRef,
+				},
+			},
+		},
+	}
+}
+
+// generateRouteBackendRef works out the service and port number that a route sends traffic to, httproutes can't reference the
+// named `http` port that ingress uses so the port number of the service is used instead
+func generateRouteBackendRef(toService string, lValues generator.BuildValues, defaultRoute bool) (gatewayv1.BackendObjectReference, error) {
+	// check the additional service ports for a port specific service name, like `servicename-port`
+	for _, service := range lValues.Services {
+		for _, addPort := range service.AdditionalServicePorts {
+			if addPort.ServiceName == toService {
+				backendService := addPort.ServiceOverrideName
+				if defaultRoute {
+					backendService = service.OverrideName
+				}
+				return serviceBackendRef(backendService, int32(addPort.ServicePort.Target)), nil
+			}
+		}
+	}
+	for _, service := range lValues.Services {
+		if service.OverrideName != toService {
+			continue
+		}
+		// the first port in the additional ports is the "default" port
+		if len(service.AdditionalServicePorts) > 0 {
+			return serviceBackendRef(service.OverrideName, int32(service.AdditionalServicePorts[0].ServicePort.Target)), nil
+		}
+		// otherwise the first port of the service type
+		if serviceType, ok := servicetypes.ServiceTypes[service.Type]; ok && len(serviceType.Ports.Ports) > 0 {
+			port := serviceType.Ports.Ports[0].Port
+			if serviceType.Ports.CanChangePort && service.ServicePort != 0 {
+				port = service.ServicePort
+			}
+			return serviceBackendRef(service.OverrideName, port), nil
+		}
+	}
+	return gatewayv1.BackendObjectReference{}, fmt.Errorf("couldn't find a port for service %s to use in a httproute", toService)
+}
+
+func serviceBackendRef(name string, port int32) gatewayv1.BackendObjectReference {
+	portNumber := gatewayv1.PortNumber(port)
+	return gatewayv1.BackendObjectReference{
+		Name: gatewayv1.ObjectName(name),
+		Port: &portNumber,
+	}
+}
+
+// TemplateHTTPRouteCertificate templates the certificate objects of an httproute
+func TemplateHTTPRouteCertificate(objects []client.Object) ([]byte, error) {
+	separator := []byte("---\n")
+	var templateYAML []byte
+	for _, obj := range objects {
+		iBytes, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't generate template: %v", err)
+		}
+		restoreResult := append(separator[:], iBytes[:]...)
+		templateYAML = append(templateYAML, restoreResult[:]...)
+	}
+	return templateYAML, nil
+}
+
+func TemplateHTTPRoutes(httpRoutes []gatewayv1.HTTPRoute) ([]byte, error) {
+	separator := []byte("---\n")
+	var templateYAML []byte
+	for _, httpRoute := range httpRoutes {
+		iBytes, err := yaml.Marshal(httpRoute)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't generate template: %v", err)
+		}
+		restoreResult := append(separator[:], iBytes[:]...)
+		templateYAML = append(templateYAML, restoreResult[:]...)
+	}
+	return templateYAML, nil
+}
@@ -0,0 +1,503 @@
+package templating
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/andreyvit/diff"
+	"github.com/compose-spec/compose-go/types"
+	"github.com/uselagoon/build-deploy-tool/internal/generator"
+	"github.com/uselagoon/build-deploy-tool/internal/helpers"
+	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+)
+
+func TestGenerateHTTPRouteTemplate(t *testing.T) {
+	type args struct {
+		route  lagoon.RouteV2
+		values generator.BuildValues
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "custom-httproute1",
+			args: args{
+				route: lagoon.RouteV2{
+					Domain:         "www.example.com",
+					LagoonService:  "nginx",
+					MonitoringPath: "/",
+					Insecure:       helpers.StrPtr("Redirect"),
+					TLSAcme:        helpers.BoolPtr(true),
+					Migrate:        helpers.BoolPtr(false),
+					Annotations: map[string]string{
+						"custom-annotation": "custom annotation value",
+					},
+					Fastly: lagoon.Fastly{
+						Watch: false,
+					},
+					AlternativeNames:      []string{"example.com"},
+					HSTSEnabled:           helpers.BoolPtr(true),
+					HSTSMaxAge:            31536000,
+					HSTSIncludeSubdomains: helpers.BoolPtr(true),
+					PathRoutes: []lagoon.PathRoute{
+						{
+							ToService: "node",
+							Path:      "/api",
+						},
+					},
+					IngressName: "www.example.com",
+					Source:      "YAML",
+				},
+				values: generator.BuildValues{
+					Project:         "example-project",
+					Environment:     "main",
+					EnvironmentType: "production",
+					Namespace:       "example-project-main",
+					BuildType:       "branch",
+					LagoonVersion:   "v2.x.x",
+					Kubernetes:      "lagoon.local",
+					Branch:          "main",
+					Gateway: &generator.Gateway{
+						Namespace: "lagoon-gateway",
+						Name:      "lagoon",
+					},
+					Services: []generator.ServiceValues{
+						{
+							Name:         "nginx",
+							OverrideName: "nginx",
+							Type:         "nginx-php",
+						},
+						{
+							Name:         "node",
+							OverrideName: "node",
+							Type:         "node",
+						},
+					},
+				},
+			},
+			want: "test-resources/httproute/result-custom-httproute1.yaml",
+		},
+		{
+			name: "autogenerated-httproute1",
+			args: args{
+				route: lagoon.RouteV2{
+					Domain:        "myservice-po-main-example-project.example.com",
+					LagoonService: "myservice-po-8192",
+					Insecure:      helpers.StrPtr("Allow"),
+					TLSAcme:       helpers.BoolPtr(true),
+					Annotations:   map[string]string{},
+					Fastly: lagoon.Fastly{
+						Watch: false,
+					},
+					Labels: map[string]string{
+						"lagoon.sh/autogenerated":    "true",
+						"app.kubernetes.io/name":     "autogenerated-ingress",
+						"app.kubernetes.io/instance": "myservice-po",
+						"lagoon.sh/service":          "myservice-po",
+						"lagoon.sh/service-type":     "basic",
+						"lagoon.sh/template":         "autogenerated-ingress-0.1.0",
+					},
+					Autogenerated: true,
+					IngressName:   "myservice-po",
+					Source:        "AUTOGENERATED",
+				},
+				values: generator.BuildValues{
+					Project:         "example-project",
+					Environment:     "main",
+					EnvironmentType: "development",
+					Namespace:       "example-project-main",
+					BuildType:       "branch",
+					LagoonVersion:   "v2.x.x",
+					Kubernetes:      "lagoon.local",
+					Branch:          "main",
+					Gateway: &generator.Gateway{
+						Name: "lagoon",
+					},
+					Services: []generator.ServiceValues{
+						{
+							Name:         "myservice-po",
+							OverrideName: "myservice-po",
+							Type:         "basic",
+							AdditionalServicePorts: []generator.AdditionalServicePort{
+								{
+									ServiceName: "myservice-po-8192",
+									ServicePort: types.ServicePortConfig{
+										Target:   8192,
+										Protocol: "tcp",
+									},
+								},
+								{
+									ServiceName: "myservice-po-8211",
+									ServicePort: types.ServicePortConfig{
+										Target:   8211,
+										Protocol: "tcp",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: "test-resources/httproute/result-autogenerated-httproute1.yaml",
+		},
+		{
+			name: "redirect-httproute1",
+			args: args{
+				route: lagoon.RouteV2{
+					Domain:        "old.example.com",
+					LagoonService: "nginx",
+					Insecure:      helpers.StrPtr("Allow"),
+					TLSAcme:       helpers.BoolPtr(true),
+					IngressName:   "old.example.com",
+					Redirect: &lagoon.RouteRedirect{
+						Target:        "https://www.example.com/blog",
+						StatusCode:    301,
+						PreservePath:  true,
+						PreserveQuery: true,
+					},
+				},
+				values: generator.BuildValues{
+					Project:         "example-project",
+					Environment:     "main",
+					EnvironmentType: "production",
+					Namespace:       "example-project-main",
+					BuildType:       "branch",
+					LagoonVersion:   "v2.x.x",
+					Kubernetes:      "lagoon.local",
+					Branch:          "main",
+					Gateway: &generator.Gateway{
+						Namespace: "lagoon-gateway",
+						Name:      "lagoon",
+					},
+					Services: []generator.ServiceValues{
+						{
+							Name:         "nginx",
+							OverrideName: "nginx",
+							Type:         "nginx-php",
+						},
+					},
+				},
+			},
+			want: "test-resources/httproute/result-redirect-httproute1.yaml",
+		},
+		{
+			name: "redirect httproute that drops the query",
+			args: args{
+				route: lagoon.RouteV2{
+					Domain:        "old.example.com",
+					LagoonService: "nginx",
+					Insecure:      helpers.StrPtr("Allow"),
+					TLSAcme:       helpers.BoolPtr(true),
+					IngressName:   "old.example.com",
+					Redirect: &lagoon.RouteRedirect{
+						Target:     "https://www.example.com/",
+						StatusCode: 301,
+					},
+				},
+				values: generator.BuildValues{
+					Project:         "example-project",
+					Environment:     "main",
+					EnvironmentType: "production",
+					Namespace:       "example-project-main",
+					BuildType:       "branch",
+					Branch:          "main",
+					Gateway: &generator.Gateway{
+						Name: "lagoon",
+					},
+					Services: []generator.ServiceValues{
+						{
+							Name:         "nginx",
+							OverrideName: "nginx",
+							Type:         "nginx-php",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "redirect httproute with an unsupported status code",
+			args: args{
+				route: lagoon.RouteV2{
+					Domain:        "old.example.com",
+					LagoonService: "nginx",
+					Insecure:      helpers.StrPtr("Allow"),
+					TLSAcme:       helpers.BoolPtr(true),
+					IngressName:   "old.example.com",
+					Redirect: &lagoon.RouteRedirect{
+						Target:        "https://www.example.com/",
+						StatusCode:    308,
+						PreservePath:  true,
+						PreserveQuery: true,
+					},
+				},
+				values: generator.BuildValues{
+					Project:         "example-project",
+					Environment:     "main",
+					EnvironmentType: "production",
+					Namespace:       "example-project-main",
+					BuildType:       "branch",
+					Branch:          "main",
+					Gateway: &generator.Gateway{
+						Name: "lagoon",
+					},
+					Services: []generator.ServiceValues{
+						{
+							Name:         "nginx",
+							OverrideName: "nginx",
+							Type:         "nginx-php",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "httproute to a service without a port",
+			args: args{
+				route: lagoon.RouteV2{
+					Domain:        "www.example.com",
+					LagoonService: "worker",
+					Insecure:      helpers.StrPtr("Redirect"),
+					TLSAcme:       helpers.BoolPtr(true),
+					IngressName:   "www.example.com",
+					Source:        "YAML",
+				},
+				values: generator.BuildValues{
+					Project:         "example-project",
+					Environment:     "main",
+					EnvironmentType: "production",
+					Namespace:       "example-project-main",
+					BuildType:       "branch",
+					Branch:          "main",
+					Gateway: &generator.Gateway{
+						Name: "lagoon",
+					},
+					Services: []generator.ServiceValues{
+						{
+							Name:         "worker",
+							OverrideName: "worker",
+							Type:         "worker",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateHTTPRouteTemplate(tt.args.route, tt.args.values)
+			if err != nil {
+				if !tt.wantErr {
+					t.Errorf("couldn't generate template %v: %v", tt.want, err)
+				}
+			}
+			if got != nil && tt.wantErr {
+				t.Errorf("wanted an error, but didn't get one")
+			}
+			if !tt.wantErr {
+				r1, err := os.ReadFile(tt.want)
+				if err != nil {
+					t.Errorf("couldn't read file %v: %v", tt.want, err)
+				}
+				gotR, err := TemplateHTTPRoutes(got)
+				if err != nil {
+					t.Errorf("couldn't generate template  %v", err)
+				}
+				if !reflect.DeepEqual(string(gotR), string(r1)) {
+					t.Errorf("GenerateHTTPRouteTemplate() = \n%v", diff.LineDiff(string(r1), string(gotR)))
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateHTTPRouteCertificate(t *testing.T) {
+	type args struct {
+		route  lagoon.RouteV2
+		values generator.BuildValues
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr string
+	}{
+		{
+			name: "custom-certificate1",
+			args: args{
+				route: lagoon.RouteV2{
+					Domain:           "www.example.com",
+					LagoonService:    "nginx",
+					Insecure:         helpers.StrPtr("Redirect"),
+					TLSAcme:          helpers.BoolPtr(true),
+					AlternativeNames: []string{"example.com"},
+					IngressName:      "www.example.com",
+					Source:           "YAML",
+				},
+				values: generator.BuildValues{
+					Project:         "example-project",
+					Environment:     "main",
+					EnvironmentType: "production",
+					Namespace:       "example-project-main",
+					BuildType:       "branch",
+					LagoonVersion:   "v2.x.x",
+					Branch:          "main",
+					Gateway: &generator.Gateway{
+						Namespace:         "lagoon-gateway",
+						Name:              "lagoon",
+						CertificateIssuer: "lagoon-acme",
+					},
+				},
+			},
+			want: "test-resources/httproute/result-custom-certificate1.yaml",
+		},
+		{
+			name: "autogenerated-certificate1",
+			args: args{
+				route: lagoon.RouteV2{
+					Domain:        "node-example-project-a-very-long-environment-name-that-is-too-long.example.com",
+					LagoonService: "node",
+					Insecure:      helpers.StrPtr("Allow"),
+					TLSAcme:       helpers.BoolPtr(true),
+					Labels: map[string]string{
+						"lagoon.sh/autogenerated":    "true",
+						"app.kubernetes.io/name":     "autogenerated-ingress",
+						"app.kubernetes.io/instance": "node",
+						"lagoon.sh/service":          "node",
+						"lagoon.sh/service-type":     "node",
+						"lagoon.sh/template":         "autogenerated-ingress-0.1.0",
+					},
+					Autogenerated: true,
+					IngressName:   "node",
+					Source:        "AUTOGENERATED",
+				},
+				values: generator.BuildValues{
+					Project:         "example-project",
+					Environment:     "a-very-long-environment-name-that-is-too-long",
+					EnvironmentType: "development",
+					Namespace:       "example-project-a-very-long-environment-name-that-is-too-long",
+					BuildType:       "branch",
+					LagoonVersion:   "v2.x.x",
+					Branch:          "a-very-long-environment-name-that-is-too-long",
+					Gateway: &generator.Gateway{
+						Name:              "lagoon",
+						CertificateIssuer: "lagoon-acme",
+					},
+					Services: []generator.ServiceValues{
+						{
+							Name:                          "node",
+							OverrideName:                  "node",
+							Type:                          "node",
+							ShortAutogeneratedRouteDomain: "abcdefgh.example.com",
+						},
+					},
+				},
+			},
+			want: "test-resources/httproute/result-autogenerated-certificate1.yaml",
+		},
+		{
+			name: "redirect-certificate1",
+			args: args{
+				route: lagoon.RouteV2{
+					Domain:        "old.example.com",
+					LagoonService: "nginx",
+					Insecure:      helpers.StrPtr("Allow"),
+					TLSAcme:       helpers.BoolPtr(true),
+					IngressName:   "old.example.com",
+					Redirect: &lagoon.RouteRedirect{
+						Target:        "https://www.example.com/",
+						StatusCode:    301,
+						PreservePath:  true,
+						PreserveQuery: true,
+					},
+				},
+				values: generator.BuildValues{
+					Project:         "example-project",
+					Environment:     "main",
+					EnvironmentType: "production",
+					Namespace:       "example-project-main",
+					BuildType:       "branch",
+					LagoonVersion:   "v2.x.x",
+					Branch:          "main",
+					Gateway: &generator.Gateway{
+						Name:              "lagoon",
+						CertificateIssuer: "lagoon-acme",
+					},
+				},
+			},
+			want: "test-resources/httproute/result-redirect-certificate1.yaml",
+		},
+		{
+			name: "tls-acme disabled",
+			args: args{
+				route: lagoon.RouteV2{
+					Domain:        "www.example.com",
+					LagoonService: "nginx",
+					Insecure:      helpers.StrPtr("Allow"),
+					TLSAcme:       helpers.BoolPtr(false),
+					IngressName:   "www.example.com",
+				},
+				values: generator.BuildValues{
+					Gateway: &generator.Gateway{
+						Name: "lagoon",
+					},
+				},
+			},
+		},
+		{
+			name: "no certificate issuer",
+			args: args{
+				route: lagoon.RouteV2{
+					Domain:        "www.example.com",
+					LagoonService: "nginx",
+					Insecure:      helpers.StrPtr("Allow"),
+					TLSAcme:       helpers.BoolPtr(true),
+					IngressName:   "www.example.com",
+				},
+				values: generator.BuildValues{
+					Gateway: &generator.Gateway{
+						Name: "lagoon",
+					},
+				},
+			},
+			wantErr: "the route www.example.com uses tls-acme but no certificate issuer is defined for the gateway api, contact your Lagoon administrator",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateHTTPRouteCertificate(tt.args.route, tt.args.values)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("GenerateHTTPRouteCertificate() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateHTTPRouteCertificate() error = %v", err)
+			}
+			if tt.want == "" {
+				if len(got) != 0 {
+					t.Errorf("GenerateHTTPRouteCertificate() = %v, want no certificate", got)
+				}
+				return
+			}
+			r1, err := os.ReadFile(tt.want)
+			if err != nil {
+				t.Errorf("couldn't read file %v: %v", tt.want, err)
+			}
+			gotR, err := TemplateHTTPRouteCertificate(got)
+			if err != nil {
+				t.Errorf("couldn't generate template  %v", err)
+			}
+			if !reflect.DeepEqual(string(gotR), string(r1)) {
+				t.Errorf("GenerateHTTPRouteCertificate() = \n%v", diff.LineDiff(string(r1), string(gotR)))
+			}
+		})
+	}
+}
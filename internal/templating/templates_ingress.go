@@ -2,6 +2,7 @@ package templating
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -22,16 +23,11 @@ func GenerateIngressTemplate(
 	route lagoon.RouteV2,
 	lValues generator.BuildValues,
 ) (*networkv1.Ingress, error) {
-
-	// truncate the route for use in labels and secretname
-	truncatedRouteDomain := route.Domain
-	if len(truncatedRouteDomain) >= 53 {
-		subdomain := strings.Split(truncatedRouteDomain, ".")[0]
-		if errs := utilvalidation.IsValidLabelValue(subdomain); errs != nil {
-			subdomain = subdomain[:53]
-		}
-		truncatedRouteDomain = fmt.Sprintf("%s-%s", strings.Split(subdomain, ".")[0], helpers.GetMD5HashWithNewLine(route.Domain)[:5])
+	template := "custom-ingress-0.1.0"
+	if route.Redirect != nil {
+		template = "redirect-ingress-0.1.0"
 	}
+	truncatedRouteDomain, labels, annotations := generateRouteMetadata(&route, lValues, template)
 
 	// create the ingress object for templating
 	ingress := &networkv1.Ingress{}
@@ -40,92 +36,10 @@ func GenerateIngressTemplate(
 		APIVersion: "networking.k8s.io/v1",
 	}
 	ingress.ObjectMeta.Name = route.IngressName
-
-	// if this is a wildcard ingress, handle templating that here
-	if route.Wildcard != nil && *route.Wildcard {
-		truncatedRouteDomain = fmt.Sprintf("wildcard-%s", truncatedRouteDomain)
-		if len(truncatedRouteDomain) >= 53 {
-			subdomain := strings.Split(truncatedRouteDomain, "-")[0]
-			if errs := utilvalidation.IsValidLabelValue(subdomain); errs != nil {
-				subdomain = subdomain[:53]
-			}
-			truncatedRouteDomain = fmt.Sprintf("%s-%s", strings.Split(subdomain, "-")[0], helpers.GetMD5HashWithNewLine(route.Domain)[:5])
-		}
-		// set the domain to include the wildcard prefix
-		if route.WildcardApex != nil && *route.WildcardApex {
-			route.AlternativeNames = append(route.AlternativeNames, route.Domain)
-		}
-		route.Domain = fmt.Sprintf("*.%s", route.Domain)
-	}
-
-	// add the default labels
-	ingress.ObjectMeta.Labels = map[string]string{
-		"lagoon.sh/autogenerated":      "false",
-		"app.kubernetes.io/name":       "custom-ingress",
-		"app.kubernetes.io/instance":   truncatedRouteDomain,
-		"app.kubernetes.io/managed-by": "build-deploy-tool",
-		"lagoon.sh/template":           "custom-ingress-0.1.0",
-		"lagoon.sh/service":            truncatedRouteDomain,
-		"lagoon.sh/service-type":       "custom-ingress",
-		"lagoon.sh/project":            lValues.Project,
-		"lagoon.sh/environment":        lValues.Environment,
-		"lagoon.sh/environmentType":    lValues.EnvironmentType,
-		"lagoon.sh/buildType":          lValues.BuildType,
-	}
-	additionalLabels := map[string]string{}
-
-	// add the default annotations
-	ingress.ObjectMeta.Annotations = map[string]string{
-		"kubernetes.io/tls-acme": strconv.FormatBool(*route.TLSAcme),
-		"fastly.amazee.io/watch": strconv.FormatBool(route.Fastly.Watch),
-		"lagoon.sh/version":      lValues.LagoonVersion,
-	}
+	ingress.ObjectMeta.Labels = labels
+	ingress.ObjectMeta.Annotations = annotations
 	additionalAnnotations := map[string]string{}
 
-	if lValues.EnvironmentType == "production" && !route.Autogenerated {
-		if route.Migrate != nil {
-			additionalLabels["activestandby.lagoon.sh/migrate"] = strconv.FormatBool(*route.Migrate)
-		} else {
-			additionalLabels["activestandby.lagoon.sh/migrate"] = "false"
-		}
-	}
-	if lValues.EnvironmentType == "production" {
-		// monitoring is only available in production environments
-		additionalAnnotations["monitor.stakater.com/enabled"] = "false"
-		primaryIngress, _ := url.Parse(lValues.Route)
-		// check if monitoring enabled, route isn't autogenerated, and the primary ingress from the .lagoon.yml is this processed routedomain
-		// and enable monitoring on the primary ingress only.
-		if lValues.Monitoring.Enabled && !route.Autogenerated && primaryIngress.Host == route.Domain {
-			additionalLabels["lagoon.sh/primaryIngress"] = "true"
-
-			// only add the monitoring annotations if monitoring is enabled
-			additionalAnnotations["monitor.stakater.com/enabled"] = "true"
-			additionalAnnotations["uptimerobot.monitor.stakater.com/alert-contacts"] = "unconfigured"
-			if lValues.Monitoring.AlertContact != "" {
-				additionalAnnotations["uptimerobot.monitor.stakater.com/alert-contacts"] = lValues.Monitoring.AlertContact
-			}
-			if lValues.Monitoring.StatusPageID != "" {
-				additionalAnnotations["uptimerobot.monitor.stakater.com/status-pages"] = lValues.Monitoring.StatusPageID
-			}
-			additionalAnnotations["uptimerobot.monitor.stakater.com/interval"] = "60"
-		}
-		if route.MonitoringPath != "" {
-			additionalAnnotations["monitor.stakater.com/overridePath"] = route.MonitoringPath
-		}
-	}
-	if route.Fastly.ServiceID != "" {
-		additionalAnnotations["fastly.amazee.io/service-id"] = route.Fastly.ServiceID
-	}
-
-	switch lValues.BuildType {
-	case "branch":
-		additionalAnnotations["lagoon.sh/branch"] = lValues.Branch
-	case "pullrequest":
-		additionalAnnotations["lagoon.sh/prNumber"] = lValues.PRNumber
-		additionalAnnotations["lagoon.sh/prHeadBranch"] = lValues.PRHeadBranch
-		additionalAnnotations["lagoon.sh/prBaseBranch"] = lValues.PRBaseBranch
-	}
-
 	switch *route.Insecure {
 	case "Allow":
 		additionalAnnotations["nginx.ingress.kubernetes.io/ssl-redirect"] = "false"
@@ -136,27 +50,15 @@ func GenerateIngressTemplate(
 
 	}
 
-	if lValues.EnvironmentType == "development" || route.Autogenerated {
-		additionalAnnotations["nginx.ingress.kubernetes.io/server-snippet"] = "add_header X-Robots-Tag \"noindex, nofollow\";\n"
-	}
-
-	switch route.Source {
-	case "API", "AUTOGENERATED":
-		additionalLabels["route.lagoon.sh/source"] = strings.ToLower(route.Source)
-	default:
-		additionalLabels["route.lagoon.sh/source"] = "yaml"
+	// redirect routes use the ingress-nginx redirect annotations, the backend service is never reached
+	if route.Redirect != nil {
+		for key, value := range generateRedirectAnnotations(*route.Redirect) {
+			additionalAnnotations[key] = value
+		}
 	}
 
-	// if idling request verification is in the `.lagoon.yml` and true, add the annotation. this supports production and development environment types
-	// in the event that production environments support idling properly that option could be available to then
-	// idle standby environments or production environments generally in opensource lagoon
-	if route.RequestVerification != nil && *route.RequestVerification {
-		// @TODO: this will eventually be changed to a `lagoon.sh` instead of `amazee.io` namespaced annotation in the future once
-		// aergia is fully integrated into the uselagoon namespace
-		additionalAnnotations["idling.amazee.io/disable-request-verification"] = "true"
-	} else {
-		// otherwise force false
-		additionalAnnotations["idling.amazee.io/disable-request-verification"] = "false"
+	if lValues.EnvironmentType == "development" || route.Autogenerated {
+		additionalAnnotations["nginx.ingress.kubernetes.io/server-snippet"] = "add_header X-Robots-Tag \"noindex, nofollow\";\n"
 	}
 
 	// check if a user has defined hsts configuration
@@ -193,10 +95,6 @@ func GenerateIngressTemplate(
 		additionalAnnotations["acme.cert-manager.io/http01-ingress-class"] = route.IngressClass
 	}
 
-	// add any additional labels
-	for key, value := range additionalLabels {
-		ingress.ObjectMeta.Labels[key] = value
-	}
 	// add any additional annotations
 	for key, value := range additionalAnnotations {
 		ingress.ObjectMeta.Annotations[key] = value
@@ -222,38 +120,13 @@ func GenerateIngressTemplate(
 		}
 	}
 
-	// set up the secretname for tls
-	if route.Autogenerated {
-		// autogenerated use the service name
-		ingress.Spec.TLS = []networkv1.IngressTLS{
-			{
-				SecretName: fmt.Sprintf("%s-tls", route.LagoonService),
-			},
-		}
-	} else {
-		// everything else uses the truncated
-		ingress.Spec.TLS = []networkv1.IngressTLS{
-			{
-				// use the truncated route domain here as we add `-tls`
-				// if a domain that is 253 chars long is used this will then exceed
-				// the 253 char limit on kubernetes names
-				SecretName: fmt.Sprintf("%s-tls", truncatedRouteDomain),
-			},
-		}
-	}
-
-	// autogenerated domains that are too long break when creating the acme challenge k8s resource
-	// this injects a shorter domain into the tls spec that is used in the k8s challenge
-	// use the compose service name to check this, as this is how Services are populated from the compose generation
-	for _, service := range lValues.Services {
-		if service.OverrideName == route.LagoonService {
-			if service.ShortAutogeneratedRouteDomain != "" && len(route.Domain) > 63 {
-				ingress.Spec.TLS[0].Hosts = append(ingress.Spec.TLS[0].Hosts, service.ShortAutogeneratedRouteDomain)
-			}
-		}
+	// set up the secretname and hosts for tls
+	ingress.Spec.TLS = []networkv1.IngressTLS{
+		{
+			SecretName: tlsSecretName(route, truncatedRouteDomain),
+			Hosts:      tlsHosts(route, lValues),
+		},
 	}
-	// add the main domain to the tls spec now
-	ingress.Spec.TLS[0].Hosts = append(ingress.Spec.TLS[0].Hosts, route.Domain)
 
 	// default service port is http in all lagoon deployments
 	// this should be the port that usually would be accessible via an ingress if the service would normally
@@ -373,6 +246,30 @@ func GenerateIngressTemplate(
 	return ingress, nil
 }
 
+// generateRedirectAnnotations generates the ingress-nginx annotations for a redirect, permanent redirects use the permanent-redirect annotation
+// and temporary redirects use the temporal-redirect annotation. The path and query are preserved by appending the nginx variables to the target
+func generateRedirectAnnotations(redirect lagoon.RouteRedirect) map[string]string {
+	target := redirect.Target
+	switch {
+	case redirect.PreservePath && redirect.PreserveQuery:
+		target = fmt.Sprintf("%s$request_uri", strings.TrimSuffix(target, "/"))
+	case redirect.PreservePath:
+		target = fmt.Sprintf("%s$uri", strings.TrimSuffix(target, "/"))
+	case redirect.PreserveQuery:
+		target = fmt.Sprintf("%s$is_args$args", target)
+	}
+	annotations := map[string]string{}
+	switch redirect.StatusCode {
+	case http.StatusFound, http.StatusTemporaryRedirect:
+		annotations["nginx.ingress.kubernetes.io/temporal-redirect"] = target
+		annotations["nginx.ingress.kubernetes.io/temporal-redirect-code"] = strconv.Itoa(redirect.StatusCode)
+	default:
+		annotations["nginx.ingress.kubernetes.io/permanent-redirect"] = target
+		annotations["nginx.ingress.kubernetes.io/permanent-redirect-code"] = strconv.Itoa(redirect.StatusCode)
+	}
+	return annotations
+}
+
 func TemplateIngress(ingress *networkv1.Ingress) ([]byte, error) {
 	separator := []byte("---\n")
 	var templateYAML []byte
@@ -384,3 +281,153 @@ func TemplateIngress(ingress *networkv1.Ingress) ([]byte, error) {
 	templateYAML = append(templateYAML, restoreResult[:]...)
 	return templateYAML, nil
 }
+
+// tlsSecretName returns the name of the secret that holds the certificate of a route
+func tlsSecretName(route lagoon.RouteV2, truncatedRouteDomain string) string {
+	if route.Autogenerated {
+		// autogenerated use the service name
+		return fmt.Sprintf("%s-tls", route.LagoonService)
+	}
+	// everything else uses the truncated route domain here as we add `-tls`
+	// if a domain that is 253 chars long is used this will then exceed
+	// the 253 char limit on kubernetes names
+	return fmt.Sprintf("%s-tls", truncatedRouteDomain)
+}
+
+// tlsHosts returns the hosts that the certificate of a route is requested for, alternative names are added by the caller
+func tlsHosts(route lagoon.RouteV2, lValues generator.BuildValues) []string {
+	var hosts []string
+	// autogenerated domains that are too long break when creating the acme challenge k8s resource
+	// this injects a shorter domain into the tls spec that is used in the k8s challenge
+	// use the compose service name to check this, as this is how Services are populated from the compose generation
+	for _, service := range lValues.Services {
+		if service.OverrideName == route.LagoonService {
+			if service.ShortAutogeneratedRouteDomain != "" && len(route.Domain) > 63 {
+				hosts = append(hosts, service.ShortAutogeneratedRouteDomain)
+			}
+		}
+	}
+	// add the main domain to the tls spec now
+	return append(hosts, route.Domain)
+}
+
+// generateRouteMetadata generates the labels and annotations that are common to all the objects a route can be templated as, if the route
+// is a wildcard the domain of the route is changed to the wildcard domain. The truncated route domain is returned for use in names
+func generateRouteMetadata(route *lagoon.RouteV2, lValues generator.BuildValues, template string) (string, map[string]string, map[string]string) {
+	// truncate the route for use in labels and secretname
+	truncatedRouteDomain := route.Domain
+	if len(truncatedRouteDomain) >= 53 {
+		subdomain := strings.Split(truncatedRouteDomain, ".")[0]
+		if errs := utilvalidation.IsValidLabelValue(subdomain); errs != nil {
+			subdomain = subdomain[:53]
+		}
+		truncatedRouteDomain = fmt.Sprintf("%s-%s", strings.Split(subdomain, ".")[0], helpers.GetMD5HashWithNewLine(route.Domain)[:5])
+	}
+
+	// if this is a wildcard ingress, handle templating that here
+	if route.Wildcard != nil && *route.Wildcard {
+		truncatedRouteDomain = fmt.Sprintf("wildcard-%s", truncatedRouteDomain)
+		if len(truncatedRouteDomain) >= 53 {
+			subdomain := strings.Split(truncatedRouteDomain, "-")[0]
+			if errs := utilvalidation.IsValidLabelValue(subdomain); errs != nil {
+				subdomain = subdomain[:53]
+			}
+			truncatedRouteDomain = fmt.Sprintf("%s-%s", strings.Split(subdomain, "-")[0], helpers.GetMD5HashWithNewLine(route.Domain)[:5])
+		}
+		// set the domain to include the wildcard prefix
+		if route.WildcardApex != nil && *route.WildcardApex {
+			route.AlternativeNames = append(route.AlternativeNames, route.Domain)
+		}
+		route.Domain = fmt.Sprintf("*.%s", route.Domain)
+	}
+
+	// add the default labels
+	labels := map[string]string{
+		"lagoon.sh/autogenerated":      "false",
+		"app.kubernetes.io/name":       "custom-ingress",
+		"app.kubernetes.io/instance":   truncatedRouteDomain,
+		"app.kubernetes.io/managed-by": "build-deploy-tool",
+		"lagoon.sh/template":           template,
+		"lagoon.sh/service":            truncatedRouteDomain,
+		"lagoon.sh/service-type":       "custom-ingress",
+		"lagoon.sh/project":            lValues.Project,
+		"lagoon.sh/environment":        lValues.Environment,
+		"lagoon.sh/environmentType":    lValues.EnvironmentType,
+		"lagoon.sh/buildType":          lValues.BuildType,
+	}
+	if route.Redirect != nil {
+		labels["app.kubernetes.io/name"] = "redirect-ingress"
+		labels["lagoon.sh/service-type"] = "redirect-ingress"
+	}
+
+	// add the default annotations
+	annotations := map[string]string{
+		"kubernetes.io/tls-acme": strconv.FormatBool(*route.TLSAcme),
+		"fastly.amazee.io/watch": strconv.FormatBool(route.Fastly.Watch),
+		"lagoon.sh/version":      lValues.LagoonVersion,
+	}
+
+	if lValues.EnvironmentType == "production" && !route.Autogenerated {
+		if route.Migrate != nil {
+			labels["activestandby.lagoon.sh/migrate"] = strconv.FormatBool(*route.Migrate)
+		} else {
+			labels["activestandby.lagoon.sh/migrate"] = "false"
+		}
+	}
+	if lValues.EnvironmentType == "production" {
+		// monitoring is only available in production environments
+		annotations["monitor.stakater.com/enabled"] = "false"
+		primaryIngress, _ := url.Parse(lValues.Route)
+		// check if monitoring enabled, route isn't autogenerated, and the primary ingress from the .lagoon.yml is this processed routedomain
+		// and enable monitoring on the primary ingress only.
+		if lValues.Monitoring.Enabled && !route.Autogenerated && primaryIngress.Host == route.Domain {
+			labels["lagoon.sh/primaryIngress"] = "true"
+
+			// only add the monitoring annotations if monitoring is enabled
+			annotations["monitor.stakater.com/enabled"] = "true"
+			annotations["uptimerobot.monitor.stakater.com/alert-contacts"] = "unconfigured"
+			if lValues.Monitoring.AlertContact != "" {
+				annotations["uptimerobot.monitor.stakater.com/alert-contacts"] = lValues.Monitoring.AlertContact
+			}
+			if lValues.Monitoring.StatusPageID != "" {
+				annotations["uptimerobot.monitor.stakater.com/status-pages"] = lValues.Monitoring.StatusPageID
+			}
+			annotations["uptimerobot.monitor.stakater.com/interval"] = "60"
+		}
+		if route.MonitoringPath != "" {
+			annotations["monitor.stakater.com/overridePath"] = route.MonitoringPath
+		}
+	}
+	if route.Fastly.ServiceID != "" {
+		annotations["fastly.amazee.io/service-id"] = route.Fastly.ServiceID
+	}
+
+	switch lValues.BuildType {
+	case "branch":
+		annotations["lagoon.sh/branch"] = lValues.Branch
+	case "pullrequest":
+		annotations["lagoon.sh/prNumber"] = lValues.PRNumber
+		annotations["lagoon.sh/prHeadBranch"] = lValues.PRHeadBranch
+		annotations["lagoon.sh/prBaseBranch"] = lValues.PRBaseBranch
+	}
+
+	switch route.Source {
+	case "API", "AUTOGENERATED":
+		labels["route.lagoon.sh/source"] = strings.ToLower(route.Source)
+	default:
+		labels["route.lagoon.sh/source"] = "yaml"
+	}
+
+	// if idling request verification is in the `.lagoon.yml` and true, add the annotation. this supports production and development environment types
+	// in the event that production environments support idling properly that option could be available to then
+	// idle standby environments or production environments generally in opensource lagoon
+	if route.RequestVerification != nil && *route.RequestVerification {
+		// @TODO: this will eventually be changed to a `lagoon.sh` instead of `amazee.io` namespaced annotation in the future once
+		// aergia is fully integrated into the uselagoon namespace
+		annotations["idling.amazee.io/disable-request-verification"] = "true"
+	} else {
+		// otherwise force false
+		annotations["idling.amazee.io/disable-request-verification"] = "false"
+	}
+	return truncatedRouteDomain, labels, annotations
+}
@@ -764,6 +764,85 @@ func TestGenerateIngressTemplate(t *testing.T) {
 			},
 			want: "test-resources/ingress/result-wildcard-ingress3.yaml",
 		},
+		{
+			name: "redirect ingress",
+			args: args{
+				route: lagoon.RouteV2{
+					Domain:        "old.example.com",
+					LagoonService: "nginx",
+					Insecure:      helpers.StrPtr("Allow"),
+					TLSAcme:       helpers.BoolPtr(true),
+					Annotations:   map[string]string{},
+					Labels:        map[string]string{},
+					IngressClass:  "nginx",
+					IngressName:   "old.example.com",
+					Redirect: &lagoon.RouteRedirect{
+						Target:        "https://www.example.com/",
+						StatusCode:    301,
+						PreservePath:  true,
+						PreserveQuery: true,
+					},
+				},
+				values: generator.BuildValues{
+					Project:         "example-project",
+					Environment:     "main",
+					EnvironmentType: "production",
+					Namespace:       "example-project-main",
+					BuildType:       "branch",
+					LagoonVersion:   "v2.x.x",
+					Kubernetes:      "lagoon.local",
+					Branch:          "main",
+					Services: []generator.ServiceValues{
+						{
+							Name:         "nginx",
+							OverrideName: "nginx",
+							Type:         "nginx-php",
+						},
+					},
+					Route: "https://www.example.com/",
+				},
+			},
+			want: "test-resources/ingress/result-redirect-ingress1.yaml",
+		},
+		{
+			name: "temporary redirect ingress without the path",
+			args: args{
+				route: lagoon.RouteV2{
+					Domain:        "promo.example.com",
+					LagoonService: "nginx",
+					Insecure:      helpers.StrPtr("Allow"),
+					TLSAcme:       helpers.BoolPtr(true),
+					Annotations:   map[string]string{},
+					Labels:        map[string]string{},
+					IngressClass:  "nginx",
+					IngressName:   "promo.example.com",
+					Redirect: &lagoon.RouteRedirect{
+						Target:        "https://www.example.com/promotions",
+						StatusCode:    307,
+						PreservePath:  false,
+						PreserveQuery: true,
+					},
+				},
+				values: generator.BuildValues{
+					Project:         "example-project",
+					Environment:     "main",
+					EnvironmentType: "development",
+					Namespace:       "example-project-main",
+					BuildType:       "branch",
+					LagoonVersion:   "v2.x.x",
+					Kubernetes:      "lagoon.local",
+					Branch:          "main",
+					Services: []generator.ServiceValues{
+						{
+							Name:         "nginx",
+							OverrideName: "nginx",
+							Type:         "nginx-php",
+						},
+					},
+				},
+			},
+			want: "test-resources/ingress/result-redirect-ingress2.yaml",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -10,6 +10,8 @@ import (
 	generator "github.com/uselagoon/build-deploy-tool/internal/generator"
 	"github.com/uselagoon/build-deploy-tool/internal/helpers"
 	"github.com/uselagoon/build-deploy-tool/internal/lagoon"
+	"github.com/uselagoon/build-deploy-tool/internal/registryauth"
+	"github.com/uselagoon/build-deploy-tool/internal/secretref"
 	"github.com/uselagoon/machinery/utils/namespace"
 )
 
@@ -218,6 +220,18 @@ func SetupEnvironment(genInput generator.GeneratorInput, templatePath string, t
 		RetryWaitMin: time.Duration(10) * time.Millisecond,
 		RetryWaitMax: time.Duration(50) * time.Millisecond,
 	})
+	// add registryauth overrides for tests
+	genInput.RegistryAuthClient = registryauth.NewClient(registryauth.Client{
+		RetryMax:     5,
+		RetryWaitMin: time.Duration(10) * time.Millisecond,
+		RetryWaitMax: time.Duration(50) * time.Millisecond,
+	})
+	// add secretref overrides for tests
+	genInput.SecretRefClient = secretref.NewClient(secretref.Client{
+		RetryMax:     5,
+		RetryWaitMin: time.Duration(10) * time.Millisecond,
+		RetryWaitMax: time.Duration(50) * time.Millisecond,
+	})
 
 	genInput.Namespace = namespace.GenerateNamespaceName("", t.EnvironmentName, t.ProjectName, "", "lagoon", false)
 
@@ -206,26 +206,6 @@ function finalizeBuildStep() {
   echo -e "##############################################\nSTEP ${6}: Completed at ${3} (${timeZone}) Duration ${diffTime} Elapsed ${diffTotalTime}${hasWarnings}\n##############################################"
 }
 
-function cleanupCertificates() {
-  [ "$1" ] || return #ingress
-  TLS_SECRETS=$(kubectl -n ${NAMESPACE} get ingress ${1} -o json | jq -r '.spec.tls[]?.secretName')
-  for TLS_SECRET in $TLS_SECRETS; do
-    echo ">> Cleaning up certificate for ${TLS_SECRET}"
-    # if cleanup secret certs is true, then clean up the secrets
-    if [ "$2" == "true" ]; then
-      if kubectl -n ${NAMESPACE} get secret ${TLS_SECRET} &> /dev/null; then
-        # check if it is a lets encrypt certificate
-        if openssl x509 -in <(kubectl -n ${NAMESPACE} get secret ${TLS_SECRET} -o json | jq -r '.data."tls.crt" | @base64d') -text -noout | grep -o -q "Let's Encrypt" &> /dev/null; then
-          # don't block execution
-          kubectl -n ${NAMESPACE} delete secret ${TLS_SECRET} &> /dev/null || true
-        fi
-      fi
-    fi
-    # delete the certmanager certificate to prevent renewals
-    kubectl -n ${NAMESPACE} delete certificates.cert-manager.io ${TLS_SECRET} &> /dev/null || true
-  done
-}
-
 touch /tmp/warnings
 
 ##############################################
@@ -263,6 +243,12 @@ fi
 currentStepEnd="$(date +"%Y-%m-%d %H:%M:%S")"
 finalizeBuildStep "${buildStartTime}" "${buildStartTime}" "${currentStepEnd}" "${NAMESPACE}" "collectEnvironment" "Initial Environment Collection" "false"
 
+# when the gateway api is enabled routes are created as httproutes instead of ingress
+ROUTE_RESOURCE=ingress
+if [ "$(featureFlag GATEWAY_API | tr '[:upper:]' '[:lower:]')" = enabled ]; then
+  ROUTE_RESOURCE=httproute
+fi
+
 if [ "${LAGOON_VARIABLES_ONLY}" != "true" ]; then
   # standard deployment
   previousStepEnd=${currentStepEnd}
@@ -1068,31 +1054,6 @@ if [ "${LAGOON_VARIABLES_ONLY}" != "true" ]; then
   # end custom route
   fi
 
-  # identify any autognerated resources based on their resource name
-  AUTOGEN_INGRESS=$(build-deploy-tool identify created-ingress | jq -r '.autogenerated[]')
-  AUTOGEN_ROUTES=$(kubectl -n ${NAMESPACE} get ingress --no-headers -l "lagoon.sh/autogenerated=true" | cut -d " " -f 1 | xargs)
-  MATCHED_AUTOGEN=false
-  DELETE_AUTOGEN=()
-  for AR in $AUTOGEN_ROUTES; do
-    for AI in $AUTOGEN_INGRESS; do
-      if [ "${AR}" == "${AI}" ]; then
-        MATCHED_AUTOGEN=true
-        continue
-      fi
-    done
-    if [ "${MATCHED_AUTOGEN}" != "true" ]; then
-      DELETE_AUTOGEN+=($AR)
-    fi
-    MATCHED_AUTOGEN=false
-  done
-  for DA in ${!DELETE_AUTOGEN[@]}; do
-    # delete any autogenerated ingress in the namespace as they are disabled
-    if kubectl -n ${NAMESPACE} get ingress ${DELETE_AUTOGEN[$DA]} &> /dev/null; then
-      echo ">> Removing autogenerated ingress for ${DELETE_AUTOGEN[$DA]} because it was disabled"
-      kubectl -n ${NAMESPACE} delete ingress ${DELETE_AUTOGEN[$DA]}
-    fi
-  done
-
   for SERVICE_TYPES_ENTRY in "${SERVICE_TYPES[@]}"
   do
     echo "=== BEGIN route processing for service ${SERVICE_TYPES_ENTRY} ==="
@@ -1156,92 +1117,15 @@ if [ "${LAGOON_VARIABLES_ONLY}" != "true" ]; then
   ### CLEANUP Ingress/routes which have been removed from .lagoon.yml
   ##############################################s
 
-  # collect the current routes excluding any certmanager requests.
-  # its also possible to exclude ingress by adding a label 'lagoon.sh/remove=false', this is then used to skip this from the removal checks
-  CURRENT_ROUTES=$(kubectl -n ${NAMESPACE} get ingress  -l "lagoon.sh/autogenerated!=true"  --no-headers  2> /dev/null | cut -d " " -f 1 | xargs)
-  # since label selectors can't be combined properly, this is done so that the build can get all the routes
-  # and then remove any that match our conditions to be ignored by the removal checker
-  IGNORE_ROUTES=$(kubectl -n ${NAMESPACE} get ingress --no-headers -l "acme.cert-manager.io/http01-solver=true"  2> /dev/null | cut -d " " -f 1 | xargs)
-  for SINGLE_ROUTE in ${IGNORE_ROUTES}; do
-    # remove ignored routes from the current routes
-    CURRENT_ROUTES=( "${CURRENT_ROUTES[@]/$SINGLE_ROUTE}" )
-  done
-  IGNORE_ROUTES=$(kubectl -n ${NAMESPACE} get ingress --no-headers -l "lagoon.sh/remove=false"  2> /dev/null | cut -d " " -f 1 | xargs)
-  for SINGLE_ROUTE in ${IGNORE_ROUTES}; do
-    # remove ignored routes from the current routes
-    CURRENT_ROUTES=( "${CURRENT_ROUTES[@]/$SINGLE_ROUTE}" )
-  done
-
-  # collect the routes that Lagoon thinks it should have based on the .lagoon.yml and any routes that have come from the api
-  # using the build-deploy-tool generator
-  YAML_ROUTES_TO_JSON=$(build-deploy-tool identify created-ingress | jq -r '.secondary[]')
-
-  MATCHED_INGRESS=false
-  DELETE_INGRESS=()
-  # loop over the routes from kubernetes
-  for SINGLE_ROUTE in ${CURRENT_ROUTES}; do
-    # loop over the routes that Lagoon thinks it should have
-    for YAML_ROUTE in ${YAML_ROUTES_TO_JSON}; do
-      if [ "${SINGLE_ROUTE}" == "${YAML_ROUTE}" ]; then
-        MATCHED_INGRESS=true
-        continue
-      fi
-    done
-    if [ "${MATCHED_INGRESS}" != "true" ]; then
-      DELETE_INGRESS+=($SINGLE_ROUTE)
-    fi
-    MATCHED_INGRESS=false
-  done
-
+  # remove any autogenerated routes that are no longer generated, and any routes that have been removed from the .lagoon.yml
+  # or the api if the project is configured to allow it. the certificates of removed routes, and of any ingress
+  # that no longer use tls-acme, are also cleaned up here to prevent any renewal or reissuing attempts
   CLEANUP_WARNINGS="false"
-  if [ ${#DELETE_INGRESS[@]} -ne 0 ]; then
+  ROUTE_CLEANUP_OUTPUT=$(build-deploy-tool run route-cleanup --delete)
+  echo "${ROUTE_CLEANUP_OUTPUT}"
+  if echo "${ROUTE_CLEANUP_OUTPUT}" | grep -q "Lagoon detected routes that have been removed"; then
     CLEANUP_WARNINGS="true"
     ((++BUILD_WARNING_COUNT))
-    API_ROUTES_CLEANUP=$(internalSystemEnvVarCheck LAGOON_API_ROUTES_CLEANUP false)
-    if [ "$API_ROUTES_CLEANUP" == true ]; then
-      # to ensure consistency with the api, enforce route cleanup
-      # if it isn't in the api, and isn't in the .lagoon.yml file, then it shouldn't exist.
-      echo ">> Lagoon detected routes that have been removed from the .lagoon.yml or Lagoon API"
-      echo "> As this project has routes managed in the API, these routes have been cleaned up."
-      echo "> If you need these routes, you should add them to the API."
-      for DI in ${DELETE_INGRESS[@]}
-      do
-        if kubectl -n ${NAMESPACE} get ingress ${DI} &> /dev/null; then
-          echo ">> Removing ingress ${DI}"
-          cleanupCertificates "${DI}" "false"
-          kubectl -n ${NAMESPACE} delete ingress ${DI}
-        fi
-      done
-    else
-      # no routes in the api we will just continue to warn
-      # since users may not have realised they removed a route from the .lagoon.yml
-      # usually this is because of a bad merge or something, and people generally aren't reading warnings anyway
-      echo ">> Lagoon detected routes that have been removed from the .lagoon.yml or Lagoon API"
-      echo "> If you need these routes, you should update your .lagoon.yml file and make sure the routes exist."
-      if [ "$(featureFlag CLEANUP_REMOVED_LAGOON_ROUTES | tr '[:upper:]' '[:lower:]')" != enabled ]; then
-        echo "> If you no longer need these routes, you can instruct Lagoon to remove it from the environment by setting the following variable"
-        echo "> 'LAGOON_FEATURE_FLAG_CLEANUP_REMOVED_LAGOON_ROUTES=enabled' as a BUILD scoped variable to this environment or project"
-        echo "> You should remove this variable after the deployment has been completed, otherwise future route removals will happen automatically"
-      else
-        echo "> 'LAGOON_FEATURE_FLAG_CLEANUP_REMOVED_LAGOON_ROUTES=enabled' is configured and the following routes will be removed."
-        echo "> You should remove this variable if you don't want routes to be removed automatically"
-      fi
-      echo "> Future releases of Lagoon may remove routes automatically, you should ensure that your routes are up always up to date if you see this warning"
-      for DI in ${DELETE_INGRESS[@]}
-      do
-        if [ "$(featureFlag CLEANUP_REMOVED_LAGOON_ROUTES | tr '[:upper:]' '[:lower:]')" = enabled ]; then
-          if kubectl -n ${NAMESPACE} get ingress ${DI} &> /dev/null; then
-            echo ">> Removing ingress ${DI}"
-            cleanupCertificates "${DI}" "false"
-            kubectl -n ${NAMESPACE} delete ingress ${DI}
-          fi
-        else
-          echo "> The route '${DI}' would be removed"
-        fi
-      done
-    fi
-  else
-    echo "No route cleanup required"
   fi
 
   currentStepEnd="$(date +"%Y-%m-%d %H:%M:%S")"
@@ -1301,7 +1185,12 @@ if [[ "$CUSTOM_ROUTES_DISABLED" == true ]] && [[ "$AUTOGEN_ROUTES_DISABLED" == t
 fi
 
 # Load all routes with correct schema and comma separated
-ROUTES=$(kubectl -n ${NAMESPACE} get ingress --sort-by='{.metadata.name}' -l "acme.cert-manager.io/http01-solver!=true" -o=go-template --template='{{range $indexItems, $ingress := .items}}{{if $indexItems}},{{end}}{{$tls := .spec.tls}}{{range $indexRule, $rule := .spec.rules}}{{if $indexRule}},{{end}}{{if $tls}}https://{{else}}http://{{end}}{{.host}}{{end}}{{end}}')
+ROUTE_URLS_TEMPLATE='{{range $indexItems, $ingress := .items}}{{if $indexItems}},{{end}}{{$tls := .spec.tls}}{{range $indexRule, $rule := .spec.rules}}{{if $indexRule}},{{end}}{{if $tls}}https://{{else}}http://{{end}}{{.host}}{{end}}{{end}}'
+if [ "${ROUTE_RESOURCE}" == "httproute" ]; then
+  # httproutes don't define tls, it is terminated by the parent gateway with the certificate that is only requested for tls-acme routes
+  ROUTE_URLS_TEMPLATE='{{range $indexItems, $route := .items}}{{if $indexItems}},{{end}}{{$tls := eq (index .metadata.annotations "kubernetes.io/tls-acme") "true"}}{{range $indexHost, $host := .spec.hostnames}}{{if $indexHost}},{{end}}{{if $tls}}https://{{else}}http://{{end}}{{$host}}{{end}}{{end}}'
+fi
+ROUTES=$(kubectl -n ${NAMESPACE} get ${ROUTE_RESOURCE} --sort-by='{.metadata.name}' -l "acme.cert-manager.io/http01-solver!=true,route.lagoon.sh/redirect!=true" -o=go-template --template="${ROUTE_URLS_TEMPLATE}")
 
 # swap dioscuri for activestanby label
 for ingress in $(kubectl  -n ${NAMESPACE} get ingress -l "dioscuri.amazee.io/migrate" -o json | jq -r '.items[] | @base64'); do
@@ -1323,12 +1212,12 @@ done
 ACTIVE_ROUTES=""
 STANDBY_ROUTES=""
 if [ ! -z "${STANDBY_ENVIRONMENT}" ]; then
-ACTIVE_ROUTES=$(kubectl -n ${NAMESPACE} get ingress --sort-by='{.metadata.name}' -l "activestandby.lagoon.sh/migrate=true" -o=go-template --template='{{range $indexItems, $ingress := .items}}{{if $indexItems}},{{end}}{{$tls := .spec.tls}}{{range $indexRule, $rule := .spec.rules}}{{if $indexRule}},{{end}}{{if $tls}}https://{{else}}http://{{end}}{{.host}}{{end}}{{end}}')
-STANDBY_ROUTES=$(kubectl -n ${NAMESPACE} get ingress --sort-by='{.metadata.name}' -l "activestandby.lagoon.sh/migrate=true" -o=go-template --template='{{range $indexItems, $ingress := .items}}{{if $indexItems}},{{end}}{{$tls := .spec.tls}}{{range $indexRule, $rule := .spec.rules}}{{if $indexRule}},{{end}}{{if $tls}}https://{{else}}http://{{end}}{{.host}}{{end}}{{end}}')
+ACTIVE_ROUTES=$(kubectl -n ${NAMESPACE} get ${ROUTE_RESOURCE} --sort-by='{.metadata.name}' -l "activestandby.lagoon.sh/migrate=true,route.lagoon.sh/redirect!=true" -o=go-template --template="${ROUTE_URLS_TEMPLATE}")
+STANDBY_ROUTES=$(kubectl -n ${NAMESPACE} get ${ROUTE_RESOURCE} --sort-by='{.metadata.name}' -l "activestandby.lagoon.sh/migrate=true,route.lagoon.sh/redirect!=true" -o=go-template --template="${ROUTE_URLS_TEMPLATE}")
 fi
 
 # Get list of autogenerated routes
-AUTOGENERATED_ROUTES=$(kubectl -n ${NAMESPACE} get ingress --sort-by='{.metadata.name}' -l "lagoon.sh/autogenerated=true" -o=go-template --template='{{range $indexItems, $ingress := .items}}{{if $indexItems}},{{end}}{{$tls := .spec.tls}}{{range $indexRule, $rule := .spec.rules}}{{if $indexRule}},{{end}}{{if $tls}}https://{{else}}http://{{end}}{{.host}}{{end}}{{end}}')
+AUTOGENERATED_ROUTES=$(kubectl -n ${NAMESPACE} get ${ROUTE_RESOURCE} --sort-by='{.metadata.name}' -l "lagoon.sh/autogenerated=true,route.lagoon.sh/redirect!=true" -o=go-template --template="${ROUTE_URLS_TEMPLATE}")
 
 if [ "${LAGOON_VARIABLES_ONLY}" != "true" ]; then
   # standard deployment
@@ -1471,6 +1360,10 @@ build-deploy-tool template lagoon-env \
   --dbaas-creds /kubectl-build-deploy/dbaas-creds.json \
   --routes "${ROUTES}"
 kubectl apply -n ${NAMESPACE} -f ${LAGOON_ENV_YAML_FOLDER}/lagoon-env-secret.yaml
+# any secret references that are synced from a secret store are merged into the lagoon-env secret by an ExternalSecret
+if [ -f ${LAGOON_ENV_YAML_FOLDER}/lagoon-env-externalsecret.yaml ]; then
+  kubectl apply -n ${NAMESPACE} -f ${LAGOON_ENV_YAML_FOLDER}/lagoon-env-externalsecret.yaml
+fi
 
 if kubectl -n ${NAMESPACE} get configmap lagoon-env &> /dev/null; then
   # this section will only run once on the initial change from configmap to secret
@@ -1514,6 +1407,10 @@ if [ ! -z "$EXISTING_LAGOONENV_VARS" ]; then
   # and provided by the lagoon-api, we can use it to work out what to remove from the existing secret
   # since the existing secret could contain variables that aren't in the api, we compare these 2 things to see what needs to be removed from the secret
   CREATED_LAGOONENV_VARS=$(cat ${LAGOON_ENV_YAML_FOLDER}/lagoon-env-secret.yaml | yq -o json | jq -r '.stringData | keys[]')
+  # the secret references are not in the generated secret, the ExternalSecret merges them in so they need to be kept
+  if [ -f ${LAGOON_ENV_YAML_FOLDER}/lagoon-env-externalsecret.yaml ]; then
+    CREATED_LAGOONENV_VARS="${CREATED_LAGOONENV_VARS} $(cat ${LAGOON_ENV_YAML_FOLDER}/lagoon-env-externalsecret.yaml | yq -o json | jq -r '.spec.data[].secretKey')"
+  fi
   VARS_TO_REMOVE=$(comm -23 <(echo $CURRENT_LAGOONENV_VARS | tr ' ' '\n' | sort) <(echo $CREATED_LAGOONENV_VARS | tr ' ' '\n' | sort))
 
   # now work out the patch operations to remove the unneeded keys from the secret
@@ -1887,12 +1784,6 @@ if [ "${LAGOON_VARIABLES_ONLY}" != "true" ]; then
     kubectl -n ${NAMESPACE} create configmap docker-compose-yaml --from-file=post-deploy="${DOCKER_COMPOSE_YAML}"
   fi
 
-  # remove any certificates for tls-acme false ingress to prevent reissuing attempts
-  TLS_FALSE_INGRESSES=$(kubectl -n ${NAMESPACE} get ingress -o json | jq -r '.items[] | select(.metadata.annotations["kubernetes.io/tls-acme"] == "false") | .metadata.name')
-  for TLS_FALSE_INGRESS in $TLS_FALSE_INGRESSES; do
-    cleanupCertificates "${TLS_FALSE_INGRESS}" "true"
-  done
-
   currentStepEnd="$(date +"%Y-%m-%d %H:%M:%S")"
   finalizeBuildStep "${buildStartTime}" "${previousStepEnd}" "${currentStepEnd}" "${NAMESPACE}" "deployCompleted" "Build and Deploy" "false"
   previousStepEnd=${currentStepEnd}
@@ -1904,6 +1795,9 @@ if [ "${LAGOON_VARIABLES_ONLY}" != "true" ]; then
     ##############################################
     set +e # Ensure failures in exec-generate-insights-configmap.sh don't halt the entire build
     INSIGHTS_WARNING_COUNT=0
+    # the insights data of each image is gathered into a copy of the images file
+    INSIGHTS_IMAGES_FILE="/kubectl-build-deploy/images-insights.yaml"
+    cp /kubectl-build-deploy/images.yaml ${INSIGHTS_IMAGES_FILE}
     for IMAGE_NAME in "${!IMAGES_BUILD[@]}"
     do
       IMAGE_TAG="${IMAGE_TAG:-latest}"
@@ -1918,6 +1812,24 @@ if [ "${LAGOON_VARIABLES_ONLY}" != "true" ]; then
       fi
       echo ""
     done
+    # any configmaps that are too large, or problems with a custom dependency track integration, are only warnings
+    LAGOON_INSIGHTS_YAML_FOLDER="/kubectl-build-deploy/lagoon/insights"
+    mkdir -p $LAGOON_INSIGHTS_YAML_FOLDER
+    if ! insightsOutput=$(build-deploy-tool template insights --saved-templates-path ${LAGOON_INSIGHTS_YAML_FOLDER} --images ${INSIGHTS_IMAGES_FILE} 2>&1); then
+      ((++INSIGHTS_WARNING_COUNT))
+      echo "> Templating the insights configmaps failed, this warning is for information only."
+    fi
+    echo "${insightsOutput}"
+    # the configmaps are replaced rather than applied, this removes the lagoon.sh/insightsProcessed label the insights handler
+    # adds once it has processed the data, and any dependency track annotation that is no longer required
+    for INSIGHTS_TEMPLATE in $(find $LAGOON_INSIGHTS_YAML_FOLDER -type f -name "*.yaml" | sort)
+    do
+      if kubectl -n ${NAMESPACE} get -f ${INSIGHTS_TEMPLATE} &> /dev/null; then
+        kubectl -n ${NAMESPACE} replace -f ${INSIGHTS_TEMPLATE}
+      else
+        kubectl -n ${NAMESPACE} create -f ${INSIGHTS_TEMPLATE}
+      fi
+    done
     set -e
     if [[ "$INSIGHTS_WARNING_COUNT" -gt 0 ]]; then
       ((++BUILD_WARNING_COUNT))
@@ -17,11 +17,7 @@ echo -e "##############################################\nBEGIN Checkout Reposito
 if [ "$(build-deploy-tool template git-credential --credential-file /home/.git-credentials)" == "store" ]; then
   git config --global credential.helper 'store --file /home/.git-credentials'
 fi
-if [ "$BUILD_TYPE" == "pullrequest" ]; then
-  /kubectl-build-deploy/scripts/git-checkout-pull-merge.sh "$SOURCE_REPOSITORY" "$PR_HEAD_SHA" "$PR_BASE_SHA"
-else
-  /kubectl-build-deploy/scripts/git-checkout-pull.sh "$SOURCE_REPOSITORY" "$GIT_REF"
-fi
+build-deploy-tool run git-checkout
 
 if [[ -n "$SUBFOLDER" ]]; then
   cd $SUBFOLDER